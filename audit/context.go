@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-phorce/dolly/xlog"
+)
+
+// ContextMessage appends the key-value pairs from the context,
+// such as the trace and span IDs, to the message of the audit event,
+// see xlog.RegisterContextValuesProvider
+func ContextMessage(ctx context.Context, message string) string {
+	values := xlog.ContextValues(ctx)
+	if len(values) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	b.WriteString(message)
+	for i := 0; i+1 < len(values); i += 2 {
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%v=%v", values[i], values[i+1])
+	}
+	return b.String()
+}
+
+// WithContext returns an Auditor that appends the key-value pairs from the context
+// to the message of the audit events, see ContextMessage.
+// The Auditor interface has no context, the request events must be audited
+// with the returned Auditor, or with the message returned by ContextMessage.
+func WithContext(ctx context.Context, auditor Auditor) Auditor {
+	return &contextAuditor{
+		Auditor: auditor,
+		ctx:     ctx,
+	}
+}
+
+type contextAuditor struct {
+	Auditor
+	ctx context.Context
+}
+
+// Audit records the event with the context values
+func (a *contextAuditor) Audit(
+	source string,
+	eventType string,
+	identity string,
+	contextID string,
+	raftIndex uint64,
+	message string) {
+	a.Auditor.Audit(source, eventType, identity, contextID, raftIndex, ContextMessage(a.ctx, message))
+}
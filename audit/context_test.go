@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ContextMessage(t *testing.T) {
+	assert.Equal(t, "message1", ContextMessage(context.Background(), "message1"))
+
+	ctx, span := tracing.StartSpan(context.Background(), "test", tracing.SpanKindInternal)
+	defer span.End()
+	sc := span.SpanContext()
+
+	exp := "trace_id=" + sc.TraceID.String() + ", span_id=" + sc.SpanID.String()
+	assert.Equal(t, "message1, "+exp, ContextMessage(ctx, "message1"))
+	assert.Equal(t, exp, ContextMessage(ctx, ""))
+
+	dest := auditor{}
+	a := WithContext(ctx, &dest)
+	a.Audit(srcBar.String(), evtFoo.String(), "alice/alice1-1", "Context-1", 1, "message1")
+	assert.Equal(t, "alice/alice1-1", dest.identity)
+	assert.Equal(t, "Context-1", dest.contextID)
+	assert.EqualValues(t, 1, dest.raftIndex)
+	assert.Equal(t, "message1, "+exp, dest.message)
+	assert.NoError(t, a.Close())
+}
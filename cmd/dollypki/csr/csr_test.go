@@ -197,7 +197,7 @@ func (s *testSuite) Test_GenCert() {
 		CAKey:      &caKeyFile,
 	})
 	s.Require().Error(err)
-	s.Equal(`ca-config: {"code":5200,"message":"invalid path"}`, err.Error())
+	s.Equal(`ca-config: invalid path`, err.Error())
 
 	cacfg := "testdata/ca-config.dev.json"
 	err = s.run(csr.GenCert, &csr.GenCertFlags{
@@ -290,7 +290,7 @@ func (s *testSuite) Test_SignCert() {
 		CAKey: &caKeyFile,
 	})
 	s.Require().Error(err)
-	s.Equal(`ca-config: {"code":5200,"message":"invalid path"}`, err.Error())
+	s.Equal(`ca-config: invalid path`, err.Error())
 
 	cacfg := "testdata/ca-config.dev.json"
 	err = s.run(csr.SignCert, &csr.SignCertFlags{
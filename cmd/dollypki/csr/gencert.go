@@ -6,12 +6,11 @@ import (
 	"strings"
 	"time"
 
-	cfsslconfig "github.com/cloudflare/cfssl/config"
-	"github.com/cloudflare/cfssl/signer"
 	"github.com/go-phorce/dolly/algorithms/guid"
 	"github.com/go-phorce/dolly/cmd/dollypki/cli"
 	"github.com/go-phorce/dolly/ctl"
-	"github.com/go-phorce/dolly/xpki/csrprov"
+	"github.com/go-phorce/dolly/xpki/authority"
+	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/pkg/errors"
 )
 
@@ -76,7 +75,7 @@ func GenCert(c ctl.Control, p interface{}) error {
 		return errors.Errorf("unsupported command for this crypto provider")
 	}
 
-	prov := csrprov.New(cryptoprov.Default())
+	prov := csr.NewProvider(cryptoprov.Default())
 
 	if *flags.CA == "" || *flags.CAKey == "" {
 		return errors.Errorf("CA certificate and key are required")
@@ -88,9 +87,9 @@ func GenCert(c ctl.Control, p interface{}) error {
 		return errors.WithMessage(err, "read CSR profile")
 	}
 
-	req := csrprov.CertificateRequest{
+	req := csr.CertificateRequest{
 		// TODO: alg and size from params
-		KeyRequest: prov.NewKeyRequest(prefixKeyLabel(*flags.Label), "ECDSA", 256, csrprov.Signing),
+		KeyRequest: prov.NewKeyRequest(prefixKeyLabel(*flags.Label), "ECDSA", 256, csr.SigningKey),
 	}
 
 	err = json.Unmarshal(csrf, &req)
@@ -103,35 +102,36 @@ func GenCert(c ctl.Control, p interface{}) error {
 	}
 
 	// Load ca-config
-	cacfg, err := cfsslconfig.LoadFile(*flags.CAConfig)
+	cacfg, err := authority.LoadConfig(*flags.CAConfig)
 	if err != nil {
 		return errors.WithMessage(err, "ca-config")
 	}
-	if cacfg.Signing == nil {
-		return errors.New("missing signing policy in ca-config")
-	}
-	if !cacfg.Signing.Valid() {
-		return errors.New("invalid signing policy in ca-config")
-	}
 
 	// ensure that signer can be created before the key is generated
-	s, _, err := csrprov.NewLocalCASignerFromFile(cryptoprov, *flags.CA, *flags.CAKey, cacfg.Signing)
+	issuer, err := authority.NewIssuer(&authority.IssuerConfig{
+		CertFile: *flags.CA,
+		KeyFile:  *flags.CAKey,
+		Profiles: cacfg.Profiles,
+	}, cryptoprov)
 	if err != nil {
-		return errors.WithMessage(err, "create signer")
+		return errors.WithMessage(err, "create issuer")
 	}
 
 	var key, csrPEM []byte
-	csrPEM, key, _, _, err = prov.ProcessCsrRequest(&req)
+	csrPEM, key, _, _, err = prov.CreateRequestAndExportKey(&req)
 	if err != nil {
-		return errors.WithMessage(err, "ProcessRequest")
+		return errors.WithMessage(err, "CreateRequestAndExportKey")
 	}
 
-	signReq := signer.SignRequest{
-		Hosts:   signer.SplitHosts(*flags.Hostname),
+	signReq := csr.SignRequest{
+		SAN:     splitHosts(*flags.Hostname),
 		Request: string(csrPEM),
 		Profile: *flags.Profile,
 	}
-	cert, err := s.Sign(signReq)
+	_, cert, err := issuer.Sign(signReq)
+	if err != nil {
+		return errors.WithMessage(err, "sign request")
+	}
 
 	if *flags.Output == "" {
 		c.(*cli.Cli).PrintCert(key, csrPEM, cert)
@@ -166,3 +166,13 @@ func prefixKeyLabel(label string) string {
 
 	return label
 }
+
+// splitHosts takes a comma-separated list of hosts and returns a slice
+// with the hosts split
+func splitHosts(hostList string) []string {
+	if hostList == "" {
+		return nil
+	}
+
+	return strings.Split(hostList, ",")
+}
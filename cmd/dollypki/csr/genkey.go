@@ -3,10 +3,10 @@ package csr
 import (
 	"encoding/json"
 
-	cfsslcli "github.com/cloudflare/cfssl/cli"
 	"github.com/go-phorce/dolly/cmd/dollypki/cli"
 	"github.com/go-phorce/dolly/ctl"
-	"github.com/go-phorce/dolly/xpki/csrprov"
+	"github.com/go-phorce/dolly/xpki/authority"
+	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/pkg/errors"
 )
 
@@ -52,16 +52,16 @@ func GenKey(c ctl.Control, p interface{}) error {
 		return errors.Errorf("unsupported command for this crypto provider")
 	}
 
-	prov := csrprov.New(cryptoprov.Default())
+	prov := csr.NewProvider(cryptoprov.Default())
 
 	csrf, err := cli.ReadStdin(*flags.CsrProfile)
 	if err != nil {
 		return errors.WithMessage(err, "read CSR profile")
 	}
 
-	req := csrprov.CertificateRequest{
+	req := csr.CertificateRequest{
 		// TODO: alg and size from params
-		KeyRequest: prov.NewKeyRequest(prefixKeyLabel(*flags.Label), "ECDSA", 256, csrprov.Signing),
+		KeyRequest: prov.NewKeyRequest(prefixKeyLabel(*flags.Label), "ECDSA", 256, csr.SigningKey),
 	}
 
 	err = json.Unmarshal(csrf, &req)
@@ -70,14 +70,19 @@ func GenKey(c ctl.Control, p interface{}) error {
 	}
 
 	if *flags.Initca {
+		policy, err := authority.MakeCAPolicy(&req)
+		if err != nil {
+			return errors.WithMessage(err, "ca policy failed")
+		}
+
 		var key, csrPEM, cert []byte
-		cert, csrPEM, key, err = prov.NewRoot(&req)
+		cert, csrPEM, key, err = authority.NewRoot("default", policy, cryptoprov.Default(), &req)
 		if err != nil {
 			return errors.WithMessage(err, "init CA")
 		}
 
 		if *flags.Output == "" {
-			cfsslcli.PrintCert(key, csrPEM, cert)
+			c.(*cli.Cli).PrintCert(key, csrPEM, cert)
 		} else {
 			baseName := *flags.Output
 
@@ -100,10 +105,10 @@ func GenKey(c ctl.Control, p interface{}) error {
 		}
 
 		var key, csrPEM []byte
-		csrPEM, key, _, _, err = prov.ProcessCsrRequest(&req)
+		csrPEM, key, _, _, err = prov.CreateRequestAndExportKey(&req)
 		if err != nil {
 			key = nil
-			return errors.WithMessage(err, "ProcessCsrRequest")
+			return errors.WithMessage(err, "CreateRequestAndExportKey")
 		}
 
 		if *flags.Output == "" {
@@ -1,11 +1,10 @@
 package csr
 
 import (
-	cfsslconfig "github.com/cloudflare/cfssl/config"
-	"github.com/cloudflare/cfssl/signer"
 	"github.com/go-phorce/dolly/cmd/dollypki/cli"
 	"github.com/go-phorce/dolly/ctl"
-	"github.com/go-phorce/dolly/xpki/csrprov"
+	"github.com/go-phorce/dolly/xpki/authority"
+	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/pkg/errors"
 )
 
@@ -71,33 +70,34 @@ func SignCert(c ctl.Control, p interface{}) error {
 	}
 
 	// Load ca-config
-	cacfg, err := cfsslconfig.LoadFile(*flags.CAConfig)
+	cacfg, err := authority.LoadConfig(*flags.CAConfig)
 	if err != nil {
 		return errors.WithMessage(err, "ca-config")
 	}
-	if cacfg.Signing == nil {
-		return errors.New("missing signing policy in ca-config")
-	}
-	if !cacfg.Signing.Valid() {
-		return errors.New("invalid signing policy in ca-config")
-	}
 
 	cryptoprov := c.(*cli.Cli).CryptoProv()
 	if cryptoprov == nil {
 		return errors.Errorf("unsupported command for this crypto provider")
 	}
 
-	s, _, err := csrprov.NewLocalCASignerFromFile(cryptoprov, *flags.CA, *flags.CAKey, cacfg.Signing)
+	issuer, err := authority.NewIssuer(&authority.IssuerConfig{
+		CertFile: *flags.CA,
+		KeyFile:  *flags.CAKey,
+		Profiles: cacfg.Profiles,
+	}, cryptoprov)
 	if err != nil {
-		return errors.WithMessage(err, "create signer")
+		return errors.WithMessage(err, "create issuer")
 	}
 
-	signReq := signer.SignRequest{
-		Hosts:   signer.SplitHosts(*flags.Hostname),
+	signReq := csr.SignRequest{
+		SAN:     splitHosts(*flags.Hostname),
 		Request: string(csrPEM),
 		Profile: *flags.Profile,
 	}
-	cert, err := s.Sign(signReq)
+	_, cert, err := issuer.Sign(signReq)
+	if err != nil {
+		return errors.WithMessage(err, "sign request")
+	}
 
 	if *flags.Output == "" {
 		c.(*cli.Cli).PrintCert(nil, nil, cert)
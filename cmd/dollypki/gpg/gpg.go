@@ -0,0 +1,41 @@
+// Package gpg provides OpenPGP commands for keys held by crypto provider
+package gpg
+
+import (
+	"crypto"
+	"time"
+
+	"github.com/go-phorce/dolly/cmd/dollypki/cli"
+	"github.com/go-phorce/dolly/ctl"
+	"github.com/go-phorce/dolly/xpki/authority"
+	"github.com/pkg/errors"
+)
+
+// loadSigner returns crypto.Signer for the key file,
+// the key file contains PEM encoded key or PKCS#11 URI
+func loadSigner(c ctl.Control, keyFile string) (crypto.Signer, error) {
+	if keyFile == "" {
+		return nil, errors.New("key file is required")
+	}
+
+	cryptoprov := c.(*cli.Cli).CryptoProv()
+	if cryptoprov == nil {
+		return nil, errors.Errorf("unsupported command for this crypto provider")
+	}
+
+	signer, err := authority.NewSignerFromFromFile(cryptoprov, keyFile)
+	if err != nil {
+		return nil, errors.WithMessage(err, "load key")
+	}
+	return signer, nil
+}
+
+// parseCreationTime returns the creation time of the OpenPGP key,
+// the value must be in RFC3339 format
+func parseCreationTime(created string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return t, errors.WithMessage(err, "invalid creation time")
+	}
+	return t, nil
+}
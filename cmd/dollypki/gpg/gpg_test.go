@@ -0,0 +1,168 @@
+package gpg_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-phorce/dolly/cmd/dollypki/gpg"
+	"github.com/go-phorce/dolly/cmd/dollypki/testsuite"
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
+	"github.com/go-phorce/dolly/xpki/cryptoprov/inmemcrypto"
+	"github.com/go-phorce/dolly/xpki/csr"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type gpgSuite struct {
+	testsuite.Suite
+
+	tmpdir  string
+	keyFile string
+}
+
+func Test_GpgSuite(t *testing.T) {
+	s := new(gpgSuite)
+
+	s.tmpdir = filepath.Join(os.TempDir(), "/tests/dolly", "gpg")
+	err := os.MkdirAll(s.tmpdir, 0777)
+	require.NoError(t, err)
+	defer os.RemoveAll(s.tmpdir)
+
+	suite.Run(t, s)
+}
+
+func (s *gpgSuite) SetupSuite() {
+	s.Suite.SetupSuite()
+
+	prov := inmemcrypto.NewProvider()
+	c, err := cryptoprov.New(prov, nil)
+	s.Require().NoError(err)
+	s.Cli.WithCryptoProvider(c)
+
+	pvk, err := csr.NewKeyRequest(prov, "gpgtest", "ECDSA", 256, csr.SigningKey).Generate()
+	s.Require().NoError(err)
+	keyID, _, err := prov.IdentifyKey(pvk)
+	s.Require().NoError(err)
+	_, key, err := prov.ExportKey(keyID)
+	s.Require().NoError(err)
+
+	s.keyFile = filepath.Join(s.tmpdir, "gpg-key.pem")
+	err = ioutil.WriteFile(s.keyFile, key, 0600)
+	s.Require().NoError(err)
+}
+
+func (s *gpgSuite) Test_PubKeySignVerify() {
+	created := "2021-01-01T00:00:00Z"
+	name := "dolly"
+	email := "dolly@go-phorce.com"
+	empty := ""
+	missing := filepath.Join(s.tmpdir, "missing")
+
+	err := s.Run(gpg.PubKey, &gpg.PubKeyFlags{})
+	s.Require().Error(err)
+	s.Equal("creation time of the key is required", err.Error())
+
+	err = s.Run(gpg.PubKey, &gpg.PubKeyFlags{Created: &created})
+	s.Require().Error(err)
+	s.Equal("key file is required", err.Error())
+
+	invalid := "yesterday"
+	err = s.Run(gpg.PubKey, &gpg.PubKeyFlags{Key: &s.keyFile, Created: &invalid})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "invalid creation time")
+
+	err = s.Run(gpg.PubKey, &gpg.PubKeyFlags{
+		Key:     &s.keyFile,
+		Name:    &name,
+		Email:   &email,
+		Created: &created,
+	})
+	s.Require().NoError(err)
+	s.HasText("-----BEGIN PGP PUBLIC KEY BLOCK-----", "# Created: 2021-01-01T00:00:00Z", "dolly <dolly@go-phorce.com>")
+
+	pubFile := filepath.Join(s.tmpdir, "pub.asc")
+	err = s.Run(gpg.PubKey, &gpg.PubKeyFlags{
+		Key:     &s.keyFile,
+		Name:    &name,
+		Email:   &email,
+		Created: &created,
+		Output:  &pubFile,
+	})
+	s.Require().NoError(err)
+	s.HasTextInFile(pubFile, "-----BEGIN PGP PUBLIC KEY BLOCK-----")
+
+	msgFile := filepath.Join(s.tmpdir, "message.txt")
+	err = ioutil.WriteFile(msgFile, []byte("message to sign\n"), 0600)
+	s.Require().NoError(err)
+
+	// sign
+	err = s.Run(gpg.Sign, &gpg.SignFlags{Key: &s.keyFile, In: &msgFile})
+	s.Require().Error(err)
+	s.Equal("creation time of the key is required", err.Error())
+
+	err = s.Run(gpg.Sign, &gpg.SignFlags{Key: &s.keyFile, In: &missing, Created: &created})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "read input")
+
+	sigFile := filepath.Join(s.tmpdir, "message.txt.asc")
+	err = s.Run(gpg.Sign, &gpg.SignFlags{
+		Key:     &s.keyFile,
+		In:      &msgFile,
+		Created: &created,
+		Output:  &sigFile,
+	})
+	s.Require().NoError(err)
+	s.HasTextInFile(sigFile, "-----BEGIN PGP SIGNATURE-----")
+
+	clearsign := true
+	clearFile := filepath.Join(s.tmpdir, "message.txt.signed")
+	err = s.Run(gpg.Sign, &gpg.SignFlags{
+		Key:       &s.keyFile,
+		In:        &msgFile,
+		Created:   &created,
+		ClearSign: &clearsign,
+		Output:    &clearFile,
+	})
+	s.Require().NoError(err)
+	s.HasTextInFile(clearFile, "-----BEGIN PGP SIGNED MESSAGE-----", "message to sign")
+
+	// verify
+	keyring := []string{pubFile}
+
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{In: &msgFile})
+	s.Require().Error(err)
+	s.Equal("keyring is required", err.Error())
+
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{Keyring: &keyring, In: &msgFile, Signature: &sigFile})
+	s.Require().NoError(err)
+	s.HasText("Good signature from key ID:", "dolly <dolly@go-phorce.com>")
+
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{Keyring: &keyring, In: &sigFile, Signature: &sigFile})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "invalid signature")
+
+	plainFile := filepath.Join(s.tmpdir, "message.out")
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{Keyring: &keyring, In: &clearFile, Signature: &empty, Output: &plainFile})
+	s.Require().NoError(err)
+	s.HasText("Good signature from key ID:")
+	s.HasTextInFile(plainFile, "message to sign")
+
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{Keyring: &keyring, In: &msgFile})
+	s.Require().Error(err)
+	s.Equal("clear-signed message not found", err.Error())
+
+	// signing with different creation time produces different key ID
+	other := "2021-01-02T00:00:00Z"
+	err = s.Run(gpg.Sign, &gpg.SignFlags{
+		Key:     &s.keyFile,
+		In:      &msgFile,
+		Created: &other,
+		Output:  &sigFile,
+	})
+	s.Require().NoError(err)
+	err = s.Run(gpg.Verify, &gpg.VerifyFlags{Keyring: &keyring, In: &msgFile, Signature: &sigFile})
+	s.Require().Error(err)
+	s.Equal("invalid signature: openpgp: signature made by unknown entity", err.Error())
+}
@@ -0,0 +1,96 @@
+package gpg
+
+import (
+	"github.com/go-phorce/dolly/cmd/dollypki/cli"
+	"github.com/go-phorce/dolly/ctl"
+	"github.com/go-phorce/dolly/xpki/gpg"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// PubKeyFlags specifies flags for PubKey command
+type PubKeyFlags struct {
+	// Key specifies file name with PEM encoded key or PKCS#11 URI
+	Key *string
+	// Name specifies name of the identity
+	Name *string
+	// Comment specifies comment of the identity
+	Comment *string
+	// Email specifies email of the identity
+	Email *string
+	// Created specifies creation time of the key in RFC3339 format,
+	// the same time must be used to sign, as the key ID depends on it
+	Created *string
+	// Output specifies the optional file name for armored public key,
+	// if not set, the output will be printed to STDOUT only
+	Output *string
+}
+
+func ensurePubKeyFlags(f *PubKeyFlags) *PubKeyFlags {
+	var (
+		emptyString = ""
+	)
+	if f.Key == nil {
+		f.Key = &emptyString
+	}
+	if f.Name == nil {
+		f.Name = &emptyString
+	}
+	if f.Comment == nil {
+		f.Comment = &emptyString
+	}
+	if f.Email == nil {
+		f.Email = &emptyString
+	}
+	if f.Created == nil {
+		f.Created = &emptyString
+	}
+	if f.Output == nil {
+		f.Output = &emptyString
+	}
+	return f
+}
+
+// PubKey creates OpenPGP entity for the key, and exports armored public key
+func PubKey(c ctl.Control, p interface{}) error {
+	flags := ensurePubKeyFlags(p.(*PubKeyFlags))
+
+	if *flags.Created == "" {
+		return errors.New("creation time of the key is required")
+	}
+	created, err := parseCreationTime(*flags.Created)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	signer, err := loadSigner(c, *flags.Key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	uid := packet.NewUserId(*flags.Name, *flags.Comment, *flags.Email)
+	if uid == nil {
+		return errors.New("invalid identity: name, comment and email must not contain ()<> characters")
+	}
+
+	entity, err := gpg.CreateOpenPGPEntityFromSigner(created, signer, uid)
+	if err != nil {
+		return errors.WithMessage(err, "create entity")
+	}
+
+	pub, err := gpg.EncodePGPEntityToPEM(entity)
+	if err != nil {
+		return errors.WithMessage(err, "encode public key")
+	}
+
+	if *flags.Output == "" {
+		c.Writer().Write(pub)
+	} else {
+		err = cli.WriteFile(*flags.Output, pub, 0664)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
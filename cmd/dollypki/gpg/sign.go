@@ -0,0 +1,108 @@
+package gpg
+
+import (
+	"bytes"
+
+	"github.com/go-phorce/dolly/cmd/dollypki/cli"
+	"github.com/go-phorce/dolly/ctl"
+	"github.com/go-phorce/dolly/xpki/gpg"
+	"github.com/pkg/errors"
+)
+
+// SignFlags specifies flags for Sign command
+type SignFlags struct {
+	// Key specifies file name with PEM encoded key or PKCS#11 URI
+	Key *string
+	// Created specifies creation time of the key in RFC3339 format,
+	// the value must match the time used to export the public key
+	Created *string
+	// In specifies file name to sign
+	In *string
+	// ClearSign specifies to produce clear-signed message,
+	// otherwise armored detached signature is produced
+	ClearSign *bool
+	// Text specifies to produce signature in text mode
+	Text *bool
+	// Output specifies the optional file name for the signature,
+	// if not set, the output will be printed to STDOUT only
+	Output *string
+}
+
+func ensureSignFlags(f *SignFlags) *SignFlags {
+	var (
+		emptyString = ""
+		falseVal    = false
+	)
+	if f.Key == nil {
+		f.Key = &emptyString
+	}
+	if f.Created == nil {
+		f.Created = &emptyString
+	}
+	if f.In == nil {
+		f.In = &emptyString
+	}
+	if f.ClearSign == nil {
+		f.ClearSign = &falseVal
+	}
+	if f.Text == nil {
+		f.Text = &falseVal
+	}
+	if f.Output == nil {
+		f.Output = &emptyString
+	}
+	return f
+}
+
+// Sign signs a file
+func Sign(c ctl.Control, p interface{}) error {
+	flags := ensureSignFlags(p.(*SignFlags))
+
+	if *flags.Created == "" {
+		return errors.New("creation time of the key is required")
+	}
+	created, err := parseCreationTime(*flags.Created)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	message, err := cli.ReadStdin(*flags.In)
+	if err != nil {
+		return errors.WithMessage(err, "read input")
+	}
+
+	signer, err := loadSigner(c, *flags.Key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	entity, err := gpg.CreateOpenPGPEntityFromSigner(created, signer, nil)
+	if err != nil {
+		return errors.WithMessage(err, "create entity")
+	}
+
+	var sig bytes.Buffer
+	if *flags.ClearSign {
+		err = gpg.OpenpgpClearSign(bytes.NewReader(message), &sig, entity, nil)
+	} else {
+		sigType := gpg.OpenpgpSigTypeBinary
+		if *flags.Text {
+			sigType = gpg.OpenpgpSigTypeText
+		}
+		err = gpg.OpenpgpDetachSign(bytes.NewReader(message), &sig, entity, sigType, nil)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "sign")
+	}
+
+	if *flags.Output == "" {
+		c.Writer().Write(sig.Bytes())
+	} else {
+		err = cli.WriteFile(*flags.Output, sig.Bytes(), 0664)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
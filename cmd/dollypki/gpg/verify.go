@@ -0,0 +1,96 @@
+package gpg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-phorce/dolly/cmd/dollypki/cli"
+	"github.com/go-phorce/dolly/ctl"
+	"github.com/go-phorce/dolly/xpki/gpg"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+// VerifyFlags specifies flags for Verify command
+type VerifyFlags struct {
+	// Keyring specifies the list of files with armored public keys
+	Keyring *[]string
+	// In specifies file name with signed data, or clear-signed message
+	In *string
+	// Signature specifies file name with detached signature,
+	// if not set, the input is verified as clear-signed message
+	Signature *string
+	// Output specifies the optional file name for the message
+	// extracted from clear-signed input
+	Output *string
+}
+
+func ensureVerifyFlags(f *VerifyFlags) *VerifyFlags {
+	var (
+		emptyString = ""
+		emptyList   = []string{}
+	)
+	if f.Keyring == nil {
+		f.Keyring = &emptyList
+	}
+	if f.In == nil {
+		f.In = &emptyString
+	}
+	if f.Signature == nil {
+		f.Signature = &emptyString
+	}
+	if f.Output == nil {
+		f.Output = &emptyString
+	}
+	return f
+}
+
+// Verify verifies a signature
+func Verify(c ctl.Control, p interface{}) error {
+	flags := ensureVerifyFlags(p.(*VerifyFlags))
+
+	if len(*flags.Keyring) == 0 {
+		return errors.New("keyring is required")
+	}
+
+	keyring, err := gpg.KeyRingFromFiles(*flags.Keyring)
+	if err != nil {
+		return errors.WithMessage(err, "load keyring")
+	}
+
+	data, err := cli.ReadStdin(*flags.In)
+	if err != nil {
+		return errors.WithMessage(err, "read input")
+	}
+
+	var signer *openpgp.Entity
+	if *flags.Signature != "" {
+		sig, err := cli.ReadStdin(*flags.Signature)
+		if err != nil {
+			return errors.WithMessage(err, "read signature")
+		}
+		signer, err = gpg.VerifyDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		var plaintext []byte
+		signer, plaintext, err = gpg.VerifyClearSignedMessage(keyring, data)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if *flags.Output != "" {
+			err = cli.WriteFile(*flags.Output, plaintext, 0664)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	fmt.Fprintf(c.Writer(), "Good signature from key ID: %X\n", signer.PrimaryKey.KeyId)
+	for _, ident := range signer.Identities {
+		fmt.Fprintf(c.Writer(), "  %s\n", ident.Name)
+	}
+
+	return nil
+}
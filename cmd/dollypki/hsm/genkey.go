@@ -7,11 +7,10 @@ import (
 
 	"github.com/go-phorce/dolly/fileutil"
 
-	cfsslcli "github.com/cloudflare/cfssl/cli"
 	"github.com/go-phorce/dolly/algorithms/guid"
 	"github.com/go-phorce/dolly/cmd/dollypki/cli"
 	"github.com/go-phorce/dolly/ctl"
-	"github.com/go-phorce/dolly/xpki/csrprov"
+	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/pkg/errors"
 )
 
@@ -71,19 +70,18 @@ func GenKey(c ctl.Control, p interface{}) error {
 	}
 
 	crypto := cryptoprov.Default()
-	csr := csrprov.New(cryptoprov.Default())
 
-	purpose := csrprov.Signing
+	purpose := csr.SigningKey
 	switch *flags.Purpose {
 	case "s", "sign", "signing":
-		purpose = csrprov.Signing
+		purpose = csr.SigningKey
 	case "e", "encrypt", "encryption":
-		purpose = csrprov.Encryption
+		purpose = csr.EncryptionKey
 	default:
 		return errors.Errorf("unsupported purpose: %q", *flags.Purpose)
 	}
 
-	req := csr.NewKeyRequest(prefixKeyLabel(*flags.Label), *flags.Algo, *flags.Size, purpose)
+	req := csr.NewKeyRequest(crypto, prefixKeyLabel(*flags.Label), *flags.Algo, *flags.Size, purpose)
 	prv, err := req.Generate()
 	if err != nil {
 		return errors.WithStack(err)
@@ -104,7 +102,7 @@ func GenKey(c ctl.Control, p interface{}) error {
 	}
 
 	if *flags.Output == "" {
-		cfsslcli.PrintCert(key, nil, nil)
+		c.(*cli.Cli).PrintCert(key, nil, nil)
 	} else {
 		err = cli.WriteFile(*flags.Output, key, 0600)
 		if err != nil {
@@ -0,0 +1,93 @@
+// Package identity provides commands to test the mapping of client certificates to identities
+package identity
+
+import (
+	"fmt"
+
+	"github.com/go-phorce/dolly/ctl"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/oid"
+	"github.com/pkg/errors"
+)
+
+// TestFlags specifies flags for Test command
+type TestFlags struct {
+	// Rules specifies the file with the mapping rules in YAML or JSON format
+	Rules *string
+	// Cert specifies the file with PEM encoded client certificate,
+	// followed by its CA certificates, which are matched by the issuers rules
+	Cert *string
+}
+
+func ensureTestFlags(f *TestFlags) *TestFlags {
+	var emptyString = ""
+	if f.Rules == nil {
+		f.Rules = &emptyString
+	}
+	if f.Cert == nil {
+		f.Cert = &emptyString
+	}
+	return f
+}
+
+// Test prints the identity that the certificate would get by the mapping rules,
+// or the reason the certificate is rejected.
+// The chain in the file is not verified, as it is by the server.
+func Test(c ctl.Control, p interface{}) error {
+	flags := ensureTestFlags(p.(*TestFlags))
+
+	if *flags.Rules == "" {
+		return errors.New("rules file is required")
+	}
+	if *flags.Cert == "" {
+		return errors.New("cert file is required")
+	}
+
+	mapping, err := identity.LoadCertMapping(*flags.Rules)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	chain, err := certutil.LoadChainFromPEM(*flags.Cert)
+	if err != nil {
+		return errors.WithMessage(err, "load cert")
+	}
+	if len(chain) == 0 {
+		return errors.New("load cert: no certificate found")
+	}
+	cert := chain[0]
+
+	w := c.Writer()
+	fmt.Fprintf(w, "Subject: %s\n", cert.Subject.String())
+	fmt.Fprintf(w, "Issuer: %s\n", cert.Issuer.String())
+	if len(cert.Extensions) > 0 {
+		// the registered extensions are printed with the names and decoded values
+		fmt.Fprintf(w, "Extensions:\n")
+		for _, ext := range cert.Extensions {
+			fmt.Fprintf(w, "  %s\n", oid.ExtensionString(ext))
+		}
+	}
+
+	r := mapping.MatchChain(chain)
+	rule := "<default>"
+	if r != nil {
+		rule = r.Name
+	}
+	fmt.Fprintf(w, "Rule: %s\n", rule)
+
+	id, err := mapping.IdentityFromChain(chain)
+	if err != nil {
+		// the rejection is the result of the test
+		if r != nil && r.Deny {
+			fmt.Fprintf(w, "Result: denied by rule %q\n", r.Name)
+		} else {
+			fmt.Fprintf(w, "Result: not applicable, %s\n", err.Error())
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "Identity: %s\n", id.String())
+	fmt.Fprintf(w, "  Role: %s\n", id.Role())
+	fmt.Fprintf(w, "  Name: %s\n", id.Name())
+
+	return nil
+}
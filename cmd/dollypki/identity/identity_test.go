@@ -0,0 +1,175 @@
+package identity_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/cmd/dollypki/identity"
+	"github.com/go-phorce/dolly/cmd/dollypki/testsuite"
+	"github.com/go-phorce/dolly/xpki/oid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const rules = `
+rules:
+  - name: revoked
+    deny: true
+    organizational_units: ["revoked"]
+  - name: ops
+    role: admin
+    organizational_units: ["ops"]
+  - name: issued
+    role: user
+    issuers: ["Test CA"]
+`
+
+type identitySuite struct {
+	testsuite.Suite
+
+	tmpdir string
+}
+
+// teamExtension is the custom extension with the name of the team
+var teamExtension = oid.MustRegister(oid.Registration{
+	Name:    "id-ce-testTeam",
+	Type:    oid.AlgExtension,
+	OID:     asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1},
+	Decoder: oid.StringDecoder,
+})
+
+var teamValue, _ = asn1.Marshal("platform")
+
+func Test_IdentitySuite(t *testing.T) {
+	s := new(identitySuite)
+
+	s.tmpdir = filepath.Join(os.TempDir(), "/tests/dolly", "identity")
+	err := os.MkdirAll(s.tmpdir, 0777)
+	require.NoError(t, err)
+	defer os.RemoveAll(s.tmpdir)
+
+	suite.Run(t, s)
+}
+
+func (s *identitySuite) writeCert(name string, ou string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: []string{ou}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		ExtraExtensions: []pkix.Extension{
+			{Id: teamExtension.OID(), Value: teamValue},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	s.Require().NoError(err)
+
+	file := filepath.Join(s.tmpdir, name+".pem")
+	err = ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	s.Require().NoError(err)
+	return file
+}
+
+// writeChain writes the client certificate followed by its CA
+func (s *identitySuite) writeChain(name string) string {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	s.Require().NoError(err)
+	ca, err := x509.ParseCertificate(caDER)
+	s.Require().NoError(err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	s.Require().NoError(err)
+
+	file := filepath.Join(s.tmpdir, name+".pem")
+	pemChain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	err = ioutil.WriteFile(file, pemChain, 0644)
+	s.Require().NoError(err)
+	return file
+}
+
+func (s *identitySuite) Test_Test() {
+	rulesFile := filepath.Join(s.tmpdir, "rules.yaml")
+	err := ioutil.WriteFile(rulesFile, []byte(rules), 0644)
+	s.Require().NoError(err)
+
+	alice := s.writeCert("alice", "ops")
+	bob := s.writeCert("bob", "revoked")
+	carol := s.writeCert("carol", "dev")
+	missing := filepath.Join(s.tmpdir, "missing.pem")
+	empty := ""
+
+	err = s.Run(identity.Test, &identity.TestFlags{})
+	s.Require().Error(err)
+	s.Equal("rules file is required", err.Error())
+
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &empty})
+	s.Require().Error(err)
+	s.Equal("cert file is required", err.Error())
+
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &missing, Cert: &alice})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "unable to read rules file")
+
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &missing})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "load cert")
+
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &alice})
+	s.Require().NoError(err)
+	s.HasText("Subject: CN=alice,OU=ops\n", "Rule: ops\n", "Identity: admin/alice\n", "  Role: admin\n", "  Name: alice\n")
+	// the extensions are printed with the names of the registered OIDs
+	s.HasText("Extensions:\n",
+		"  id-ce-extKeyUsage (2.5.29.37): id-kp-clientAuth\n",
+		"  id-ce-testTeam (1.3.6.1.4.1.99999.1): platform\n")
+
+	// the rejection is reported as the result
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &bob})
+	s.Require().NoError(err)
+	s.HasText("Rule: revoked\n", "Result: denied by rule \"revoked\"\n")
+	s.HasNoText("Identity:")
+
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &carol})
+	s.Require().NoError(err)
+	s.HasText("Rule: <default>\n", "Result: not applicable, no rule matched: subject=\"CN=carol,OU=dev\"\n")
+	s.HasNoText("Identity:")
+
+	// the issuers are matched by the CA certificates in the file
+	dave := s.writeChain("dave")
+	err = s.Run(identity.Test, &identity.TestFlags{Rules: &rulesFile, Cert: &dave})
+	s.Require().NoError(err)
+	s.HasText("Issuer: CN=Test CA\n", "Rule: issued\n", "Identity: user/dave\n")
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/go-phorce/dolly/cmd/dollypki/cli"
 	"github.com/go-phorce/dolly/cmd/dollypki/csr"
+	"github.com/go-phorce/dolly/cmd/dollypki/gpg"
 	"github.com/go-phorce/dolly/cmd/dollypki/hsm"
+	"github.com/go-phorce/dolly/cmd/dollypki/identity"
 	"github.com/go-phorce/dolly/ctl"
 	"github.com/go-phorce/dolly/xpki/cryptoprov"
 )
@@ -97,6 +99,49 @@ func ParseAndRun(cmdname string, args []string, out io.Writer, errout io.Writer)
 	signcertFlags.CAKey = cmdSigncert.Flag("ca-key", "file name with CA key").Required().String()
 	signcertFlags.Output = cmdSigncert.Flag("output", "optional prefix for output files").String()
 
+	// gpg pubkey|sign|verify
+	cmdGpg := app.Command("gpg", "Perform OpenPGP operations").
+		PreAction(cli.PopulateControl).
+		PreAction(cli.EnsureCryptoProvider)
+
+	gpgPubKeyFlags := new(gpg.PubKeyFlags)
+	cmdGpgPubKey := cmdGpg.Command("pubkey", "Create OpenPGP entity for the key and export armored public key").
+		Action(cli.RegisterAction(gpg.PubKey, gpgPubKeyFlags))
+	gpgPubKeyFlags.Key = cmdGpgPubKey.Flag("key", "File name with key or PKCS#11 URI").Required().String()
+	gpgPubKeyFlags.Name = cmdGpgPubKey.Flag("name", "Name of the identity").String()
+	gpgPubKeyFlags.Comment = cmdGpgPubKey.Flag("comment", "Comment of the identity").String()
+	gpgPubKeyFlags.Email = cmdGpgPubKey.Flag("email", "Email of the identity").String()
+	gpgPubKeyFlags.Created = cmdGpgPubKey.Flag("created", "Creation time of the key in RFC3339 format, required to sign with the key").Required().String()
+	gpgPubKeyFlags.Output = cmdGpgPubKey.Flag("output", "Optional output file name").String()
+
+	gpgSignFlags := new(gpg.SignFlags)
+	cmdGpgSign := cmdGpg.Command("sign", "Sign file").
+		Action(cli.RegisterAction(gpg.Sign, gpgSignFlags))
+	gpgSignFlags.Key = cmdGpgSign.Flag("key", "File name with key or PKCS#11 URI").Required().String()
+	gpgSignFlags.Created = cmdGpgSign.Flag("created", "Creation time of the key in RFC3339 format, as used for pubkey").Required().String()
+	gpgSignFlags.In = cmdGpgSign.Flag("in", "File name to sign").Required().String()
+	gpgSignFlags.ClearSign = cmdGpgSign.Flag("clearsign", "Produce clear-signed message instead of detached signature").Bool()
+	gpgSignFlags.Text = cmdGpgSign.Flag("text", "Produce detached signature in text mode").Bool()
+	gpgSignFlags.Output = cmdGpgSign.Flag("output", "Optional output file name").String()
+
+	gpgVerifyFlags := new(gpg.VerifyFlags)
+	cmdGpgVerify := cmdGpg.Command("verify", "Verify signature").
+		Action(cli.RegisterAction(gpg.Verify, gpgVerifyFlags))
+	gpgVerifyFlags.Keyring = cmdGpgVerify.Flag("keyring", "File name with armored public keys").Required().Strings()
+	gpgVerifyFlags.In = cmdGpgVerify.Flag("in", "File name with signed data or clear-signed message").Required().String()
+	gpgVerifyFlags.Signature = cmdGpgVerify.Flag("sig", "File name with detached signature").String()
+	gpgVerifyFlags.Output = cmdGpgVerify.Flag("output", "Optional output file name for the message from clear-signed input").String()
+
+	// identity test
+	cmdIdentity := app.Command("identity", "Perform client identity operations").
+		PreAction(cli.PopulateControl)
+
+	identityTestFlags := new(identity.TestFlags)
+	cmdIdentityTest := cmdIdentity.Command("test", "Show the extensions and the identity of the client certificate by the mapping rules").
+		Action(cli.RegisterAction(identity.Test, identityTestFlags))
+	identityTestFlags.Rules = cmdIdentityTest.Flag("rules", "File with identity mapping rules in YAML or JSON format").Required().String()
+	identityTestFlags.Cert = cmdIdentityTest.Flag("cert", "File with PEM encoded client certificate, followed by its CA certificates to match the issuers").Required().String()
+
 	cryptoprov.Register("SoftHSM", cryptoprov.Crypto11Loader)
 
 	cli.Parse(args)
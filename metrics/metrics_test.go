@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-phorce/dolly/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -106,6 +107,39 @@ func Test_SetProviderPrometheus(t *testing.T) {
 	assert.Contains(t, body, `env_tag="test_value"`)
 }
 
+func Test_PrometheusHistograms(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	d, err := metrics.NewPrometheusSinkFrom(metrics.PrometheusOpts{
+		Expiration: time.Minute,
+		Histograms: map[string][]float64{
+			"test_metrics_sample": {1, 5, 10},
+		},
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	prov, err := metrics.New(&metrics.Config{
+		FilterDefault: true,
+		ServiceName:   "dolly",
+	}, d)
+	require.NoError(t, err)
+	run(prov, 10)
+
+	r, err := http.NewRequest(http.MethodGet, "/stats", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE dolly_test_metrics_sample histogram")
+	assert.Contains(t, body, `dolly_test_metrics_sample_bucket{le="1"} 2`)
+	assert.Contains(t, body, `dolly_test_metrics_sample_bucket{le="5"} 6`)
+	assert.Contains(t, body, `dolly_test_metrics_sample_bucket{le="+Inf"} 10`)
+	assert.Contains(t, body, "# TYPE dolly_test_metrics_since summary")
+}
+
 //
 // Mock
 //
@@ -23,6 +23,13 @@ type PrometheusOpts struct {
 	// Expiration is the duration a metric is valid for, after which it will be
 	// untracked. If the value is zero, a metric is never expired.
 	Expiration time.Duration
+	// Histograms specifies the buckets of the samples to be reported as histograms,
+	// keyed by the metric name or its suffix, for example "http_request_perf",
+	// the other samples are reported as summaries.
+	Histograms map[string][]float64
+	// Registerer is used to register the sink,
+	// if not set then prometheus.DefaultRegisterer is used
+	Registerer prometheus.Registerer
 }
 
 // PrometheusSink provides a MetricSink that can be used
@@ -31,9 +38,11 @@ type PrometheusSink struct {
 	mu         sync.Mutex
 	gauges     map[string]prometheus.Gauge
 	summaries  map[string]prometheus.Summary
+	histograms map[string]prometheus.Histogram
 	counters   map[string]prometheus.Counter
 	updates    map[string]time.Time
 	expiration time.Duration
+	buckets    map[string][]float64
 }
 
 // NewPrometheusSink creates a new PrometheusSink using the default options.
@@ -46,12 +55,18 @@ func NewPrometheusSinkFrom(opts PrometheusOpts) (*PrometheusSink, error) {
 	sink := &PrometheusSink{
 		gauges:     make(map[string]prometheus.Gauge),
 		summaries:  make(map[string]prometheus.Summary),
+		histograms: make(map[string]prometheus.Histogram),
 		counters:   make(map[string]prometheus.Counter),
 		updates:    make(map[string]time.Time),
 		expiration: opts.Expiration,
+		buckets:    opts.Histograms,
 	}
 
-	return sink, prometheus.Register(sink)
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	return sink, registerer.Register(sink)
 }
 
 // Describe is needed to meet the Collector interface.
@@ -88,6 +103,15 @@ func (p *PrometheusSink) Collect(c chan<- prometheus.Metric) {
 			v.Collect(c)
 		}
 	}
+	for k, v := range p.histograms {
+		last := p.updates[k]
+		if expire && last.Add(p.expiration).Before(now) {
+			delete(p.updates, k)
+			delete(p.histograms, k)
+		} else {
+			v.Collect(c)
+		}
+	}
 	for k, v := range p.counters {
 		last := p.updates[k]
 		if expire && last.Add(p.expiration).Before(now) {
@@ -139,11 +163,41 @@ func (p *PrometheusSink) SetGauge(parts []string, val float32, tags []Tag) {
 	p.updates[hash] = time.Now()
 }
 
-// AddSample is for timing information, where quantiles are used
+// histogramBuckets returns the buckets, if the metric is configured as histogram
+func (p *PrometheusSink) histogramBuckets(key string) ([]float64, bool) {
+	if b, ok := p.buckets[key]; ok {
+		return b, true
+	}
+	for name, b := range p.buckets {
+		if strings.HasSuffix(key, "_"+name) {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// AddSample is for timing information, where quantiles are used,
+// or buckets if the metric is configured as histogram
 func (p *PrometheusSink) AddSample(parts []string, val float32, tags []Tag) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	key, hash := p.flattenKey(parts, tags)
+	if buckets, ok := p.histogramBuckets(key); ok {
+		h, ok := p.histograms[hash]
+		if !ok {
+			h = prometheus.NewHistogram(prometheus.HistogramOpts{
+				Name:        key,
+				Help:        key,
+				Buckets:     buckets,
+				ConstLabels: prometheusLabels(tags),
+			})
+			p.histograms[hash] = h
+		}
+		h.Observe(float64(val))
+		p.updates[hash] = time.Now()
+		return
+	}
+
 	g, ok := p.summaries[hash]
 	if !ok {
 		g = prometheus.NewSummary(prometheus.SummaryOpts{
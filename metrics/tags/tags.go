@@ -5,8 +5,12 @@ const (
 	URI = "uri"
 	// Method is the name of the metrics tag used for request Method
 	Method = "method"
+	// Identity is the name of the metrics tag used for request identity name
+	Identity = "identity"
 	// Role is the name of the metrics tag used for request Role
 	Role = "role"
+	// IdentityProvider is the name of the metrics tag used for the provider of request identity
+	IdentityProvider = "identity_provider"
 	// Status is the name of the metrics tag used for response status code
 	Status = "status"
 )
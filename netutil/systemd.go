@@ -0,0 +1,99 @@
+package netutil
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd
+var listenFdsStart = 3
+
+// SystemdListener provides a listener passed by systemd socket activation
+type SystemdListener struct {
+	net.Listener
+	// Name is the name of the socket as specified by FileDescriptorName,
+	// or LISTEN_FDNAMES
+	Name string
+}
+
+var systemd struct {
+	once      sync.Once
+	listeners []*SystemdListener
+	err       error
+}
+
+// SystemdListeners returns the listeners passed by systemd socket activation,
+// as specified by LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES environment variables.
+// The environment is consumed on the first call, the subsequent calls return
+// the same listeners.
+// If the process was not activated by systemd, then the empty list is returned.
+func SystemdListeners() ([]*SystemdListener, error) {
+	systemd.once.Do(func() {
+		systemd.listeners, systemd.err = systemdListeners(
+			os.Getenv("LISTEN_PID"),
+			os.Getenv("LISTEN_FDS"),
+			os.Getenv("LISTEN_FDNAMES"),
+		)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	return systemd.listeners, systemd.err
+}
+
+func systemdListeners(pid, fds, fdnames string) ([]*SystemdListener, error) {
+	if pid == "" || fds == "" {
+		return nil, nil
+	}
+
+	p, err := strconv.Atoi(pid)
+	if err != nil {
+		return nil, errors.Errorf("invalid LISTEN_PID: %q", pid)
+	}
+	if p != os.Getpid() {
+		// passed to another process
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, errors.Errorf("invalid LISTEN_FDS: %q", fds)
+	}
+
+	var names []string
+	if fdnames != "" {
+		names = strings.Split(fdnames, ":")
+	}
+
+	list := make([]*SystemdListener, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		// net.FileListener duplicates the descriptor
+		f.Close()
+		if err != nil {
+			for _, sl := range list {
+				sl.Close()
+			}
+			return nil, errors.WithMessagef(err, "fd=%d, name=%q", fd, name)
+		}
+
+		list = append(list, &SystemdListener{
+			Listener: l,
+			Name:     name,
+		})
+	}
+
+	return list, nil
+}
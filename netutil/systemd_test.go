@@ -0,0 +1,81 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdListeners(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	list, err := systemdListeners("", "", "")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	list, err = systemdListeners(strconv.Itoa(os.Getpid()+1), "1", "")
+	require.NoError(t, err)
+	assert.Empty(t, list, "passed to another process")
+
+	_, err = systemdListeners("abc", "1", "")
+	assert.EqualError(t, err, `invalid LISTEN_PID: "abc"`)
+
+	_, err = systemdListeners(pid, "x", "")
+	assert.EqualError(t, err, `invalid LISTEN_FDS: "x"`)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	saved := listenFdsStart
+	defer func() { listenFdsStart = saved }()
+	listenFdsStart = int(f.Fd())
+
+	list, err = systemdListeners(pid, "1", "api")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "api", list[0].Name)
+	assert.Equal(t, l.Addr().String(), list[0].Addr().String())
+
+	go func() {
+		c, err := list[0].Accept()
+		if err == nil {
+			fmt.Fprint(c, "hello")
+			c.Close()
+		}
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = c.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+	c.Close()
+	list[0].Close()
+
+	// not a socket
+	tmp, err := os.CreateTemp("", "systemd")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	listenFdsStart = int(tmp.Fd())
+	_, err = systemdListeners(pid, "1", "")
+	assert.Error(t, err)
+}
+
+func TestSystemdListenersFromEnv(t *testing.T) {
+	list, err := SystemdListeners()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+}
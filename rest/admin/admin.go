@@ -0,0 +1,248 @@
+// Package admin provides a rest.Service with administrative end-points
+// to change log levels at runtime, report metrics and build info,
+// and capture pprof profiles on demand
+package admin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "rest/admin")
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "admin"
+
+// DefaultBasePath specifies the default path prefix of the admin end-points
+const DefaultBasePath = "/v1/admin"
+
+// DefaultMaxProfileDuration specifies the default limit of CPU profile capture
+const DefaultMaxProfileDuration = 60 * time.Second
+
+// Config provides the configuration of the admin service
+type Config struct {
+	// BasePath specifies the path prefix of the admin end-points,
+	// if not specified, then DefaultBasePath
+	BasePath string
+	// Roles specifies the roles allowed to access the admin end-points,
+	// if not specified, then the access is controlled by the server's authz only,
+	// and when the server has no authz, all the requests are denied
+	Roles []string
+	// Metrics specifies the in-memory sink to report the metrics,
+	// if not specified, then the metrics end-point is not available
+	Metrics *metrics.InmemSink
+	// ProfilesDir specifies the folder to store pprof captures,
+	// if not specified, then a temporary folder is created
+	ProfilesDir string
+	// MaxProfileDuration specifies the limit of CPU profile capture,
+	// if not specified, then DefaultMaxProfileDuration
+	MaxProfileDuration time.Duration
+}
+
+// Service provides the admin end-points
+type Service struct {
+	server   rest.Server
+	cfg      Config
+	authz    *authz.Provider
+	profiler http.Handler
+
+	lock    sync.Mutex
+	reverts map[string]*revert
+}
+
+// New returns an instance of the admin service
+func New(server rest.Server, cfg *Config) (*Service, error) {
+	if server == nil {
+		return nil, errors.New("invalid parameter: server")
+	}
+
+	s := &Service{
+		server:  server,
+		reverts: make(map[string]*revert),
+	}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.BasePath == "" {
+		s.cfg.BasePath = DefaultBasePath
+	}
+	s.cfg.BasePath = "/" + strings.Trim(s.cfg.BasePath, "/")
+	if s.cfg.MaxProfileDuration <= 0 {
+		s.cfg.MaxProfileDuration = DefaultMaxProfileDuration
+	}
+
+	var err error
+	if s.cfg.ProfilesDir == "" {
+		s.cfg.ProfilesDir, err = ioutil.TempDir("", "admin_profiles")
+	} else {
+		err = os.MkdirAll(s.cfg.ProfilesDir, 0700)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	s.profiler, err = xhttp.NewRequestProfiler(http.HandlerFunc(s.waitProfile), s.cfg.ProfilesDir, nil, profileCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.cfg.Roles) > 0 {
+		s.authz, err = authz.New(&authz.Config{
+			Allow:     []string{s.cfg.BasePath + ":" + strings.Join(s.cfg.Roles, ",")},
+			LogDenied: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close stops the pending log level reverts
+func (s *Service) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, r := range s.reverts {
+		r.timer.Stop()
+		delete(s.reverts, key)
+	}
+}
+
+// BasePath returns the path prefix of the admin end-points
+func (s *Service) BasePath() string {
+	return s.cfg.BasePath
+}
+
+// ProfilesDir returns the folder where pprof captures are stored
+func (s *Service) ProfilesDir() string {
+	return s.cfg.ProfilesDir
+}
+
+// Register adds the admin end-points to the router
+func (s *Service) Register(r rest.Router) {
+	if s.authz != nil {
+		r = r.With(s.authzMiddleware)
+	} else if !hasAuthz(s.server) {
+		logger.Errorf("reason=no_authz, path=%q, err=[roles are not configured and the server has no authz, the access is denied]",
+			s.cfg.BasePath)
+		r = r.With(denyAll)
+	}
+
+	base := s.cfg.BasePath
+	r.GET(base+"/info", s.info)
+	r.GET(base+"/logs", s.logLevels)
+	r.PUT(base+"/logs", s.setLogLevel)
+	r.GET(base+"/metrics", s.metrics)
+	r.GET(base+"/pprof", s.listProfiles)
+	r.POST(base+"/pprof", s.captureProfile)
+	r.GET(base+"/pprof/:name", s.downloadProfile)
+}
+
+// authzMiddleware allows the request only for the configured roles
+func (s *Service) authzMiddleware(handler http.Handler) http.Handler {
+	h, err := s.authz.NewHandler(handler)
+	if err != nil {
+		panic(fmt.Sprintf("%+v", err))
+	}
+	return h
+}
+
+// authzServer is implemented by the server with the authorization
+type authzServer interface {
+	Authz() authz.HTTPAuthz
+}
+
+func hasAuthz(server rest.Server) bool {
+	as, ok := server.(authzServer)
+	return ok && as.Authz() != nil
+}
+
+// denyAll rejects all the requests
+func denyAll(http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		marshal.WriteJSON(w, r, httperror.WithForbidden("access to admin end-points is not configured"))
+	})
+}
+
+// Info provides the server info
+type Info struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	Hostname   string    `json:"hostname"`
+	LocalIP    string    `json:"local_ip"`
+	Port       string    `json:"port"`
+	Protocol   string    `json:"protocol"`
+	ListenURLs []string  `json:"listen_urls,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Uptime     string    `json:"uptime"`
+	Ready      bool      `json:"ready"`
+	GoVersion  string    `json:"go_version"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	CPUs       int       `json:"cpus"`
+	Goroutines int       `json:"goroutines"`
+	PID        int       `json:"pid"`
+}
+
+func (s *Service) info(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	res := &Info{
+		Name:       s.server.Name(),
+		Version:    s.server.Version(),
+		Hostname:   s.server.HostName(),
+		LocalIP:    s.server.LocalIP(),
+		Port:       s.server.Port(),
+		Protocol:   s.server.Protocol(),
+		StartedAt:  s.server.StartedAt(),
+		Uptime:     s.server.Uptime().String(),
+		Ready:      s.server.IsReady(),
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		CPUs:       runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		PID:        os.Getpid(),
+	}
+	for _, u := range s.server.ListenURLs() {
+		res.ListenURLs = append(res.ListenURLs, u.String())
+	}
+	marshal.WriteJSON(w, r, res)
+}
+
+func (s *Service) metrics(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	if s.cfg.Metrics == nil {
+		marshal.WriteJSON(w, r, httperror.WithNotFound("in-memory metrics are not configured"))
+		return
+	}
+	summary, err := s.cfg.Metrics.DisplayMetrics()
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.New(http.StatusServiceUnavailable, httperror.NotReady, "%s", err.Error()))
+		return
+	}
+	marshal.WriteJSON(w, r, summary)
+}
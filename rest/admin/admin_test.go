@@ -0,0 +1,269 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/rest/admin"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRepo = "github.com/go-phorce/dolly/rest/admin/test"
+
+var _ = xlog.NewPackageLogger(testRepo, "pkg1")
+var _ = xlog.NewPackageLogger(testRepo, "pkg2")
+
+// server provides the server info used by admin service
+type server struct {
+	rest.Server
+	started time.Time
+	authz   authz.HTTPAuthz
+}
+
+func (s *server) Name() string                { return "testsvc" }
+func (s *server) Version() string             { return "v1.2.3" }
+func (s *server) HostName() string            { return "host1" }
+func (s *server) LocalIP() string             { return "127.0.0.1" }
+func (s *server) Port() string                { return "8080" }
+func (s *server) Protocol() string            { return "https" }
+func (s *server) StartedAt() time.Time        { return s.started }
+func (s *server) Uptime() time.Duration       { return time.Since(s.started) }
+func (s *server) IsReady() bool               { return true }
+func (s *server) ListenURLs() []*url.URL      { return []*url.URL{{Scheme: "https", Host: "host1:8080"}} }
+func (s *server) Service(string) rest.Service { return nil }
+func (s *server) Authz() authz.HTTPAuthz      { return s.authz }
+
+func newRouter(t *testing.T, cfg *admin.Config) (*admin.Service, http.Handler) {
+	// the access is controlled by the server
+	az, err := authz.New(&authz.Config{AllowAny: []string{"/"}})
+	require.NoError(t, err)
+
+	svc, err := admin.New(&server{started: time.Now().UTC(), authz: az}, cfg)
+	require.NoError(t, err)
+
+	router := rest.NewRouter(http.NotFound)
+	svc.Register(router)
+	return svc, router.Handler()
+}
+
+func call(t *testing.T, h http.Handler, method, path string, body []byte, role string, res interface{}) int {
+	req, err := http.NewRequest(method, path, bytes.NewReader(body))
+	require.NoError(t, err)
+	if role != "" {
+		req = identity.WithTestIdentity(req, identity.NewIdentity(role, "test", ""))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if res != nil && w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(res), w.Body.String())
+	}
+	return w.Code
+}
+
+func Test_New(t *testing.T) {
+	_, err := admin.New(nil, nil)
+	assert.EqualError(t, err, "invalid parameter: server")
+
+	svc, err := admin.New(&server{}, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	assert.Equal(t, admin.ServiceName, svc.Name())
+	assert.Equal(t, admin.DefaultBasePath, svc.BasePath())
+	assert.True(t, svc.IsReady())
+	assert.DirExists(t, svc.ProfilesDir())
+
+	dir, err := ioutil.TempDir("", "admin_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	svc, err = admin.New(&server{}, &admin.Config{
+		BasePath:    "ops/",
+		ProfilesDir: dir + "/profiles",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/ops", svc.BasePath())
+	assert.Equal(t, dir+"/profiles", svc.ProfilesDir())
+	assert.DirExists(t, svc.ProfilesDir())
+}
+
+func Test_Authz(t *testing.T) {
+	svc, h := newRouter(t, &admin.Config{Roles: []string{"admin", "ops"}})
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	assert.Equal(t, http.StatusUnauthorized, call(t, h, http.MethodGet, "/v1/admin/info", nil, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, call(t, h, http.MethodGet, "/v1/admin/info", nil, "user", nil))
+	assert.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/info", nil, "admin", nil))
+	assert.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/logs", nil, "ops", nil))
+}
+
+func Test_AuthzNotConfigured(t *testing.T) {
+	svc, err := admin.New(&server{started: time.Now().UTC()}, nil)
+	require.NoError(t, err)
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	router := rest.NewRouter(http.NotFound)
+	svc.Register(router)
+	h := router.Handler()
+
+	// fails closed without roles and server's authz
+	assert.Equal(t, http.StatusForbidden, call(t, h, http.MethodGet, "/v1/admin/info", nil, "", nil))
+	assert.Equal(t, http.StatusForbidden, call(t, h, http.MethodGet, "/v1/admin/info", nil, "admin", nil))
+	assert.Equal(t, http.StatusForbidden, call(t, h, http.MethodPut, "/v1/admin/logs", nil, "admin", nil))
+}
+
+func Test_Info(t *testing.T) {
+	svc, h := newRouter(t, nil)
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	var info admin.Info
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/info", nil, "", &info))
+	assert.Equal(t, "testsvc", info.Name)
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "host1", info.Hostname)
+	assert.Equal(t, []string{"https://host1:8080"}, info.ListenURLs)
+	assert.True(t, info.Ready)
+	assert.NotEmpty(t, info.GoVersion)
+	assert.NotEmpty(t, info.Uptime)
+	assert.Equal(t, os.Getpid(), info.PID)
+}
+
+func Test_LogLevels(t *testing.T) {
+	svc, h := newRouter(t, nil)
+	defer os.RemoveAll(svc.ProfilesDir())
+	defer svc.Close()
+
+	xlog.SetPackageLogLevel(testRepo, "*", xlog.INFO)
+
+	levels := func() map[string]*admin.LogLevel {
+		var res admin.LogLevelsResponse
+		require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/logs?repo="+testRepo, nil, "", &res))
+		m := map[string]*admin.LogLevel{}
+		for i := range res.Levels {
+			l := &res.Levels[i]
+			assert.Equal(t, testRepo, l.Repo)
+			m[l.Package] = l
+		}
+		return m
+	}
+
+	m := levels()
+	require.Len(t, m, 2)
+	assert.Equal(t, "INFO", m["pkg1"].Level)
+	assert.Nil(t, m["pkg1"].RevertAt)
+
+	var all admin.LogLevelsResponse
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/logs", nil, "", &all))
+	assert.True(t, len(all.Levels) > 2)
+
+	set := func(req string) int {
+		return call(t, h, http.MethodPut, "/v1/admin/logs", []byte(req), "", nil)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, set(`{"level":"DEBUG"}`))
+	assert.Equal(t, http.StatusNotFound, set(`{"repo":"unknown","level":"DEBUG"}`))
+	assert.Equal(t, http.StatusNotFound, set(`{"repo":"`+testRepo+`","package":"unknown","level":"DEBUG"}`))
+	assert.Equal(t, http.StatusBadRequest, set(`{"repo":"`+testRepo+`","package":"pkg1","level":"LOUD"}`))
+	assert.Equal(t, http.StatusBadRequest, set(`{"repo":"`+testRepo+`","package":"pkg1","level":"DEBUG","ttl":"soon"}`))
+
+	// permanent
+	require.Equal(t, http.StatusOK, set(`{"repo":"`+testRepo+`","package":"pkg2","level":"W"}`))
+	m = levels()
+	assert.Equal(t, "INFO", m["pkg1"].Level)
+	assert.Equal(t, "WARNING", m["pkg2"].Level)
+	assert.Nil(t, m["pkg2"].RevertAt)
+
+	// with revert, the second change keeps the original level
+	require.Equal(t, http.StatusOK, set(`{"repo":"`+testRepo+`","package":"pkg1","level":"DEBUG","ttl":"300ms"}`))
+	require.Equal(t, http.StatusOK, set(`{"repo":"`+testRepo+`","package":"pkg1","level":"TRACE","ttl":"300ms"}`))
+	m = levels()
+	assert.Equal(t, "TRACE", m["pkg1"].Level)
+	assert.NotNil(t, m["pkg1"].RevertAt)
+
+	// all packages
+	require.Equal(t, http.StatusOK, set(`{"repo":"`+testRepo+`","level":"ERROR","ttl":"500ms"}`))
+	m = levels()
+	assert.Equal(t, "ERROR", m["pkg1"].Level)
+	assert.Equal(t, "ERROR", m["pkg2"].Level)
+	assert.NotNil(t, m["pkg2"].RevertAt)
+
+	time.Sleep(time.Second)
+	m = levels()
+	assert.Equal(t, "INFO", m["pkg1"].Level)
+	assert.Equal(t, "WARNING", m["pkg2"].Level)
+	assert.Nil(t, m["pkg1"].RevertAt)
+	assert.Nil(t, m["pkg2"].RevertAt)
+}
+
+func Test_Metrics(t *testing.T) {
+	svc, h := newRouter(t, nil)
+	defer os.RemoveAll(svc.ProfilesDir())
+	assert.Equal(t, http.StatusNotFound, call(t, h, http.MethodGet, "/v1/admin/metrics", nil, "", nil))
+
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	im.SetGauge([]string{"test", "gauge"}, 42, nil)
+
+	svc, h = newRouter(t, &admin.Config{Metrics: im})
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	var summary metrics.Summary
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/metrics", nil, "", &summary))
+	require.Len(t, summary.Gauges, 1)
+	assert.Equal(t, "test.gauge", summary.Gauges[0].Name)
+	assert.Equal(t, float32(42), summary.Gauges[0].Value)
+}
+
+func Test_Profiles(t *testing.T) {
+	svc, h := newRouter(t, &admin.Config{MaxProfileDuration: time.Second})
+	defer os.RemoveAll(svc.ProfilesDir())
+
+	var list admin.ProfilesResponse
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/pprof", nil, "", &list))
+	assert.Empty(t, list.Profiles)
+
+	assert.Equal(t, http.StatusBadRequest, call(t, h, http.MethodPost, "/v1/admin/pprof", nil, "", nil))
+	assert.Equal(t, http.StatusBadRequest, call(t, h, http.MethodPost, "/v1/admin/pprof?profile.cpu&seconds=x", nil, "", nil))
+
+	started := time.Now()
+	var captured admin.ProfilesResponse
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodPost, "/v1/admin/pprof?profile.cpu&profile.mem&seconds=30", nil, "", &captured))
+	// capped by MaxProfileDuration
+	assert.True(t, time.Since(started) < 10*time.Second)
+	require.Len(t, captured.Profiles, 2)
+
+	types := map[string]admin.Profile{}
+	for _, p := range captured.Profiles {
+		types[p.Type] = p
+	}
+	require.Contains(t, types, "cpu")
+	require.Contains(t, types, "mem")
+	assert.NotZero(t, types["mem"].Size)
+
+	require.Equal(t, http.StatusOK, call(t, h, http.MethodGet, "/v1/admin/pprof", nil, "", &list))
+	assert.Len(t, list.Profiles, 2)
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/admin/pprof/"+types["mem"].Name, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, int(types["mem"].Size), w.Body.Len())
+
+	assert.Equal(t, http.StatusBadRequest, call(t, h, http.MethodGet, "/v1/admin/pprof/other", nil, "", nil))
+	assert.Equal(t, http.StatusNotFound, call(t, h, http.MethodGet, "/v1/admin/pprof/cpu_missing", nil, "", nil))
+}
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+)
+
+// LogLevel provides the log level of the package
+type LogLevel struct {
+	Repo    string `json:"repo"`
+	Package string `json:"package"`
+	Level   string `json:"level"`
+	// RevertAt specifies the time when the previous level is restored
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// LogLevelsResponse provides the log levels
+type LogLevelsResponse struct {
+	Levels []LogLevel `json:"levels"`
+}
+
+// SetLogLevelRequest specifies the request to change the log level
+type SetLogLevelRequest struct {
+	// Repo specifies the logger repository
+	Repo string `json:"repo"`
+	// Package specifies the package, or "*" for all packages in the repository
+	Package string `json:"package"`
+	// Level specifies the level, such as DEBUG, TRACE, INFO, NOTICE, WARNING, ERROR
+	Level string `json:"level"`
+	// TTL specifies the duration, such as "10m", after which the previous level is restored,
+	// if not specified, then the level is not reverted
+	TTL string `json:"ttl,omitempty"`
+}
+
+// revert restores the log levels after TTL
+type revert struct {
+	timer  *time.Timer
+	at     time.Time
+	levels map[string]xlog.LogLevel
+}
+
+func (s *Service) logLevels(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	marshal.WriteJSON(w, r, s.listLogLevels(r.URL.Query().Get("repo")))
+}
+
+func (s *Service) setLogLevel(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	var req SetLogLevelRequest
+	if err := marshal.DecodeBody(w, r, &req); err != nil {
+		return
+	}
+
+	if req.Repo == "" {
+		marshal.WriteJSON(w, r, httperror.WithInvalidParam("missing repo parameter"))
+		return
+	}
+	repo, err := xlog.GetRepoLogger(req.Repo)
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.WithNotFound(err.Error()))
+		return
+	}
+
+	pkg := req.Package
+	if pkg == "" {
+		pkg = "*"
+	}
+	levels := repo.LogLevels()
+	if _, ok := levels[pkg]; !ok && pkg != "*" {
+		marshal.WriteJSON(w, r, httperror.WithNotFound("package not found: %s", pkg))
+		return
+	}
+
+	level, err := xlog.ParseLevel(req.Level)
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.WithInvalidParam(err.Error()))
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl < 0 {
+			marshal.WriteJSON(w, r, httperror.WithInvalidParam("invalid ttl: %q", req.TTL))
+			return
+		}
+	}
+
+	s.changeLogLevel(req.Repo, repo, pkg, level, ttl)
+
+	marshal.WriteJSON(w, r, s.listLogLevels(req.Repo))
+}
+
+// changeLogLevel sets the level of the package,
+// and schedules to restore the previous levels if ttl is specified
+func (s *Service) changeLogLevel(repoName string, repo xlog.RepoLogger, pkg string, level xlog.LogLevel, ttl time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := repoName + "/" + pkg
+
+	previous := repo.LogLevels()
+	if pkg != "*" {
+		previous = map[string]xlog.LogLevel{pkg: previous[pkg]}
+	}
+	if pkg == "*" {
+		// the change of all packages supersedes the pending reverts of the packages
+		for p := range previous {
+			if pending, ok := s.reverts[repoName+"/"+p]; ok {
+				pending.timer.Stop()
+				previous[p] = pending.levels[p]
+				delete(s.reverts, repoName+"/"+p)
+			}
+		}
+	}
+	if pending, ok := s.reverts[key]; ok {
+		// keep the levels before the first change
+		pending.timer.Stop()
+		previous = pending.levels
+		delete(s.reverts, key)
+	}
+
+	repo.SetLogLevel(map[string]xlog.LogLevel{pkg: level})
+	logger.Noticef("repo=%q, package=%q, level=%s, ttl=%s", repoName, pkg, level, ttl)
+
+	if ttl == 0 {
+		return
+	}
+
+	rv := &revert{
+		at:     time.Now().Add(ttl).UTC(),
+		levels: previous,
+	}
+	rv.timer = time.AfterFunc(ttl, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.reverts[key] != rv {
+			return
+		}
+		delete(s.reverts, key)
+		repo.SetLogLevel(rv.levels)
+		logger.Noticef("repo=%q, package=%q, status=reverted", repoName, pkg)
+	})
+	s.reverts[key] = rv
+}
+
+// listLogLevels returns the log levels of the packages,
+// in all repositories if repo is not specified
+func (s *Service) listLogLevels(repoName string) *LogLevelsResponse {
+	repos := xlog.GetRepos()
+	if repoName != "" {
+		repos = []string{repoName}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := &LogLevelsResponse{
+		Levels: []LogLevel{},
+	}
+	for _, name := range repos {
+		repo, err := xlog.GetRepoLogger(name)
+		if err != nil {
+			continue
+		}
+		allRevert := s.reverts[name+"/*"]
+		for pkg, level := range repo.LogLevels() {
+			ll := LogLevel{
+				Repo:    name,
+				Package: pkg,
+				Level:   level.String(),
+			}
+			if rv := s.reverts[name+"/"+pkg]; rv != nil {
+				at := rv.at
+				ll.RevertAt = &at
+			} else if allRevert != nil {
+				at := allRevert.at
+				ll.RevertAt = &at
+			}
+			res.Levels = append(res.Levels, ll)
+		}
+	}
+
+	sort.Slice(res.Levels, func(i, j int) bool {
+		if res.Levels[i].Repo == res.Levels[j].Repo {
+			return res.Levels[i].Package < res.Levels[j].Package
+		}
+		return res.Levels[i].Repo < res.Levels[j].Repo
+	})
+	return res
+}
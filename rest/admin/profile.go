@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+)
+
+// DefaultProfileDuration specifies the default duration of CPU profile capture
+const DefaultProfileDuration = 10 * time.Second
+
+// Profile provides the info about captured profile
+type Profile struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProfilesResponse provides the list of captured profiles
+type ProfilesResponse struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+type contextKey int
+
+const keyContextProfiles contextKey = iota
+
+// capturedProfiles collects the profiles created for the request
+type capturedProfiles struct {
+	lock  sync.Mutex
+	files []string
+}
+
+// profileCreated is called by the request profiler when the profile is written
+func profileCreated(t xhttp.ProfileType, r *http.Request, f string) {
+	logger.Noticef("profile=%v, status=created, location=%s", t, f)
+	if c, ok := r.Context().Value(keyContextProfiles).(*capturedProfiles); ok {
+		c.lock.Lock()
+		c.files = append(c.files, f)
+		c.lock.Unlock()
+	}
+}
+
+// captureProfile captures the profiles specified by ?profile.cpu and ?profile.mem query parameters,
+// the CPU profile is captured for ?seconds=N, capped by MaxProfileDuration
+func (s *Service) captureProfile(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	q := r.URL.Query()
+	_, cpu := q["profile.cpu"]
+	_, mem := q["profile.mem"]
+	if !cpu && !mem {
+		marshal.WriteJSON(w, r, httperror.WithInvalidParam("profile.cpu or profile.mem parameter is required"))
+		return
+	}
+	if v := q.Get("seconds"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			marshal.WriteJSON(w, r, httperror.WithInvalidParam("invalid seconds parameter: %q", v))
+			return
+		}
+	}
+
+	captured := &capturedProfiles{}
+	r = r.WithContext(context.WithValue(r.Context(), keyContextProfiles, captured))
+
+	// the profiles are written when the profiler returns
+	s.profiler.ServeHTTP(w, r)
+
+	res := &ProfilesResponse{
+		Profiles: []Profile{},
+	}
+	for _, f := range captured.files {
+		if p := profileInfo(f); p != nil {
+			res.Profiles = append(res.Profiles, *p)
+		}
+	}
+	if len(res.Profiles) == 0 {
+		marshal.WriteJSON(w, r, httperror.WithUnexpected("unable to capture the profile"))
+		return
+	}
+	marshal.WriteJSON(w, r, res)
+}
+
+// waitProfile waits for CPU profile duration, it does not write the response
+func (s *Service) waitProfile(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if _, cpu := q["profile.cpu"]; !cpu {
+		return
+	}
+
+	duration := DefaultProfileDuration
+	if n, err := strconv.Atoi(q.Get("seconds")); err == nil {
+		duration = time.Duration(n) * time.Second
+	}
+	if duration > s.cfg.MaxProfileDuration {
+		duration = s.cfg.MaxProfileDuration
+	}
+
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+}
+
+func (s *Service) listProfiles(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+	files, err := ioutil.ReadDir(s.cfg.ProfilesDir)
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.WithUnexpected("unable to list profiles: %s", err.Error()))
+		return
+	}
+
+	res := &ProfilesResponse{
+		Profiles: []Profile{},
+	}
+	for _, fi := range files {
+		if p := profileInfo(filepath.Join(s.cfg.ProfilesDir, fi.Name())); p != nil {
+			res.Profiles = append(res.Profiles, *p)
+		}
+	}
+	sort.Slice(res.Profiles, func(i, j int) bool {
+		return res.Profiles[i].CreatedAt.Before(res.Profiles[j].CreatedAt)
+	})
+	marshal.WriteJSON(w, r, res)
+}
+
+func (s *Service) downloadProfile(w http.ResponseWriter, r *http.Request, p rest.Params) {
+	name := p.ByName("name")
+	if filepath.Base(name) != name || profileType(name) == "" {
+		marshal.WriteJSON(w, r, httperror.WithInvalidParam("invalid profile name: %q", name))
+		return
+	}
+
+	f, err := os.Open(filepath.Join(s.cfg.ProfilesDir, name))
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.WithNotFound("profile not found: %s", name))
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set(header.ContentType, "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// profileInfo returns the info of the profile file,
+// or nil if the file is not a profile
+func profileInfo(file string) *Profile {
+	name := filepath.Base(file)
+	typ := profileType(name)
+	if typ == "" {
+		return nil
+	}
+	fi, err := os.Stat(file)
+	if err != nil || fi.IsDir() {
+		return nil
+	}
+	return &Profile{
+		Name:      name,
+		Type:      typ,
+		Size:      fi.Size(),
+		CreatedAt: fi.ModTime().UTC(),
+	}
+}
+
+// profileType returns the type of the profile by the file name,
+// as created by the request profiler
+func profileType(name string) string {
+	for _, t := range []xhttp.ProfileType{xhttp.ProfileCPU, xhttp.ProfileMem} {
+		if strings.HasPrefix(name, t.String()+"_") {
+			return t.String()
+		}
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package rest
 import (
 	"os"
 	"strings"
+	"time"
 )
 
 // TLSInfoConfig contains configuration info for the TLS
@@ -27,6 +28,13 @@ type HTTPServerConfig interface {
 	GetVIPName() string
 	// BindAddr is the address that the HTTPS service should be exposed on
 	GetBindAddr() string
+	// ListenURLs specifies the list of listeners, if not set then BindAddr is used.
+	// Supported formats:
+	//	http://host:port
+	//	https://host:port
+	//	unix:///path/to/socket[?tls=true]
+	//	systemd://[name][?tls=true]
+	GetListenURLs() []string
 	// PackageLogger if set, specifies name of the package logger
 	GetPackageLogger() string
 	// AllowProfiling if set, will allow for per request CPU/Memory profiling triggered by the URI QueryString
@@ -37,6 +45,32 @@ type HTTPServerConfig interface {
 	GetServices() []string
 	// HeartbeatSecs specifies heartbeat GetHeartbeatSecserval in seconds [30 secs is a minimum]
 	GetHeartbeatSecs() int
+	// ReadHeaderTimeout specifies the amount of time allowed to read request headers,
+	// if not set then ReadTimeout is used
+	GetReadHeaderTimeout() time.Duration
+	// ReadTimeout specifies the maximum duration for reading the entire request, including the body,
+	// if not set then there is no timeout
+	GetReadTimeout() time.Duration
+	// WriteTimeout specifies the maximum duration before timing out writes of the response,
+	// if not set then there is no timeout.
+	// The streaming writers of marshal package extend the deadline on each write,
+	// when built with Go 1.20 and later, otherwise the streaming routes require no timeout
+	GetWriteTimeout() time.Duration
+	// IdleTimeout specifies the maximum amount of time to wait for the next request
+	// when keep-alives are enabled, if not set then 2 hours is used
+	GetIdleTimeout() time.Duration
+	// MaxHeaderBytes specifies the maximum number of bytes the server will read
+	// parsing the request header, if not set then http.DefaultMaxHeaderBytes is used
+	GetMaxHeaderBytes() int
+	// MaxRequestSize specifies the maximum size of the request body in bytes,
+	// if not set then MaxRequestSize is used
+	GetMaxRequestSize() int64
+	// RouteRequestSizes specifies the maximum size of the request body in bytes
+	// per route, where the key is the path prefix, and the longest matched prefix is used
+	GetRouteRequestSizes() map[string]int64
+	// HealthProbes specifies if /healthz, /readyz and /livez end-points are served,
+	// bypassing the readiness, authorization and rate limiting
+	GetHealthProbes() bool
 }
 
 // GetPort returns the port from HTTP bind address,
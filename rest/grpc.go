@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCService provides a way for services to register gRPC end-points.
+// A Service that implements GRPCService is hosted on the same listeners
+// as HTTP end-points, the requests are dispatched by application/grpc content type.
+// The listeners without TLS serve gRPC only when enabled by WithH2C.
+type GRPCService interface {
+	RegisterGRPC(*grpc.Server)
+}
+
+// IsGRPCRequest returns true if the request is gRPC call
+func IsGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 &&
+		strings.HasPrefix(r.Header.Get(header.ContentType), header.ApplicationGRPC)
+}
+
+// WithGRPCServerOptions specifies options for the gRPC server
+func (server *HTTPServer) WithGRPCServerOptions(opts ...grpc.ServerOption) *HTTPServer {
+	server.grpcOptions = append(server.grpcOptions, opts...)
+	return server
+}
+
+// WithGRPCUnaryInterceptor appends interceptors to the gRPC unary calls chain,
+// the interceptors are invoked after the built-in ready and authz checks
+func (server *HTTPServer) WithGRPCUnaryInterceptor(interceptors ...grpc.UnaryServerInterceptor) *HTTPServer {
+	server.grpcUnary = append(server.grpcUnary, interceptors...)
+	return server
+}
+
+// WithGRPCStreamInterceptor appends interceptors to the gRPC streaming calls chain,
+// the interceptors are invoked after the built-in ready and authz checks
+func (server *HTTPServer) WithGRPCStreamInterceptor(interceptors ...grpc.StreamServerInterceptor) *HTTPServer {
+	server.grpcStream = append(server.grpcStream, interceptors...)
+	return server
+}
+
+// WithH2C enables HTTP/2 over cleartext (h2c) on the listeners without TLS,
+// as required by gRPC clients that do not use TLS.
+// h2c is disabled by default, and is served for all end-points when enabled.
+func (server *HTTPServer) WithH2C(enabled bool) *HTTPServer {
+	server.h2c = enabled
+	return server
+}
+
+// GRPCServer returns gRPC server created by NewMux,
+// or nil if none of the services implement GRPCService
+func (server *HTTPServer) GRPCServer() *grpc.Server {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	return server.grpcServer
+}
+
+// newGRPCServer returns gRPC server with the registered services,
+// or nil if none of the services implement GRPCService
+func (server *HTTPServer) newGRPCServer() *grpc.Server {
+	server.lock.RLock()
+	registered := server.orderedServices()
+	server.lock.RUnlock()
+
+	var services []GRPCService
+	for _, s := range registered {
+		if gs, ok := s.(GRPCService); ok {
+			services = append(services, gs)
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	unary := []grpc.UnaryServerInterceptor{server.readyUnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{server.readyStreamInterceptor}
+	if az, ok := server.authz.(authz.GRPCAuthz); ok {
+		unary = append(unary, az.NewUnaryInterceptor())
+		stream = append(stream, az.NewStreamInterceptor())
+	}
+	unary = append(unary, server.grpcUnary...)
+	stream = append(stream, server.grpcStream...)
+
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, server.grpcOptions...)
+
+	gs := grpc.NewServer(opts...)
+	for _, s := range services {
+		s.RegisterGRPC(gs)
+	}
+
+	logger.Infof("service=%s, grpc_services=%d", server.Name(), len(services))
+	return gs
+}
+
+func (server *HTTPServer) readyUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !server.isServing() {
+		return nil, status.Error(codes.Unavailable, "the service is not ready yet")
+	}
+	return handler(ctx, req)
+}
+
+func (server *HTTPServer) readyStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !server.isServing() {
+		return status.Error(codes.Unavailable, "the service is not ready yet")
+	}
+	return handler(srv, ss)
+}
+
+// grpcMux dispatches gRPC requests to gRPC server,
+// and other requests to HTTP handler
+type grpcMux struct {
+	grpc *grpc.Server
+	http http.Handler
+}
+
+func (m *grpcMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if IsGRPCRequest(r) {
+		m.grpc.ServeHTTP(w, r)
+	} else {
+		m.http.ServeHTTP(w, r)
+	}
+}
+
+// withGRPCRoutes returns the handler that sets the route template of gRPC calls
+// to the full method name of the registered services, such as /pkg.Service/Method
+func withGRPCRoutes(gs *grpc.Server, handler http.Handler) http.Handler {
+	methods := map[string]bool{}
+	for name, info := range gs.GetServiceInfo() {
+		for _, m := range info.Methods {
+			methods["/"+name+"/"+m.Name] = true
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPCRequest(r) && methods[r.URL.Path] {
+			var ri *routeInfo
+			r, ri = withRouteInfo(r)
+			ri.template.Store(r.URL.Path)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// withH2C returns the handler that serves HTTP/2 over cleartext
+func withH2C(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, &http2.Server{})
+}
+
+// bypassGRPC returns the handler that passes gRPC requests to next,
+// bypassing the HTTP specific handler, as gRPC calls are checked by interceptors
+func bypassGRPC(next, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPCRequest(r) {
+			next.ServeHTTP(w, r)
+		} else {
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
+
+// tlsConfigWithHTTP2 returns TLS config that negotiates HTTP/2,
+// as required by gRPC
+func tlsConfigWithHTTP2(cfg *tls.Config) *tls.Config {
+	for _, p := range cfg.NextProtos {
+		if p == http2.NextProtoTLS {
+			return cfg
+		}
+	}
+	cfg = cfg.Clone()
+	cfg.NextProtos = append([]string{http2.NextProtoTLS}, cfg.NextProtos...)
+	if len(cfg.NextProtos) == 1 {
+		cfg.NextProtos = append(cfg.NextProtos, "http/1.1")
+	}
+	return cfg
+}
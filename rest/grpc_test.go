@@ -0,0 +1,248 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthService hosts gRPC health service
+type healthService struct {
+	service
+	health *health.Server
+}
+
+func (s *healthService) RegisterGRPC(gs *grpc.Server) {
+	healthpb.RegisterHealthServer(gs, s.health)
+}
+
+func roleFromHeader(r *http.Request) (identity.Identity, error) {
+	if role := r.Header.Get("x-test-role"); role != "" {
+		return identity.NewIdentity(role, "test", ""), nil
+	}
+	return identity.GuestIdentityMapper(r)
+}
+
+func Test_GRPC(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("rest"), im)
+	require.NoError(t, err)
+
+	tlsCfg, pool := selfSignedTLS(t)
+
+	cfg := &serverConfig{
+		ServiceName: "grpc",
+		BindAddr:    getBindAddr(""),
+		ListenURLs: []string{
+			"https://127.0.0.1:0",
+			"http://127.0.0.1:0",
+		},
+	}
+
+	az, err := authz.New(&authz.Config{
+		AllowAny: []string{
+			testURL,
+			"/grpc.health.v1.Health/Check",
+		},
+		Allow: []string{
+			"/grpc.health.v1.Health/Watch:admin",
+		},
+	})
+	require.NoError(t, err)
+
+	server, err := rest.New("v1.0.123", "", cfg, tlsCfg)
+	require.NoError(t, err)
+
+	var unaryCalls, streamCalls int32
+	server.WithAuditor(auditor.NewInMemory()).
+		WithAuthz(az).
+		WithH2C(true).
+		WithIdentityProvider(roleFromHeader).
+		WithGRPCUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddInt32(&unaryCalls, 1)
+			return handler(ctx, req)
+		}).
+		WithGRPCStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			atomic.AddInt32(&streamCalls, 1)
+			// identity is shared with HTTP stack
+			assert.Equal(t, "admin", identity.FromContext(ss.Context()).Identity().Role())
+			return handler(srv, ss)
+		})
+
+	hs := health.NewServer()
+	server.AddService(&healthService{
+		service: service{server: server},
+		health:  hs,
+	})
+	assert.Nil(t, server.GRPCServer())
+
+	err = server.StartHTTP()
+	require.NoError(t, err)
+	require.NotNil(t, server.GRPCServer())
+
+	for i := 0; i < 30 && !server.IsReady(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.True(t, server.IsReady())
+
+	urls := server.ListenURLs()
+	require.Len(t, urls, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tlsConn, err := grpc.DialContext(ctx, urls[0].Host,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})),
+		grpc.WithBlock())
+	require.NoError(t, err)
+	defer tlsConn.Close()
+
+	plainConn, err := grpc.DialContext(ctx, urls[1].Host, grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer plainConn.Close()
+
+	t.Run("unary", func(t *testing.T) {
+		for _, conn := range []*grpc.ClientConn{tlsConn, plainConn} {
+			res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&unaryCalls))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		client := healthpb.NewHealthClient(tlsConn)
+
+		// guest is not allowed
+		watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		_, err = watch.Recv()
+		require.Error(t, err)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&streamCalls))
+
+		adminCtx := metadata.AppendToOutgoingContext(ctx, "x-test-role", "admin")
+		watch, err = client.Watch(adminCtx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		res, err := watch.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		res, err = watch.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.Status)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&streamCalls))
+
+		// the status of gRPC call is published by the request metrics
+		data := im.Data()
+		assert.Equal(t, 1, data[0].Counters["rest.http.request.status.failed;method=POST;role=guest;status=403;uri=/grpc.health.v1.Health/Watch"].Count)
+	})
+
+	t.Run("rest", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		}
+		resp, err := client.Get(urls[0].String() + testURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "URL: "+testURL)
+
+		resp2, err := http.Get(urls[1].String() + testURL)
+		require.NoError(t, err)
+		defer resp2.Body.Close()
+		assert.Equal(t, http.StatusOK, resp2.StatusCode)
+	})
+
+	// open stream is closed on shutdown
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "x-test-role", "admin")
+	watch, err := healthpb.NewHealthClient(plainConn).Watch(adminCtx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	server.WithShutdownTimeout(time.Second)
+	server.StopHTTP()
+
+	_, err = watch.Recv()
+	assert.Error(t, err)
+}
+
+func Test_H2C(t *testing.T) {
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+
+	for _, enabled := range []bool{false, true} {
+		cfg := &serverConfig{
+			ServiceName: "h2c",
+			BindAddr:    getBindAddr(""),
+			ListenURLs:  []string{"http://127.0.0.1:0"},
+		}
+		server, err := rest.New("v1.0.123", "", cfg, nil)
+		require.NoError(t, err)
+		server.WithH2C(enabled).AddService(NewService(server))
+
+		err = server.StartHTTP()
+		require.NoError(t, err)
+		for i := 0; i < 30 && !server.IsReady(); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		resp, err := client.Get(server.ListenURLs()[0].String() + testURL)
+		if enabled {
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, 2, resp.ProtoMajor)
+		} else {
+			// HTTP/2 over cleartext is not served by default
+			assert.Error(t, err)
+		}
+		server.StopHTTP()
+	}
+}
+
+func Test_IsGRPCRequest(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "/pkg.Service/Method", nil)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/grpc+proto")
+	assert.False(t, rest.IsGRPCRequest(r), "HTTP/1.1")
+
+	r.ProtoMajor = 2
+	assert.True(t, rest.IsGRPCRequest(r))
+
+	r.Header.Set("Content-Type", "application/json")
+	assert.False(t, rest.IsGRPCRequest(r))
+}
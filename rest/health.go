@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-phorce/dolly/rest/health"
+	"github.com/pkg/errors"
+)
+
+// Health probes end-points
+const (
+	// HealthzPath reports the status of all the health checks
+	HealthzPath = "/healthz"
+	// ReadyzPath reports the status of the readiness checks
+	ReadyzPath = "/readyz"
+	// LivezPath reports the status of the liveness checks
+	LivezPath = "/livez"
+)
+
+// DefaultHealthCheckInterval specifies the default interval to run the health checks
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// ServiceHealth can be implemented by a Service to provide its health checks,
+// the names of the checks are prefixed with the service name: <service>/<check>
+type ServiceHealth interface {
+	HealthChecks() []health.Check
+}
+
+// WithHealthChecks adds the server health checks
+func (server *HTTPServer) WithHealthChecks(checks ...health.Check) *HTTPServer {
+	server.healthChecks = append(server.healthChecks, checks...)
+	return server
+}
+
+// WithHealthCheckInterval sets the interval to run the health checks on the Scheduler,
+// the results are cached for the interval
+func (server *HTTPServer) WithHealthCheckInterval(interval time.Duration) *HTTPServer {
+	server.healthInterval = interval
+	return server
+}
+
+// Health returns the health checks registry created by NewMux
+func (server *HTTPServer) Health() *health.Registry {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	return server.health
+}
+
+// newHealth returns the registry with the built-in, server and services health checks
+func (server *HTTPServer) newHealth() (*health.Registry, error) {
+	registry := health.NewRegistry(server.healthInterval)
+
+	err := registry.Register(
+		health.Check{
+			Name: "ping",
+			Kind: health.Liveness,
+			Func: func(context.Context) (health.Status, map[string]interface{}, error) {
+				return health.StatusPass, nil, nil
+			},
+		},
+		health.Check{
+			Name: "server",
+			Func: server.serverHealthCheck,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = registry.Register(server.healthChecks...); err != nil {
+		return nil, err
+	}
+
+	server.lock.RLock()
+	services := server.orderedServices()
+	server.lock.RUnlock()
+
+	for _, s := range services {
+		err = registry.Register(health.Check{
+			Name: s.Name(),
+			Func: serviceHealthCheck(s),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if sh, ok := s.(ServiceHealth); ok {
+			for _, c := range sh.HealthChecks() {
+				c.Name = s.Name() + "/" + c.Name
+				if err = registry.Register(c); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return registry, nil
+}
+
+func (server *HTTPServer) serverHealthCheck(context.Context) (health.Status, map[string]interface{}, error) {
+	details := map[string]interface{}{
+		"uptime": server.Uptime().String(),
+	}
+	if server.IsStopping() {
+		return health.StatusFail, details, errors.New("the server is stopping")
+	}
+	// the services readiness is reported by their checks
+	if atomic.LoadInt32(&server.serving) == 0 {
+		return health.StatusFail, details, errors.New("the server is not serving")
+	}
+	return health.StatusPass, details, nil
+}
+
+func serviceHealthCheck(s Service) health.CheckFunc {
+	return func(context.Context) (health.Status, map[string]interface{}, error) {
+		if !s.IsReady() {
+			return health.StatusFail, nil, errors.New("the service is not ready")
+		}
+		return health.StatusPass, nil, nil
+	}
+}
+
+// registerHealth registers the health probes end-points, if enabled by HealthProbes config,
+// the paths already registered by the services are skipped
+func (server *HTTPServer) registerHealth(router Router, registry *health.Registry) {
+	if !server.httpConfig.GetHealthProbes() {
+		return
+	}
+
+	registered := map[string]bool{}
+	for _, r := range router.Routes() {
+		if r.Method == http.MethodGet && r.Host == "" {
+			registered[r.Path] = true
+		}
+	}
+
+	probes := map[string]health.Kind{
+		HealthzPath: health.All,
+		ReadyzPath:  health.Readiness,
+		LivezPath:   health.Liveness,
+	}
+	for path, kind := range probes {
+		if registered[path] {
+			logger.Warningf("reason=health_probe, path=%q, err=[route already registered]", path)
+			continue
+		}
+		h := registry.NewHandler(kind)
+		router.GET(path, func(w http.ResponseWriter, r *http.Request, _ Params) {
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isHealthProbe returns true for the health probes end-points,
+// which must be served regardless of the server readiness, authorization and rate limits
+func (server *HTTPServer) isHealthProbe(r *http.Request) bool {
+	return server.httpConfig.GetHealthProbes() && r.Method == http.MethodGet && isHealthPath(r.URL.Path)
+}
+
+func isHealthPath(path string) bool {
+	return path == HealthzPath || path == ReadyzPath || path == LivezPath
+}
+
+// bypassHealth returns the handler that serves the health probes by next handler,
+// and other requests by the handler
+func (server *HTTPServer) bypassHealth(next, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.isHealthProbe(r) {
+			next.ServeHTTP(w, r)
+		} else {
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+)
+
+// Report is the response of the health handler
+type Report struct {
+	Status Status `json:"status"`
+	// Failed contains the names of the failed checks
+	Failed []string `json:"failed,omitempty"`
+	// Checks contains the results of the checks, if verbose report is requested
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// NewReport returns the report for the results,
+// the check results are included if verbose is true
+func NewReport(results []*Result, verbose bool) *Report {
+	report := &Report{
+		Status: AggregateStatus(results),
+	}
+	for _, r := range results {
+		if r.Status == StatusFail {
+			report.Failed = append(report.Failed, r.Name)
+		}
+	}
+	if verbose {
+		report.Checks = make([]Result, len(results))
+		for i, r := range results {
+			report.Checks[i] = *r
+		}
+	}
+	return report
+}
+
+// NewHandler returns http.Handler that reports the aggregated status
+// of the checks of the specified kind.
+// The response has 503 Service Unavailable status code if any of the checks failed,
+// and includes the results of the checks if "verbose" query parameter is specified.
+func (r *Registry) NewHandler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		_, verbose := q["verbose"]
+
+		report := NewReport(r.Results(req.Context(), kind), verbose)
+
+		code := http.StatusOK
+		if report.Status == StatusFail {
+			code = http.StatusServiceUnavailable
+		}
+
+		pp := marshal.DontPrettyPrint
+		if _, ok := q["pp"]; ok {
+			pp = marshal.PrettyPrint
+		}
+
+		w.Header().Set(header.CacheControl, "no-store")
+		marshal.WritePlainJSON(w, code, report, pp)
+	})
+}
@@ -0,0 +1,327 @@
+// Package health provides named health checks with cached results,
+// and HTTP handlers to report the health, readiness and liveness of the service
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/tasks"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "rest/health")
+
+var (
+	keyForCheckFailed = []string{"health", "check", "failed"}
+	keyForCheckStatus = []string{"health", "check", "status"}
+)
+
+// DefaultTimeout specifies the default time allowed for a check to complete
+const DefaultTimeout = 5 * time.Second
+
+// Status of the check
+type Status string
+
+const (
+	// StatusPass indicates that the check passed
+	StatusPass Status = "pass"
+	// StatusWarn indicates that the check passed with a warning,
+	// and does not affect the aggregated status
+	StatusWarn Status = "warn"
+	// StatusFail indicates that the check failed
+	StatusFail Status = "fail"
+)
+
+// Kind specifies the probes the check is included in
+type Kind int
+
+const (
+	// Readiness check reports if the service can serve the requests,
+	// the Load Balancer should not send requests to the service if it fails
+	Readiness Kind = 1 << iota
+	// Liveness check reports if the process is healthy,
+	// the process should be restarted if it fails
+	Liveness
+
+	// All specifies all the checks
+	All = Readiness | Liveness
+)
+
+// CheckFunc performs the check, and returns its status with optional details.
+// If error is returned, then the check is reported as failed, unless StatusWarn is returned.
+type CheckFunc func(ctx context.Context) (Status, map[string]interface{}, error)
+
+// Check specifies a named health check
+type Check struct {
+	// Name of the check, must be unique
+	Name string
+	// Kind specifies the probes the check is included in,
+	// if not specified, then Readiness
+	Kind Kind
+	// Timeout specifies the time allowed for the check to complete,
+	// if not specified, then DefaultTimeout
+	Timeout time.Duration
+	// Func performs the check
+	Func CheckFunc
+}
+
+// Result of the check
+type Result struct {
+	Name    string                 `json:"name"`
+	Status  Status                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	// Latency of the check in nanoseconds
+	Latency   time.Duration `json:"latency"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// AggregateStatus returns StatusFail if any of the checks failed,
+// StatusWarn if any of the checks has warning, or StatusPass otherwise
+func AggregateStatus(results []*Result) Status {
+	status := StatusPass
+	for _, r := range results {
+		switch r.Status {
+		case StatusFail:
+			return StatusFail
+		case StatusWarn:
+			status = StatusWarn
+		}
+	}
+	return status
+}
+
+// Registry runs the registered checks and caches their results
+type Registry struct {
+	lock   sync.RWMutex
+	checks []*check
+	ttl    time.Duration
+}
+
+type check struct {
+	Check
+	// runLock serializes the check runs, so concurrent probes share the result
+	runLock sync.Mutex
+	// lock guards the cached result, so Expire does not wait for the running check
+	lock sync.Mutex
+	last *Result
+	// generation is incremented by Expire, to discard the result of the running check
+	generation uint64
+}
+
+// NewRegistry returns a registry, where the check results are cached
+// for the specified time to live
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl}
+}
+
+// Register adds the checks
+func (r *Registry) Register(checks ...Check) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, c := range checks {
+		if c.Name == "" {
+			return errors.New("invalid parameter: name")
+		}
+		if c.Func == nil {
+			return errors.New("invalid parameter: func")
+		}
+		for _, existing := range r.checks {
+			if existing.Name == c.Name {
+				return errors.Errorf("health check already registered: %s", c.Name)
+			}
+		}
+		if c.Kind == 0 {
+			c.Kind = Readiness
+		}
+		if c.Timeout <= 0 {
+			c.Timeout = DefaultTimeout
+		}
+		r.checks = append(r.checks, &check{Check: c})
+	}
+	return nil
+}
+
+// Names returns the names of the registered checks in registration order
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, len(r.checks))
+	for i, c := range r.checks {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Results returns the results of the checks of the specified kind,
+// the checks with expired results are run concurrently
+func (r *Registry) Results(ctx context.Context, kind Kind) []*Result {
+	return r.run(ctx, kind, false)
+}
+
+// RunAll runs all the checks, regardless of cached results
+func (r *Registry) RunAll(ctx context.Context) []*Result {
+	return r.run(ctx, All, true)
+}
+
+// Expire discards the cached results, so the checks are run on the next request
+func (r *Registry) Expire() {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, c := range r.checks {
+		c.expire()
+	}
+}
+
+// Schedule adds a task to the scheduler to run the checks at the specified interval
+func (r *Registry) Schedule(scheduler tasks.Scheduler, interval time.Duration) {
+	secs := uint64(interval / time.Second)
+	if secs == 0 {
+		secs = 1
+	}
+	task := tasks.NewTaskAtIntervals(secs, tasks.Seconds).
+		Do("health", func() { r.RunAll(context.Background()) })
+	scheduler.Add(task)
+}
+
+func (r *Registry) run(ctx context.Context, kind Kind, force bool) []*Result {
+	r.lock.RLock()
+	var list []*check
+	for _, c := range r.checks {
+		if c.Kind&kind != 0 {
+			list = append(list, c)
+		}
+	}
+	r.lock.RUnlock()
+
+	results := make([]*Result, len(list))
+	var wg sync.WaitGroup
+	for i, c := range list {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.result(ctx, r.ttl, force)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// result returns the cached result if not expired,
+// or runs the check
+func (c *check) result(ctx context.Context, ttl time.Duration, force bool) *Result {
+	started := time.Now()
+
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+
+	// the result may be updated while waiting for the lock
+	last, generation := c.cached()
+	if last != nil && (last.CheckedAt.After(started) || (!force && time.Since(last.CheckedAt) < ttl)) {
+		return last
+	}
+
+	res := c.run(ctx)
+	c.store(res, generation)
+	return res
+}
+
+// cached returns the cached result and its generation
+func (c *check) cached() (*Result, uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.last, c.generation
+}
+
+// store caches the result, unless the results were expired while the check was running
+func (c *check) store(res *Result, generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation == generation {
+		c.last = res
+	}
+}
+
+// expire discards the cached result
+func (c *check) expire() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.last = nil
+	c.generation++
+}
+
+// run performs the check with the timeout
+func (c *check) run(ctx context.Context) *Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	type response struct {
+		status  Status
+		details map[string]interface{}
+		err     error
+	}
+
+	started := time.Now()
+	done := make(chan response, 1)
+	go func() {
+		status, details, err := c.Func(ctx)
+		done <- response{status: status, details: details, err: err}
+	}()
+
+	var res response
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = errors.WithMessagef(ctx.Err(), "check not completed in %s", c.Timeout)
+	}
+
+	result := &Result{
+		Name:      c.Name,
+		Status:    res.status,
+		Details:   res.details,
+		Latency:   time.Since(started),
+		CheckedAt: started,
+	}
+	if res.err != nil {
+		result.Error = res.err.Error()
+		if result.Status == "" || res.status == StatusPass {
+			result.Status = StatusFail
+		}
+	} else if result.Status == "" {
+		result.Status = StatusPass
+	}
+
+	publish(result)
+	return result
+}
+
+// publish publishes the check status to metrics, and logs the failures
+func publish(r *Result) {
+	tag := metrics.Tag{Name: "check", Value: r.Name}
+
+	var value float32
+	if r.Status != StatusFail {
+		value = 1
+	}
+	metrics.SetGauge(keyForCheckStatus, value, tag)
+
+	switch r.Status {
+	case StatusFail:
+		metrics.IncrCounter(keyForCheckFailed, 1, tag)
+		logger.Errorf("check=%q, status=%s, latency=%s, err=[%s]", r.Name, r.Status, r.Latency, r.Error)
+	case StatusWarn:
+		logger.Warningf("check=%q, status=%s, latency=%s, err=[%s]", r.Name, r.Status, r.Latency, r.Error)
+	default:
+		logger.Tracef("check=%q, status=%s, latency=%s", r.Name, r.Status, r.Latency)
+	}
+}
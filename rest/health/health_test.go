@@ -0,0 +1,250 @@
+package health_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest/health"
+	"github.com/go-phorce/dolly/tasks"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(status health.Status, err error) (health.CheckFunc, *int32) {
+	var calls int32
+	return func(context.Context) (health.Status, map[string]interface{}, error) {
+		n := atomic.AddInt32(&calls, 1)
+		return status, map[string]interface{}{"calls": n}, err
+	}, &calls
+}
+
+func Test_Register(t *testing.T) {
+	r := health.NewRegistry(time.Minute)
+	f, _ := counter(health.StatusPass, nil)
+
+	assert.EqualError(t, r.Register(health.Check{Func: f}), "invalid parameter: name")
+	assert.EqualError(t, r.Register(health.Check{Name: "db"}), "invalid parameter: func")
+	require.NoError(t, r.Register(health.Check{Name: "db", Func: f}, health.Check{Name: "cache", Func: f}))
+	assert.EqualError(t, r.Register(health.Check{Name: "db", Func: f}), "health check already registered: db")
+	assert.Equal(t, []string{"db", "cache"}, r.Names())
+}
+
+func Test_Results(t *testing.T) {
+	ctx := context.Background()
+	r := health.NewRegistry(time.Minute)
+
+	pass, passCalls := counter(health.StatusPass, nil)
+	warn, _ := counter(health.StatusWarn, errors.New("slow"))
+	live, _ := counter("", nil)
+	failed, _ := counter("", errors.New("connection refused"))
+
+	require.NoError(t, r.Register(
+		health.Check{Name: "db", Func: pass},
+		health.Check{Name: "cache", Func: warn},
+		health.Check{Name: "goroutines", Kind: health.Liveness, Func: live},
+	))
+
+	res := r.Results(ctx, health.Readiness)
+	require.Len(t, res, 2)
+	assert.Equal(t, "cache", res[0].Name)
+	assert.Equal(t, health.StatusWarn, res[0].Status)
+	assert.Equal(t, "slow", res[0].Error)
+	assert.Equal(t, "db", res[1].Name)
+	assert.Equal(t, health.StatusPass, res[1].Status)
+	assert.Equal(t, int32(1), res[1].Details["calls"])
+	assert.False(t, res[1].CheckedAt.IsZero())
+	assert.Equal(t, health.StatusWarn, health.AggregateStatus(res))
+
+	res = r.Results(ctx, health.Liveness)
+	require.Len(t, res, 1)
+	assert.Equal(t, "goroutines", res[0].Name)
+	assert.Equal(t, health.StatusPass, res[0].Status)
+
+	assert.Len(t, r.Results(ctx, health.All), 3)
+
+	// cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(passCalls))
+	r.RunAll(ctx)
+	assert.Equal(t, int32(2), atomic.LoadInt32(passCalls))
+	r.Expire()
+	r.Results(ctx, health.Readiness)
+	assert.Equal(t, int32(3), atomic.LoadInt32(passCalls))
+
+	require.NoError(t, r.Register(health.Check{Name: "backend", Func: failed}))
+	res = r.Results(ctx, health.Readiness)
+	require.Len(t, res, 3)
+	assert.Equal(t, "backend", res[0].Name)
+	assert.Equal(t, health.StatusFail, res[0].Status)
+	assert.Equal(t, "connection refused", res[0].Error)
+	assert.Equal(t, health.StatusFail, health.AggregateStatus(res))
+}
+
+func Test_Expired(t *testing.T) {
+	ctx := context.Background()
+	r := health.NewRegistry(0)
+	f, calls := counter(health.StatusPass, nil)
+	require.NoError(t, r.Register(health.Check{Name: "db", Func: f}))
+
+	r.Results(ctx, health.All)
+	r.Results(ctx, health.All)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func Test_ExpireRunning(t *testing.T) {
+	ctx := context.Background()
+	r := health.NewRegistry(time.Minute)
+
+	var calls int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	require.NoError(t, r.Register(health.Check{
+		Name: "db",
+		Func: func(context.Context) (health.Status, map[string]interface{}, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				started <- struct{}{}
+				<-release
+			}
+			return health.StatusPass, nil, nil
+		},
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Results(ctx, health.All)
+	}()
+	<-started
+
+	// Expire does not wait for the running check
+	expired := make(chan struct{})
+	go func() {
+		r.Expire()
+		close(expired)
+	}()
+	select {
+	case <-expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expire is blocked by the running check")
+	}
+
+	close(release)
+	<-done
+
+	// the result of the check started before Expire is not cached
+	r.Results(ctx, health.All)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	r.Results(ctx, health.All)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_Timeout(t *testing.T) {
+	r := health.NewRegistry(time.Minute)
+	require.NoError(t, r.Register(health.Check{
+		Name:    "slow",
+		Timeout: 50 * time.Millisecond,
+		Func: func(ctx context.Context) (health.Status, map[string]interface{}, error) {
+			time.Sleep(time.Second)
+			return health.StatusPass, nil, nil
+		},
+	}))
+
+	started := time.Now()
+	res := r.Results(context.Background(), health.Readiness)
+	assert.True(t, time.Since(started) < time.Second)
+	require.Len(t, res, 1)
+	assert.Equal(t, health.StatusFail, res[0].Status)
+	assert.Contains(t, res[0].Error, "check not completed in 50ms")
+	assert.True(t, res[0].Latency >= 50*time.Millisecond)
+}
+
+func Test_Metrics(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("svc"), im)
+	require.NoError(t, err)
+
+	r := health.NewRegistry(time.Minute)
+	pass, _ := counter(health.StatusPass, nil)
+	failed, _ := counter(health.StatusFail, errors.New("failed"))
+	require.NoError(t, r.Register(
+		health.Check{Name: "db", Func: pass},
+		health.Check{Name: "backend", Func: failed},
+	))
+	r.RunAll(context.Background())
+
+	data := im.Data()
+	require.NotEmpty(t, data)
+
+	gauges := data[0].Gauges
+	require.Contains(t, gauges, "svc.health.check.status;check=db")
+	assert.Equal(t, float32(1), gauges["svc.health.check.status;check=db"].Value)
+	require.Contains(t, gauges, "svc.health.check.status;check=backend")
+	assert.Equal(t, float32(0), gauges["svc.health.check.status;check=backend"].Value)
+	assert.Contains(t, data[0].Counters, "svc.health.check.failed;check=backend")
+	assert.NotContains(t, data[0].Counters, "svc.health.check.failed;check=db")
+}
+
+func Test_Schedule(t *testing.T) {
+	r := health.NewRegistry(time.Minute)
+	f, calls := counter(health.StatusPass, nil)
+	require.NoError(t, r.Register(health.Check{Name: "db", Func: f}))
+
+	scheduler := tasks.NewScheduler()
+	r.Schedule(scheduler, 100*time.Millisecond)
+	require.Equal(t, 1, scheduler.Count())
+
+	require.NoError(t, scheduler.Start())
+	defer scheduler.Stop()
+
+	for i := 0; i < 50 && atomic.LoadInt32(calls) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NotEqual(t, int32(0), atomic.LoadInt32(calls))
+}
+
+func Test_Handler(t *testing.T) {
+	r := health.NewRegistry(time.Minute)
+	pass, _ := counter(health.StatusPass, nil)
+	failed, _ := counter("", errors.New("connection refused"))
+	require.NoError(t, r.Register(
+		health.Check{Name: "db", Func: pass, Kind: health.All},
+		health.Check{Name: "backend", Func: failed},
+	))
+
+	get := func(kind health.Kind, url string) (int, *health.Report) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		r.NewHandler(kind).ServeHTTP(w, req)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var report health.Report
+		require.NoError(t, marshal.Decode(w.Body, &report))
+		return w.Code, &report
+	}
+
+	code, report := get(health.Readiness, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, []string{"backend"}, report.Failed)
+	assert.Empty(t, report.Checks)
+
+	code, report = get(health.Readiness, "/readyz?verbose")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "backend", report.Checks[0].Name)
+	assert.Equal(t, "connection refused", report.Checks[0].Error)
+	assert.Equal(t, "db", report.Checks[1].Name)
+	assert.Equal(t, health.StatusPass, report.Checks[1].Status)
+
+	code, report = get(health.Liveness, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusPass, report.Status)
+	assert.Empty(t, report.Failed)
+}
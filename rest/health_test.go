@@ -0,0 +1,206 @@
+package rest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/rest/health"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probedService provides health checks
+type probedService struct {
+	ready int32
+}
+
+func (s *probedService) Name() string { return "probed" }
+func (s *probedService) Close()       {}
+
+func (s *probedService) IsReady() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+func (s *probedService) Register(r rest.Router) {
+	r.GET("/v1/probed", func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (s *probedService) HealthChecks() []health.Check {
+	return []health.Check{
+		{
+			Name: "db",
+			Func: func(context.Context) (health.Status, map[string]interface{}, error) {
+				return health.StatusWarn, map[string]interface{}{"conns": 1}, errors.New("pool is low")
+			},
+		},
+	}
+}
+
+func Test_HealthProbes(t *testing.T) {
+	probes := true
+	cfg := &serverConfig{
+		BindAddr:     getBindAddr("localhost"),
+		HealthProbes: &probes,
+	}
+
+	svc := &probedService{ready: 1}
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(auditor.NewInMemory()).
+		WithPreStopDelay(time.Second).
+		WithHealthChecks(health.Check{
+			Name: "disk",
+			Kind: health.Liveness,
+			Func: func(context.Context) (health.Status, map[string]interface{}, error) {
+				return health.StatusPass, nil, nil
+			},
+		})
+	server.AddService(svc)
+	assert.Nil(t, server.Health())
+
+	require.NoError(t, server.StartHTTP())
+	waitFor(t, server.IsReady)
+	require.NotNil(t, server.Health())
+
+	get := func(path string) (int, *health.Report) {
+		resp, err := http.Get(fmt.Sprintf("http://%s%s", cfg.BindAddr, path))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var report health.Report
+		require.NoError(t, marshal.Decode(resp.Body, &report))
+		return resp.StatusCode, &report
+	}
+	names := func(report *health.Report) []string {
+		var list []string
+		for _, r := range report.Checks {
+			list = append(list, r.Name)
+		}
+		return list
+	}
+
+	code, report := get(rest.HealthzPath + "?verbose")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusWarn, report.Status)
+	assert.Equal(t, []string{"disk", "ping", "probed", "probed/db", "server"}, names(report))
+	assert.Equal(t, "pool is low", report.Checks[3].Error)
+
+	code, report = get(rest.ReadyzPath + "?verbose")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"probed", "probed/db", "server"}, names(report))
+
+	code, report = get(rest.LivezPath + "?verbose")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusPass, report.Status)
+	assert.Equal(t, []string{"disk", "ping"}, names(report))
+
+	// not ready
+	atomic.StoreInt32(&svc.ready, 0)
+	server.Health().Expire()
+
+	code, report = get(rest.ReadyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, []string{"probed"}, report.Failed)
+	assert.Empty(t, report.Checks)
+
+	code, _ = get(rest.LivezPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/v1/probed", cfg.BindAddr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	atomic.StoreInt32(&svc.ready, 1)
+	server.Health().Expire()
+	code, _ = get(rest.ReadyzPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	// readiness reports the shutdown
+	stopped := make(chan struct{})
+	go func() {
+		server.StopHTTP()
+		close(stopped)
+	}()
+	<-server.Stopping()
+
+	code, report = get(rest.ReadyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"server"}, report.Failed)
+
+	code, _ = get(rest.LivezPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	<-stopped
+}
+
+func Test_HealthDuplicateCheck(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.WithHealthChecks(health.Check{
+		Name: "ping",
+		Func: func(context.Context) (health.Status, map[string]interface{}, error) {
+			return health.StatusPass, nil, nil
+		},
+	})
+	assert.Panics(t, func() { server.NewMux() })
+}
+
+// livezService registers its own liveness probe
+type livezService struct{}
+
+func (s *livezService) Name() string  { return "livez" }
+func (s *livezService) IsReady() bool { return true }
+func (s *livezService) Close()        {}
+
+func (s *livezService) Register(r rest.Router) {
+	r.GET(rest.LivezPath, func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		w.Write([]byte("custom"))
+	})
+}
+
+func Test_HealthProbesConfig(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr: getBindAddr(""),
+	}
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.AddService(&livezService{})
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	call := func(handler http.Handler, uri string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, uri, nil)
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// disabled by default
+	handler := server.NewMux()
+	assert.NotNil(t, server.Health())
+	assert.Equal(t, http.StatusNotFound, call(handler, rest.HealthzPath).Code)
+	assert.Equal(t, "custom", call(handler, rest.LivezPath).Body.String())
+
+	// the route registered by the service is kept
+	probes := true
+	cfg.HealthProbes = &probes
+	require.NotPanics(t, func() {
+		handler = server.NewMux()
+	})
+	// the server is not serving
+	assert.Equal(t, http.StatusServiceUnavailable, call(handler, rest.ReadyzPath).Code)
+	assert.Equal(t, "custom", call(handler, rest.LivezPath).Body.String())
+}
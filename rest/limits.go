@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-phorce/dolly/audit"
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/metrics/tags"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+)
+
+var keyForHTTPReqTooLarge = []string{"http", "request", "too_large"}
+
+// MaxRequestSizeFor returns the maximum size of the request body in bytes
+// for the given request path, as configured by MaxRequestSize and RouteRequestSizes
+func (server *HTTPServer) MaxRequestSizeFor(path string) int64 {
+	limit := server.httpConfig.GetMaxRequestSize()
+	if limit <= 0 {
+		limit = MaxRequestSize
+	}
+
+	matched := -1
+	for prefix, size := range server.httpConfig.GetRouteRequestSizes() {
+		if len(prefix) > matched && strings.HasPrefix(path, prefix) {
+			matched = len(prefix)
+			limit = size
+		}
+	}
+	return limit
+}
+
+// requestSizeMiddleware rejects requests with the body larger than allowed
+func (server *HTTPServer) requestSizeMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := server.MaxRequestSizeFor(r.URL.Path)
+		// gRPC message size is limited by grpc.MaxRecvMsgSize server option
+		if limit <= 0 || r.Body == nil || r.Body == http.NoBody || IsGRPCRequest(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			server.requestTooLarge(r, limit)
+			marshal.WriteJSON(w, r, httperror.WithRequestTooLarge("request body exceeds %d bytes", limit))
+			return
+		}
+
+		// the decompressed body is limited by marshal.DecodeBody
+		r = marshal.WithMaxBodySize(r, limit)
+		r.Body = &limitedBody{
+			ReadCloser: r.Body,
+			remaining:  limit,
+			limit:      limit,
+			onExceeded: func() { server.requestTooLarge(r, limit) },
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requestTooLarge audits and publishes metrics for rejected request
+func (server *HTTPServer) requestTooLarge(r *http.Request, limit int64) {
+	idn := identity.FromRequest(r)
+	role := idn.Identity().Role()
+
+	metrics.IncrCounter(keyForHTTPReqTooLarge, 1,
+		metrics.Tag{Name: tags.Method, Value: r.Method},
+		metrics.Tag{Name: tags.Role, Value: role},
+		metrics.Tag{Name: tags.URI, Value: server.metricsURI(r)},
+	)
+
+	server.Audit(
+		EvtSourceHTTP,
+		EvtRequestTooLarge,
+		idn.Identity().String(),
+		idn.CorrelationID(),
+		0,
+		audit.ContextMessage(r.Context(),
+			fmt.Sprintf("method=%s, path=%q, content_length=%d, limit=%d",
+				r.Method, r.URL.Path, r.ContentLength, limit)),
+	)
+}
+
+// metricsURI returns the URI label of the request, consistent with the request metrics:
+// the route template, or the path normalized by WithMetricsPathNormalizer option
+func (server *HTTPServer) metricsURI(r *http.Request) string {
+	return xhttp.RequestMetricsURI(r, server.requestMetricsOptions()...)
+}
+
+// limitedBody is the request body that fails with httperror.WithRequestTooLarge
+// when more than limit bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining  int64
+	limit      int64
+	onExceeded func()
+	err        error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one byte more than allowed to detect the overflow
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = httperror.WithRequestTooLarge("request body exceeds %d bytes", b.limit)
+	b.onExceeded()
+	return n, b.err
+}
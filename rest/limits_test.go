@@ -0,0 +1,196 @@
+package rest_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoService struct{}
+
+func (s *echoService) Name() string  { return "echo" }
+func (s *echoService) IsReady() bool { return true }
+func (s *echoService) Close()        {}
+
+func (s *echoService) Register(r rest.Router) {
+	echo := func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		var req map[string]string
+		if err := marshal.DecodeBody(w, r, &req); err != nil {
+			return
+		}
+		marshal.WriteJSON(w, r, req)
+	}
+	r.POST("/v1/echo", echo)
+	r.POST("/v1/upload/echo", echo)
+}
+
+func Test_MaxRequestSizeFor(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr: getBindAddr(""),
+	}
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(rest.MaxRequestSize), server.MaxRequestSizeFor("/v1/echo"))
+
+	cfg.MaxRequestSize = 100
+	cfg.RouteRequestSizes = map[string]int64{
+		"/v1/upload":      1000,
+		"/v1/upload/echo": 10,
+	}
+	assert.Equal(t, int64(100), server.MaxRequestSizeFor("/v1/echo"))
+	assert.Equal(t, int64(1000), server.MaxRequestSizeFor("/v1/upload"))
+	assert.Equal(t, int64(1000), server.MaxRequestSizeFor("/v1/upload/file"))
+	assert.Equal(t, int64(10), server.MaxRequestSizeFor("/v1/upload/echo"))
+}
+
+func Test_RequestSizeLimit(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr:       getBindAddr(""),
+		MaxRequestSize: 32,
+		RouteRequestSizes: map[string]int64{
+			"/v1/upload": 1024,
+		},
+	}
+	audit := auditor.NewInMemory()
+
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(audit)
+	server.AddService(&echoService{})
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	handler := server.NewMux()
+
+	small := `{"a":"b"}`
+	large := fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("b", 100))
+
+	t.Run("allowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPost, "/v1/echo", strings.NewReader(small))
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, small, w.Body.String())
+	})
+
+	t.Run("content_length", func(t *testing.T) {
+		audit.Reset()
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPost, "/v1/echo", strings.NewReader(large))
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"code":"request_too_large","message":"request body exceeds 32 bytes"}`, w.Body.String())
+
+		e := audit.Find(rest.EvtSourceHTTP, rest.EvtRequestTooLarge)
+		require.NotNil(t, e)
+		assert.Contains(t, e.Message, `path="/v1/echo"`)
+		assert.Contains(t, e.Message, "limit=32")
+	})
+
+	t.Run("streamed", func(t *testing.T) {
+		audit.Reset()
+		w := httptest.NewRecorder()
+		// unknown content length
+		r, err := http.NewRequest(http.MethodPost, "/v1/echo", ioutil.NopCloser(bytes.NewReader([]byte(large))))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), r.ContentLength)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"code":"request_too_large","message":"request body exceeds 32 bytes"}`, w.Body.String())
+		assert.Equal(t, 1, audit.Len())
+		assert.NotNil(t, audit.Find(rest.EvtSourceHTTP, rest.EvtRequestTooLarge))
+	})
+
+	t.Run("route", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPost, "/v1/upload/echo", strings.NewReader(large))
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("exact", func(t *testing.T) {
+		exact := fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("b", 32-8))
+		require.Len(t, exact, 32)
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPost, "/v1/echo", ioutil.NopCloser(strings.NewReader(exact)))
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, exact, w.Body.String())
+	})
+
+	t.Run("decompressed", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw, err := negotiate.NewCompressor(&buf, negotiate.Gzip)
+		require.NoError(t, err)
+		_, err = zw.Write([]byte(fmt.Sprintf(`{"a":"%s"}`, strings.Repeat("b", 10000))))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.True(t, buf.Len() <= 1024)
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPost, "/v1/upload/echo", &buf)
+		require.NoError(t, err)
+		r.Header.Set(header.ContentEncoding, negotiate.Gzip)
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"code":"request_too_large","message":"decompressed request body exceeds 1024 bytes"}`, w.Body.String())
+	})
+}
+
+func Test_ServerTimeouts(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr:          getBindAddr(""),
+		ReadHeaderTimeout: time.Second,
+		ReadTimeout:       2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		MaxHeaderBytes:    4096,
+		MaxRequestSize:    16,
+	}
+
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(auditor.NewInMemory())
+	server.AddService(&echoService{})
+
+	err = server.StartHTTP()
+	require.NoError(t, err)
+	defer server.StopHTTP()
+
+	for i := 0; i < 30 && !server.IsReady(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.True(t, server.IsReady())
+
+	url := fmt.Sprintf("http://localhost:%s/v1/echo", server.Port())
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"a":"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the header is larger than allowed
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Large", strings.Repeat("x", 8192))
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp2.StatusCode)
+}
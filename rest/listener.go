@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/netutil"
+	"github.com/pkg/errors"
+)
+
+// Listener schemes supported by ListenURLs
+const (
+	// ListenHTTP specifies plaintext TCP listener: http://host:port
+	ListenHTTP = "http"
+	// ListenHTTPS specifies TLS TCP listener: https://host:port
+	ListenHTTPS = "https"
+	// ListenUnix specifies unix domain socket listener: unix:///path/to/socket
+	ListenUnix = "unix"
+	// ListenSystemd specifies listeners passed by systemd socket activation:
+	// systemd:// for all passed sockets, or systemd://name for the named socket
+	ListenSystemd = "systemd"
+)
+
+// serverListener is the listener with its reported URL
+type serverListener struct {
+	net.Listener
+	url *url.URL
+	// systemd specifies that the listener is owned by systemd socket activation,
+	// and must not be closed when the server fails to start
+	systemd bool
+}
+
+// listen opens the listeners as configured by ListenURLs,
+// or the default listener on BindAddr
+func (server *HTTPServer) listen() ([]*serverListener, error) {
+	urls := server.httpConfig.GetListenURLs()
+	if len(urls) == 0 {
+		bindAddr := server.httpConfig.GetBindAddr()
+		if _, err := net.ResolveTCPAddr("tcp", bindAddr); err != nil {
+			return nil, errors.WithMessagef(err, "reason=ResolveTCPAddr, service=%s, bind=%q",
+				server.Name(), bindAddr)
+		}
+		urls = []string{server.Protocol() + "://" + bindAddr}
+	}
+
+	var list []*serverListener
+	closeAll := func() {
+		for _, l := range list {
+			if !l.systemd {
+				l.Close()
+			}
+		}
+	}
+
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			closeAll()
+			return nil, errors.WithMessagef(err, "reason=invalid_listen_url, service=%s, url=%q",
+				server.Name(), s)
+		}
+
+		ls, err := server.openListeners(u)
+		if err != nil {
+			closeAll()
+			return nil, errors.WithMessagef(err, "reason=unable_listen, service=%s, url=%q",
+				server.Name(), s)
+		}
+		list = append(list, ls...)
+	}
+
+	return list, nil
+}
+
+func (server *HTTPServer) openListeners(u *url.URL) ([]*serverListener, error) {
+	useTLS := u.Query().Get("tls") == "true"
+	if u.Scheme == ListenHTTP || u.Scheme == ListenHTTPS {
+		useTLS = u.Scheme == ListenHTTPS
+	}
+	if useTLS && server.tlsConfig == nil {
+		return nil, errors.New("TLS is not configured")
+	}
+
+	var list []net.Listener
+	switch u.Scheme {
+	case ListenHTTP, ListenHTTPS:
+		l, err := net.Listen("tcp", u.Host)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		list = append(list, l)
+	case ListenUnix:
+		path := u.Path
+		if u.Host != "" {
+			// relative path: unix://name.sock
+			path = u.Host + path
+		}
+		if path == "" {
+			return nil, errors.New("missing socket path")
+		}
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		list = append(list, l)
+	case ListenSystemd:
+		sl, err := netutil.SystemdListeners()
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range sl {
+			if u.Host == "" || u.Host == l.Name {
+				list = append(list, l)
+			}
+		}
+		if len(list) == 0 {
+			return nil, errors.Errorf("systemd socket not found: %q", u.Host)
+		}
+	default:
+		return nil, errors.Errorf("unsupported scheme: %q", u.Scheme)
+	}
+
+	res := make([]*serverListener, len(list))
+	for i, l := range list {
+		res[i] = &serverListener{
+			Listener: l,
+			url:      listenerURL(l.Addr(), useTLS),
+			systemd:  u.Scheme == ListenSystemd,
+		}
+		if useTLS {
+			res[i].Listener = tls.NewListener(l, server.httpServer.TLSConfig)
+		}
+	}
+	return res, nil
+}
+
+// removeStaleSocket removes the socket file left by the process that is not running,
+// the socket that accepts connections is not removed
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		// net.Listen reports the error, if the path is not available
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return errors.Errorf("socket is in use: %s", path)
+	}
+	return errors.WithStack(os.Remove(path))
+}
+
+// listenerURL returns URL for the bound address
+func listenerURL(addr net.Addr, useTLS bool) *url.URL {
+	if addr.Network() == "unix" {
+		u := &url.URL{Scheme: ListenUnix, Path: addr.String()}
+		if useTLS {
+			u.RawQuery = "tls=true"
+		}
+		return u
+	}
+	scheme := ListenHTTP
+	if useTLS {
+		scheme = ListenHTTPS
+	}
+	return &url.URL{Scheme: scheme, Host: addr.String()}
+}
+
+// ListenURLs returns the URLs of the listeners the server is bound to
+func (server *HTTPServer) ListenURLs() []*url.URL {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+
+	list := make([]*url.URL, len(server.listeners))
+	for i, l := range server.listeners {
+		list[i] = l.url
+	}
+	return list
+}
+
+// listenURLsString returns the list of listeners for the audit
+func listenURLsString(list []*serverListener) string {
+	urls := make([]string, len(list))
+	for i, l := range list {
+		urls[i] = l.url.String()
+	}
+	return strings.Join(urls, ",")
+}
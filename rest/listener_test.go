@@ -0,0 +1,210 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(crt)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, pool
+}
+
+func Test_ServerListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listeners")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "dolly.sock")
+	// stale socket is replaced
+	stale, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	tlsCfg, pool := selfSignedTLS(t)
+
+	cfg := &serverConfig{
+		ServiceName: "listeners",
+		BindAddr:    getBindAddr(""),
+		ListenURLs: []string{
+			"https://127.0.0.1:0",
+			"http://127.0.0.1:0",
+			"unix://" + sock,
+		},
+	}
+
+	server, err := rest.New("v1.0.123", "", cfg, tlsCfg)
+	require.NoError(t, err)
+	audit := auditor.NewInMemory()
+	server.WithAuditor(audit)
+	server.AddService(NewService(server))
+
+	assert.Empty(t, server.ListenURLs())
+
+	err = server.StartHTTP()
+	require.NoError(t, err)
+	defer server.StopHTTP()
+
+	for i := 0; i < 30 && !server.IsReady(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.True(t, server.IsReady())
+
+	urls := server.ListenURLs()
+	require.Len(t, urls, 3)
+	assert.Equal(t, "https", urls[0].Scheme)
+	assert.NotEqual(t, "127.0.0.1:0", urls[0].Host)
+	assert.Equal(t, "http", urls[1].Scheme)
+	assert.Equal(t, "unix://"+sock, urls[2].String())
+
+	e := audit.Find(rest.EvtSourceStatus, rest.EvtServiceStarted)
+	require.NotNil(t, e)
+	assert.Contains(t, e.Message, "listeners="+urls[0].String()+","+urls[1].String())
+
+	t.Run("https", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		}
+		resp, err := client.Get(urls[0].String() + testURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		resp, err := http.Get(urls[1].String() + testURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("unix", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+				},
+			},
+		}
+		resp, err := client.Get("http://unix" + testURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func Test_ServerListenersErrors(t *testing.T) {
+	tcs := []struct {
+		urls []string
+		err  string
+	}{
+		{
+			urls: []string{"https://127.0.0.1:0"},
+			err:  `reason=unable_listen, service=invalid, url="https://127.0.0.1:0": TLS is not configured`,
+		},
+		{
+			urls: []string{"http://127.0.0.1:0", "ftp://127.0.0.1:0"},
+			err:  `reason=unable_listen, service=invalid, url="ftp://127.0.0.1:0": unsupported scheme: "ftp"`,
+		},
+		{
+			urls: []string{"unix://"},
+			err:  `reason=unable_listen, service=invalid, url="unix://": missing socket path`,
+		},
+		{
+			urls: []string{"systemd://api"},
+			err:  `reason=unable_listen, service=invalid, url="systemd://api": systemd socket not found: "api"`,
+		},
+		{
+			urls: []string{"http://%zz"},
+			err:  `reason=invalid_listen_url, service=invalid, url="http://%zz": parse "http://%zz": invalid URL escape "%zz"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		cfg := &serverConfig{
+			ServiceName: "invalid",
+			BindAddr:    getBindAddr(""),
+			ListenURLs:  tc.urls,
+		}
+		server, err := rest.New("v1.0.123", "", cfg, nil)
+		require.NoError(t, err)
+
+		err = server.StartHTTP()
+		require.Error(t, err)
+		assert.Equal(t, tc.err, err.Error())
+		assert.Empty(t, server.ListenURLs())
+	}
+}
+
+func Test_ServerListenerSocketInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listeners")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "dolly.sock")
+	active, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer active.Close()
+
+	cfg := &serverConfig{
+		ServiceName: "inuse",
+		BindAddr:    getBindAddr(""),
+		ListenURLs:  []string{"unix://" + sock},
+	}
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+
+	err = server.StartHTTP()
+	require.Error(t, err)
+	assert.Equal(t, `reason=unable_listen, service=inuse, url="unix://`+sock+`": socket is in use: `+sock, err.Error())
+
+	// the socket of the running process is not removed
+	_, err = os.Stat(sock)
+	assert.NoError(t, err)
+	conn, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+	conn.Close()
+}
@@ -0,0 +1,279 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-phorce/dolly/rest/ready"
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/pkg/errors"
+)
+
+// Middleware wraps http.Handler with additional processing
+type Middleware func(http.Handler) http.Handler
+
+// Names of the built-in middleware stages,
+// in the order they are applied to the router's handler.
+// The request path is rewritten to the API version negotiated by Accept header
+// before the outermost stage, so all the stages see the versioned path.
+const (
+	// MiddlewareReady rejects requests until the server and its services are ready
+	MiddlewareReady = "ready"
+	// MiddlewareAuthz enforces the authorization, if configured with WithAuthz
+	MiddlewareAuthz = "authz"
+	// MiddlewareRequestSize rejects requests with the body larger than allowed
+	MiddlewareRequestSize = "request_size"
+	// MiddlewareRequestLogger logs the requests
+	MiddlewareRequestLogger = "logger"
+	// MiddlewareMetrics publishes the request metrics
+	MiddlewareMetrics = "metrics"
+	// MiddlewareIdentity sets the caller's identity on the request context
+	MiddlewareIdentity = "identity"
+	// MiddlewareTracing starts the server span for each request,
+	// continuing the trace from W3C traceparent header
+	MiddlewareTracing = "tracing"
+	// MiddlewareInFlight tracks the requests being processed
+	MiddlewareInFlight = "inflight"
+	// MiddlewareRateLimit limits the rate of the requests, if configured with WithRateLimiter
+	MiddlewareRateLimit = "ratelimit"
+)
+
+type positionKind int
+
+const (
+	positionLast positionKind = iota
+	positionFirst
+	positionBefore
+	positionAfter
+	positionReplace
+)
+
+// Position specifies where the middleware is placed in the pipeline.
+//
+// The pipeline is ordered from the innermost stage, which wraps the router,
+// to the outermost stage, which receives the request first.
+type Position struct {
+	kind   positionKind
+	anchor string
+}
+
+var (
+	// First places the middleware as the innermost stage, right after the router
+	First = Position{kind: positionFirst}
+	// Last places the middleware as the outermost stage
+	Last = Position{kind: positionLast}
+)
+
+// Before places the middleware before the named stage,
+// that is closer to the router
+func Before(name string) Position {
+	return Position{kind: positionBefore, anchor: name}
+}
+
+// After places the middleware after the named stage,
+// that is further from the router
+func After(name string) Position {
+	return Position{kind: positionAfter, anchor: name}
+}
+
+// Replace replaces the named stage with the middleware
+func Replace(name string) Position {
+	return Position{kind: positionReplace, anchor: name}
+}
+
+// String returns the position description
+func (p Position) String() string {
+	switch p.kind {
+	case positionFirst:
+		return "first"
+	case positionBefore:
+		return "before:" + p.anchor
+	case positionAfter:
+		return "after:" + p.anchor
+	case positionReplace:
+		return "replace:" + p.anchor
+	default:
+		return "last"
+	}
+}
+
+type namedMiddleware struct {
+	name string
+	mw   Middleware
+}
+
+// Use registers the named middleware in the server's pipeline at the given position.
+// The middleware takes effect on the next call to NewMux.
+// The name must be unique, unless Replace position is used with the same name.
+func (server *HTTPServer) Use(name string, pos Position, mw Middleware) error {
+	if name == "" {
+		return errors.New("invalid parameter: name")
+	}
+	if mw == nil {
+		return errors.New("invalid parameter: middleware")
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	idx := server.middlewareIndex(name)
+	if idx >= 0 && !(pos.kind == positionReplace && pos.anchor == name) {
+		return errors.Errorf("middleware already registered: %s", name)
+	}
+
+	stage := namedMiddleware{name: name, mw: mw}
+
+	switch pos.kind {
+	case positionFirst:
+		server.middleware = append([]namedMiddleware{stage}, server.middleware...)
+	case positionLast:
+		server.middleware = append(server.middleware, stage)
+	default:
+		anchor := server.middlewareIndex(pos.anchor)
+		if anchor < 0 {
+			return errors.Errorf("middleware not found: %s", pos.anchor)
+		}
+		switch pos.kind {
+		case positionReplace:
+			server.middleware[anchor] = stage
+		case positionAfter:
+			anchor++
+			fallthrough
+		case positionBefore:
+			server.middleware = append(server.middleware, namedMiddleware{})
+			copy(server.middleware[anchor+1:], server.middleware[anchor:])
+			server.middleware[anchor] = stage
+		}
+	}
+
+	logger.Debugf("service=%s, middleware=%s, position=%s",
+		server.Name(), name, pos)
+	return nil
+}
+
+// RemoveMiddleware removes the named middleware from the server's pipeline,
+// and returns false if the middleware is not registered.
+func (server *HTTPServer) RemoveMiddleware(name string) bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	idx := server.middlewareIndex(name)
+	if idx < 0 {
+		return false
+	}
+	server.middleware = append(server.middleware[:idx], server.middleware[idx+1:]...)
+	return true
+}
+
+// Middleware returns the names of the registered middleware,
+// ordered from the innermost to the outermost stage
+func (server *HTTPServer) Middleware() []string {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	names := make([]string, len(server.middleware))
+	for i, m := range server.middleware {
+		names[i] = m.name
+	}
+	return names
+}
+
+func (server *HTTPServer) middlewareIndex(name string) int {
+	for i, m := range server.middleware {
+		if m.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyMiddleware wraps the handler with the registered middleware
+func (server *HTTPServer) applyMiddleware(handler http.Handler) http.Handler {
+	server.lock.Lock()
+	pipeline := make([]namedMiddleware, len(server.middleware))
+	copy(pipeline, server.middleware)
+	server.lock.Unlock()
+
+	for _, m := range pipeline {
+		handler = m.mw(handler)
+	}
+	return handler
+}
+
+// registerDefaultMiddleware registers the built-in stages
+func (server *HTTPServer) registerDefaultMiddleware() {
+	server.middleware = []namedMiddleware{
+		{name: MiddlewareReady, mw: server.readyMiddleware},
+		{name: MiddlewareAuthz, mw: server.authzMiddleware},
+		{name: MiddlewareRequestSize, mw: server.requestSizeMiddleware},
+		{name: MiddlewareRequestLogger, mw: server.requestLoggerMiddleware},
+		{name: MiddlewareMetrics, mw: server.requestMetricsMiddleware},
+		{name: MiddlewareIdentity, mw: server.identityMiddleware},
+		{name: MiddlewareTracing, mw: server.tracingMiddleware},
+		{name: MiddlewareInFlight, mw: server.inflightMiddleware},
+	}
+}
+
+// WithRateLimiter adds the rate limiter stage after the authorization stage,
+// so the requests are throttled before they are authorized,
+// for example with ratelimit.Limiter.Handler.
+// The health probes are not throttled.
+func (server *HTTPServer) WithRateLimiter(rateLimiter Middleware) *HTTPServer {
+	limiter := func(handler http.Handler) http.Handler {
+		return server.bypassHealth(handler, rateLimiter(handler))
+	}
+	server.RemoveMiddleware(MiddlewareRateLimit)
+	err := server.Use(MiddlewareRateLimit, After(MiddlewareAuthz), limiter)
+	if err != nil {
+		// the authz stage is removed, the identity is still required
+		err = server.Use(MiddlewareRateLimit, Before(MiddlewareIdentity), limiter)
+	}
+	if err != nil {
+		logger.Errorf("reason=rate_limiter, err=[%+v]", err)
+	}
+	return server
+}
+
+func (server *HTTPServer) readyMiddleware(handler http.Handler) http.Handler {
+	verifier := ready.NewServiceStatusVerifier(servingStatus{server: server}, handler)
+	// health probes report the status
+	return bypassGRPC(handler, server.bypassHealth(handler, verifier))
+}
+
+func (server *HTTPServer) authzMiddleware(handler http.Handler) http.Handler {
+	if server.authz == nil {
+		return handler
+	}
+	authzHandler, err := server.authz.NewHandler(handler)
+	if err != nil {
+		panic(fmt.Sprintf("%+v", err))
+	}
+	return bypassGRPC(handler, server.bypassHealth(handler, authzHandler))
+}
+
+func (server *HTTPServer) tracingMiddleware(handler http.Handler) http.Handler {
+	return tracing.NewHTTPHandler(handler, tracing.WithRoute(RouteTemplate))
+}
+
+func (server *HTTPServer) requestMetricsMiddleware(handler http.Handler) http.Handler {
+	return xhttp.NewRequestMetrics(handler, server.requestMetricsOptions()...)
+}
+
+func (server *HTTPServer) requestMetricsOptions() []xhttp.RequestMetricsOption {
+	return append([]xhttp.RequestMetricsOption{xhttp.WithMetricsRoute(RouteTemplate)}, server.metricsOptions...)
+}
+
+func (server *HTTPServer) requestLoggerMiddleware(handler http.Handler) http.Handler {
+	opts := append([]xhttp.RequestLoggerOption{xhttp.WithLoggerRoute(RouteTemplate)}, server.loggerOptions...)
+	return xhttp.NewRequestLogger(handler, server.Name(), serverExtraLogger, time.Millisecond, server.httpConfig.GetPackageLogger(), opts...)
+}
+
+func (server *HTTPServer) identityMiddleware(handler http.Handler) http.Handler {
+	if server.identityMapper != nil {
+		return identity.NewContextHandler(handler, server.identityMapper)
+	}
+	return identity.NewContextHandler(handler, identity.GuestIdentityMapper)
+}
@@ -0,0 +1,381 @@
+package rest_test
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xhttp/ratelimit"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tracer returns middleware that appends the name to the X-Trace response header
+func tracer(name string) rest.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		rest.MiddlewareReady,
+		rest.MiddlewareAuthz,
+		rest.MiddlewareRequestSize,
+		rest.MiddlewareRequestLogger,
+		rest.MiddlewareMetrics,
+		rest.MiddlewareIdentity,
+		rest.MiddlewareTracing,
+		rest.MiddlewareInFlight,
+	}, server.Middleware())
+
+	noop := func(h http.Handler) http.Handler { return h }
+
+	assert.EqualError(t, server.Use("", rest.Last, noop), "invalid parameter: name")
+	assert.EqualError(t, server.Use("noop", rest.Last, nil), "invalid parameter: middleware")
+	assert.EqualError(t, server.Use(rest.MiddlewareAuthz, rest.Last, noop), "middleware already registered: authz")
+	assert.EqualError(t, server.Use("noop", rest.Before("missing"), noop), "middleware not found: missing")
+	assert.EqualError(t, server.Use("noop", rest.Replace("missing"), noop), "middleware not found: missing")
+
+	require.NoError(t, server.Use("first", rest.First, tracer("first")))
+	require.NoError(t, server.Use("last", rest.Last, tracer("last")))
+	require.NoError(t, server.Use("before_identity", rest.Before(rest.MiddlewareIdentity), tracer("before_identity")))
+	require.NoError(t, server.Use("after_ready", rest.After(rest.MiddlewareReady), tracer("after_ready")))
+	require.NoError(t, server.Use("authz2", rest.Replace(rest.MiddlewareAuthz), noop))
+	require.NoError(t, server.Use("last", rest.Replace("last"), tracer("last2")))
+	assert.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+	assert.False(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	assert.Equal(t, []string{
+		"first",
+		"after_ready",
+		"authz2",
+		rest.MiddlewareRequestSize,
+		rest.MiddlewareRequestLogger,
+		rest.MiddlewareMetrics,
+		"before_identity",
+		rest.MiddlewareIdentity,
+		rest.MiddlewareTracing,
+		rest.MiddlewareInFlight,
+		"last",
+	}, server.Middleware())
+
+	assert.Equal(t, "last", rest.Last.String())
+	assert.Equal(t, "first", rest.First.String())
+	assert.Equal(t, "before:a", rest.Before("a").String())
+	assert.Equal(t, "after:a", rest.After("a").String())
+	assert.Equal(t, "replace:a", rest.Replace("a").String())
+
+	server.AddService(NewService(server))
+	handler := server.NewMux()
+
+	// the ready stage is removed, the request is served before the server is started
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, testURL, nil)
+	require.NoError(t, err)
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the outermost stage receives the request first
+	assert.Equal(t, "last2,before_identity,after_ready,first",
+		strings.Join(w.Header().Values("X-Trace"), ","))
+}
+
+func Test_MiddlewareDefault(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.AddService(NewService(server))
+
+	var id identity.Identity
+	require.NoError(t, server.Use("capture", rest.Before(rest.MiddlewareReady), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = identity.FromRequest(r).Identity()
+			next.ServeHTTP(w, r)
+		})
+	}))
+
+	handler := server.NewMux()
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, testURL, nil)
+	require.NoError(t, err)
+	handler.ServeHTTP(w, r)
+	// the server is not started
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Nil(t, id)
+
+	// without the ready stage the inner middleware sees the identity
+	assert.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+	handler = server.NewMux()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, id)
+	assert.Equal(t, identity.GuestRoleName, id.Role())
+}
+
+func Test_MiddlewareRequestLogger(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.WithRequestLoggerOptions(
+		xhttp.WithLoggerFormat(xhttp.LogFormatKV),
+		xhttp.WithLoggerFields(xhttp.LogFieldPath, xhttp.LogFieldRoute, xhttp.LogFieldStatus),
+	)
+	server.AddService(NewService(server))
+	assert.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+	handler := server.NewMux()
+
+	tw := bytes.Buffer{}
+	writer := bufio.NewWriter(&tw)
+	xlog.SetFormatter(xlog.NewPrettyFormatter(writer, false))
+	defer xlog.SetFormatter(xlog.NewPrettyFormatter(os.Stderr, false))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, testURL, nil)
+	require.NoError(t, err)
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, tw.String(), `path="/v1/test", route="/v1/test", status=200`)
+
+	tw.Reset()
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, "/v1/missing", nil)
+	require.NoError(t, err)
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, tw.String(), `path="/v1/missing", route="", status=404`)
+}
+
+func Test_MiddlewareRequestMetrics(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("rest"), im)
+	require.NoError(t, err)
+
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.WithRequestMetricsOptions(xhttp.WithMetricsPathNormalizer(xhttp.NormalizePathIDs))
+	server.AddService(NewService(server))
+	assert.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+	handler := server.NewMux()
+
+	for _, path := range []string{testURL, "/v1/missing/123"} {
+		r, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	data := im.Data()
+	assert.Equal(t, 1, data[0].Counters["rest.http.request.status.successful;method=GET;role=guest;status=200;uri=/v1/test"].Count)
+	// the path of the unmatched route is normalized
+	assert.Equal(t, 1, data[0].Counters["rest.http.request.status.failed;method=GET;role=guest;status=404;uri=/v1/missing/:id"].Count)
+}
+
+func Test_MiddlewareRequestMetricsUnmatched(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("rest"), im)
+	require.NoError(t, err)
+
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.AddService(NewService(server))
+	// the ready stage rejects the requests before routing
+	handler := server.NewMux()
+
+	for _, path := range []string{testURL, "/v1/missing/123", "/wp-login.php"} {
+		r, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	data := im.Data()
+	// the route is resolved before the middleware
+	assert.Equal(t, 1, data[0].Counters["rest.http.request.status.failed;method=GET;role=guest;status=503;uri=/v1/test"].Count)
+	// the paths of the unmatched routes are not published
+	assert.Equal(t, 2, data[0].Counters["rest.http.request.status.failed;method=GET;role=guest;status=503;uri=unmatched"].Count)
+}
+
+func Test_MiddlewareRateLimit(t *testing.T) {
+	probes := true
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr(""), HealthProbes: &probes}, nil)
+	require.NoError(t, err)
+	server.AddService(NewService(server))
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	limiter, err := ratelimit.New(nil, ratelimit.Policy{
+		Name:   "guest",
+		By:     ratelimit.KeyRole,
+		Limit:  1,
+		Period: time.Minute,
+	})
+	require.NoError(t, err)
+
+	server.WithRateLimiter(limiter.Handler).WithRateLimiter(limiter.Handler)
+	assert.Equal(t, []string{
+		rest.MiddlewareAuthz,
+		rest.MiddlewareRateLimit,
+		rest.MiddlewareRequestSize,
+		rest.MiddlewareRequestLogger,
+		rest.MiddlewareMetrics,
+		rest.MiddlewareIdentity,
+		rest.MiddlewareTracing,
+		rest.MiddlewareInFlight,
+	}, server.Middleware())
+
+	handler := server.NewMux()
+	call := func(uri string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, uri, nil)
+		require.NoError(t, err)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := call(testURL)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get(header.RateLimitRemaining))
+
+	w = call(testURL)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get(header.RetryAfter))
+
+	// the health probes are not throttled
+	for i := 0; i < 3; i++ {
+		w = call(rest.LivezPath)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(header.RateLimitRemaining))
+	}
+
+	// without authz stage, the limiter is placed before the identity
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareAuthz))
+	server.WithRateLimiter(limiter.Handler)
+	assert.Equal(t, []string{
+		rest.MiddlewareRequestSize,
+		rest.MiddlewareRequestLogger,
+		rest.MiddlewareMetrics,
+		rest.MiddlewareRateLimit,
+		rest.MiddlewareIdentity,
+		rest.MiddlewareTracing,
+		rest.MiddlewareInFlight,
+	}, server.Middleware())
+}
+
+// versionedService registers the admin route of two versions
+type versionedService struct{}
+
+func (s *versionedService) Name() string  { return "versioned" }
+func (s *versionedService) IsReady() bool { return true }
+func (s *versionedService) Close()        {}
+
+func (s *versionedService) Register(r rest.Router) {
+	for _, v := range []string{"v1", "v2"} {
+		body := []byte(v)
+		r.Version(v).GET("/admin", func(w http.ResponseWriter, _ *http.Request, _ rest.Params) {
+			w.Write(body)
+		})
+	}
+}
+
+func Test_MiddlewareVersion(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	az, err := authz.New(&authz.Config{
+		AllowAny: []string{"/v1"},
+		Allow:    []string{"/v2/admin:admin"},
+	})
+	require.NoError(t, err)
+	server.WithAuthz(az)
+	server.AddService(&versionedService{})
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	var paths []string
+	require.NoError(t, server.Use("path", rest.Last, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}))
+	handler := server.NewMux()
+
+	call := func(uri, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, uri, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			r.Header.Set(header.Accept, accept)
+		}
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := call("/v2/admin", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// the negotiated version is authorized
+	w = call("/admin", "application/json; version=v2")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "v2")
+
+	w = call("/admin", "application/json; version=v1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Body.String())
+	assert.Equal(t, header.Accept, w.Header().Get(header.Vary))
+
+	// the outermost stage sees the versioned path
+	assert.Equal(t, []string{"/v2/admin", "/v2/admin", "/v1/admin"}, paths)
+}
+
+func Test_MiddlewareRateLimitVersion(t *testing.T) {
+	server, err := rest.New("v1.0.123", "", &serverConfig{BindAddr: getBindAddr("")}, nil)
+	require.NoError(t, err)
+	server.AddService(&versionedService{})
+	require.True(t, server.RemoveMiddleware(rest.MiddlewareReady))
+
+	limiter, err := ratelimit.New(nil, ratelimit.Policy{
+		Name:   "admin",
+		By:     ratelimit.KeyRole,
+		Limit:  1,
+		Period: time.Minute,
+		Paths:  []string{"/v2/admin"},
+	})
+	require.NoError(t, err)
+	server.WithRateLimiter(limiter.Handler)
+	handler := server.NewMux()
+
+	call := func(uri, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, uri, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			r.Header.Set(header.Accept, accept)
+		}
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := call("/v2/admin", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the negotiated version is throttled by the policy of the versioned path
+	w = call("/admin", "application/json; version=v2")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = call("/admin", "application/json; version=v1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(header.RateLimitLimit))
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/go-phorce/dolly/rest/openapi"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+)
+
+// RouteDoc provides the documentation metadata of the route,
+// see Router.Doc
+type RouteDoc struct {
+	// OperationID specifies optional unique operation ID
+	OperationID string
+	// Summary specifies a short summary of the operation
+	Summary string
+	// Description specifies a verbose explanation of the operation
+	Description string
+	// Tags specifies the tags for the operation
+	Tags []string
+	// Request specifies an instance of the request body type, if any
+	Request interface{}
+	// Response specifies an instance of the response body type, if any
+	Response interface{}
+	// Status specifies the status code of successful response,
+	// if not specified, then 200 is used
+	Status int
+	// Errors specifies the errors returned by the route
+	Errors []*httperror.Error
+	// Roles specifies the roles required to access the route,
+	// the roles are cross-checked with the Authz configuration, if provided
+	Roles []string
+	// Deprecated specifies that the route is deprecated
+	Deprecated bool
+}
+
+// WithOpenAPI enables to serve OpenAPI document of the registered routes at the path
+func (server *HTTPServer) WithOpenAPI(path string, info openapi.Info) *HTTPServer {
+	server.openapiPath = path
+	server.openapiInfo = info
+	return server
+}
+
+// OpenAPI returns the document generated by NewMux,
+// or nil if OpenAPI is not enabled
+func (server *HTTPServer) OpenAPI() *openapi.Document {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	return server.openapi
+}
+
+// registerOpenAPI registers the OpenAPI end-point,
+// the document is generated after all the routes are registered
+func (server *HTTPServer) registerOpenAPI(router Router) {
+	if server.openapiPath == "" {
+		return
+	}
+
+	var doc *openapi.Document
+	router.Doc(&RouteDoc{
+		Summary:  "OpenAPI document",
+		Tags:     []string{"meta"},
+		Response: openapi.Document{},
+	}).GET(server.openapiPath, func(w http.ResponseWriter, r *http.Request, _ Params) {
+		marshal.WriteJSON(w, r, doc)
+	})
+
+	var access authz.AccessDescriber
+	if a, ok := server.authz.(authz.AccessDescriber); ok {
+		access = &serverAccess{AccessDescriber: a, server: server}
+	}
+	doc = NewOpenAPI(server.openapiInfo, router.Routes(), access)
+
+	server.lock.Lock()
+	server.openapi = doc
+	server.lock.Unlock()
+}
+
+// serverAccess describes the access of the server routes,
+// the health probes bypass the authorization
+type serverAccess struct {
+	authz.AccessDescriber
+	server *HTTPServer
+}
+
+func (a *serverAccess) AllowedAccess(path string) authz.Access {
+	if a.server.httpConfig.GetHealthProbes() && isHealthPath(path) {
+		return authz.Access{AllowAny: true}
+	}
+	return a.AccessDescriber.AllowedAccess(path)
+}
+
+// NewOpenAPI returns OpenAPI document for the routes.
+// If access is provided, then the allowed roles are documented as configured in Authz,
+// the roles specified by RouteDoc are kept as documented roles,
+// and the mismatches are logged.
+func NewOpenAPI(info openapi.Info, routes []Route, access authz.AccessDescriber) *openapi.Document {
+	g := openapi.NewGenerator(info)
+	for _, r := range routes {
+		route := &openapi.Route{
+			Method: r.Method,
+			Path:   r.Path,
+		}
+		if d := r.Doc; d != nil {
+			route.OperationID = d.OperationID
+			route.Summary = d.Summary
+			route.Description = d.Description
+			route.Tags = d.Tags
+			route.Request = d.Request
+			route.Response = d.Response
+			route.Status = d.Status
+			route.Errors = d.Errors
+			route.Roles = d.Roles
+			route.Deprecated = d.Deprecated
+		}
+
+		if access != nil {
+			a := access.AllowedAccess(r.Path)
+			if r.Doc != nil && !a.AllowAny && !a.AllowAnyRole {
+				allowed := map[string]bool{}
+				for _, role := range a.Roles {
+					allowed[role] = true
+				}
+				for _, role := range r.Doc.Roles {
+					if !allowed[role] {
+						logger.Warningf("reason=role_not_allowed, method=%s, path=%s, role=%q",
+							r.Method, r.Path, role)
+					}
+				}
+			}
+			if !a.AllowAny && !a.AllowAnyRole && len(a.Roles) == 0 {
+				logger.Warningf("reason=no_access, method=%s, path=%s", r.Method, r.Path)
+			}
+			route.AllowAny = a.AllowAny
+			route.AllowAnyRole = a.AllowAnyRole
+			route.Roles = a.Roles
+			if r.Doc != nil {
+				route.DocumentedRoles = r.Doc.Roles
+			}
+		}
+
+		g.AddRoute(route)
+	}
+	return g.Document()
+}
@@ -0,0 +1,361 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/httperror"
+)
+
+const (
+	// ContentTypeJSON specifies the media type of the documented requests and responses
+	ContentTypeJSON = "application/json"
+
+	schemaRefPrefix = "#/components/schemas/"
+)
+
+// Route provides the metadata of the route to document
+type Route struct {
+	// Method specifies HTTP method
+	Method string
+	// Path specifies the path template of the router,
+	// such as /v1/users/:id or /v1/files/*filepath
+	Path string
+	// OperationID specifies optional unique operation ID
+	OperationID string
+	// Summary specifies a short summary of the operation
+	Summary string
+	// Description specifies a verbose explanation of the operation
+	Description string
+	// Tags specifies the tags for the operation
+	Tags []string
+	// Request specifies an instance of the request body type, if any
+	Request interface{}
+	// Response specifies an instance of the response body type, if any
+	Response interface{}
+	// Status specifies the status code of successful response,
+	// if not specified, then 200 is used
+	Status int
+	// Errors specifies the errors returned by the operation
+	Errors []*httperror.Error
+	// Deprecated specifies that the operation is deprecated
+	Deprecated bool
+
+	// AllowAny specifies that any request is allowed
+	AllowAny bool
+	// AllowAnyRole specifies that any request with a role is allowed
+	AllowAnyRole bool
+	// Roles specifies the allowed roles
+	Roles []string
+	// DocumentedRoles specifies the roles documented by the route,
+	// when the allowed roles are provided by authz, so the drift can be detected
+	DocumentedRoles []string
+}
+
+// Generator builds OpenAPI document
+type Generator struct {
+	doc   *Document
+	types map[reflect.Type]string
+	names map[string]reflect.Type
+}
+
+// NewGenerator returns a new Generator
+func NewGenerator(info Info) *Generator {
+	return &Generator{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   map[string]*PathItem{},
+		},
+		types: map[reflect.Type]string{},
+		names: map[string]reflect.Type{},
+	}
+}
+
+// Document returns the generated document
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+// AddRoute adds the operation for the route to the document
+func (g *Generator) AddRoute(r *Route) *Operation {
+	p, params := PathTemplate(r.Path)
+
+	op := &Operation{
+		OperationID:  r.OperationID,
+		Summary:      r.Summary,
+		Description:  r.Description,
+		Tags:         r.Tags,
+		Deprecated:   r.Deprecated,
+		Responses:    map[string]*Response{},
+		AllowAny:     r.AllowAny,
+		AllowAnyRole: r.AllowAnyRole && !r.AllowAny,
+	}
+	if !op.AllowAny && !op.AllowAnyRole && len(r.Roles) > 0 {
+		op.AllowedRoles = uniqueSorted(r.Roles)
+	}
+	if len(r.DocumentedRoles) > 0 {
+		op.DocumentedRoles = uniqueSorted(r.DocumentedRoles)
+	}
+
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if r.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				ContentTypeJSON: {Schema: g.Schema(r.Request)},
+			},
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := &Response{
+		Description: http.StatusText(status),
+	}
+	if r.Response != nil {
+		res.Content = map[string]MediaType{
+			ContentTypeJSON: {Schema: g.Schema(r.Response)},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = res
+
+	g.addErrors(op, r.Errors)
+
+	item := g.doc.Paths[p]
+	if item == nil {
+		item = &PathItem{}
+		g.doc.Paths[p] = item
+	}
+	item.SetOperation(strings.ToUpper(r.Method), op)
+	return op
+}
+
+// addErrors adds the error responses, grouped by the status code
+func (g *Generator) addErrors(op *Operation, errs []*httperror.Error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	codes := map[int][]string{}
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		status := e.HTTPStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		codes[status] = append(codes[status], e.Code)
+	}
+
+	schema := g.Schema(httperror.Error{})
+	for status, list := range codes {
+		list = uniqueSorted(list)
+		op.Responses[strconv.Itoa(status)] = &Response{
+			Description: fmt.Sprintf("%s: %s", http.StatusText(status), strings.Join(list, ", ")),
+			Content: map[string]MediaType{
+				ContentTypeJSON: {
+					Schema:  schema,
+					Example: &httperror.Error{Code: list[0], Message: http.StatusText(status)},
+				},
+			},
+		}
+	}
+}
+
+// Schema returns the schema of the value type,
+// the named struct types are added to the components of the document
+// and returned as the reference
+func (g *Generator) Schema(v interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	byteSliceType = reflect.TypeOf([]byte(nil))
+)
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case byteSliceType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.structRef(t)
+	}
+	// interface{} and other types allow any value
+	return &Schema{}
+}
+
+// structRef returns the reference to the struct schema in the components
+func (g *Generator) structRef(t reflect.Type) *Schema {
+	name, ok := g.types[t]
+	if !ok {
+		name = g.schemaName(t)
+		g.types[t] = name
+		g.names[name] = t
+
+		if g.doc.Components == nil {
+			g.doc.Components = &Components{Schemas: map[string]*Schema{}}
+		}
+		// register the name before the fields to allow recursive types
+		g.doc.Components.Schemas[name] = &Schema{Type: "object"}
+		g.doc.Components.Schemas[name] = g.structSchema(t)
+	}
+	return &Schema{Ref: schemaRefPrefix + name}
+}
+
+// schemaName returns the unique name of the type schema,
+// qualified by the package name on conflict
+func (g *Generator) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := g.names[name]; !ok || existing == t {
+		return name
+	}
+	name = path.Base(t.PkgPath()) + "." + t.Name()
+	for i := 2; ; i++ {
+		if _, ok := g.names[name]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("%s.%s%d", path.Base(t.PkgPath()), t.Name(), i)
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	g.addFields(s, t, map[reflect.Type]bool{})
+	if len(s.Required) > 0 {
+		sort.Strings(s.Required)
+	}
+	return s
+}
+
+// addFields adds the properties of the exported fields,
+// the fields of embedded structs without the name are promoted
+func (g *Generator) addFields(s *Schema, t reflect.Type, visited map[reflect.Type]bool) {
+	if visited[t] {
+		// the embedded struct is already added, such as self-embedded pointer
+		return
+	}
+	visited[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft, visited)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func parseTag(tag string) (string, string) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tag[idx+1:]
+	}
+	return tag, ""
+}
+
+// PathTemplate converts the router path template, such as /v1/users/:id,
+// to OpenAPI path template, such as /v1/users/{id},
+// and returns the names of the path parameters
+func PathTemplate(p string) (string, []string) {
+	var params []string
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if len(part) > 1 && (part[0] == ':' || part[0] == '*') {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func uniqueSorted(list []string) []string {
+	m := map[string]bool{}
+	res := make([]string, 0, len(list))
+	for _, s := range list {
+		if !m[s] {
+			m[s] = true
+			res = append(res, s)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,207 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest/openapi"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created_at"`
+}
+
+type user struct {
+	base
+	Name     string            `json:"name"`
+	Email    string            `json:"email,omitempty"`
+	Age      int               `json:"age,omitempty"`
+	Score    float64           `json:"score"`
+	Active   bool              `json:"active"`
+	Roles    []string          `json:"roles"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Manager  *user             `json:"manager,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	TTL      time.Duration     `json:"ttl"`
+	Extra    interface{}       `json:"extra,omitempty"`
+	Internal string            `json:"-"`
+	NoTag    string
+	private  string
+}
+
+// Error conflicts with httperror.Error
+type Error struct {
+	Reason string `json:"reason"`
+}
+
+// node embeds itself
+type node struct {
+	*node
+	Value string `json:"value"`
+}
+
+type usersResponse struct {
+	Users []user `json:"users"`
+}
+
+func Test_PathTemplate(t *testing.T) {
+	tcases := []struct {
+		path   string
+		exp    string
+		params []string
+	}{
+		{"/", "/", nil},
+		{"/v1/users", "/v1/users", nil},
+		{"/v1/users/:id", "/v1/users/{id}", []string{"id"}},
+		{"/v1/users/:id/roles/:role", "/v1/users/{id}/roles/{role}", []string{"id", "role"}},
+		{"/v1/files/*filepath", "/v1/files/{filepath}", []string{"filepath"}},
+	}
+	for _, tc := range tcases {
+		p, params := openapi.PathTemplate(tc.path)
+		assert.Equal(t, tc.exp, p)
+		assert.Equal(t, tc.params, params)
+	}
+}
+
+func Test_Schema(t *testing.T) {
+	g := openapi.NewGenerator(openapi.Info{Title: "test", Version: "v1"})
+
+	assert.Equal(t, &openapi.Schema{Type: "string"}, g.Schema(""))
+	assert.Equal(t, &openapi.Schema{Type: "integer", Format: "int32"}, g.Schema(int32(1)))
+	assert.Equal(t, &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}, g.Schema([]string{}))
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "date-time"}, g.Schema(&time.Time{}))
+	assert.Equal(t, &openapi.Schema{}, g.Schema(nil))
+	assert.Nil(t, g.Document().Components)
+
+	s := g.Schema(&usersResponse{})
+	assert.Equal(t, "#/components/schemas/usersResponse", s.Ref)
+
+	schemas := g.Document().Components.Schemas
+	require.Len(t, schemas, 2)
+	require.Contains(t, schemas, "user")
+
+	res := schemas["usersResponse"]
+	assert.Equal(t, []string{"users"}, res.Required)
+	assert.Equal(t, "array", res.Properties["users"].Type)
+	assert.Equal(t, "#/components/schemas/user", res.Properties["users"].Items.Ref)
+
+	u := schemas["user"]
+	assert.Equal(t, "object", u.Type)
+	assert.Equal(t, []string{"NoTag", "active", "created_at", "id", "name", "roles", "score", "ttl"}, u.Required)
+	assert.Len(t, u.Properties, 14)
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "date-time"}, u.Properties["created_at"])
+	assert.Equal(t, &openapi.Schema{Type: "integer", Format: "int64"}, u.Properties["age"])
+	assert.Equal(t, &openapi.Schema{Type: "number", Format: "double"}, u.Properties["score"])
+	assert.Equal(t, &openapi.Schema{Type: "boolean"}, u.Properties["active"])
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "byte"}, u.Properties["data"])
+	assert.Equal(t, &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}, u.Properties["labels"])
+	assert.Equal(t, &openapi.Schema{Ref: "#/components/schemas/user"}, u.Properties["manager"])
+	assert.Equal(t, &openapi.Schema{}, u.Properties["extra"])
+	assert.NotContains(t, u.Properties, "Internal")
+	assert.NotContains(t, u.Properties, "private")
+
+	// the name conflict is resolved by the package name
+	s = g.Schema(httperror.Error{})
+	assert.Equal(t, "#/components/schemas/Error", s.Ref)
+	s = g.Schema(struct {
+		E Error `json:"e"`
+	}{})
+	assert.Equal(t, "#/components/schemas/openapi_test.Error", s.Properties["e"].Ref)
+	assert.Equal(t, "#/components/schemas/Error", g.Schema(&httperror.Error{}).Ref)
+
+	// the self-embedded struct
+	assert.Equal(t, "#/components/schemas/node", g.Schema(&node{}).Ref)
+	n := g.Document().Components.Schemas["node"]
+	require.NotNil(t, n)
+	assert.Len(t, n.Properties, 1)
+	assert.Equal(t, []string{"value"}, n.Required)
+}
+
+func Test_AddRoute(t *testing.T) {
+	g := openapi.NewGenerator(openapi.Info{Title: "test", Version: "v1"})
+
+	op := g.AddRoute(&openapi.Route{
+		Method:   http.MethodGet,
+		Path:     "/v1/users/:id",
+		Summary:  "Get user",
+		Tags:     []string{"users"},
+		Response: user{},
+		Errors: []*httperror.Error{
+			httperror.WithNotFound("user not found"),
+			httperror.WithInvalidParam("invalid id"),
+			httperror.WithInvalidRequest("invalid request"),
+		},
+		Roles:           []string{"user", "admin", "user"},
+		DocumentedRoles: []string{"user", "reader"},
+	})
+	assert.Equal(t, "Get user", op.Summary)
+	assert.Equal(t, []string{"admin", "user"}, op.AllowedRoles)
+	assert.Equal(t, []string{"reader", "user"}, op.DocumentedRoles)
+	require.Len(t, op.Parameters, 1)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+	assert.True(t, op.Parameters[0].Required)
+	assert.Nil(t, op.RequestBody)
+
+	require.Len(t, op.Responses, 3)
+	assert.Equal(t, "OK", op.Responses["200"].Description)
+	assert.Equal(t, "#/components/schemas/user", op.Responses["200"].Content[openapi.ContentTypeJSON].Schema.Ref)
+	assert.Equal(t, "Not Found: not_found", op.Responses["404"].Description)
+	assert.Equal(t, "Bad Request: invalid_parameter, invalid_request", op.Responses["400"].Description)
+	assert.Equal(t, "#/components/schemas/Error", op.Responses["400"].Content[openapi.ContentTypeJSON].Schema.Ref)
+
+	op = g.AddRoute(&openapi.Route{
+		Method:       http.MethodPost,
+		Path:         "/v1/users/:id",
+		Request:      &user{},
+		Status:       http.StatusCreated,
+		AllowAnyRole: true,
+		Roles:        []string{"admin"},
+		Deprecated:   true,
+	})
+	assert.True(t, op.AllowAnyRole)
+	assert.Empty(t, op.AllowedRoles)
+	require.NotNil(t, op.RequestBody)
+	assert.Equal(t, "#/components/schemas/user", op.RequestBody.Content[openapi.ContentTypeJSON].Schema.Ref)
+	assert.Equal(t, &openapi.Response{Description: "Created"}, op.Responses["201"])
+
+	g.AddRoute(&openapi.Route{Method: http.MethodGet, Path: "/v1/status", AllowAny: true, AllowAnyRole: true})
+
+	doc := g.Document()
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	require.Len(t, doc.Paths, 2)
+	item := doc.Paths["/v1/users/{id}"]
+	require.NotNil(t, item)
+	assert.NotNil(t, item.Operation(http.MethodGet))
+	assert.NotNil(t, item.Operation(http.MethodPost))
+	assert.Nil(t, item.Operation(http.MethodDelete))
+
+	status := doc.Paths["/v1/status"].Get
+	assert.True(t, status.AllowAny)
+	assert.False(t, status.AllowAnyRole)
+
+	js, err := json.Marshal(doc)
+	require.NoError(t, err)
+	assert.Contains(t, string(js), `"openapi":"3.0.3"`)
+	assert.Contains(t, string(js), `"$ref":"#/components/schemas/user"`)
+	assert.Contains(t, string(js), `"x-allowed-roles":["admin","user"]`)
+	assert.Contains(t, string(js), `"x-allow-any":true`)
+	assert.Contains(t, string(js), `"deprecated":true`)
+}
+
+func Test_PathItem(t *testing.T) {
+	item := &openapi.PathItem{}
+	for _, m := range []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"} {
+		op := &openapi.Operation{Summary: m}
+		item.SetOperation(m, op)
+		assert.Equal(t, op, item.Operation(m))
+	}
+	item.SetOperation("CONNECT", &openapi.Operation{})
+	assert.Nil(t, item.Operation("CONNECT"))
+}
@@ -0,0 +1,137 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/rest/openapi"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type docItem struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created_at"`
+}
+
+// documentedService registers the routes with the documentation
+type documentedService struct{}
+
+func (s *documentedService) Name() string  { return "documented" }
+func (s *documentedService) IsReady() bool { return true }
+func (s *documentedService) Close()        {}
+
+func (s *documentedService) Register(r rest.Router) {
+	handle := func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		w.WriteHeader(http.StatusOK)
+	}
+	r.Doc(&rest.RouteDoc{
+		Summary:  "Get item",
+		Tags:     []string{"items"},
+		Response: docItem{},
+		Errors:   []*httperror.Error{httperror.WithNotFound("item not found")},
+		Roles:    []string{"admin", "reader"},
+	}).GET("/v1/items/:id", handle)
+	r.Doc(&rest.RouteDoc{
+		Summary: "Create item",
+		Request: docItem{},
+		Status:  http.StatusCreated,
+	}).POST("/v1/items", handle)
+	r.GET("/v1/undocumented", handle)
+}
+
+func Test_OpenAPI(t *testing.T) {
+	probes := true
+	cfg := &serverConfig{
+		BindAddr:     getBindAddr("localhost"),
+		HealthProbes: &probes,
+	}
+
+	az, err := authz.New(&authz.Config{
+		AllowAny: []string{"/v1/openapi"},
+		Allow:    []string{"/v1/items:admin,writer"},
+	})
+	require.NoError(t, err)
+
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(auditor.NewInMemory()).
+		WithAuthz(az).
+		WithOpenAPI("/v1/openapi", openapi.Info{Title: "test", Version: "v1.0.123"})
+	server.AddService(&documentedService{})
+	assert.Equal(t, az, server.Authz())
+	assert.Nil(t, server.OpenAPI())
+
+	require.NoError(t, server.StartHTTP())
+	defer server.StopHTTP()
+	waitFor(t, server.IsReady)
+	require.NotNil(t, server.OpenAPI())
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/v1/openapi", cfg.BindAddr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc openapi.Document
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Equal(t, "test", doc.Info.Title)
+
+	for _, p := range []string{"/v1/items/{id}", "/v1/items", "/v1/undocumented", "/v1/openapi", rest.HealthzPath} {
+		assert.Contains(t, doc.Paths, p)
+	}
+
+	// the health probes are not authorized
+	hresp, err := http.Get(fmt.Sprintf("http://%s%s", cfg.BindAddr, rest.HealthzPath))
+	require.NoError(t, err)
+	hresp.Body.Close()
+	assert.Equal(t, http.StatusOK, hresp.StatusCode)
+
+	get := doc.Paths["/v1/items/{id}"].Get
+	require.NotNil(t, get)
+	assert.Equal(t, "Get item", get.Summary)
+	// documented as configured in authz, the drift is kept
+	assert.Equal(t, []string{"admin", "writer"}, get.AllowedRoles)
+	assert.Equal(t, []string{"admin", "reader"}, get.DocumentedRoles)
+	assert.Equal(t, "#/components/schemas/docItem", get.Responses["200"].Content[openapi.ContentTypeJSON].Schema.Ref)
+	assert.Contains(t, get.Responses, "404")
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+
+	healthz := doc.Paths[rest.HealthzPath].Get
+	require.NotNil(t, healthz)
+	assert.True(t, healthz.AllowAny)
+
+	post := doc.Paths["/v1/items"].Post
+	require.NotNil(t, post)
+	assert.Contains(t, post.Responses, "201")
+	assert.NotNil(t, post.RequestBody)
+	assert.Equal(t, []string{"admin", "writer"}, post.AllowedRoles)
+
+	assert.True(t, doc.Paths["/v1/openapi"].Get.AllowAny)
+	assert.Empty(t, doc.Paths["/v1/undocumented"].Get.AllowedRoles)
+
+	require.NotNil(t, doc.Components)
+	assert.Contains(t, doc.Components.Schemas, "docItem")
+	assert.Contains(t, doc.Components.Schemas, "Error")
+}
+
+func Test_NewOpenAPI(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+	(&documentedService{}).Register(router)
+
+	// without authz the roles are documented as declared by the routes
+	doc := rest.NewOpenAPI(openapi.Info{Title: "test"}, router.Routes(), nil)
+	assert.Equal(t, []string{"admin", "reader"}, doc.Paths["/v1/items/{id}"].Get.AllowedRoles)
+	assert.Empty(t, doc.Paths["/v1/items"].Post.AllowedRoles)
+	assert.Empty(t, doc.Paths["/v1/items/{id}"].Get.DocumentedRoles)
+	assert.Len(t, doc.Paths, 3)
+}
@@ -207,6 +207,9 @@ type serverConfig struct {
 	// BindAddr is the address that the HTTPS service should be exposed on
 	BindAddr string
 
+	// ListenURLs specifies the list of listeners
+	ListenURLs []string
+
 	// ServerTLS provides TLS config for server
 	ServerTLS tlsConfig
 
@@ -224,6 +227,30 @@ type serverConfig struct {
 
 	// HeartbeatSecs specifies heartbeat interval in seconds [30 secs is a minimum]
 	HeartbeatSecs int
+
+	// ReadHeaderTimeout specifies the amount of time allowed to read request headers
+	ReadHeaderTimeout time.Duration
+
+	// ReadTimeout specifies the maximum duration for reading the entire request
+	ReadTimeout time.Duration
+
+	// WriteTimeout specifies the maximum duration before timing out writes of the response
+	WriteTimeout time.Duration
+
+	// IdleTimeout specifies the maximum amount of time to wait for the next request
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes specifies the maximum number of bytes of the request header
+	MaxHeaderBytes int
+
+	// MaxRequestSize specifies the maximum size of the request body in bytes
+	MaxRequestSize int64
+
+	// RouteRequestSizes specifies the maximum size of the request body in bytes per route
+	RouteRequestSizes map[string]int64
+
+	// HealthProbes specifies if the health probes end-points are served
+	HealthProbes *bool
 }
 
 // GetServiceName specifies name of the service: HTTP|HTTPS|WebAPI
@@ -246,6 +273,11 @@ func (c *serverConfig) GetBindAddr() string {
 	return c.BindAddr
 }
 
+// GetListenURLs specifies the list of listeners
+func (c *serverConfig) GetListenURLs() []string {
+	return c.ListenURLs
+}
+
 // GetPackageLogger if set, specifies name of the package logger
 func (c *serverConfig) GetPackageLogger() string {
 	return c.PackageLogger
@@ -271,6 +303,46 @@ func (c *serverConfig) GetHeartbeatSecs() int {
 	return c.HeartbeatSecs
 }
 
+// GetReadHeaderTimeout specifies the amount of time allowed to read request headers
+func (c *serverConfig) GetReadHeaderTimeout() time.Duration {
+	return c.ReadHeaderTimeout
+}
+
+// GetReadTimeout specifies the maximum duration for reading the entire request
+func (c *serverConfig) GetReadTimeout() time.Duration {
+	return c.ReadTimeout
+}
+
+// GetWriteTimeout specifies the maximum duration before timing out writes of the response
+func (c *serverConfig) GetWriteTimeout() time.Duration {
+	return c.WriteTimeout
+}
+
+// GetIdleTimeout specifies the maximum amount of time to wait for the next request
+func (c *serverConfig) GetIdleTimeout() time.Duration {
+	return c.IdleTimeout
+}
+
+// GetMaxHeaderBytes specifies the maximum number of bytes of the request header
+func (c *serverConfig) GetMaxHeaderBytes() int {
+	return c.MaxHeaderBytes
+}
+
+// GetMaxRequestSize specifies the maximum size of the request body in bytes
+func (c *serverConfig) GetMaxRequestSize() int64 {
+	return c.MaxRequestSize
+}
+
+// GetRouteRequestSizes specifies the maximum size of the request body in bytes per route
+func (c *serverConfig) GetRouteRequestSizes() map[string]int64 {
+	return c.RouteRequestSizes
+}
+
+// GetHealthProbes specifies if the health probes end-points are served
+func (c *serverConfig) GetHealthProbes() bool {
+	return c.HealthProbes != nil && *c.HealthProbes
+}
+
 func createServerTLSInfo(cfg *tlsConfig) (*tls.Config, *tlsconfig.KeypairReloader, error) {
 	certFile := cfg.GetCertFile()
 	keyFile := cfg.GetKeyFile()
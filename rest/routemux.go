@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/julienschmidt/httprouter"
+)
+
+// routeMux dispatches the requests to the routers of the hosts,
+// and negotiates API version
+type routeMux struct {
+	lock     sync.RWMutex
+	root     *httprouter.Router
+	hosts    map[string]*httprouter.Router
+	versions map[string]bool
+	routes   []Route
+	// templates resolves the route templates by the hosts,
+	// before the request is dispatched
+	templates map[string]*httprouter.Router
+}
+
+func newRouteMux(notfoundhandler http.HandlerFunc) *routeMux {
+	m := &routeMux{
+		root:      httprouter.New(),
+		hosts:     map[string]*httprouter.Router{},
+		versions:  map[string]bool{},
+		templates: map[string]*httprouter.Router{},
+	}
+	if notfoundhandler != nil {
+		m.root.NotFound = notfoundhandler
+	}
+	return m
+}
+
+// router returns the router for the host, or the root router
+func (m *routeMux) router(host string) *httprouter.Router {
+	if host == "" {
+		return m.root
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	r := m.hosts[host]
+	if r == nil {
+		r = httprouter.New()
+		// fallback to the routes of the wildcard hosts, and then of any host
+		r.HandleMethodNotAllowed = false
+		r.NotFound = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			m.match(req.Host, host).ServeHTTP(w, req)
+		})
+		m.hosts[host] = r
+	}
+	return r
+}
+
+func (m *routeMux) addRoute(r Route) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.routes = append(m.routes, r)
+
+	t := m.templates[r.Host]
+	if t == nil {
+		t = httprouter.New()
+		m.templates[r.Host] = t
+	}
+	path := r.Path
+	t.Handle(r.Method, path, func(_ http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		if ri, ok := req.Context().Value(routeContextKey{}).(*routeInfo); ok {
+			ri.template.Store(path)
+		}
+	})
+}
+
+// resolveRoute sets the template of the route registered for the request
+// on the routeInfo of the request, without dispatching the request
+func (m *routeMux) resolveRoute(r *http.Request) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	// the exact host, the wildcards, and then any host
+	for _, key := range append(hostKeys(normalizeHost(r.Host)), "") {
+		if t := m.templates[key]; t != nil {
+			if h, _, _ := t.Lookup(r.Method, r.URL.Path); h != nil {
+				h(nil, r, nil)
+				return
+			}
+		}
+	}
+}
+
+func (m *routeMux) listRoutes() []Route {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]Route(nil), m.routes...)
+}
+
+func (m *routeMux) addVersion(version string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.versions[version] = true
+}
+
+// match returns the router for the request host,
+// the exact host takes precedence over the wildcards, from the most specific one.
+// If after is specified, then the router following the host router of after is returned.
+func (m *routeMux) match(host, after string) *httprouter.Router {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if len(m.hosts) == 0 {
+		return m.root
+	}
+
+	found := after == ""
+	for _, key := range hostKeys(normalizeHost(host)) {
+		if !found {
+			found = key == after
+			continue
+		}
+		if r := m.hosts[key]; r != nil {
+			return r
+		}
+	}
+	return m.root
+}
+
+// hostKeys returns the exact host and the wildcards matching the host,
+// such as a.example.com, *.example.com, *.com
+func hostKeys(host string) []string {
+	keys := []string{host}
+	for i := 0; i < len(host); i++ {
+		if host[i] == '.' {
+			keys = append(keys, "*"+host[i:])
+		}
+	}
+	return keys
+}
+
+// negotiateVersion returns the version requested by Accept header,
+// if the version is not specified in the path,
+// and the route is registered for the version
+func (m *routeMux) negotiateVersion(r *http.Request) string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if len(m.versions) == 0 {
+		return ""
+	}
+
+	segment := strings.TrimPrefix(r.URL.Path, "/")
+	if idx := strings.IndexByte(segment, '/'); idx >= 0 {
+		segment = segment[:idx]
+	}
+	if m.versions[segment] {
+		return ""
+	}
+
+	for _, v := range AcceptVersions(r.Header.Get(header.Accept)) {
+		if !m.versions[v] && !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		if m.versions[v] && m.hasRoute(r.Host, r.Method, "/"+v+r.URL.Path) {
+			return v
+		}
+	}
+	return ""
+}
+
+// hasRoute returns true if the route is registered for the host,
+// the wildcard hosts or any host
+func (m *routeMux) hasRoute(host, method, path string) bool {
+	for _, key := range hostKeys(normalizeHost(host)) {
+		if r := m.hosts[key]; r != nil {
+			if h, _, _ := r.Lookup(method, path); h != nil {
+				return true
+			}
+		}
+	}
+	h, _, _ := m.root.Lookup(method, path)
+	return h != nil
+}
+
+// withVersion returns the request with the path of the version negotiated by Accept header,
+// the request with the versioned path is returned as is
+func (m *routeMux) withVersion(w http.ResponseWriter, r *http.Request) *http.Request {
+	if v := m.negotiateVersion(r); v != "" {
+		w.Header().Add(header.Vary, header.Accept)
+
+		u := *r.URL
+		u.Path = "/" + v + u.Path
+		u.RawPath = ""
+		r = r.WithContext(r.Context())
+		r.URL = &u
+	}
+	return r
+}
+
+func (m *routeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.match(r.Host, "").ServeHTTP(w, m.withVersion(w, r))
+}
+
+var vndVersion = regexp.MustCompile(`\.(v[0-9][0-9a-z.]*)\+`)
+
+// AcceptVersions returns the API versions requested by Accept header,
+// in the order of the media ranges, specified by version parameter,
+// such as "application/json; version=v2", or by the vendor media type,
+// such as "application/vnd.example.v2+json"
+func AcceptVersions(accept string) []string {
+	var list []string
+	for _, mr := range strings.Split(accept, ",") {
+		parts := strings.Split(mr, ";")
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "version") {
+				if v := strings.Trim(strings.TrimSpace(kv[1]), `"`); v != "" {
+					list = append(list, v)
+				}
+			}
+		}
+		if m := vndVersion.FindStringSubmatch(parts[0]); m != nil {
+			list = append(list, m[1])
+		}
+	}
+	return list
+}
+
+// normalizeHost returns the lower case host without the port
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -1,7 +1,10 @@
 package rest
 
 import (
+	"context"
 	"net/http"
+	"strings"
+	"sync/atomic"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/rs/cors"
@@ -80,20 +83,54 @@ type Router interface {
 	PATCH(path string, handle Handle)
 	DELETE(path string, handle Handle)
 	CONNECT(path string, handle Handle)
+	// With returns a Router that registers routes on the same router,
+	// with the handles wrapped by the given middleware.
+	// The first middleware in the list receives the request first.
+	With(middleware ...Middleware) Router
+	// Doc returns a Router that registers routes on the same router,
+	// with the documentation metadata
+	Doc(doc *RouteDoc) Router
+	// Group returns a Router that registers routes on the same router,
+	// with the path prefix, such as /v1, and the handles wrapped by the given middleware.
+	Group(prefix string, middleware ...Middleware) Router
+	// Version returns a Router that registers routes with /<version> prefix, such as /v2.
+	// The requests without the version in the path are routed to the version
+	// requested by Accept header, such as "application/json; version=v2",
+	// or "application/vnd.example.v2+json", if the route exists for the version.
+	Version(version string, middleware ...Middleware) Router
+	// Host returns a Router that registers routes served only for the requests
+	// to the host, such as tenant1.example.com, or *.example.com for any subdomain.
+	// The requests to the host that don't match the host routes
+	// are served by the routes registered without the host.
+	Host(host string) Router
+	// Routes returns the registered routes, in the order of registration
+	Routes() []Route
+}
+
+// Route provides the registered route
+type Route struct {
+	Method string
+	Path   string
+	// Host is the host of the route, or empty for any host
+	Host string
+	// Doc is the documentation metadata, if provided
+	Doc *RouteDoc
 }
 
 type proxy struct {
-	router *httprouter.Router
-	cors   *cors.Cors
+	mux        *routeMux
+	cors       *cors.Cors
+	middleware []Middleware
+	doc        *RouteDoc
+	prefix     string
+	host       string
 }
 
 // NewRouter returns a new initialized Router.
 func NewRouter(notfoundhandler http.HandlerFunc) Router {
-	r := &proxy{
-		router: httprouter.New(),
+	return &proxy{
+		mux: newRouteMux(notfoundhandler),
 	}
-	r.router.NotFound = notfoundhandler
-	return r
 }
 
 // NewRouterWithCORS returns a new initialized Router with CORS enabled
@@ -116,12 +153,10 @@ func NewRouterWithCORS(notfoundhandler http.HandlerFunc, opt *CORSOptions) Route
 		c = cors.Default()
 	}
 
-	r := &proxy{
-		router: httprouter.New(),
-		cors:   c,
+	return &proxy{
+		mux:  newRouteMux(notfoundhandler),
+		cors: c,
 	}
-	r.router.NotFound = notfoundhandler
-	return r
 }
 
 func proxyHandle(handle Handle) httprouter.Handle {
@@ -130,49 +165,199 @@ func proxyHandle(handle Handle) httprouter.Handle {
 	}
 }
 
+type routeContextKey struct{}
+
+// routeInfo holds the template of the route matched by the router,
+// it is added to the request context by the server before routing,
+// so the outer handlers can find the route after the request is dispatched
+type routeInfo struct {
+	template atomic.Value
+}
+
+func (ri *routeInfo) get() string {
+	s, _ := ri.template.Load().(string)
+	return s
+}
+
+// withRouteInfo returns the request with routeInfo in the context
+func withRouteInfo(r *http.Request) (*http.Request, *routeInfo) {
+	if ri, ok := r.Context().Value(routeContextKey{}).(*routeInfo); ok {
+		return r, ri
+	}
+	ri := &routeInfo{}
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey{}, ri)), ri
+}
+
+// RouteTemplate returns the template of the route matched by the router,
+// such as /v1/users/:id, or empty string if the request was not routed yet.
+// The server resolves the route before the middleware,
+// so the template is available to the requests rejected before routing.
+func RouteTemplate(r *http.Request) string {
+	if ri, ok := r.Context().Value(routeContextKey{}).(*routeInfo); ok {
+		return ri.get()
+	}
+	return ""
+}
+
+// routeHandle sets the route template on the request
+func routeHandle(path string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		r, ri := withRouteInfo(r)
+		ri.template.Store(path)
+		handle(w, r, p)
+	}
+}
+
+type paramsContextKey struct{}
+
+// middlewareHandle returns httprouter.Handle that invokes the handle
+// wrapped by the middleware
+func middlewareHandle(handle Handle, middleware []Middleware) httprouter.Handle {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := r.Context().Value(paramsContextKey{}).(Params)
+		handle(w, r, p)
+	})
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := context.WithValue(r.Context(), paramsContextKey{}, Params(p))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+func (p *proxy) handle(method, path string, handle Handle) {
+	path = p.prefix + path
+	router := p.mux.router(p.host)
+	if len(p.middleware) > 0 {
+		router.Handle(method, path, routeHandle(path, middlewareHandle(handle, p.middleware)))
+	} else {
+		router.Handle(method, path, routeHandle(path, proxyHandle(handle)))
+	}
+	p.mux.addRoute(Route{
+		Method: method,
+		Path:   path,
+		Host:   p.host,
+		Doc:    p.doc,
+	})
+}
+
+// clone returns a copy of the proxy that registers routes on the same router
+func (p *proxy) clone() *proxy {
+	c := *p
+	return &c
+}
+
+// With returns a Router that registers routes with the middleware
+func (p *proxy) With(middleware ...Middleware) Router {
+	c := p.clone()
+	c.middleware = appendMiddleware(p.middleware, middleware)
+	return c
+}
+
+// Doc returns a Router that registers routes with the documentation metadata
+func (p *proxy) Doc(doc *RouteDoc) Router {
+	c := p.clone()
+	c.doc = doc
+	return c
+}
+
+// Group returns a Router that registers routes with the prefix and the middleware
+func (p *proxy) Group(prefix string, middleware ...Middleware) Router {
+	c := p.clone()
+	c.prefix = p.prefix + cleanPrefix(prefix)
+	c.middleware = appendMiddleware(p.middleware, middleware)
+	return c
+}
+
+// Version returns a Router that registers routes with the version prefix
+func (p *proxy) Version(version string, middleware ...Middleware) Router {
+	version = strings.Trim(version, "/")
+	p.mux.addVersion(version)
+	return p.Group("/"+version, middleware...)
+}
+
+// Host returns a Router that registers routes for the host
+func (p *proxy) Host(host string) Router {
+	c := p.clone()
+	c.host = normalizeHost(host)
+	return c
+}
+
+// Routes returns the registered routes
+func (p *proxy) Routes() []Route {
+	return p.mux.listRoutes()
+}
+
+func appendMiddleware(list []Middleware, middleware []Middleware) []Middleware {
+	mw := make([]Middleware, 0, len(list)+len(middleware))
+	mw = append(mw, list...)
+	return append(mw, middleware...)
+}
+
+// cleanPrefix returns the prefix with leading slash and without trailing slash
+func cleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// versionHandler returns the handler that rewrites the request path
+// to the version negotiated by Accept header, and resolves the route template,
+// before calling the handler
+func (p *proxy) versionHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, _ = withRouteInfo(p.mux.withVersion(w, r))
+		p.mux.resolveRoute(r)
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func (p *proxy) Handler() http.Handler {
 	if p.cors != nil {
-		return p.cors.Handler(p.router)
+		return p.cors.Handler(p.mux)
 	}
-	return p.router
+	return p.mux
 }
 
 // GET is a shortcut for router.Handle("GET", path, handle)
 func (p *proxy) GET(path string, handle Handle) {
-	p.router.Handle("GET", path, proxyHandle(handle))
+	p.handle("GET", path, handle)
 }
 
 // HEAD is a shortcut for router.Handle("HEAD", path, handle)
 func (p *proxy) HEAD(path string, handle Handle) {
-	p.router.Handle("HEAD", path, proxyHandle(handle))
+	p.handle("HEAD", path, handle)
 }
 
 // OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle)
 func (p *proxy) OPTIONS(path string, handle Handle) {
-	p.router.Handle("OPTIONS", path, proxyHandle(handle))
+	p.handle("OPTIONS", path, handle)
 }
 
 // POST is a shortcut for router.Handle("POST", path, handle)
 func (p *proxy) POST(path string, handle Handle) {
-	p.router.Handle("POST", path, proxyHandle(handle))
+	p.handle("POST", path, handle)
 }
 
 // PUT is a shortcut for router.Handle("PUT", path, handle)
 func (p *proxy) PUT(path string, handle Handle) {
-	p.router.Handle("PUT", path, proxyHandle(handle))
+	p.handle("PUT", path, handle)
 }
 
 // PATCH is a shortcut for router.Handle("PATCH", path, handle)
 func (p *proxy) PATCH(path string, handle Handle) {
-	p.router.Handle("PATCH", path, proxyHandle(handle))
+	p.handle("PATCH", path, handle)
 }
 
 // DELETE is a shortcut for router.Handle("DELETE", path, handle)
 func (p *proxy) DELETE(path string, handle Handle) {
-	p.router.Handle("DELETE", path, proxyHandle(handle))
+	p.handle("DELETE", path, handle)
 }
 
 // CONNECT is a shortcut for router.Handle("CONNECT", path, handle)
 func (p *proxy) CONNECT(path string, handle Handle) {
-	p.router.Handle("CONNECT", path, proxyHandle(handle))
+	p.handle("CONNECT", path, handle)
 }
@@ -98,3 +98,251 @@ func Test_Router(t *testing.T) {
 	assert.Equal(t, 0, h.parameters["DELETE"])
 	assert.Equal(t, 0, h.parameters["OTHER"])
 }
+
+func Test_RouterWith(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+
+	var calls []string
+	mw := func(name string) rest.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	var param string
+	handle := func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		calls = append(calls, "handle")
+		param = p.ByName("id")
+	}
+
+	router.GET("/plain/:id", handle)
+	protected := router.With(mw("auth"))
+	protected.GET("/protected/:id", handle)
+	protected.With(mw("audit"), mw("extra")).POST("/protected/:id", handle)
+
+	rh := router.Handler()
+
+	r, err := http.NewRequest(http.MethodGet, "/plain/1", nil)
+	require.NoError(t, err)
+	rh.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []string{"handle"}, calls)
+	assert.Equal(t, "1", param)
+
+	calls = nil
+	r, err = http.NewRequest(http.MethodGet, "/protected/2", nil)
+	require.NoError(t, err)
+	rh.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []string{"auth", "handle"}, calls)
+	assert.Equal(t, "2", param)
+
+	calls = nil
+	r, err = http.NewRequest(http.MethodPost, "/protected/3", nil)
+	require.NoError(t, err)
+	rh.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []string{"auth", "audit", "extra", "handle"}, calls)
+	assert.Equal(t, "3", param)
+
+	// middleware can reject the request
+	router.With(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}).DELETE("/protected/:id", handle)
+
+	calls = nil
+	w := httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodDelete, "/protected/4", nil)
+	require.NoError(t, err)
+	rh.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, calls)
+}
+
+func Test_RouterDoc(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+	assert.Empty(t, router.Routes())
+
+	handle := func(w http.ResponseWriter, r *http.Request, p rest.Params) {}
+	doc := &rest.RouteDoc{Summary: "Get item"}
+
+	router.GET("/v1/items", handle)
+	router.Doc(doc).GET("/v1/items/:id", handle)
+	router.With(func(next http.Handler) http.Handler { return next }).
+		Doc(&rest.RouteDoc{Summary: "Delete item"}).
+		DELETE("/v1/items/:id", handle)
+
+	routes := router.Routes()
+	require.Len(t, routes, 3)
+	assert.Equal(t, rest.Route{Method: http.MethodGet, Path: "/v1/items"}, routes[0])
+	assert.Equal(t, rest.Route{Method: http.MethodGet, Path: "/v1/items/:id", Doc: doc}, routes[1])
+	assert.Equal(t, http.MethodDelete, routes[2].Method)
+	assert.Equal(t, "Delete item", routes[2].Doc.Summary)
+
+	// the documented router is still serving the routes
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/items/1", nil)
+	require.NoError(t, err)
+	router.Handler().ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func serve(t *testing.T, h http.Handler, method, host, path, accept string) *httptest.ResponseRecorder {
+	r, err := http.NewRequest(method, path, nil)
+	require.NoError(t, err)
+	if host != "" {
+		r.Host = host
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// named returns the handle that writes the name and the route template
+func named(name string) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		w.Write([]byte(name + " " + rest.RouteTemplate(r)))
+	}
+}
+
+func Test_RouterGroup(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+
+	var calls []string
+	mw := func(name string) rest.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	api := router.Group("/api/", mw("api"))
+	api.GET("/status", named("status"))
+	users := api.Group("users", mw("users"))
+	users.GET("/:id", named("user"))
+	users.With(mw("audit")).DELETE("/:id", named("delete"))
+
+	h := router.Handler()
+
+	w := serve(t, h, http.MethodGet, "", "/api/status", "")
+	assert.Equal(t, "status /api/status", w.Body.String())
+	assert.Equal(t, []string{"api"}, calls)
+
+	calls = nil
+	w = serve(t, h, http.MethodGet, "", "/api/users/1", "")
+	assert.Equal(t, "user /api/users/:id", w.Body.String())
+	assert.Equal(t, []string{"api", "users"}, calls)
+
+	calls = nil
+	w = serve(t, h, http.MethodDelete, "", "/api/users/1", "")
+	assert.Equal(t, "delete /api/users/:id", w.Body.String())
+	assert.Equal(t, []string{"api", "users", "audit"}, calls)
+
+	assert.Equal(t, http.StatusNotFound, serve(t, h, http.MethodGet, "", "/status", "").Code)
+
+	var paths []string
+	for _, r := range router.Routes() {
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	assert.Equal(t, []string{"GET /api/status", "GET /api/users/:id", "DELETE /api/users/:id"}, paths)
+}
+
+func Test_RouterVersion(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+	router.Version("v1").GET("/items/:id", named("v1"))
+	router.Version("/v2/").GET("/items/:id", named("v2"))
+	router.GET("/status", named("status"))
+
+	h := router.Handler()
+
+	tcases := []struct {
+		path   string
+		accept string
+		exp    string
+		code   int
+	}{
+		{"/v1/items/1", "", "v1 /v1/items/:id", http.StatusOK},
+		{"/v2/items/1", "", "v2 /v2/items/:id", http.StatusOK},
+		// the version in path takes precedence
+		{"/v1/items/1", "application/json; version=v2", "v1 /v1/items/:id", http.StatusOK},
+		{"/items/1", "application/json; version=v2", "v2 /v2/items/:id", http.StatusOK},
+		{"/items/1", "application/json;version=\"1\"", "v1 /v1/items/:id", http.StatusOK},
+		{"/items/1", "application/vnd.dolly.v2+json", "v2 /v2/items/:id", http.StatusOK},
+		{"/items/1", "application/vnd.dolly.v3+json, application/vnd.dolly.v1+json", "v1 /v1/items/:id", http.StatusOK},
+		{"/items/1", "application/json", "", http.StatusNotFound},
+		{"/items/1", "application/json; version=v3", "", http.StatusNotFound},
+		// the version without the route falls back to the path
+		{"/status", "application/json; version=v2", "status /status", http.StatusOK},
+		{"/items/1", "application/json; version=v3, application/json; version=v1", "v1 /v1/items/:id", http.StatusOK},
+		{"/status", "application/json", "status /status", http.StatusOK},
+	}
+	for _, tc := range tcases {
+		w := serve(t, h, http.MethodGet, "", tc.path, tc.accept)
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.path, tc.accept)
+		if tc.code == http.StatusOK {
+			assert.Equal(t, tc.exp, w.Body.String(), "%s %s", tc.path, tc.accept)
+		}
+	}
+
+	w := serve(t, h, http.MethodGet, "", "/items/1", "application/json; version=v2")
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+}
+
+func Test_AcceptVersions(t *testing.T) {
+	assert.Empty(t, rest.AcceptVersions(""))
+	assert.Empty(t, rest.AcceptVersions("application/json"))
+	assert.Equal(t, []string{"v2"}, rest.AcceptVersions("application/json; charset=utf-8; version=v2"))
+	assert.Equal(t, []string{"2", "v1.1"}, rest.AcceptVersions("application/json; Version=2, application/vnd.example.v1.1+json"))
+}
+
+func Test_RouterHost(t *testing.T) {
+	router := rest.NewRouter(notFoundHandler)
+	router.GET("/items/:id", named("any"))
+	router.GET("/status", named("status"))
+	router.Host("Tenant1.example.com:8443").GET("/items/:id", named("tenant1"))
+	router.Host("*.example.com").GET("/items/:id", named("wildcard"))
+	router.Host("tenant2.example.com").Group("/admin").POST("/reset", named("reset"))
+
+	h := router.Handler()
+
+	tcases := []struct {
+		host   string
+		path   string
+		exp    string
+		code   int
+		method string
+	}{
+		{"", "/items/1", "any /items/:id", http.StatusOK, http.MethodGet},
+		{"other.org", "/items/1", "any /items/:id", http.StatusOK, http.MethodGet},
+		{"tenant1.example.com", "/items/1", "tenant1 /items/:id", http.StatusOK, http.MethodGet},
+		{"TENANT1.example.com:443", "/items/1", "tenant1 /items/:id", http.StatusOK, http.MethodGet},
+		{"tenant3.example.com", "/items/1", "wildcard /items/:id", http.StatusOK, http.MethodGet},
+		{"a.b.example.com", "/items/1", "wildcard /items/:id", http.StatusOK, http.MethodGet},
+		{"example.com", "/items/1", "any /items/:id", http.StatusOK, http.MethodGet},
+		// falls back to the routes of any host
+		{"tenant1.example.com", "/status", "status /status", http.StatusOK, http.MethodGet},
+		{"tenant2.example.com", "/admin/reset", "reset /admin/reset", http.StatusOK, http.MethodPost},
+		{"tenant2.example.com", "/items/1", "wildcard /items/:id", http.StatusOK, http.MethodGet},
+		{"tenant1.example.com", "/admin/reset", "", http.StatusNotFound, http.MethodPost},
+	}
+	for _, tc := range tcases {
+		w := serve(t, h, tc.method, tc.host, tc.path, "")
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.host, tc.path)
+		if tc.code == http.StatusOK {
+			assert.Equal(t, tc.exp, w.Body.String(), "%s %s", tc.host, tc.path)
+		}
+	}
+
+	routes := router.Routes()
+	require.Len(t, routes, 5)
+	assert.Equal(t, "tenant1.example.com", routes[2].Host)
+	assert.Equal(t, "*.example.com", routes[3].Host)
+	assert.Equal(t, "/admin/reset", routes[4].Path)
+}
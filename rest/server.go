@@ -9,11 +9,13 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	metricsutil "github.com/go-phorce/dolly/metrics/util"
 	"github.com/go-phorce/dolly/netutil"
-	"github.com/go-phorce/dolly/rest/ready"
+	"github.com/go-phorce/dolly/rest/health"
+	"github.com/go-phorce/dolly/rest/openapi"
 	"github.com/go-phorce/dolly/tasks"
 	"github.com/go-phorce/dolly/xhttp"
 	"github.com/go-phorce/dolly/xhttp/authz"
@@ -23,11 +25,13 @@ import (
 	"github.com/go-phorce/dolly/xhttp/marshal"
 	"github.com/go-phorce/dolly/xlog"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
 )
 
 var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "rest")
 
-// MaxRequestSize specifies max size of regular HTTP Post requests in bytes, 64 Mb
+// MaxRequestSize specifies default max size of regular HTTP Post requests in bytes, 64 Mb,
+// if not overridden by HTTPServerConfig
 const MaxRequestSize = 64 * 1024 * 1024
 
 const (
@@ -37,6 +41,10 @@ const (
 	EvtServiceStarted = "service started"
 	// EvtServiceStopped specifies Service Stopped event
 	EvtServiceStopped = "service stopped"
+	// EvtSourceHTTP specifies source for HTTP requests
+	EvtSourceHTTP = "http"
+	// EvtRequestTooLarge specifies Request Too Large event
+	EvtRequestTooLarge = "request too large"
 )
 
 // ServerEvent specifies server event type
@@ -63,6 +71,8 @@ type Server interface {
 	LocalIP() string
 	Port() string
 	Protocol() string
+	// ListenURLs returns the URLs of the listeners the server is bound to
+	ListenURLs() []*url.URL
 	StartedAt() time.Time
 	Uptime() time.Duration
 	Service(name string) Service
@@ -116,7 +126,7 @@ type HTTPServer struct {
 	port            string
 	ipaddr          string
 	version         string
-	serving         bool
+	serving         int32
 	startedAt       time.Time
 	clientAuth      string
 	scheduler       tasks.Scheduler
@@ -124,6 +134,31 @@ type HTTPServer struct {
 	evtHandlers     map[ServerEvent][]ServerEventFunc
 	lock            sync.RWMutex
 	shutdownTimeout time.Duration
+	middleware      []namedMiddleware
+	listeners       []*serverListener
+	grpcServer      *grpc.Server
+	grpcOptions     []grpc.ServerOption
+	grpcUnary       []grpc.UnaryServerInterceptor
+	grpcStream      []grpc.StreamServerInterceptor
+	h2c             bool
+	loggerOptions   []xhttp.RequestLoggerOption
+	metricsOptions  []xhttp.RequestMetricsOption
+
+	serviceOrder        []string
+	preStopDelay        time.Duration
+	serviceCloseTimeout time.Duration
+	stopping            int32
+	stoppingCh          chan struct{}
+	inflight            inflightTracker
+	cancelBase          context.CancelFunc
+
+	health         *health.Registry
+	healthChecks   []health.Check
+	healthInterval time.Duration
+
+	openapiPath string
+	openapiInfo openapi.Info
+	openapi     *openapi.Document
 }
 
 // New creates a new instance of the server
@@ -155,8 +190,13 @@ func New(
 		port:            GetPort(httpConfig.GetBindAddr()),
 		tlsConfig:       tlsConfig,
 		shutdownTimeout: time.Duration(5) * time.Second,
+
+		serviceCloseTimeout: time.Duration(5) * time.Second,
+		stoppingCh:          make(chan struct{}),
+		healthInterval:      DefaultHealthCheckInterval,
 	}
 	s.muxFactory = s
+	s.registerDefaultMiddleware()
 	if tlsConfig != nil {
 		s.clientAuth = tlsClientAuthToStrMap[tlsConfig.ClientAuth]
 	}
@@ -176,7 +216,13 @@ func (server *HTTPServer) WithAuthz(authz authz.HTTPAuthz) *HTTPServer {
 	return server
 }
 
-// WithIdentityProvider enables to set idenity on each request
+// Authz returns the authorization provider set by WithAuthz, or nil
+func (server *HTTPServer) Authz() authz.HTTPAuthz {
+	return server.authz
+}
+
+// WithIdentityProvider enables to set idenity on each request,
+// use identity.ChainedProvider to combine the providers with fallbacks
 func (server *HTTPServer) WithIdentityProvider(provider identity.ProviderFromRequest) *HTTPServer {
 	server.identityMapper = provider
 	return server
@@ -201,6 +247,20 @@ func (server *HTTPServer) WithShutdownTimeout(timeout time.Duration) *HTTPServer
 	return server
 }
 
+// WithRequestLoggerOptions sets the options of the request logger,
+// such as the structured format, the fields, the sampling and the log levels per path
+func (server *HTTPServer) WithRequestLoggerOptions(opts ...xhttp.RequestLoggerOption) *HTTPServer {
+	server.loggerOptions = append(server.loggerOptions, opts...)
+	return server
+}
+
+// WithRequestMetricsOptions sets the options of the request metrics,
+// such as the path normalization and the identity labels
+func (server *HTTPServer) WithRequestMetricsOptions(opts ...xhttp.RequestMetricsOption) *HTTPServer {
+	server.metricsOptions = append(server.metricsOptions, opts...)
+	return server
+}
+
 var tlsClientAuthToStrMap = map[tls.ClientAuthType]string{
 	tls.NoClientCert:               "NoClientCert",
 	tls.RequestClientCert:          "RequestClientCert",
@@ -213,6 +273,9 @@ var tlsClientAuthToStrMap = map[tls.ClientAuthType]string{
 func (server *HTTPServer) AddService(s Service) {
 	server.lock.Lock()
 	defer server.lock.Unlock()
+	if _, ok := server.services[s.Name()]; !ok {
+		server.serviceOrder = append(server.serviceOrder, s.Name())
+	}
 	server.services[s.Name()] = s
 }
 
@@ -289,9 +352,16 @@ func (server *HTTPServer) TLSConfig() *tls.Config {
 	return server.tlsConfig
 }
 
-// IsReady returns true when the server is ready to serve
+// IsReady returns true when the server is ready to serve,
+// and false when the server is stopping
 func (server *HTTPServer) IsReady() bool {
-	if !server.serving {
+	return !server.IsStopping() && server.isServing()
+}
+
+// isServing returns true when the server and its services can serve the requests,
+// including when the requests are being drained on shutdown
+func (server *HTTPServer) isServing() bool {
+	if atomic.LoadInt32(&server.serving) == 0 {
 		return false
 	}
 	for _, ss := range server.services {
@@ -323,39 +393,41 @@ func (server *HTTPServer) WithMuxFactory(muxFactory MuxFactory) {
 	server.muxFactory = muxFactory
 }
 
-// StartHTTP will verify all the TLS related files are present and start the actual HTTPS listener for the server
+// StartHTTP will verify all the TLS related files are present and start the actual HTTPS listener for the server.
+// The server listens on all URLs specified by ListenURLs, or on BindAddr if ListenURLs is not configured.
 func (server *HTTPServer) StartHTTP() error {
 	bindAddr := server.httpConfig.GetBindAddr()
 	var err error
 
-	// Main server
-	if _, err = net.ResolveTCPAddr("tcp", bindAddr); err != nil {
-		return errors.WithMessagef(err, "reason=ResolveTCPAddr, service=%s, bind=%q",
-			server.Name(), bindAddr)
+	idleTimeout := server.httpConfig.GetIdleTimeout()
+	if idleTimeout <= 0 {
+		idleTimeout = time.Hour * 2
 	}
 
 	server.httpServer = &http.Server{
-		IdleTimeout: time.Hour * 2,
-		ErrorLog:    xlog.Stderr,
+		ReadHeaderTimeout: server.httpConfig.GetReadHeaderTimeout(),
+		ReadTimeout:       server.httpConfig.GetReadTimeout(),
+		WriteTimeout:      server.httpConfig.GetWriteTimeout(),
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    server.httpConfig.GetMaxHeaderBytes(),
+		ErrorLog:          xlog.Stderr,
+		TLSConfig:         server.tlsConfig,
 	}
 
-	var httpsListener net.Listener
-
-	if server.tlsConfig != nil {
-		// Start listening on main server over TLS
-		httpsListener, err = tls.Listen("tcp", bindAddr, server.tlsConfig)
-		if err != nil {
-			return errors.WithMessagef(err, "reason=unable_listen, service=%s, address=%q",
-				server.Name(), bindAddr)
-		}
-
-		server.httpServer.TLSConfig = server.tlsConfig
-	} else {
-		server.httpServer.Addr = bindAddr
+	// the base context is cancelled when the requests were not drained on shutdown
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	server.cancelBase = cancelBase
+	server.httpServer.BaseContext = func(net.Listener) context.Context {
+		return baseCtx
 	}
 
 	httpHandler := server.muxFactory.NewMux()
 
+	if server.tlsConfig != nil && server.GRPCServer() != nil {
+		// gRPC requires HTTP/2
+		server.httpServer.TLSConfig = tlsConfigWithHTTP2(server.tlsConfig)
+	}
+
 	if server.httpConfig.GetAllowProfiling() {
 		if httpHandler, err = xhttp.NewRequestProfiler(httpHandler, server.httpConfig.GetProfilerDir(), nil, xhttp.LogProfile()); err != nil {
 			return errors.WithStack(err)
@@ -364,12 +436,29 @@ func (server *HTTPServer) StartHTTP() error {
 
 	server.httpServer.Handler = httpHandler
 
-	serve := func() error {
-		server.serving = true
-		if httpsListener != nil {
-			return server.httpServer.Serve(httpsListener)
+	listeners, err := server.listen()
+	if err != nil {
+		return err
+	}
+
+	server.lock.Lock()
+	server.listeners = listeners
+	server.lock.Unlock()
+
+	serve := func(l *serverListener) {
+		logger.Infof("service=%s, listener=%s, status=starting",
+			server.Name(), l.url)
+
+		// this is a blocking call to serve
+		if err := server.httpServer.Serve(l); err != nil {
+			atomic.StoreInt32(&server.serving, 0)
+			//panic, only if not Serve error while stopping the server,
+			// which is a valid error
+			if netutil.IsAddrInUse(err) || err != http.ErrServerClosed {
+				logger.Panicf("service=%s, listener=%s, err=[%v]", server.Name(), l.url, errors.WithStack(err))
+			}
+			logger.Warningf("service=%s, listener=%s, status=stopped, reason=[%s]", server.Name(), l.url, err.Error())
 		}
-		return server.httpServer.ListenAndServe()
 	}
 
 	go func() {
@@ -380,19 +469,16 @@ func (server *HTTPServer) StartHTTP() error {
 		logger.Infof("service=%s, port=%v, status=starting, protocol=%s",
 			server.Name(), bindAddr, server.Protocol())
 
-		// this is a blocking call to serve
-		if err := serve(); err != nil {
-			server.serving = false
-			//panic, only if not Serve error while stopping the server,
-			// which is a valid error
-			if netutil.IsAddrInUse(err) || err != http.ErrServerClosed {
-				logger.Panicf("service=%s, err=[%v]", server.Name(), errors.WithStack(err))
-			}
-			logger.Warningf("service=%s, status=stopped, reason=[%s]", server.Name(), err.Error())
+		atomic.StoreInt32(&server.serving, 1)
+		for _, l := range listeners {
+			go serve(l)
 		}
 	}()
 
 	if server.Scheduler() != nil {
+		if registry := server.Health(); registry != nil {
+			registry.Schedule(server.Scheduler(), server.healthInterval)
+		}
 		if server.httpConfig.GetHeartbeatSecs() > 0 {
 			task := tasks.NewTaskAtIntervals(uint64(server.httpConfig.GetHeartbeatSecs()), tasks.Seconds).
 				Do("hearbeat", hearbeatMetricsTask, server)
@@ -407,8 +493,8 @@ func (server *HTTPServer) StartHTTP() error {
 		server.HostName(),
 		server.LocalIP(),
 		0,
-		fmt.Sprintf("address=%q, ClientAuth=%s",
-			strings.TrimPrefix(bindAddr, ":"), server.clientAuth),
+		fmt.Sprintf("address=%q, ClientAuth=%s, listeners=%s",
+			strings.TrimPrefix(bindAddr, ":"), server.clientAuth, listenURLsString(listeners)),
 	)
 
 	return nil
@@ -421,29 +507,52 @@ func hearbeatMetricsTask(server *HTTPServer) {
 
 // StopHTTP will perform a graceful shutdown of the serivce by
 //		1) signally to the Load Balancer to remove this instance from the pool
-//				by changing to response to /availability
+//				by changing IsReady to false, and firing ServerStoppingEvent
 //		2) cause new responses to have their Connection closed when finished
 //				to force clients to re-connect [hopefully to a different instance]
-//		3) wait the minShutdownTime to ensure the LB has noticed the status change
+//		3) wait the preStopDelay to ensure the LB has noticed the status change
 //		4) wait for existing requests to finish processing
 //		5) step 4 is capped by a overrall timeout where we'll give up waiting
-//			 for the requests to complete and will exit.
+//			 for the requests to complete, cancel their context and will exit.
+//		6) close the services in reverse registration order
 //
 // it is expected that you don't try and use the server instance again
 // after this. [i.e. if you want to start it again, create another server instance]
 func (server *HTTPServer) StopHTTP() {
-	// close services
-	for _, f := range server.services {
-		logger.Tracef("service=%q", f.Name())
-		f.Close()
+	if !server.beginStopping() {
+		logger.Warningf("service=%s, reason=already_stopping", server.Name())
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout)
-	defer cancel()
-	err := server.httpServer.Shutdown(ctx)
-	if err != nil {
-		logger.Errorf("reason=Shutdown, err=[%+v]", err)
+	for _, handler := range server.evtHandlers[ServerStoppingEvent] {
+		handler(ServerStoppingEvent)
+	}
+
+	if server.httpServer != nil {
+		server.httpServer.SetKeepAlivesEnabled(false)
+
+		if server.preStopDelay > 0 {
+			logger.Infof("service=%s, status=draining, delay=%s", server.Name(), server.preStopDelay)
+			time.Sleep(server.preStopDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout)
+		defer cancel()
+		err := server.httpServer.Shutdown(ctx)
+		if err != nil {
+			logger.Errorf("reason=Shutdown, in_flight=%v, err=[%+v]", server.InFlightRequests(), err)
+			// cancel the remaining requests
+			server.cancelBase()
+			server.httpServer.Close()
+		}
+		server.cancelBase()
 	}
+	if gs := server.GRPCServer(); gs != nil {
+		// close the remaining gRPC streams
+		gs.Stop()
+	}
+
+	server.closeServices()
 
 	for _, handler := range server.evtHandlers[ServerStoppedEvent] {
 		handler(ServerStoppedEvent)
@@ -462,6 +571,8 @@ func (server *HTTPServer) StopHTTP() {
 
 // NewMux creates a new http handler for the http server, typically you only
 // need to call this directly for tests.
+// The router's handler is wrapped with the middleware pipeline,
+// see Use and RemoveMiddleware to customize it.
 func (server *HTTPServer) NewMux() http.Handler {
 	var router Router
 	if server.cors != nil {
@@ -470,40 +581,60 @@ func (server *HTTPServer) NewMux() http.Handler {
 		router = NewRouter(notFoundHandler)
 	}
 
-	for _, f := range server.services {
+	server.lock.RLock()
+	services := server.orderedServices()
+	server.lock.RUnlock()
+
+	for _, f := range services {
 		f.Register(router)
 	}
+
+	registry, err := server.newHealth()
+	if err != nil {
+		panic(fmt.Sprintf("%+v", err))
+	}
+	server.registerHealth(router, registry)
+	server.lock.Lock()
+	server.health = registry
+	server.lock.Unlock()
+
+	server.registerOpenAPI(router)
 	logger.Debugf("service=%s, service_count=%d",
 		server.Name(), len(server.services))
 
-	var err error
-	httpHandler := router.Handler()
-
 	logger.Infof("service=%s, ClientAuth=%s", server.Name(), server.clientAuth)
 
-	// service ready
-	httpHandler = ready.NewServiceStatusVerifier(server, httpHandler)
-
-	if server.authz != nil {
-		httpHandler, err = server.authz.NewHandler(httpHandler)
-		if err != nil {
-			panic(fmt.Sprintf("%+v", err))
-		}
+	grpcServer := server.newGRPCServer()
+	server.lock.Lock()
+	server.grpcServer = grpcServer
+	server.lock.Unlock()
+
+	handler := router.Handler()
+	if grpcServer != nil {
+		handler = withGRPCRoutes(grpcServer, server.applyMiddleware(&grpcMux{
+			grpc: grpcServer,
+			http: handler,
+		}))
+	} else {
+		handler = server.applyMiddleware(handler)
 	}
+	handler = withVersion(router, handler)
+	if server.h2c {
+		handler = withH2C(handler)
+	}
+	return handler
+}
 
-	// logging wrapper
-	httpHandler = xhttp.NewRequestLogger(httpHandler, server.Name(), serverExtraLogger, time.Millisecond, server.httpConfig.GetPackageLogger())
-
-	// metrics wrapper
-	httpHandler = xhttp.NewRequestMetrics(httpHandler)
-
-	// role/contextID wrapper
-	if server.identityMapper != nil {
-		httpHandler = identity.NewContextHandler(httpHandler, server.identityMapper)
-	} else {
-		httpHandler = identity.NewContextHandler(httpHandler, identity.GuestIdentityMapper)
+// withVersion negotiates API version before the middleware,
+// so the authorization, the rate limits and the metrics
+// are applied to the versioned path that is served by the router
+func withVersion(router Router, handler http.Handler) http.Handler {
+	if v, ok := router.(interface {
+		versionHandler(http.Handler) http.Handler
+	}); ok {
+		return v.versionHandler(handler)
 	}
-	return httpHandler
+	return handler
 }
 
 // ServeHTTP should write reply headers and data to the ResponseWriter
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServiceCloseTimeout can be implemented by a Service
+// that requires specific time to close its resources on shutdown
+type ServiceCloseTimeout interface {
+	CloseTimeout() time.Duration
+}
+
+// WithPreStopDelay sets the time to wait after the server is marked as not ready,
+// before stopping to accept new requests, to allow Load Balancers to notice the change
+func (server *HTTPServer) WithPreStopDelay(delay time.Duration) *HTTPServer {
+	server.preStopDelay = delay
+	return server
+}
+
+// WithServiceCloseTimeout sets the default time to wait for each service to close on shutdown,
+// a service can override it by implementing ServiceCloseTimeout
+func (server *HTTPServer) WithServiceCloseTimeout(timeout time.Duration) *HTTPServer {
+	server.serviceCloseTimeout = timeout
+	return server
+}
+
+// IsStopping returns true when the server is draining the requests on shutdown
+func (server *HTTPServer) IsStopping() bool {
+	return atomic.LoadInt32(&server.stopping) == 1
+}
+
+// Stopping returns a channel that is closed when the server starts draining on shutdown,
+// long-lived handlers, such as streams, should complete when the channel is closed
+func (server *HTTPServer) Stopping() <-chan struct{} {
+	return server.stoppingCh
+}
+
+// InFlightRequests returns the number of requests being processed per route,
+// where the key is the method and the route template, such as "GET /v1/users/:id",
+// or the URL path if the request was not routed yet
+func (server *HTTPServer) InFlightRequests() map[string]int {
+	return server.inflight.counts()
+}
+
+// servingStatus reports the server as ready while draining the requests,
+// to continue serving until Load Balancers notice the change
+type servingStatus struct {
+	server *HTTPServer
+}
+
+// IsReady returns true when the server can serve the requests
+func (s servingStatus) IsReady() bool {
+	return s.server.isServing()
+}
+
+// beginStopping marks the server as not ready and signals the long-lived handlers
+func (server *HTTPServer) beginStopping() bool {
+	if !atomic.CompareAndSwapInt32(&server.stopping, 0, 1) {
+		return false
+	}
+	if registry := server.Health(); registry != nil {
+		// readiness probes must report the status change
+		registry.Expire()
+	}
+	close(server.stoppingCh)
+	return true
+}
+
+// inflightMiddleware tracks the requests being processed
+func (server *HTTPServer) inflightMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ri := withRouteInfo(r)
+		req := &inflightRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			route:  ri,
+		}
+		server.inflight.add(req)
+		defer server.inflight.remove(req)
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type inflightRequest struct {
+	method string
+	path   string
+	route  *routeInfo
+}
+
+func (r *inflightRequest) key() string {
+	if t := r.route.get(); t != "" {
+		return r.method + " " + t
+	}
+	return r.method + " " + r.path
+}
+
+// inflightTracker tracks the requests being processed
+type inflightTracker struct {
+	lock     sync.Mutex
+	requests map[*inflightRequest]struct{}
+}
+
+func (t *inflightTracker) add(r *inflightRequest) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.requests == nil {
+		t.requests = make(map[*inflightRequest]struct{})
+	}
+	t.requests[r] = struct{}{}
+}
+
+func (t *inflightTracker) remove(r *inflightRequest) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.requests, r)
+}
+
+func (t *inflightTracker) counts() map[string]int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	res := make(map[string]int, len(t.requests))
+	for r := range t.requests {
+		res[r.key()]++
+	}
+	return res
+}
+
+// closeServices closes the services in reverse registration order
+func (server *HTTPServer) closeServices() {
+	server.lock.RLock()
+	services := server.orderedServices()
+	server.lock.RUnlock()
+
+	for i := len(services) - 1; i >= 0; i-- {
+		s := services[i]
+
+		timeout := server.serviceCloseTimeout
+		if ct, ok := s.(ServiceCloseTimeout); ok {
+			timeout = ct.CloseTimeout()
+		}
+
+		logger.Tracef("service=%q, status=closing, timeout=%s", s.Name(), timeout)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Close()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			logger.Warningf("service=%q, reason=close_timeout, timeout=%s", s.Name(), timeout)
+		}
+	}
+}
+
+// orderedServices returns the services in registration order
+func (server *HTTPServer) orderedServices() []Service {
+	list := make([]Service, 0, len(server.serviceOrder))
+	for _, name := range server.serviceOrder {
+		list = append(list, server.services[name])
+	}
+	return list
+}
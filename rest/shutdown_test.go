@@ -0,0 +1,222 @@
+package rest_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/testify/auditor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closingService records the order of Close calls
+type closingService struct {
+	name    string
+	closed  *[]string
+	lock    *sync.Mutex
+	block   chan struct{}
+	timeout time.Duration
+	release chan struct{}
+	started chan struct{}
+}
+
+func (s *closingService) Name() string  { return s.name }
+func (s *closingService) IsReady() bool { return true }
+
+func (s *closingService) Close() {
+	if s.block != nil {
+		<-s.block
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	*s.closed = append(*s.closed, s.name)
+}
+
+func (s *closingService) CloseTimeout() time.Duration {
+	return s.timeout
+}
+
+func (s *closingService) Register(r rest.Router) {
+	if s.release == nil {
+		return
+	}
+	r.GET("/v1/slow/:id", func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		s.started <- struct{}{}
+		select {
+		case <-s.release:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 100 && !cond(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.True(t, cond())
+}
+
+func Test_GracefulShutdown(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr: getBindAddr("localhost"),
+	}
+
+	var closed []string
+	lock := &sync.Mutex{}
+
+	slow := &closingService{
+		name:    "slow",
+		closed:  &closed,
+		lock:    lock,
+		timeout: time.Second,
+		release: make(chan struct{}),
+		started: make(chan struct{}, 10),
+	}
+	blocked := &closingService{
+		name:    "blocked",
+		closed:  &closed,
+		lock:    lock,
+		timeout: 100 * time.Millisecond,
+		block:   make(chan struct{}),
+	}
+	last := &closingService{
+		name:    "last",
+		closed:  &closed,
+		lock:    lock,
+		timeout: time.Second,
+	}
+
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(auditor.NewInMemory()).
+		WithPreStopDelay(500 * time.Millisecond).
+		WithShutdownTimeout(5 * time.Second)
+	server.AddService(slow)
+	server.AddService(blocked)
+	server.AddService(last)
+	defer close(blocked.block)
+
+	stoppingReady := make(chan bool, 1)
+	server.OnEvent(rest.ServerStoppingEvent, func(evt rest.ServerEvent) {
+		stoppingReady <- server.IsReady()
+	})
+
+	require.NoError(t, server.StartHTTP())
+	waitFor(t, server.IsReady)
+	assert.False(t, server.IsStopping())
+	assert.Empty(t, server.InFlightRequests())
+
+	url := fmt.Sprintf("http://%s/v1/slow/", cfg.BindAddr)
+
+	results := make(chan int, 10)
+	get := func(id string) {
+		resp, err := http.Get(url + id)
+		if err != nil {
+			results <- 0
+			return
+		}
+		resp.Body.Close()
+		results <- resp.StatusCode
+	}
+
+	go get("1")
+	<-slow.started
+	assert.Equal(t, map[string]int{"GET /v1/slow/:id": 1}, server.InFlightRequests())
+
+	stopped := make(chan struct{})
+	go func() {
+		server.StopHTTP()
+		close(stopped)
+	}()
+
+	select {
+	case <-server.Stopping():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "stopping was not signalled")
+	}
+	assert.True(t, server.IsStopping())
+	assert.False(t, server.IsReady())
+	assert.False(t, <-stoppingReady, "IsReady must be false on ServerStoppingEvent")
+
+	// requests are served while the Load Balancer notices the status change
+	go get("2")
+	<-slow.started
+	assert.Equal(t, map[string]int{"GET /v1/slow/:id": 2}, server.InFlightRequests())
+
+	// the server waits for in-flight requests
+	time.Sleep(700 * time.Millisecond)
+	select {
+	case <-stopped:
+		require.Fail(t, "the server stopped with in-flight requests")
+	default:
+	}
+
+	close(slow.release)
+	assert.Equal(t, http.StatusOK, <-results)
+	assert.Equal(t, http.StatusOK, <-results)
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "the server did not stop")
+	}
+	assert.Empty(t, server.InFlightRequests())
+
+	// reverse registration order, the blocked service timed out
+	lock.Lock()
+	assert.Equal(t, []string{"last", "slow"}, closed)
+	lock.Unlock()
+
+	// second call is ignored
+	server.StopHTTP()
+}
+
+func Test_ShutdownTimeout(t *testing.T) {
+	cfg := &serverConfig{
+		BindAddr: getBindAddr("localhost"),
+	}
+
+	var closed []string
+	slow := &closingService{
+		name:    "slow",
+		closed:  &closed,
+		lock:    &sync.Mutex{},
+		timeout: time.Second,
+		release: make(chan struct{}),
+		started: make(chan struct{}, 1),
+	}
+
+	server, err := rest.New("v1.0.123", "", cfg, nil)
+	require.NoError(t, err)
+	server.WithAuditor(auditor.NewInMemory()).
+		WithShutdownTimeout(200 * time.Millisecond)
+	server.AddService(slow)
+
+	require.NoError(t, server.StartHTTP())
+	waitFor(t, server.IsReady)
+
+	results := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/v1/slow/1", cfg.BindAddr))
+		if err == nil {
+			resp.Body.Close()
+		}
+		results <- err
+	}()
+	<-slow.started
+
+	started := time.Now()
+	server.StopHTTP()
+	assert.True(t, time.Since(started) < 5*time.Second)
+
+	// the request is cancelled and the connection is closed
+	<-results
+	waitFor(t, func() bool { return len(server.InFlightRequests()) == 0 })
+	assert.Equal(t, []string{"slow"}, closed)
+}
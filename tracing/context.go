@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TraceID is W3C Trace Context trace-id
+type TraceID [16]byte
+
+// SpanID is W3C Trace Context parent-id of the span
+type SpanID [8]byte
+
+// IsValid returns true if the ID is not all zeros
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String returns hex encoded ID
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid returns true if the ID is not all zeros
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String returns hex encoded ID
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// FlagSampled is the trace-flags value of the sampled trace
+const FlagSampled byte = 0x01
+
+// SpanContext identifies the span in the trace,
+// and propagates over the process boundaries in traceparent and tracestate headers
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Flags is W3C Trace Context trace-flags
+	Flags byte
+	// TraceState is vendor-specific data of tracestate header
+	TraceState string
+	// Remote is true if the context was received from the remote parent
+	Remote bool
+}
+
+// IsValid returns true if the trace and span IDs are valid
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled returns true if the trace is sampled
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled == FlagSampled
+}
+
+// TraceParent returns the value of traceparent header
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses the value of traceparent header
+func ParseTraceParent(value string) (SpanContext, error) {
+	sc := SpanContext{Remote: true}
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, errors.Errorf("invalid traceparent: %q", value)
+	}
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff {
+		return sc, errors.Errorf("invalid traceparent version: %q", value)
+	}
+	// the future versions may have additional fields
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, errors.Errorf("invalid traceparent: %q", value)
+	}
+
+	traceID, err := decodeHex(parts[1], len(sc.TraceID))
+	if err != nil {
+		return sc, errors.Errorf("invalid trace-id: %q", value)
+	}
+	copy(sc.TraceID[:], traceID)
+
+	spanID, err := decodeHex(parts[2], len(sc.SpanID))
+	if err != nil {
+		return sc, errors.Errorf("invalid parent-id: %q", value)
+	}
+	copy(sc.SpanID[:], spanID)
+
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, errors.Errorf("invalid trace-flags: %q", value)
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, errors.Errorf("invalid traceparent: %q", value)
+	}
+	return sc, nil
+}
+
+// decodeHex decodes lower case hex value of the expected size
+func decodeHex(s string, size int) ([]byte, error) {
+	if len(s) != size*2 || strings.ToLower(s) != s {
+		return nil, errors.New("invalid size")
+	}
+	return hex.DecodeString(s)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,51 @@
+package tracing_test
+
+import (
+	"testing"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseTraceParent(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := tracing.ParseTraceParent(tp)
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.Remote)
+	assert.Equal(t, tp, sc.TraceParent())
+
+	sc, err = tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	assert.False(t, sc.IsSampled())
+
+	// the future version may have additional fields
+	sc, err = tracing.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	require.NoError(t, err)
+	assert.True(t, sc.IsSampled())
+
+	tcases := []struct {
+		tp  string
+		err string
+	}{
+		{"", `invalid traceparent: ""`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", `invalid traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"`},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", `invalid traceparent version: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"`},
+		{"0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", `invalid traceparent version: "0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", `invalid traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"`},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", `invalid trace-id: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", `invalid trace-id: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01", `invalid parent-id: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01"`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", `invalid trace-flags: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1"`},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", `invalid traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"`},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", `invalid traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"`},
+	}
+	for _, tc := range tcases {
+		_, err = tracing.ParseTraceParent(tc.tp)
+		assert.EqualError(t, err, tc.err, tc.tp)
+	}
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"sync"
+
+	"github.com/go-phorce/dolly/xlog"
+)
+
+// Exporter receives the ended sampled spans,
+// for example to send them to the tracing backend.
+// ExportSpan is called synchronously, so the implementation
+// should not block.
+type Exporter interface {
+	ExportSpan(s *SpanData)
+}
+
+// InMemoryExporter keeps the exported spans in memory, useful for tests
+type InMemoryExporter struct {
+	lock  sync.Mutex
+	spans []*SpanData
+}
+
+// NewInMemoryExporter returns InMemoryExporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan implements Exporter interface
+func (e *InMemoryExporter) ExportSpan(s *SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// Spans returns the exported spans, in order of End
+func (e *InMemoryExporter) Spans() []*SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]*SpanData(nil), e.spans...)
+}
+
+// Reset removes the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = nil
+}
+
+type logExporter struct {
+	logger xlog.Logger
+	level  xlog.LogLevel
+}
+
+// NewLogExporter returns Exporter that logs the spans
+func NewLogExporter(logger xlog.Logger, level xlog.LogLevel) Exporter {
+	return &logExporter{
+		logger: logger,
+		level:  level,
+	}
+}
+
+// ExportSpan implements Exporter interface
+func (e *logExporter) ExportSpan(s *SpanData) {
+	e.logger.KV(e.level,
+		"span", s.Name,
+		"kind", s.Kind.String(),
+		"trace_id", s.SpanContext.TraceID.String(),
+		"span_id", s.SpanContext.SpanID.String(),
+		"parent_id", s.ParentSpanID.String(),
+		"duration", s.Duration().String(),
+		"attributes", s.Attributes,
+		"error", s.Error,
+	)
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+)
+
+// Attributes of the HTTP spans
+const (
+	// AttrHTTPMethod is HTTP method
+	AttrHTTPMethod = "http.method"
+	// AttrHTTPTarget is the path of the request
+	AttrHTTPTarget = "http.target"
+	// AttrHTTPRoute is the template of the matched route
+	AttrHTTPRoute = "http.route"
+	// AttrHTTPURL is the URL of the client request
+	AttrHTTPURL = "http.url"
+	// AttrHTTPStatusCode is HTTP status code
+	AttrHTTPStatusCode = "http.status_code"
+	// AttrHTTPRetry is the number of the client request retry
+	AttrHTTPRetry = "http.retry"
+)
+
+// Inject sets traceparent and tracestate headers
+// from the current span in the context
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(header.TraceParent, sc.TraceParent())
+	if sc.TraceState != "" {
+		h.Set(header.TraceState, sc.TraceState)
+	} else {
+		h.Del(header.TraceState)
+	}
+}
+
+// Extract returns the remote span context from traceparent and tracestate headers
+func Extract(h http.Header) (SpanContext, bool) {
+	tp := h.Get(header.TraceParent)
+	if tp == "" {
+		return SpanContext{}, false
+	}
+	sc, err := ParseTraceParent(tp)
+	if err != nil {
+		logger.Debugf("reason=invalid_traceparent, traceparent=%q, err=[%v]", tp, err)
+		return SpanContext{}, false
+	}
+	sc.TraceState = strings.Join(h.Values(header.TraceState), ",")
+	return sc, true
+}
+
+// HTTPOption is an option that can be passed to NewHTTPHandler
+type HTTPOption func(c *httpConfig)
+
+type httpConfig struct {
+	route func(r *http.Request) string
+}
+
+// WithRoute is an Option to provide the template of the route matched by the router,
+// which is used as the name of the span
+func WithRoute(route func(r *http.Request) string) HTTPOption {
+	return func(c *httpConfig) {
+		c.route = route
+	}
+}
+
+type httpHandler struct {
+	handler http.Handler
+	cfg     httpConfig
+}
+
+// NewHTTPHandler returns a handler that starts the server span for each request,
+// continuing the trace from traceparent header of the request
+func NewHTTPHandler(handler http.Handler, opts ...HTTPOption) http.Handler {
+	h := &httpHandler{
+		handler: handler,
+	}
+	for _, opt := range opts {
+		opt(&h.cfg)
+	}
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if sc, ok := Extract(r.Header); ok {
+		ctx = ContextWithRemoteSpanContext(ctx, sc)
+	}
+	ctx, span := StartSpan(ctx, "HTTP "+r.Method, SpanKindServer)
+	defer span.End()
+
+	span.SetAttribute(AttrHTTPMethod, r.Method)
+	span.SetAttribute(AttrHTTPTarget, r.URL.Path)
+
+	sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	r = r.WithContext(ctx)
+	h.handler.ServeHTTP(sw, r)
+
+	if h.cfg.route != nil {
+		if route := h.cfg.route(r); route != "" {
+			span.SetName("HTTP " + r.Method + " " + route)
+			span.SetAttribute(AttrHTTPRoute, route)
+		}
+	}
+	span.SetAttribute(AttrHTTPStatusCode, sw.statusCode)
+	if sw.statusCode >= http.StatusInternalServerError {
+		span.SetError(statusError(sw.statusCode))
+	}
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return http.StatusText(int(e))
+}
+
+// statusWriter captures the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader sets the HTTP status code of the response
+func (w *statusWriter) WriteHeader(sc int) {
+	w.statusCode = sc
+	w.ResponseWriter.WriteHeader(sc)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush sends any buffered data to the client
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,118 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InjectExtract(t *testing.T) {
+	h := http.Header{}
+	tracing.Inject(context.Background(), h)
+	assert.Empty(t, h)
+	_, ok := tracing.Extract(h)
+	assert.False(t, ok)
+
+	h.Set(header.TraceParent, "invalid")
+	_, ok = tracing.Extract(h)
+	assert.False(t, ok)
+
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	h.Set(header.TraceParent, tp)
+	h.Add(header.TraceState, "a=1")
+	h.Add(header.TraceState, "b=2")
+	sc, ok := tracing.Extract(h)
+	require.True(t, ok)
+	assert.Equal(t, "a=1,b=2", sc.TraceState)
+
+	out := http.Header{}
+	out.Set(header.TraceState, "stale=1")
+	tracing.Inject(tracing.ContextWithRemoteSpanContext(context.Background(), sc), out)
+	assert.Equal(t, tp, out.Get(header.TraceParent))
+	assert.Equal(t, "a=1,b=2", out.Get(header.TraceState))
+
+	sc.TraceState = ""
+	tracing.Inject(tracing.ContextWithRemoteSpanContext(context.Background(), sc), out)
+	assert.Empty(t, out.Get(header.TraceState))
+}
+
+func Test_HTTPHandler(t *testing.T) {
+	exp := tracing.NewInMemoryExporter()
+	tracing.SetExporter(exp, nil)
+	defer tracing.SetExporter(nil, nil)
+
+	var current tracing.SpanContext
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current = tracing.SpanContextFromContext(r.Context())
+		if r.URL.Path == "/v1/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	route := func(r *http.Request) string {
+		if r.URL.Path == "/v1/items/123" {
+			return "/v1/items/:id"
+		}
+		return ""
+	}
+	h := tracing.NewHTTPHandler(handler, tracing.WithRoute(route))
+
+	t.Run("remote", func(t *testing.T) {
+		exp.Reset()
+		tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		r := httptest.NewRequest(http.MethodGet, "/v1/items/123", nil)
+		r.Header.Set(header.TraceParent, tp)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", current.TraceID.String())
+		assert.False(t, current.Remote)
+
+		spans := exp.Spans()
+		require.Len(t, spans, 1)
+		s := spans[0]
+		assert.Equal(t, "HTTP GET /v1/items/:id", s.Name)
+		assert.Equal(t, tracing.SpanKindServer, s.Kind)
+		assert.Equal(t, "00f067aa0ba902b7", s.ParentSpanID.String())
+		assert.Equal(t, current.SpanID, s.SpanContext.SpanID)
+		assert.Equal(t, "/v1/items/:id", s.Attributes[tracing.AttrHTTPRoute])
+		assert.Equal(t, "/v1/items/123", s.Attributes[tracing.AttrHTTPTarget])
+		assert.Equal(t, http.MethodGet, s.Attributes[tracing.AttrHTTPMethod])
+		assert.Equal(t, http.StatusOK, s.Attributes[tracing.AttrHTTPStatusCode])
+		assert.Empty(t, s.Error)
+	})
+
+	t.Run("new", func(t *testing.T) {
+		exp.Reset()
+		r := httptest.NewRequest(http.MethodPost, "/v1/fail", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.True(t, current.IsValid())
+
+		spans := exp.Spans()
+		require.Len(t, spans, 1)
+		s := spans[0]
+		assert.Equal(t, "HTTP POST", s.Name)
+		assert.False(t, s.ParentSpanID.IsValid())
+		assert.Equal(t, http.StatusInternalServerError, s.Attributes[tracing.AttrHTTPStatusCode])
+		assert.Equal(t, "Internal Server Error", s.Error)
+	})
+
+	t.Run("unsampled", func(t *testing.T) {
+		exp.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/v1/items/123", nil)
+		r.Header.Set(header.TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", current.TraceID.String())
+		assert.Empty(t, exp.Spans())
+	})
+}
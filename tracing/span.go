@@ -0,0 +1,203 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship of the span to its parent and children
+type SpanKind int
+
+const (
+	// SpanKindInternal is the internal operation of the application
+	SpanKindInternal SpanKind = iota
+	// SpanKindServer is the server side of the remote request
+	SpanKindServer
+	// SpanKindClient is the client side of the remote request
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// SpanData is the snapshot of the ended span, passed to the Exporter
+type SpanData struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	// Error is the error description, if the operation failed
+	Error string
+}
+
+// Duration returns the duration of the span
+func (d *SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Span represents the operation in the trace.
+// The methods of nil Span are no-op.
+type Span struct {
+	lock     sync.Mutex
+	data     SpanData
+	ended    bool
+	exporter Exporter
+}
+
+// SpanContext returns the context of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording returns true if the span is sampled and will be exported
+func (s *Span) IsRecording() bool {
+	return s != nil && s.exporter != nil
+}
+
+// SetName sets the name of the span
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute sets the attribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the operation as failed
+func (s *Span) SetError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End completes the span and exports it, if the span is sampled.
+// Only the first call is effective.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.lock.Unlock()
+
+	s.exporter.ExportSpan(&data)
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// ContextWithSpan returns a copy of the context with the span
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, s)
+}
+
+// SpanFromContext returns the current span from the context,
+// or nil if the context has no span
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteSpanContext returns a copy of the context with the remote parent,
+// for example extracted from the incoming request
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the context of the current span,
+// or of the remote parent if the context has no span
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if s := SpanFromContext(ctx); s != nil {
+		return s.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteContextKey{}).(SpanContext)
+	return sc
+}
+
+// StartSpan starts a new span, the child of the current span in the context,
+// and returns a copy of the context with the new span.
+// The caller must call End on the returned span.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := getTracer()
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{
+		SpanID: newSpanID(),
+	}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		if t.exporter != nil && t.sampler(sc.TraceID) {
+			sc.Flags |= FlagSampled
+		}
+	}
+
+	s := &Span{
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  sc,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+		},
+	}
+	if sc.IsSampled() {
+		s.exporter = t.exporter
+	}
+	return ContextWithSpan(ctx, s), s
+}
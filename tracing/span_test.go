@@ -0,0 +1,147 @@
+package tracing_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "tracing_test")
+
+func Test_StartSpan(t *testing.T) {
+	exp := tracing.NewInMemoryExporter()
+	tracing.SetExporter(exp, nil)
+	defer tracing.SetExporter(nil, nil)
+
+	ctx, root := tracing.StartSpan(context.Background(), "root", tracing.SpanKindServer)
+	assert.True(t, root.IsRecording())
+	assert.Equal(t, root, tracing.SpanFromContext(ctx))
+	rsc := root.SpanContext()
+	assert.True(t, rsc.IsValid())
+	assert.True(t, rsc.IsSampled())
+	assert.False(t, rsc.Remote)
+
+	_, child := tracing.StartSpan(ctx, "child", tracing.SpanKindClient)
+	csc := child.SpanContext()
+	assert.Equal(t, rsc.TraceID, csc.TraceID)
+	assert.NotEqual(t, rsc.SpanID, csc.SpanID)
+
+	child.SetAttribute("key", "value")
+	child.SetError(errors.New("failed"))
+	child.SetError(nil)
+	child.End()
+	// the attributes after End are not exported
+	child.SetAttribute("key", "changed")
+	child.End()
+	root.SetName("root2")
+	root.End()
+
+	spans := exp.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, tracing.SpanKindClient, spans[0].Kind)
+	assert.Equal(t, rsc.SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, spans[0].Attributes)
+	assert.Equal(t, "failed", spans[0].Error)
+	assert.True(t, spans[0].Duration() >= 0)
+	assert.Equal(t, "root2", spans[1].Name)
+	assert.False(t, spans[1].ParentSpanID.IsValid())
+
+	exp.Reset()
+	assert.Empty(t, exp.Spans())
+
+	assert.Equal(t, "server", tracing.SpanKindServer.String())
+	assert.Equal(t, "client", tracing.SpanKindClient.String())
+	assert.Equal(t, "internal", tracing.SpanKindInternal.String())
+}
+
+func Test_StartSpanRemote(t *testing.T) {
+	exp := tracing.NewInMemoryExporter()
+	tracing.SetExporter(exp, tracing.NeverSample)
+	defer tracing.SetExporter(nil, nil)
+
+	// the new trace is not sampled
+	_, span := tracing.StartSpan(nil, "root", tracing.SpanKindInternal)
+	assert.True(t, span.SpanContext().IsValid())
+	assert.False(t, span.SpanContext().IsSampled())
+	assert.False(t, span.IsRecording())
+	span.SetAttribute("key", "value")
+	span.End()
+	assert.Empty(t, exp.Spans())
+
+	// the remote parent is sampled
+	remote, err := tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	remote.TraceState = "vendor=value"
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), remote)
+	assert.Equal(t, remote, tracing.SpanContextFromContext(ctx))
+
+	_, span = tracing.StartSpan(ctx, "server", tracing.SpanKindServer)
+	sc := span.SpanContext()
+	assert.Equal(t, remote.TraceID, sc.TraceID)
+	assert.Equal(t, "vendor=value", sc.TraceState)
+	assert.True(t, sc.IsSampled())
+	span.End()
+
+	spans := exp.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, remote.SpanID, spans[0].ParentSpanID)
+}
+
+func Test_NilSpan(t *testing.T) {
+	var span *tracing.Span
+	assert.False(t, span.IsRecording())
+	assert.False(t, span.SpanContext().IsValid())
+	span.SetName("name")
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("failed"))
+	span.End()
+
+	assert.Nil(t, tracing.SpanFromContext(nil))
+	assert.False(t, tracing.SpanContextFromContext(nil).IsValid())
+	assert.False(t, tracing.SpanContextFromContext(context.Background()).IsValid())
+}
+
+func Test_Samplers(t *testing.T) {
+	id := tracing.TraceID{1}
+	assert.True(t, tracing.AlwaysSample(id))
+	assert.False(t, tracing.NeverSample(id))
+	assert.True(t, tracing.RatioSampler(1)(id))
+	assert.False(t, tracing.RatioSampler(0)(id))
+
+	sampled := 0
+	s := tracing.RatioSampler(0.5)
+	for i := 0; i < 1000; i++ {
+		if s(id) {
+			sampled++
+		}
+	}
+	assert.True(t, sampled > 300 && sampled < 700, "sampled %d", sampled)
+}
+
+func Test_LogContext(t *testing.T) {
+	var b bytes.Buffer
+	writer := bufio.NewWriter(&b)
+	xlog.SetFormatter(xlog.NewStringFormatter(writer).WithCaller(true))
+
+	ctx, span := tracing.StartSpan(context.Background(), "root", tracing.SpanKindInternal)
+	sc := span.SpanContext()
+
+	xlog.WithContext(ctx, logger).Infof("status=ok")
+	assert.Contains(t, b.String(), `src=Test_LogContext, trace_id="`+sc.TraceID.String()+`", span_id="`+sc.SpanID.String()+`", status=ok`)
+	b.Reset()
+
+	tracing.NewLogExporter(logger, xlog.INFO).ExportSpan(&tracing.SpanData{
+		Name:        "root",
+		SpanContext: sc,
+		Attributes:  map[string]interface{}{"key": 1},
+	})
+	assert.Contains(t, b.String(), `span="root", kind="internal", trace_id="`+sc.TraceID.String()+`"`)
+}
@@ -0,0 +1,81 @@
+// Package tracing provides distributed tracing with W3C Trace Context propagation.
+//
+// The spans are started per request by the server middleware and per attempt
+// by the HTTP client, and the ended spans are sent to the Exporter
+// configured by SetExporter.
+//
+// The trace and span IDs are attached to the logs returned by xlog.WithContext,
+// which is used by the request errors logged by xhttp and rest packages,
+// and to the messages of audit.WithContext and audit.ContextMessage,
+// which is used by the request events audited by rest.HTTPServer.
+// The access log includes the trace ID with xhttp.LogFieldTraceID field.
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/go-phorce/dolly/xlog"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "tracing")
+
+// Sampler decides if the new trace is sampled
+type Sampler func(traceID TraceID) bool
+
+// AlwaysSample samples all traces
+func AlwaysSample(TraceID) bool {
+	return true
+}
+
+// NeverSample samples no traces
+func NeverSample(TraceID) bool {
+	return false
+}
+
+// RatioSampler samples the specified fraction of traces, from 0 to 1
+func RatioSampler(rate float64) Sampler {
+	return func(TraceID) bool {
+		return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+	}
+}
+
+type tracer struct {
+	exporter Exporter
+	sampler  Sampler
+}
+
+var globalTracer atomic.Value // *tracer
+
+func init() {
+	globalTracer.Store(&tracer{sampler: AlwaysSample})
+
+	xlog.RegisterContextValuesProvider(func(ctx context.Context) []interface{} {
+		sc := SpanContextFromContext(ctx)
+		if !sc.IsValid() {
+			return nil
+		}
+		return []interface{}{"trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String()}
+	})
+}
+
+func getTracer() *tracer {
+	return globalTracer.Load().(*tracer)
+}
+
+// SetExporter sets the global exporter of the sampled spans,
+// and the sampler of the new traces, AlwaysSample if nil.
+// The spans of the traces continued from the remote parent
+// are sampled as decided by the parent.
+// If exporter is nil, then the spans are not recorded,
+// but the trace context is still propagated.
+func SetExporter(exporter Exporter, sampler Sampler) {
+	if sampler == nil {
+		sampler = AlwaysSample
+	}
+	globalTracer.Store(&tracer{
+		exporter: exporter,
+		sampler:  sampler,
+	})
+}
@@ -0,0 +1,376 @@
+package xhttp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xlog"
+)
+
+// LogFormat specifies the format of the request log
+type LogFormat string
+
+const (
+	// LogFormatText is the default colon-separated format,
+	// see NewRequestLogger
+	LogFormatText LogFormat = "text"
+	// LogFormatKV logs the fields as key=value pairs with xlog.Logger.KV
+	LogFormatKV LogFormat = "kv"
+	// LogFormatJSON logs the fields as JSON object
+	LogFormatJSON LogFormat = "json"
+	// LogFormatCombined logs in Apache/NCSA combined format
+	LogFormatCombined LogFormat = "combined"
+)
+
+// The fields of the structured request log
+const (
+	// LogFieldServer is the prefix of the request logger, usually the server name
+	LogFieldServer = "server"
+	// LogFieldMethod is HTTP method
+	LogFieldMethod = "method"
+	// LogFieldHost is the host of the request
+	LogFieldHost = "host"
+	// LogFieldPath is URL path
+	LogFieldPath = "path"
+	// LogFieldRoute is the template of the matched route, see WithLoggerRoute
+	LogFieldRoute = "route"
+	// LogFieldStatus is HTTP status code
+	LogFieldStatus = "status"
+	// LogFieldRemoteAddr is the remote address of the connection
+	LogFieldRemoteAddr = "remote"
+	// LogFieldClientIP is the IP of the client, as reported by the proxies
+	LogFieldClientIP = "client_ip"
+	// LogFieldProto is HTTP protocol version
+	LogFieldProto = "proto"
+	// LogFieldRequestSize is the number of bytes read from the request body
+	LogFieldRequestSize = "req_bytes"
+	// LogFieldResponseSize is the number of bytes written to the response body
+	LogFieldResponseSize = "resp_bytes"
+	// LogFieldDuration is the duration of the request, in the logger granularity
+	LogFieldDuration = "duration"
+	// LogFieldUpstream is the duration of the upstream calls, in the logger granularity,
+	// see AddUpstreamDuration
+	LogFieldUpstream = "upstream"
+	// LogFieldAgent is User-Agent header
+	LogFieldAgent = "agent"
+	// LogFieldReferer is Referer header
+	LogFieldReferer = "referer"
+	// LogFieldCorrelationID is the correlation ID of the request
+	LogFieldCorrelationID = "correlation_id"
+	// LogFieldTraceID is W3C Trace Context trace-id of the request
+	LogFieldTraceID = "trace_id"
+	// LogFieldSpanID is the ID of the server span of the request
+	LogFieldSpanID = "span_id"
+	// LogFieldIdentity is the name of the caller identity
+	LogFieldIdentity = "identity"
+	// LogFieldRole is the role of the caller identity
+	LogFieldRole = "role"
+	// LogFieldIdentityProvider is the name of the provider of the caller identity,
+	// see identity.ChainedProvider
+	LogFieldIdentityProvider = "identity_provider"
+	// LogFieldClientCN is CN of the client certificate
+	LogFieldClientCN = "client_cn"
+	// LogFieldTLSVersion is TLS version of the connection
+	LogFieldTLSVersion = "tls_version"
+	// LogFieldTLSCipher is TLS cipher suite of the connection
+	LogFieldTLSCipher = "tls_cipher"
+)
+
+// DefaultLogFields specifies the fields of the structured request log,
+// if not configured by WithLoggerFields
+var DefaultLogFields = []string{
+	LogFieldServer,
+	LogFieldMethod,
+	LogFieldPath,
+	LogFieldRoute,
+	LogFieldStatus,
+	LogFieldRemoteAddr,
+	LogFieldProto,
+	LogFieldRequestSize,
+	LogFieldResponseSize,
+	LogFieldDuration,
+	LogFieldAgent,
+	LogFieldCorrelationID,
+	LogFieldTraceID,
+	LogFieldRole,
+	LogFieldIdentity,
+	LogFieldIdentityProvider,
+}
+
+// LoggerPathLevel allows to specify a log level for specified Path,
+// the Path ending with "*" matches as a prefix
+type LoggerPathLevel struct {
+	Path  string `json:"path,omitempty" yaml:"path,omitempty"`
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+// WithLoggerFormat is an Option to specify the format of the log
+func WithLoggerFormat(format LogFormat) RequestLoggerOption {
+	return func(c *configuration) {
+		c.format = format
+	}
+}
+
+// WithLoggerFields is an Option to specify the fields of KV and JSON log formats
+func WithLoggerFields(fields ...string) RequestLoggerOption {
+	return func(c *configuration) {
+		c.fields = fields
+	}
+}
+
+// WithLoggerSampling is an Option to log only the specified fraction
+// of successful requests, from 0 to 1, the failed requests are always logged
+func WithLoggerSampling(rate float64) RequestLoggerOption {
+	return func(c *configuration) {
+		c.sampling = rate
+	}
+}
+
+// WithLoggerPathLevels is an Option to specify the log level on path match,
+// the requests are logged at INFO level by default
+func WithLoggerPathLevels(value []LoggerPathLevel) RequestLoggerOption {
+	return func(c *configuration) {
+		for _, pl := range value {
+			level, err := xlog.ParseLevel(strings.ToUpper(pl.Level))
+			if err != nil {
+				logger.Errorf("reason=invalid_level, path=%q, level=%q", pl.Path, pl.Level)
+				continue
+			}
+			c.pathLevels = append(c.pathLevels, pathLevel{path: pl.Path, level: level})
+		}
+	}
+}
+
+// WithLoggerRoute is an Option to provide the template of the route matched by the router
+func WithLoggerRoute(route func(r *http.Request) string) RequestLoggerOption {
+	return func(c *configuration) {
+		c.route = route
+	}
+}
+
+type pathLevel struct {
+	path  string
+	level xlog.LogLevel
+}
+
+func (c *configuration) level(path string) xlog.LogLevel {
+	for _, pl := range c.pathLevels {
+		if matchPath(pl.path, path) {
+			return pl.level
+		}
+	}
+	return xlog.INFO
+}
+
+func (c *configuration) sampled(statusCode int) bool {
+	if c.sampling >= 1 || statusCode >= 400 {
+		return true
+	}
+	return c.sampling > 0 && rand.Float64() < c.sampling
+}
+
+// accessRecord holds the values of the log fields
+type accessRecord struct {
+	cfg          *configuration
+	r            *http.Request
+	rw           *ResponseCapture
+	start        time.Time
+	duration     time.Duration
+	requestBytes uint64
+}
+
+func (a *accessRecord) value(field string) interface{} {
+	r := a.r
+	switch field {
+	case LogFieldServer:
+		return a.cfg.prefix
+	case LogFieldMethod:
+		return r.Method
+	case LogFieldHost:
+		return r.Host
+	case LogFieldPath:
+		return r.URL.Path
+	case LogFieldRoute:
+		if a.cfg.route != nil {
+			return a.cfg.route(r)
+		}
+		return ""
+	case LogFieldStatus:
+		return a.rw.StatusCode()
+	case LogFieldRemoteAddr:
+		return r.RemoteAddr
+	case LogFieldClientIP:
+		return identity.FromRequest(r).ClientIP()
+	case LogFieldProto:
+		return fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)
+	case LogFieldRequestSize:
+		return a.requestBytes
+	case LogFieldResponseSize:
+		return a.rw.BodySize()
+	case LogFieldDuration:
+		return a.duration.Nanoseconds() / a.cfg.granularity
+	case LogFieldUpstream:
+		return UpstreamDuration(r.Context()).Nanoseconds() / a.cfg.granularity
+	case LogFieldAgent:
+		return r.Header.Get(header.UserAgent)
+	case LogFieldReferer:
+		return r.Referer()
+	case LogFieldCorrelationID:
+		return identity.FromRequest(r).CorrelationID()
+	case LogFieldTraceID:
+		if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+			return sc.TraceID.String()
+		}
+		return ""
+	case LogFieldSpanID:
+		if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+			return sc.SpanID.String()
+		}
+		return ""
+	case LogFieldIdentity:
+		return identity.FromRequest(r).Identity().Name()
+	case LogFieldRole:
+		return identity.FromRequest(r).Identity().Role()
+	case LogFieldIdentityProvider:
+		return identity.FromRequest(r).Provider()
+	case LogFieldClientCN:
+		return clientCN(r)
+	case LogFieldTLSVersion:
+		if r.TLS == nil {
+			return ""
+		}
+		return tlsVersionName(r.TLS.Version)
+	case LogFieldTLSCipher:
+		if r.TLS == nil {
+			return ""
+		}
+		return tls.CipherSuiteName(r.TLS.CipherSuite)
+	}
+	return ""
+}
+
+func (a *accessRecord) fields() []string {
+	if len(a.cfg.fields) > 0 {
+		return a.cfg.fields
+	}
+	return DefaultLogFields
+}
+
+// kv returns the key/value pairs of the fields
+func (a *accessRecord) kv() []interface{} {
+	fields := a.fields()
+	entries := make([]interface{}, 0, len(fields)*2)
+	for _, f := range fields {
+		entries = append(entries, f, a.value(f))
+	}
+	return entries
+}
+
+// json returns the fields as JSON object, the fields are written in the configured order
+func (a *accessRecord) json() string {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range a.fields() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeJSON(&b, f)
+		b.WriteByte(':')
+		writeJSON(&b, a.value(f))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		b.WriteString(`""`)
+		return
+	}
+	b.Write(bytes.TrimRight(tmp.Bytes(), "\n"))
+}
+
+// combined returns the log line in Apache/NCSA combined format
+func (a *accessRecord) combined() string {
+	r := a.r
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	user := identity.FromRequest(r).Identity().Name()
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	size := "-"
+	if n := a.rw.BodySize(); n > 0 {
+		size = fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q",
+		dash(host),
+		dash(user),
+		a.start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+uri+" "+r.Proto,
+		a.rw.StatusCode(),
+		size,
+		dash(r.Referer()),
+		dash(r.Header.Get(header.UserAgent)))
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func clientCN(r *http.Request) string {
+	if r.TLS == nil {
+		return ""
+	}
+	pc := r.TLS.PeerCertificates
+	if len(pc) == 0 {
+		return ""
+	}
+	return pc[0].Subject.CommonName
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += uint64(n)
+	return n, err
+}
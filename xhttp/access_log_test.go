@@ -0,0 +1,197 @@
+package xhttp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveAccessLog(t *testing.T, h http.Handler, r *http.Request, opts ...RequestLoggerOption) string {
+	tw := bytes.Buffer{}
+	writer := bufio.NewWriter(&tw)
+	xlog.SetFormatter(xlog.NewPrettyFormatter(writer, false))
+
+	lg := NewRequestLogger(h, "BOB", nil, time.Millisecond, "", opts...)
+	lg.ServeHTTP(httptest.NewRecorder(), r)
+	return tw.String()
+}
+
+func echoHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			ioutil.ReadAll(r.Body)
+		}
+		AddUpstreamDuration(r.Context(), 3*time.Millisecond)
+		AddUpstreamDuration(r.Context(), 2*time.Millisecond)
+		w.WriteHeader(status)
+		w.Write([]byte("hello"))
+	})
+}
+
+func newAccessLogRequest(t *testing.T, method, path, body string) *http.Request {
+	r, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(t, err)
+	r.RemoteAddr = "10.0.0.1:51500"
+	r.Header.Set(header.UserAgent, "test-agent")
+	r.Header.Set("Referer", "https://example.com/")
+	return r
+}
+
+func Test_AccessLogKV(t *testing.T) {
+	r := newAccessLogRequest(t, http.MethodPost, "/v1/items", `{"id":1}`)
+	logLine := serveAccessLog(t, echoHandler(http.StatusCreated), r,
+		WithLoggerFormat(LogFormatKV),
+		WithLoggerFields(LogFieldServer, LogFieldMethod, LogFieldPath, LogFieldStatus, LogFieldRequestSize, LogFieldResponseSize, LogFieldUpstream, LogFieldAgent, LogFieldRole),
+	)
+	assert.Contains(t, logLine, ` I | xhttp: src=ServeHTTP, server="BOB", method="POST", path="/v1/items", status=201, req_bytes=8, resp_bytes=5, upstream=5, agent="test-agent", role="guest"`)
+	assert.NotContains(t, logLine, "remote=")
+}
+
+func Test_AccessLogIdentityProvider(t *testing.T) {
+	r := newAccessLogRequest(t, http.MethodGet, "/v1/items", "")
+	r = identity.WithTestIdentity(r, identity.WithProviderName(identity.NewIdentity("service", "billing", ""), "apikey"))
+	logLine := serveAccessLog(t, echoHandler(http.StatusOK), r,
+		WithLoggerFormat(LogFormatKV),
+		WithLoggerFields(LogFieldMethod, LogFieldRole, LogFieldIdentity, LogFieldIdentityProvider),
+	)
+	assert.Contains(t, logLine, `src=ServeHTTP, method="GET", role="service", identity="billing", identity_provider="apikey"`)
+}
+
+func Test_AccessLogJSON(t *testing.T) {
+	r := newAccessLogRequest(t, http.MethodGet, "/v1/items/1?x=<y>", "")
+	logLine := serveAccessLog(t, echoHandler(http.StatusOK), r,
+		WithLoggerFormat(LogFormatJSON),
+		WithLoggerRoute(func(*http.Request) string { return "/v1/items/:id" }),
+	)
+	idx := strings.Index(logLine, "{")
+	require.True(t, idx > 0, logLine)
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(logLine[idx:]), &rec), logLine)
+	assert.Equal(t, "BOB", rec[LogFieldServer])
+	assert.Equal(t, "GET", rec[LogFieldMethod])
+	assert.Equal(t, "/v1/items/1", rec[LogFieldPath])
+	assert.Equal(t, "/v1/items/:id", rec[LogFieldRoute])
+	assert.Equal(t, float64(200), rec[LogFieldStatus])
+	assert.Equal(t, "10.0.0.1:51500", rec[LogFieldRemoteAddr])
+	assert.Equal(t, "1.1", rec[LogFieldProto])
+	assert.Equal(t, float64(5), rec[LogFieldResponseSize])
+	assert.Equal(t, "test-agent", rec[LogFieldAgent])
+	assert.Len(t, rec, len(DefaultLogFields))
+
+	// the fields are logged in the configured order
+	assert.True(t, strings.HasPrefix(logLine[idx:], `{"server":"BOB","method":"GET","path":"/v1/items/1","route":"/v1/items/:id",`), logLine)
+}
+
+func Test_AccessLogCombined(t *testing.T) {
+	r := newAccessLogRequest(t, http.MethodGet, "/v1/items?limit=10", "")
+	logLine := serveAccessLog(t, echoHandler(http.StatusNotFound), r, WithLoggerFormat(LogFormatCombined))
+	assert.Contains(t, logLine, ` I | xhttp: src=ServeHTTP, 10.0.0.1 - - [`)
+	assert.Contains(t, logLine, `] "GET /v1/items?limit=10 HTTP/1.1" 404 5 "https://example.com/" "test-agent"`)
+}
+
+func Test_AccessLogTLS(t *testing.T) {
+	r := newAccessLogRequest(t, http.MethodGet, "/v1/items", "")
+	r.TLS = &tls.ConnectionState{
+		Version:     tls.VersionTLS13,
+		CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+		PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{CommonName: "client.example.com"}},
+		},
+	}
+	logLine := serveAccessLog(t, echoHandler(http.StatusOK), r,
+		WithLoggerFormat(LogFormatKV),
+		WithLoggerFields(LogFieldClientCN, LogFieldTLSVersion, LogFieldTLSCipher, LogFieldHost),
+	)
+	assert.Contains(t, logLine, `client_cn="client.example.com", tls_version="TLS1.3", tls_cipher="TLS_AES_128_GCM_SHA256"`)
+
+	assert.Equal(t, "0x0305", tlsVersionName(0x0305))
+	for _, v := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12} {
+		assert.True(t, strings.HasPrefix(tlsVersionName(v), "TLS1."))
+	}
+}
+
+func Test_AccessLogSampling(t *testing.T) {
+	opts := []RequestLoggerOption{WithLoggerSampling(0)}
+
+	r := newAccessLogRequest(t, http.MethodGet, "/v1/items", "")
+	assert.Empty(t, serveAccessLog(t, echoHandler(http.StatusOK), r, opts...))
+
+	// the failed requests are always logged
+	r = newAccessLogRequest(t, http.MethodGet, "/v1/items", "")
+	assert.Contains(t, serveAccessLog(t, echoHandler(http.StatusInternalServerError), r, opts...), "/v1/items")
+
+	cfg := &configuration{sampling: 0.5}
+	logged := 0
+	for i := 0; i < 1000; i++ {
+		if cfg.sampled(http.StatusOK) {
+			logged++
+		}
+	}
+	assert.True(t, logged > 300 && logged < 700, "logged %d", logged)
+}
+
+func Test_AccessLogPathLevels(t *testing.T) {
+	opts := []RequestLoggerOption{
+		WithLoggerPathLevels([]LoggerPathLevel{
+			{Path: "/v1/status", Level: "warning"},
+			{Path: "/v1/admin/*", Level: "ERROR"},
+			{Path: "/v1/bad", Level: "LOUD"},
+		}),
+	}
+
+	tcases := []struct {
+		path  string
+		level string
+	}{
+		{"/v1/status", " W | "},
+		{"/v1/status/node", " I | "},
+		{"/v1/admin/users", " E | "},
+		{"/v1/bad", " I | "},
+	}
+	for _, tc := range tcases {
+		r := newAccessLogRequest(t, http.MethodGet, tc.path, "")
+		logLine := serveAccessLog(t, echoHandler(http.StatusOK), r, opts...)
+		assert.Contains(t, logLine, tc.level+"xhttp: src=ServeHTTP, BOB::GET:"+tc.path+":", tc.path)
+	}
+
+	cfg := &configuration{}
+	WithLoggerPathLevels([]LoggerPathLevel{{Path: "/trace", Level: "trace"}})(cfg)
+	assert.Equal(t, xlog.TRACE, cfg.level("/trace"))
+	assert.Equal(t, xlog.INFO, cfg.level("/other"))
+}
+
+func Test_UpstreamDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), UpstreamDuration(nil))
+	AddUpstreamDuration(nil, time.Second)
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	AddUpstreamDuration(r.Context(), time.Second)
+	assert.Equal(t, time.Duration(0), UpstreamDuration(r.Context()))
+
+	r, timer := withUpstreamTimer(r)
+	AddUpstreamDuration(r.Context(), time.Second)
+	AddUpstreamDuration(r.Context(), time.Second)
+	assert.Equal(t, 2*time.Second, UpstreamDuration(r.Context()))
+
+	// the timer is reused
+	r2, timer2 := withUpstreamTimer(r)
+	assert.Equal(t, r, r2)
+	assert.Equal(t, timer, timer2)
+}
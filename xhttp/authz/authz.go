@@ -78,7 +78,7 @@ type HTTPAuthz interface {
 // You can call Allow or AllowAny to specify which roles are allowed
 // access to which path segments.
 // once configured you can create a Unary interceptor that enforces that
-// configuration for you by calling NewUnaryInterceptor
+// configuration for you by calling NewUnaryInterceptor or NewStreamInterceptor
 type GRPCAuthz interface {
 	// SetGRPCRoleMapper configures the function that provides
 	// the mapping from a gRPC request to a role name
@@ -89,6 +89,26 @@ type GRPCAuthz interface {
 	// URI being request, and either return an error, or pass the request on to the supplied
 	// delegate handler
 	NewUnaryInterceptor() grpc.UnaryServerInterceptor
+	// NewStreamInterceptor returns grpc.StreamServerInterceptor that enforces the current
+	// authorization configuration for streaming calls.
+	NewStreamInterceptor() grpc.StreamServerInterceptor
+}
+
+// Access describes the access allowed to a path
+type Access struct {
+	// AllowAny specifies that any request is allowed
+	AllowAny bool
+	// AllowAnyRole specifies that any request with a non empty role is allowed
+	AllowAnyRole bool
+	// Roles specifies the allowed roles, sorted alphabetically
+	Roles []string
+}
+
+// AccessDescriber provides the description of the configured access,
+// for example to document the allowed roles of API
+type AccessDescriber interface {
+	// AllowedAccess returns the access allowed to the path
+	AllowedAccess(path string) Access
 }
 
 // Config contains configuration for the authorization module
@@ -376,6 +396,20 @@ func (c *Provider) walkPath(path string, create bool) *pathNode {
 	return currentNode
 }
 
+// AllowedAccess returns the access allowed to the path,
+// as configured on the deepest node matching the path
+func (c *Provider) AllowedAccess(path string) Access {
+	if c.pathRoot == nil || len(path) == 0 || path[0] != '/' {
+		return Access{}
+	}
+	node := c.walkPath(path, false)
+	return Access{
+		AllowAny:     node.allowAny(),
+		AllowAnyRole: (node.allow & allowAnyRole) != 0,
+		Roles:        node.allowedRoleKeys(),
+	}
+}
+
 // isAllowed returns true if access to 'path' is allowed for the specified role.
 func (c *Provider) isAllowed(path, role string) bool {
 	node := c.walkPath(path, false)
@@ -467,3 +501,18 @@ func (c *Provider) NewUnaryInterceptor() grpc.UnaryServerInterceptor {
 		return handler(ctx, req)
 	}
 }
+
+// NewStreamInterceptor returns grpc.StreamServerInterceptor to check access
+func (c *Provider) NewStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		role := c.grpcRoleMapper(ss.Context())
+		if role == "" {
+			role = identity.GuestRoleName
+		}
+		if !c.isAllowed(info.FullMethod, role) {
+			return status.Errorf(codes.PermissionDenied, "the %q role is not allowed", role)
+		}
+
+		return handler(srv, ss)
+	}
+}
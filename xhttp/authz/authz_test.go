@@ -204,6 +204,25 @@ func TestConfig_AllowAny(t *testing.T) {
 	check("/foo/eve", "barry", true)
 }
 
+func TestConfig_AllowedAccess(t *testing.T) {
+	c := &Provider{cfg: &Config{}}
+	assert.Equal(t, Access{}, c.AllowedAccess("/"))
+
+	c.Allow("/v1/users", "admin", "ops")
+	c.Allow("/v1/users/self", "user")
+	c.AllowAnyRole("/v1/profile")
+	c.AllowAny("/v1/status")
+
+	assert.Equal(t, Access{Roles: []string{}}, c.AllowedAccess("/v1"))
+	assert.Equal(t, Access{}, c.AllowedAccess(""))
+	assert.Equal(t, Access{Roles: []string{"admin", "ops"}}, c.AllowedAccess("/v1/users"))
+	assert.Equal(t, Access{Roles: []string{"admin", "ops"}}, c.AllowedAccess("/v1/users/:id"))
+	// the child path overrides the parent
+	assert.Equal(t, Access{Roles: []string{"user"}}, c.AllowedAccess("/v1/users/self"))
+	assert.Equal(t, Access{AllowAnyRole: true, Roles: []string{}}, c.AllowedAccess("/v1/profile/:id"))
+	assert.Equal(t, Access{AllowAny: true, Roles: []string{}}, c.AllowedAccess("/v1/status"))
+}
+
 func TestConfig_TreeAsText(t *testing.T) {
 	c, err := New(&Config{})
 	require.NoError(t, err)
@@ -397,6 +416,49 @@ func TestNewUnaryInterceptor(t *testing.T) {
 	assert.Equal(t, `rpc error: code = PermissionDenied desc = the "guest" role is not allowed`, err.Error())
 }
 
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestNewStreamInterceptor(t *testing.T) {
+	c, err := New(&Config{
+		AllowAny: []string{
+			"/pb.Service/stream1",
+		},
+		Allow: []string{
+			"/pb.Service/stream2:bob",
+		},
+	})
+	require.NoError(t, err)
+
+	stream := c.NewStreamInterceptor()
+	called := 0
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		called++
+		return nil
+	}
+	ss := &testServerStream{ctx: context.Background()}
+
+	err = stream(nil, ss, &grpc.StreamServerInfo{FullMethod: "/pb.Service/stream1"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 1, called)
+
+	err = stream(nil, ss, &grpc.StreamServerInfo{FullMethod: "/pb.Service/stream2"}, handler)
+	require.Error(t, err)
+	assert.Equal(t, `rpc error: code = PermissionDenied desc = the "guest" role is not allowed`, err.Error())
+	assert.Equal(t, 1, called)
+
+	c.SetGRPCRoleMapper(func(ctx context.Context) string { return "bob" })
+	err = stream(nil, ss, &grpc.StreamServerInfo{FullMethod: "/pb.Service/stream2"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 2, called)
+}
+
 func testHTTPHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Hello"))
@@ -0,0 +1,343 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+// DefaultCaptureMaxBodySize specifies the default limit of the captured body
+const DefaultCaptureMaxBodySize = 64 * 1024
+
+// CapturedRequest is the record of the captured request and response
+type CapturedRequest struct {
+	Time              time.Time   `json:"time"`
+	Method            string      `json:"method"`
+	URI               string      `json:"uri"`
+	Route             string      `json:"route,omitempty"`
+	Status            int         `json:"status"`
+	Duration          int64       `json:"duration_ms"`
+	CorrelationID     string      `json:"correlation_id,omitempty"`
+	TraceID           string      `json:"trace_id,omitempty"`
+	Identity          string      `json:"identity,omitempty"`
+	Role              string      `json:"role,omitempty"`
+	RequestHeaders    http.Header `json:"request_headers,omitempty"`
+	RequestBody       string      `json:"request_body,omitempty"`
+	RequestTruncated  bool        `json:"request_truncated,omitempty"`
+	ResponseHeaders   http.Header `json:"response_headers,omitempty"`
+	ResponseBody      string      `json:"response_body,omitempty"`
+	ResponseTruncated bool        `json:"response_truncated,omitempty"`
+}
+
+// CaptureSink receives the captured requests,
+// the sink is called after the response is served
+type CaptureSink func(c *CapturedRequest)
+
+// NewCaptureWriter returns CaptureSink that writes the captured requests
+// as JSON lines to the writer, for example to the rotated file
+// returned by logrotate.NewWriter
+func NewCaptureWriter(w io.Writer) CaptureSink {
+	var lock sync.Mutex
+	return func(c *CapturedRequest) {
+		b, err := json.Marshal(c)
+		if err != nil {
+			logger.Errorf("reason=marshal, uri=%q, err=[%v]", c.URI, err)
+			return
+		}
+		b = append(b, '\n')
+
+		lock.Lock()
+		defer lock.Unlock()
+		if _, err = w.Write(b); err != nil {
+			logger.Errorf("reason=write, uri=%q, err=[%v]", c.URI, err)
+		}
+	}
+}
+
+// BodyCaptureOption is an option that can be passed to NewBodyCapture
+type BodyCaptureOption func(c *captureConfig)
+
+type captureConfig struct {
+	maxBodySize int
+	paths       []string
+	identities  []string
+	filter      func(r *http.Request) bool
+	redactor    *Redactor
+	route       func(r *http.Request) string
+}
+
+// WithCaptureMaxBodySize is an Option to limit the size of the captured
+// request and response bodies, DefaultCaptureMaxBodySize by default
+func WithCaptureMaxBodySize(size int) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.maxBodySize = size
+	}
+}
+
+// WithCapturePaths is an Option to capture only the requests on path match,
+// the path ending with "*" matches as a prefix
+func WithCapturePaths(paths ...string) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.paths = paths
+	}
+}
+
+// WithCaptureIdentities is an Option to capture only the requests
+// from the callers with the specified identity names or roles
+func WithCaptureIdentities(names ...string) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.identities = names
+	}
+}
+
+// WithCaptureFilter is an Option to provide a custom filter of the requests to capture
+func WithCaptureFilter(filter func(r *http.Request) bool) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.filter = filter
+	}
+}
+
+// WithCaptureRedactor is an Option to specify the redaction rules,
+// DefaultCaptureRedaction is applied by default
+func WithCaptureRedactor(redactor *Redactor) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.redactor = redactor
+	}
+}
+
+// WithCaptureRoute is an Option to provide the template of the route matched by the router
+func WithCaptureRoute(route func(r *http.Request) string) BodyCaptureOption {
+	return func(c *captureConfig) {
+		c.route = route
+	}
+}
+
+// BodyCapture is a http.Handler that records headers and bodies of the matching requests
+// and responses for debugging, and forwards the requests on down the chain.
+// The capture can be enabled and disabled at runtime.
+type BodyCapture struct {
+	handler http.Handler
+	sink    CaptureSink
+	cfg     captureConfig
+	enabled int32
+}
+
+// NewBodyCapture creates a new BodyCapture handler, requests are chained to the supplied handler,
+// and the captured requests are passed to the sink.
+// The capture is disabled until Enable is called.
+// To match the requests by identity, the handler must be served
+// after the identity is established, see identity.NewContextHandler.
+func NewBodyCapture(handler http.Handler, sink CaptureSink, opts ...BodyCaptureOption) *BodyCapture {
+	if handler == nil {
+		panic(errNoHandler)
+	}
+	if sink == nil {
+		panic("NewBodyCapture was supplied a nil sink")
+	}
+
+	cfg := captureConfig{
+		maxBodySize: DefaultCaptureMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.redactor == nil {
+		cfg.redactor, _ = NewRedactor(DefaultCaptureRedaction())
+	}
+
+	return &BodyCapture{
+		handler: handler,
+		sink:    sink,
+		cfg:     cfg,
+	}
+}
+
+// Enable enables or disables the capture
+func (c *BodyCapture) Enable(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	atomic.StoreInt32(&c.enabled, val)
+}
+
+// Enabled returns true if the capture is enabled
+func (c *BodyCapture) Enabled() bool {
+	return atomic.LoadInt32(&c.enabled) == 1
+}
+
+// ServeHTTP implements the http.Handler interface
+func (c *BodyCapture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.Enabled() || !c.matches(r) {
+		c.handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now().UTC()
+	rec := &CapturedRequest{
+		Time:           start,
+		Method:         r.Method,
+		URI:            r.URL.RequestURI(),
+		RequestHeaders: r.Header.Clone(),
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		// read the body up to the limit, and chain the rest to the handler
+		read, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(c.cfg.maxBodySize)+1))
+		if err != nil {
+			xlog.WithContext(r.Context(), logger).Errorf("reason=read_body, uri=%q, err=[%v]", rec.URI, err)
+		}
+		body := read
+		if len(body) > c.cfg.maxBodySize {
+			body = body[:c.cfg.maxBodySize]
+			rec.RequestTruncated = true
+		}
+		rec.RequestBody = string(body)
+
+		// shallow copy, to not modify the request of the caller
+		orig := r.Body
+		r = r.WithContext(r.Context())
+		r.Body = &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(read), orig),
+			Closer: orig,
+		}
+	}
+
+	rw := &captureWriter{
+		ResponseCapture: NewResponseCapture(w),
+		limit:           c.cfg.maxBodySize,
+	}
+	c.handler.ServeHTTP(rw, r)
+
+	rec.Status = rw.StatusCode()
+	rec.Duration = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	rec.ResponseHeaders = w.Header().Clone()
+	rec.ResponseBody = rw.body.String()
+	rec.ResponseTruncated = rw.truncated
+
+	idn := identity.FromRequest(r)
+	rec.CorrelationID = idn.CorrelationID()
+	if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+		rec.TraceID = sc.TraceID.String()
+	}
+	rec.Identity = idn.Identity().Name()
+	rec.Role = idn.Identity().Role()
+	if c.cfg.route != nil {
+		rec.Route = c.cfg.route(r)
+	}
+
+	// the bodies are redacted after decompression, as compressed bytes can not be inspected
+	rec.RequestBody, rec.RequestTruncated = c.decodeBody(rec.RequestHeaders, rec.RequestBody, rec.RequestTruncated)
+	rec.ResponseBody, rec.ResponseTruncated = c.decodeBody(rec.ResponseHeaders, rec.ResponseBody, rec.ResponseTruncated)
+
+	c.cfg.redactor.Redact(rec)
+	c.sink(rec)
+}
+
+// decodeBody returns the captured body decompressed by Content-Encoding,
+// up to the capture limit; the body that can not be decompressed
+// is replaced with RedactedValue
+func (c *BodyCapture) decodeBody(h http.Header, body string, truncated bool) (string, bool) {
+	encoding := strings.TrimSpace(h.Get(header.ContentEncoding))
+	if body == "" || encoding == "" || strings.EqualFold(encoding, negotiate.Identity) {
+		return body, truncated
+	}
+
+	rd, err := negotiate.NewDecompressor(strings.NewReader(body), encoding)
+	if err != nil {
+		logger.Debugf("reason=decode_body, encoding=%q, err=[%v]", encoding, err.Error())
+		return RedactedValue, truncated
+	}
+	defer rd.Close()
+
+	decoded, err := ioutil.ReadAll(io.LimitReader(rd, int64(c.cfg.maxBodySize)+1))
+	if err != nil && !(truncated && errors.Cause(err) == io.ErrUnexpectedEOF) {
+		// the partially decoded body is kept only when the capture was truncated
+		logger.Debugf("reason=decode_body, encoding=%q, err=[%v]", encoding, err.Error())
+		return RedactedValue, truncated
+	}
+	if len(decoded) > c.cfg.maxBodySize {
+		decoded = decoded[:c.cfg.maxBodySize]
+		truncated = true
+	}
+	return string(decoded), truncated
+}
+
+func (c *BodyCapture) matches(r *http.Request) bool {
+	if len(c.cfg.paths) > 0 {
+		found := false
+		for _, p := range c.cfg.paths {
+			if matchPath(p, r.URL.Path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(c.cfg.identities) > 0 {
+		idn := identity.FromRequest(r).Identity()
+		found := false
+		for _, name := range c.cfg.identities {
+			if name == idn.Name() || name == idn.Role() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return c.cfg.filter == nil || c.cfg.filter(r)
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter captures the response body up to the limit
+type captureWriter struct {
+	*ResponseCapture
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write the supplied data to the response, and captures it up to the limit
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if remain := w.limit - w.body.Len(); remain > 0 {
+		if len(data) > remain {
+			w.body.Write(data[:remain])
+			w.truncated = true
+		} else {
+			w.body.Write(data)
+		}
+	} else if len(data) > 0 {
+		w.truncated = true
+	}
+	return w.ResponseCapture.Write(data)
+}
+
+// matchPath returns true if the path matches the pattern,
+// the pattern ending with "*" matches as a prefix
+func matchPath(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == path
+}
@@ -3,10 +3,22 @@ package header
 const (
 	// Accept is HTTP header for "Accept"
 	Accept = "Accept"
+	// AcceptEncoding is HTTP header for "Accept-Encoding"
+	AcceptEncoding = "Accept-Encoding"
+	// ApplicationCBOR is HTTP header value for "application/cbor"
+	ApplicationCBOR = "application/cbor"
 	// ApplicationJSON is HTTP header value for "application/json"
 	ApplicationJSON = "application/json"
+	// ApplicationMsgPack is HTTP header value for "application/msgpack"
+	ApplicationMsgPack = "application/msgpack"
 	// ApplicationJoseJSON is HTTP header value for "application/jose+json"
 	ApplicationJoseJSON = "application/jose+json"
+	// ApplicationNDJSON is HTTP header value for "application/x-ndjson"
+	ApplicationNDJSON = "application/x-ndjson"
+	// ApplicationProblemJSON is HTTP header value for "application/problem+json"
+	ApplicationProblemJSON = "application/problem+json"
+	// ApplicationProtobuf is HTTP header value for "application/x-protobuf"
+	ApplicationProtobuf = "application/x-protobuf"
 	// ApplicationGRPC is HTTP header value for "application/grpc"
 	ApplicationGRPC = "application/grpc"
 	// ApplicationTimestampQuery is HTTP header value for RFC3161 Timestamp request
@@ -21,28 +33,50 @@ const (
 	CacheControl = "Cache-Control"
 	// ContentDisposition is HTTP header for "Content-Disposition"
 	ContentDisposition = "Content-Disposition"
+	// ContentEncoding is HTTP header for "Content-Encoding"
+	ContentEncoding = "Content-Encoding"
 	// ContentLength is HTTP header for "Content-Length"
 	ContentLength = "Content-Length"
 	// ContentType is HTTP header for "Content-Type"
 	ContentType = "Content-Type"
 	// IfMatch is HTTP header for "If-Match"
 	IfMatch = "If-Match"
+	// LastEventID is HTTP header for "Last-Event-ID"
+	LastEventID = "Last-Event-ID"
 	// Link is HTTP header for "Link"
 	Link = "Link"
 	// Location is HTTP header for "Location"
 	Location = "Location"
+	// RateLimitLimit is HTTP header for "RateLimit-Limit"
+	RateLimitLimit = "RateLimit-Limit"
+	// RateLimitRemaining is HTTP header for "RateLimit-Remaining"
+	RateLimitRemaining = "RateLimit-Remaining"
+	// RateLimitReset is HTTP header for "RateLimit-Reset"
+	RateLimitReset = "RateLimit-Reset"
 	// ReplayNonce is HTTP header for "Replay-Nonce"
 	ReplayNonce = "Replay-Nonce"
+	// RetryAfter is HTTP header for "Retry-After"
+	RetryAfter = "Retry-After"
+	// TextEventStream is HTTP header value for "text/event-stream"
+	TextEventStream = "text/event-stream"
 	// TextPlain is HTTP header value for "application/json"
 	TextPlain = "text/plain"
+	// TraceParent is W3C Trace Context header for "traceparent"
+	TraceParent = "traceparent"
+	// TraceState is W3C Trace Context header for "tracestate"
+	TraceState = "tracestate"
 	// UserAgent is HTTP header value for "User-Agent"
 	UserAgent = "User-Agent"
+	// Vary is HTTP header for "Vary"
+	Vary = "Vary"
 	// XHostname contains the name of the HTTP header to indicate which host requested the signature
 	XHostname = "X-HostName"
 	// XCorrelationID is HTTP header for "X-Correlation-ID"
 	XCorrelationID = "X-Correlation-ID"
 	// XDeviceID is HTTP header for "X-Device-ID"
 	XDeviceID = "X-Device-ID"
+	// XAPIKey is HTTP header for "X-API-Key"
+	XAPIKey = "X-API-Key"
 	// XFilename contains the name of the artifact to sign
 	XFilename = "X-Filename"
 	// XForwardedProto contains the protocol
@@ -9,8 +9,14 @@ import (
 
 func Test_Headers(t *testing.T) {
 	assert.Equal(t, "Accept", header.Accept)
+	assert.Equal(t, "Accept-Encoding", header.AcceptEncoding)
+	assert.Equal(t, "application/cbor", header.ApplicationCBOR)
+	assert.Equal(t, "application/msgpack", header.ApplicationMsgPack)
+	assert.Equal(t, "application/x-protobuf", header.ApplicationProtobuf)
 	assert.Equal(t, "application/json", header.ApplicationJSON)
 	assert.Equal(t, "application/jose+json", header.ApplicationJoseJSON)
+	assert.Equal(t, "application/x-ndjson", header.ApplicationNDJSON)
+	assert.Equal(t, "application/problem+json", header.ApplicationProblemJSON)
 	assert.Equal(t, "application/grpc", header.ApplicationGRPC)
 	assert.Equal(t, "application/timestamp-query", header.ApplicationTimestampQuery)
 	assert.Equal(t, "application/timestamp-reply", header.ApplicationTimestampReply)
@@ -18,14 +24,25 @@ func Test_Headers(t *testing.T) {
 	assert.Equal(t, "Bearer", header.Bearer)
 	assert.Equal(t, "Cache-Control", header.CacheControl)
 	assert.Equal(t, "Content-Type", header.ContentType)
+	assert.Equal(t, "Content-Encoding", header.ContentEncoding)
 	assert.Equal(t, "Content-Disposition", header.ContentDisposition)
 	assert.Equal(t, "If-Match", header.IfMatch)
+	assert.Equal(t, "Last-Event-ID", header.LastEventID)
+	assert.Equal(t, "RateLimit-Limit", header.RateLimitLimit)
+	assert.Equal(t, "RateLimit-Remaining", header.RateLimitRemaining)
+	assert.Equal(t, "RateLimit-Reset", header.RateLimitReset)
 	assert.Equal(t, "Replay-Nonce", header.ReplayNonce)
+	assert.Equal(t, "Retry-After", header.RetryAfter)
+	assert.Equal(t, "text/event-stream", header.TextEventStream)
 	assert.Equal(t, "text/plain", header.TextPlain)
+	assert.Equal(t, "traceparent", header.TraceParent)
+	assert.Equal(t, "tracestate", header.TraceState)
 	assert.Equal(t, "User-Agent", header.UserAgent)
+	assert.Equal(t, "Vary", header.Vary)
 	assert.Equal(t, "X-HostName", header.XHostname)
 	assert.Equal(t, "X-Correlation-ID", header.XCorrelationID)
 	assert.Equal(t, "X-Device-ID", header.XDeviceID)
+	assert.Equal(t, "X-API-Key", header.XAPIKey)
 	assert.Equal(t, "X-Filename", header.XFilename)
 	assert.Equal(t, "X-Forwarded-Proto", header.XForwardedProto)
 }
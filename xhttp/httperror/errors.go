@@ -5,8 +5,7 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/go-phorce/dolly/xhttp/header"
-	"github.com/ugorji/go/codec"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
 )
 
 // Error represents a single error from API.
@@ -198,16 +197,24 @@ func (m *ManyError) HasErrors() bool {
 	return len(m.Errors) > 0
 }
 
-// WriteHTTPResponse implements how to serialize this error into a HTTP Response
+// WriteHTTPResponse implements how to serialize this error into a HTTP Response,
+// the error is written as RFC 7807 problem details if WantsProblem,
+// otherwise in the format negotiated by Accept header
 func (e *Error) WriteHTTPResponse(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set(header.ContentType, header.ApplicationJSON)
-	w.WriteHeader(e.HTTPStatus)
-	codec.NewEncoder(w, encoderHandle(shouldPrettyPrint(r))).Encode(e)
+	if WantsProblem(r) {
+		e.Problem(instance(r), correlationID(w, r)).WriteHTTPResponse(w, r)
+		return
+	}
+	negotiate.WriteResponse(w, r, e.HTTPStatus, e)
 }
 
-// WriteHTTPResponse implements how to serialize this error into a HTTP Response
+// WriteHTTPResponse implements how to serialize this error into a HTTP Response,
+// the error is written as RFC 7807 problem details if WantsProblem,
+// otherwise in the format negotiated by Accept header
 func (m *ManyError) WriteHTTPResponse(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set(header.ContentType, header.ApplicationJSON)
-	w.WriteHeader(m.HTTPStatus)
-	codec.NewEncoder(w, encoderHandle(shouldPrettyPrint(r))).Encode(m)
+	if WantsProblem(r) {
+		m.Problem(instance(r), correlationID(w, r)).WriteHTTPResponse(w, r)
+		return
+	}
+	negotiate.WriteResponse(w, r, m.HTTPStatus, m)
 }
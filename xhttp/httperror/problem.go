@@ -0,0 +1,160 @@
+package httperror
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+)
+
+// ProblemTypeBlank is the default problem type,
+// when the problem has no additional semantics beyond that of the HTTP status code
+const ProblemTypeBlank = "about:blank"
+
+// Problem is RFC 7807 problem details
+type Problem struct {
+	// Type is URI reference that identifies the problem type
+	Type string `json:"type"`
+	// Title is a short summary of the problem type
+	Title string `json:"title"`
+	// Status is HTTP status code
+	Status int `json:"status"`
+	// Detail is an explanation specific to this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is URI reference that identifies the specific occurrence of the problem
+	Instance string `json:"instance,omitempty"`
+
+	// Code is the extension member with the error code
+	Code string `json:"code,omitempty"`
+	// CorrelationID is the extension member with the correlation ID of the request
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Errors is the extension member with the errors of the fields
+	Errors map[string]*Error `json:"errors,omitempty"`
+}
+
+// ProblemConfig specifies the options of RFC 7807 problem details responses
+type ProblemConfig struct {
+	// Always specifies to write the errors as problem details,
+	// otherwise only if requested by "Accept: application/problem+json" header
+	Always bool
+	// TypeBaseURI specifies the base URI of the problem type,
+	// such as https://api.example.com/errors/, the problem type is TypeBaseURI + code.
+	// If not specified, then about:blank type is used
+	TypeBaseURI string
+}
+
+var problemConfig atomic.Value
+
+func init() {
+	problemConfig.Store(ProblemConfig{})
+}
+
+// SetProblemConfig sets the options of problem details responses
+func SetProblemConfig(cfg ProblemConfig) {
+	problemConfig.Store(cfg)
+}
+
+// GetProblemConfig returns the options of problem details responses
+func GetProblemConfig() ProblemConfig {
+	return problemConfig.Load().(ProblemConfig)
+}
+
+// WantsProblem returns true if the errors should be written as problem details
+// for the request
+func WantsProblem(r *http.Request) bool {
+	if GetProblemConfig().Always {
+		return true
+	}
+	return r != nil && strings.Contains(r.Header.Get(header.Accept), header.ApplicationProblemJSON)
+}
+
+// ProblemType returns the problem type URI of the code
+func ProblemType(code string) string {
+	base := GetProblemConfig().TypeBaseURI
+	if base == "" || code == "" {
+		return ProblemTypeBlank
+	}
+	return base + code
+}
+
+// Problem returns problem details of the error
+func (e *Error) Problem(instance, correlationID string) *Problem {
+	return &Problem{
+		Type:          ProblemType(e.Code),
+		Title:         http.StatusText(e.HTTPStatus),
+		Status:        e.HTTPStatus,
+		Detail:        e.Message,
+		Instance:      instance,
+		Code:          e.Code,
+		CorrelationID: correlationID,
+	}
+}
+
+// Problem returns problem details of the errors,
+// the errors are reported in the errors extension member
+func (m *ManyError) Problem(instance, correlationID string) *Problem {
+	return &Problem{
+		Type:          ProblemType(m.Code),
+		Title:         http.StatusText(m.HTTPStatus),
+		Status:        m.HTTPStatus,
+		Detail:        m.Message,
+		Instance:      instance,
+		Code:          m.Code,
+		CorrelationID: correlationID,
+		Errors:        m.Errors,
+	}
+}
+
+// ToError returns *ManyError if the problem has the errors of the fields,
+// or *Error otherwise
+func (p *Problem) ToError() error {
+	code := p.Code
+	if code == "" && p.Type != "" && p.Type != ProblemTypeBlank {
+		code = path.Base(p.Type)
+	}
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+
+	if len(p.Errors) > 0 {
+		return &ManyError{
+			HTTPStatus: p.Status,
+			Code:       code,
+			Message:    msg,
+			Errors:     p.Errors,
+		}
+	}
+	return &Error{
+		HTTPStatus: p.Status,
+		Code:       code,
+		Message:    msg,
+	}
+}
+
+// WriteHTTPResponse implements how to serialize the problem into a HTTP Response
+func (p *Problem) WriteHTTPResponse(w http.ResponseWriter, r *http.Request) {
+	negotiate.WriteResponseAs(w, r, p.Status, negotiate.JSON, header.ApplicationProblemJSON, p)
+}
+
+// correlationID returns the correlation ID of the response or the request
+func correlationID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(header.XCorrelationID); id != "" {
+		return id
+	}
+	if r != nil {
+		return r.Header.Get(header.XCorrelationID)
+	}
+	return ""
+}
+
+// instance returns the instance of the problem for the request
+func instance(r *http.Request) string {
+	if r == nil || r.URL == nil {
+		return ""
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,123 @@
+package httperror_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblem_Config(t *testing.T) {
+	defer httperror.SetProblemConfig(httperror.ProblemConfig{})
+
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, httperror.ProblemConfig{}, httperror.GetProblemConfig())
+	assert.False(t, httperror.WantsProblem(r))
+	assert.False(t, httperror.WantsProblem(nil))
+	assert.Equal(t, httperror.ProblemTypeBlank, httperror.ProblemType(httperror.NotFound))
+
+	r.Header.Set(header.Accept, "application/problem+json, application/json")
+	assert.True(t, httperror.WantsProblem(r))
+
+	httperror.SetProblemConfig(httperror.ProblemConfig{
+		Always:      true,
+		TypeBaseURI: "https://api.example.com/errors/",
+	})
+	assert.True(t, httperror.WantsProblem(nil))
+	assert.Equal(t, "https://api.example.com/errors/not_found", httperror.ProblemType(httperror.NotFound))
+	assert.Equal(t, httperror.ProblemTypeBlank, httperror.ProblemType(""))
+}
+
+func TestProblem_WriteHTTPResponse(t *testing.T) {
+	defer httperror.SetProblemConfig(httperror.ProblemConfig{})
+	httperror.SetProblemConfig(httperror.ProblemConfig{TypeBaseURI: "https://api.example.com/errors/"})
+
+	r, err := http.NewRequest(http.MethodGet, "/v1/items/1", nil)
+	require.NoError(t, err)
+
+	// not requested
+	w := httptest.NewRecorder()
+	httperror.WithNotFound("item not found").WriteHTTPResponse(w, r)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+	assert.Equal(t, `{"code":"not_found","message":"item not found"}`, w.Body.String())
+
+	r.Header.Set(header.Accept, header.ApplicationProblemJSON)
+	r.Header.Set(header.XCorrelationID, "req-1")
+
+	w = httptest.NewRecorder()
+	w.Header().Set(header.XCorrelationID, "resp-1")
+	httperror.WithNotFound("item not found").WriteHTTPResponse(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, header.ApplicationProblemJSON, w.Header().Get(header.ContentType))
+
+	var p httperror.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, httperror.Problem{
+		Type:          "https://api.example.com/errors/not_found",
+		Title:         "Not Found",
+		Status:        http.StatusNotFound,
+		Detail:        "item not found",
+		Instance:      "/v1/items/1",
+		Code:          httperror.NotFound,
+		CorrelationID: "resp-1",
+	}, p)
+
+	many := httperror.NewMany(http.StatusBadRequest, httperror.InvalidRequest, "validation failed")
+	many.Add("name", httperror.WithInvalidParam("name is required"))
+
+	w = httptest.NewRecorder()
+	many.WriteHTTPResponse(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, header.ApplicationProblemJSON, w.Header().Get(header.ContentType))
+
+	p = httperror.Problem{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, "validation failed", p.Detail)
+	assert.Equal(t, "req-1", p.CorrelationID)
+	require.Contains(t, p.Errors, "name")
+	assert.Equal(t, httperror.InvalidParam, p.Errors["name"].Code)
+	assert.Equal(t, "name is required", p.Errors["name"].Message)
+}
+
+func TestProblem_ToError(t *testing.T) {
+	p := &httperror.Problem{
+		Type:   "https://api.example.com/errors/conflict",
+		Title:  "Conflict",
+		Status: http.StatusConflict,
+	}
+	err := p.ToError()
+	require.IsType(t, &httperror.Error{}, err)
+	e := err.(*httperror.Error)
+	assert.Equal(t, http.StatusConflict, e.HTTPStatus)
+	assert.Equal(t, httperror.Conflict, e.Code)
+	assert.Equal(t, "Conflict", e.Message)
+
+	p = &httperror.Problem{
+		Type:   httperror.ProblemTypeBlank,
+		Title:  "Bad Request",
+		Status: http.StatusBadRequest,
+		Detail: "validation failed",
+		Code:   httperror.InvalidRequest,
+		Errors: map[string]*httperror.Error{
+			"name": {Code: httperror.InvalidParam, Message: "name is required"},
+		},
+	}
+	err = p.ToError()
+	require.IsType(t, &httperror.ManyError{}, err)
+	many := err.(*httperror.ManyError)
+	assert.Equal(t, http.StatusBadRequest, many.HTTPStatus)
+	assert.Equal(t, httperror.InvalidRequest, many.Code)
+	assert.Equal(t, "validation failed", many.Message)
+	assert.True(t, many.HasErrors())
+
+	// the error of about:blank type without code
+	err = (&httperror.Problem{Type: httperror.ProblemTypeBlank, Status: http.StatusNotFound, Detail: "missing"}).ToError()
+	assert.Equal(t, &httperror.Error{HTTPStatus: http.StatusNotFound, Message: "missing"}, err)
+}
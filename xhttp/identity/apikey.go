@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// APIKeyLookup returns Identity of API key, or nil if the key is unknown
+type APIKeyLookup func(key string) (Identity, error)
+
+// StaticAPIKeys returns APIKeyLookup for the fixed keys
+func StaticAPIKeys(keys map[string]Identity) APIKeyLookup {
+	// the keys are stored hashed, to not keep them in memory as is
+	hashed := make(map[[sha256.Size]byte]Identity, len(keys))
+	for k, id := range keys {
+		hashed[sha256.Sum256([]byte(k))] = id
+	}
+	return func(key string) (Identity, error) {
+		return hashed[sha256.Sum256([]byte(key))], nil
+	}
+}
+
+// NewAPIKeyProvider returns ProviderFromRequest that maps API key
+// from the specified header, by default X-API-Key, to Identity.
+// If the request has no API key, then ErrNotApplicable is returned.
+func NewAPIKeyProvider(headerName string, lookup APIKeyLookup) ProviderFromRequest {
+	if headerName == "" {
+		headerName = header.XAPIKey
+	}
+	return func(r *http.Request) (Identity, error) {
+		return apiKeyIdentity(r.Header.Get(headerName), lookup)
+	}
+}
+
+// NewAPIKeyContextProvider returns ProviderFromContext that maps API key
+// from the specified key of gRPC metadata, by default x-api-key, to Identity.
+// If the call has no API key, then ErrNotApplicable is returned.
+func NewAPIKeyContextProvider(headerName string, lookup APIKeyLookup) ProviderFromContext {
+	if headerName == "" {
+		headerName = header.XAPIKey
+	}
+	headerName = strings.ToLower(headerName)
+	return func(ctx context.Context) (Identity, error) {
+		var key string
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(headerName); len(values) > 0 {
+			key = values[0]
+		}
+		return apiKeyIdentity(key, lookup)
+	}
+}
+
+func apiKeyIdentity(key string, lookup APIKeyLookup) (Identity, error) {
+	if key == "" {
+		return nil, NotApplicable("API key not found")
+	}
+	id, err := lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, errors.New("invalid API key")
+	}
+	return id, nil
+}
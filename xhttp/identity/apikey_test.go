@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_APIKeyProvider(t *testing.T) {
+	lookup := StaticAPIKeys(map[string]Identity{
+		"key1": NewIdentity("service", "billing", ""),
+	})
+
+	p := NewAPIKeyProvider("", lookup)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := p(r)
+	require.Error(t, err)
+	assert.True(t, IsNotApplicable(err))
+	assert.Equal(t, "API key not found", err.Error())
+
+	r.Header.Set(header.XAPIKey, "key2")
+	_, err = p(r)
+	require.Error(t, err)
+	assert.False(t, IsNotApplicable(err))
+	assert.Equal(t, "invalid API key", err.Error())
+
+	r.Header.Set(header.XAPIKey, "key1")
+	id, err := p(r)
+	require.NoError(t, err)
+	assert.Equal(t, "service/billing", id.String())
+
+	p = NewAPIKeyProvider("X-Custom-Key", func(key string) (Identity, error) {
+		return nil, errors.New("lookup failed")
+	})
+	r.Header.Set("X-Custom-Key", "key1")
+	_, err = p(r)
+	assert.EqualError(t, err, "lookup failed")
+}
+
+func Test_APIKeyContextProvider(t *testing.T) {
+	lookup := StaticAPIKeys(map[string]Identity{
+		"key1": NewIdentity("service", "billing", ""),
+	})
+
+	p := NewAPIKeyContextProvider("", lookup)
+	_, err := p(context.Background())
+	require.Error(t, err)
+	assert.True(t, IsNotApplicable(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+	id, err := p(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "service/billing", id.String())
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key2"))
+	_, err = p(ctx)
+	assert.EqualError(t, err, "invalid API key")
+}
@@ -0,0 +1,455 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/fileutil/reloader"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"gopkg.in/yaml.v2"
+)
+
+// Sources of the identity name in CertRule
+const (
+	// NameFromCN uses the subject common name
+	NameFromCN = "cn"
+	// NameFromURI uses the first URI SAN, such as SPIFFE ID
+	NameFromURI = "uri"
+	// NameFromDNS uses the first DNS SAN
+	NameFromDNS = "dns"
+	// NameFromEmail uses the first email SAN
+	NameFromEmail = "email"
+)
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":              x509.ExtKeyUsageAny,
+	"server_auth":      x509.ExtKeyUsageServerAuth,
+	"client_auth":      x509.ExtKeyUsageClientAuth,
+	"code_signing":     x509.ExtKeyUsageCodeSigning,
+	"email_protection": x509.ExtKeyUsageEmailProtection,
+	"time_stamping":    x509.ExtKeyUsageTimeStamping,
+	"ocsp_signing":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// CertRule maps the client certificate to the identity.
+// All specified conditions must match, and a condition matches
+// if any of its values matches. The values are patterns
+// in path.Match syntax, except PolicyOIDs and ExtKeyUsages;
+// the pattern with trailing "**" matches any suffix,
+// for example "spiffe://example.org/ns/prod/**".
+type CertRule struct {
+	// Name of the rule, used in logs
+	Name string `json:"name" yaml:"name"`
+	// Deny specifies to reject the matched certificate
+	Deny bool `json:"deny,omitempty" yaml:"deny,omitempty"`
+	// Role of the identity, required if not Deny
+	Role string `json:"role,omitempty" yaml:"role,omitempty"`
+	// NameFrom specifies the source of the identity name:
+	// cn, uri, dns or email; by default cn.
+	// For uri and dns, the first value matched by the rule is used.
+	NameFrom string `json:"name_from,omitempty" yaml:"name_from,omitempty"`
+
+	// Issuers match the common name or the DN of the CA certificates
+	// in the verified chain, the issuer claimed by the certificate is not used
+	Issuers []string `json:"issuers,omitempty" yaml:"issuers,omitempty"`
+	// URIs match URI SAN, such as SPIFFE ID
+	URIs []string `json:"uris,omitempty" yaml:"uris,omitempty"`
+	// DNSNames match DNS SAN
+	DNSNames []string `json:"dns_names,omitempty" yaml:"dns_names,omitempty"`
+	// OrganizationalUnits match the subject OU
+	OrganizationalUnits []string `json:"organizational_units,omitempty" yaml:"organizational_units,omitempty"`
+	// PolicyOIDs match the certificate policies, such as 1.3.6.1.4.1.1
+	PolicyOIDs []string `json:"policy_oids,omitempty" yaml:"policy_oids,omitempty"`
+	// ExtKeyUsages match the extended key usage, by name such as client_auth,
+	// or by OID
+	ExtKeyUsages []string `json:"ext_key_usages,omitempty" yaml:"ext_key_usages,omitempty"`
+}
+
+// CertMapping specifies the rules to map the client certificates to the identities
+type CertMapping struct {
+	// DefaultRole is the role of the caller without certificate,
+	// or with the certificate that matches no rule.
+	// If empty, then ErrNotApplicable is returned for such callers.
+	DefaultRole string `json:"default_role,omitempty" yaml:"default_role,omitempty"`
+	// Rules are evaluated in order, the first matched rule is applied
+	Rules []*CertRule `json:"rules" yaml:"rules"`
+}
+
+// LoadCertMapping loads the mapping rules from YAML or JSON file
+func LoadCertMapping(file string) (*CertMapping, error) {
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to read rules file")
+	}
+
+	cfg := new(CertMapping)
+	if strings.HasSuffix(file, ".json") {
+		err = json.Unmarshal(body, cfg)
+	} else {
+		err = yaml.Unmarshal(body, cfg)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to unmarshal rules from %q", file)
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, errors.WithMessagef(err, "invalid rules in %q", file)
+	}
+	return cfg, nil
+}
+
+// Validate returns error if the rules are invalid
+func (m *CertMapping) Validate() error {
+	for i, r := range m.Rules {
+		if r.Name == "" {
+			r.Name = "rules[" + strconv.Itoa(i) + "]"
+		}
+		if !r.Deny && r.Role == "" {
+			return errors.Errorf("role is required: rule=%q", r.Name)
+		}
+		switch r.NameFrom {
+		case "", NameFromCN, NameFromURI, NameFromDNS, NameFromEmail:
+		default:
+			return errors.Errorf("invalid name_from %q: rule=%q", r.NameFrom, r.Name)
+		}
+		for _, list := range [][]string{r.Issuers, r.URIs, r.DNSNames, r.OrganizationalUnits} {
+			for _, p := range list {
+				if _, err := path.Match(strings.TrimSuffix(p, "**"), ""); err != nil {
+					return errors.Errorf("invalid pattern %q: rule=%q", p, r.Name)
+				}
+			}
+		}
+		for _, oid := range r.PolicyOIDs {
+			if !isOID(oid) {
+				return errors.Errorf("invalid policy OID %q: rule=%q", oid, r.Name)
+			}
+		}
+		for _, eku := range r.ExtKeyUsages {
+			if _, ok := extKeyUsages[eku]; !ok && !isOID(eku) {
+				return errors.Errorf("invalid extended key usage %q: rule=%q", eku, r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule matched by the certificate, or nil.
+// The rules with Issuers do not match, as the certificate has no verified chain,
+// see MatchChain
+func (m *CertMapping) Match(cert *x509.Certificate) *CertRule {
+	return m.MatchChain([]*x509.Certificate{cert})
+}
+
+// MatchChain returns the first rule matched by the verified chain, or nil.
+// The chain starts with the client certificate, followed by its CA certificates.
+func (m *CertMapping) MatchChain(chain []*x509.Certificate) *CertRule {
+	if len(chain) == 0 {
+		return nil
+	}
+	for _, r := range m.Rules {
+		if r.matches(chain) {
+			return r
+		}
+	}
+	return nil
+}
+
+// IdentityFromCertificate returns Identity of the certificate.
+// If cert is nil, then the identity has DefaultRole.
+// The rules with Issuers do not match, see IdentityFromChain
+func (m *CertMapping) IdentityFromCertificate(cert *x509.Certificate) (Identity, error) {
+	if cert == nil {
+		return m.IdentityFromChain(nil)
+	}
+	return m.IdentityFromChain([]*x509.Certificate{cert})
+}
+
+// IdentityFromChain returns Identity of the verified chain,
+// that starts with the client certificate, followed by its CA certificates.
+// If the chain is empty, then the identity has DefaultRole.
+func (m *CertMapping) IdentityFromChain(chain []*x509.Certificate) (Identity, error) {
+	if len(chain) == 0 {
+		if m.DefaultRole == "" {
+			return nil, NotApplicable("client certificate is required")
+		}
+		return NewIdentity(m.DefaultRole, "", ""), nil
+	}
+
+	cert := chain[0]
+	r := m.MatchChain(chain)
+	if r == nil {
+		if m.DefaultRole == "" {
+			return nil, NotApplicable("no rule matched: subject=%q", cert.Subject.String())
+		}
+		return NewIdentityWithUserInfo(m.DefaultRole, cert.Subject.CommonName, "", cert), nil
+	}
+	if r.Deny {
+		return nil, errors.Errorf("denied by rule %q: subject=%q", r.Name, cert.Subject.String())
+	}
+	return NewIdentityWithUserInfo(r.Role, r.name(cert), "", cert), nil
+}
+
+func (r *CertRule) matches(chain []*x509.Certificate) bool {
+	cert := chain[0]
+	if len(r.Issuers) > 0 {
+		var issuers []string
+		for _, ca := range chain[1:] {
+			issuers = append(issuers, ca.Subject.CommonName, ca.Subject.String())
+		}
+		if !matchAny(r.Issuers, issuers) {
+			return false
+		}
+	}
+	if len(r.URIs) > 0 {
+		uris := make([]string, len(cert.URIs))
+		for i, u := range cert.URIs {
+			uris[i] = u.String()
+		}
+		if !matchAny(r.URIs, uris) {
+			return false
+		}
+	}
+	if len(r.DNSNames) > 0 && !matchAny(r.DNSNames, cert.DNSNames) {
+		return false
+	}
+	if len(r.OrganizationalUnits) > 0 && !matchAny(r.OrganizationalUnits, cert.Subject.OrganizationalUnit) {
+		return false
+	}
+	if len(r.PolicyOIDs) > 0 {
+		policies := make([]string, len(cert.PolicyIdentifiers))
+		for i, oid := range cert.PolicyIdentifiers {
+			policies[i] = oid.String()
+		}
+		if !containsAny(r.PolicyOIDs, policies) {
+			return false
+		}
+	}
+	if len(r.ExtKeyUsages) > 0 && !r.matchesExtKeyUsage(cert) {
+		return false
+	}
+	return true
+}
+
+func (r *CertRule) matchesExtKeyUsage(cert *x509.Certificate) bool {
+	for _, eku := range r.ExtKeyUsages {
+		if usage, ok := extKeyUsages[eku]; ok {
+			for _, u := range cert.ExtKeyUsage {
+				if u == usage {
+					return true
+				}
+			}
+			continue
+		}
+		for _, oid := range cert.UnknownExtKeyUsage {
+			if oid.String() == eku {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *CertRule) name(cert *x509.Certificate) string {
+	var name string
+	switch r.NameFrom {
+	case NameFromURI:
+		uris := make([]string, len(cert.URIs))
+		for i, u := range cert.URIs {
+			uris[i] = u.String()
+		}
+		name = firstMatch(r.URIs, uris)
+	case NameFromDNS:
+		name = firstMatch(r.DNSNames, cert.DNSNames)
+	case NameFromEmail:
+		if len(cert.EmailAddresses) > 0 {
+			name = cert.EmailAddresses[0]
+		}
+	}
+	if name == "" {
+		name = cert.Subject.CommonName
+	}
+	return name
+}
+
+func matchPattern(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "**") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "**"))
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func matchAny(patterns, values []string) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if matchPattern(p, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// firstMatch returns the first value matched by any of the patterns,
+// or the first value if there are no patterns
+func firstMatch(patterns, values []string) string {
+	for _, v := range values {
+		if len(patterns) == 0 {
+			return v
+		}
+		for _, p := range patterns {
+			if matchPattern(p, v) {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func containsAny(expected, values []string) bool {
+	for _, e := range expected {
+		for _, v := range values {
+			if e == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isOID(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, p := range parts {
+		if p == "" || strings.Trim(p, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CertIdentityMapper maps the client certificates to the identities
+// by the rules loaded from file, and reloads the rules when the file is modified
+type CertIdentityMapper struct {
+	lock     sync.RWMutex
+	mapping  *CertMapping
+	reloader *reloader.Reloader
+}
+
+// NewCertIdentityMapper returns CertIdentityMapper with the rules
+func NewCertIdentityMapper(mapping *CertMapping) (*CertIdentityMapper, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	return &CertIdentityMapper{
+		mapping: mapping,
+	}, nil
+}
+
+// NewCertIdentityMapperFromFile returns CertIdentityMapper with the rules loaded from file.
+// If checkInterval is not 0, then the rules are reloaded when the file is modified.
+func NewCertIdentityMapperFromFile(file string, checkInterval time.Duration) (*CertIdentityMapper, error) {
+	mapping, err := LoadCertMapping(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &CertIdentityMapper{
+		mapping: mapping,
+	}
+	if checkInterval > 0 {
+		m.reloader, err = reloader.NewReloader(file, checkInterval, m.onChanged)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return m, nil
+}
+
+func (m *CertIdentityMapper) onChanged(file string, modifiedAt time.Time) {
+	mapping, err := LoadCertMapping(file)
+	if err != nil {
+		logger.Errorf("reason=reload, file=%q, err=[%v]", file, err.Error())
+		return
+	}
+
+	m.lock.Lock()
+	m.mapping = mapping
+	m.lock.Unlock()
+
+	logger.Infof("status=reloaded, file=%q, rules=%d", file, len(mapping.Rules))
+}
+
+// Mapping returns the current rules
+func (m *CertIdentityMapper) Mapping() *CertMapping {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.mapping
+}
+
+// Close stops reloading the rules
+func (m *CertIdentityMapper) Close() error {
+	if m.reloader == nil {
+		return nil
+	}
+	return m.reloader.Close()
+}
+
+// IdentityFromCertificate returns Identity of the certificate
+func (m *CertIdentityMapper) IdentityFromCertificate(cert *x509.Certificate) (Identity, error) {
+	return m.Mapping().IdentityFromCertificate(cert)
+}
+
+// IdentityFromChain returns Identity of the verified chain
+func (m *CertIdentityMapper) IdentityFromChain(chain []*x509.Certificate) (Identity, error) {
+	return m.Mapping().IdentityFromChain(chain)
+}
+
+// IdentityFromRequest returns Identity of the verified client certificate,
+// it can be used as ProviderFromRequest.
+// The client certificate that is not verified by the server,
+// for example with tls.RequestClientCert, is treated as no certificate.
+func (m *CertIdentityMapper) IdentityFromRequest(r *http.Request) (Identity, error) {
+	chain := verifiedChain(r.TLS)
+	if len(chain) == 0 {
+		id, err := m.IdentityFromChain(nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewIdentity(id.Role(), ClientIPFromRequest(r), ""), nil
+	}
+	return m.IdentityFromChain(chain)
+}
+
+// IdentityFromContext returns Identity of the verified client certificate of gRPC peer,
+// it can be used as ProviderFromContext
+func (m *CertIdentityMapper) IdentityFromContext(ctx context.Context) (Identity, error) {
+	var chain []*x509.Certificate
+	if p, ok := peer.FromContext(ctx); ok {
+		if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			chain = verifiedChain(&ti.State)
+		}
+	}
+	return m.IdentityFromChain(chain)
+}
+
+// verifiedChain returns the first chain verified by TLS handshake,
+// or nil if the peer certificate was not verified
+func verifiedChain(state *tls.ConnectionState) []*x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0]
+}
@@ -0,0 +1,349 @@
+package identity
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const testRulesYAML = `
+default_role: guest
+rules:
+  - name: revoked
+    deny: true
+    dns_names: ["revoked.example.com"]
+  - name: spiffe
+    role: service
+    name_from: uri
+    uris: ["spiffe://example.org/ns/prod/**"]
+  - name: ops
+    role: admin
+    issuers: ["Dolly Issuing CA"]
+    organizational_units: ["ops", "sre*"]
+    ext_key_usages: ["client_auth"]
+  - name: policy
+    role: partner
+    name_from: dns
+    policy_oids: ["1.3.6.1.4.1.99999.1"]
+  - role: custom
+    name_from: email
+    ext_key_usages: ["1.3.6.1.4.1.99999.2"]
+`
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Dolly Issuing CA", Organization: []string{"dolly"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return ca, key
+}
+
+func newTestCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, tmpl *x509.Certificate) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func Test_CertMapping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(testRulesYAML), 0644))
+
+	mapping, err := LoadCertMapping(file)
+	require.NoError(t, err)
+	require.Len(t, mapping.Rules, 5)
+	assert.Equal(t, "rules[4]", mapping.Rules[4].Name)
+
+	ca, caKey := newTestCA(t)
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/web")
+	other, _ := url.Parse("spiffe://example.org/ns/dev/sa/web")
+
+	tcases := []struct {
+		name string
+		cert *x509.Certificate
+		rule string
+		id   string
+		err  string
+	}{
+		{
+			name: "deny",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:  pkix.Name{CommonName: "revoked", OrganizationalUnit: []string{"ops"}},
+				DNSNames: []string{"revoked.example.com"},
+			}),
+			rule: "revoked",
+			err:  `denied by rule "revoked": subject="CN=revoked,OU=ops"`,
+		},
+		{
+			name: "spiffe",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject: pkix.Name{CommonName: "web"},
+				URIs:    []*url.URL{other, spiffe},
+			}),
+			rule: "spiffe",
+			id:   "service/spiffe://example.org/ns/prod/sa/web",
+		},
+		{
+			name: "ou",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:     pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"sre-team"}},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}),
+			rule: "ops",
+			id:   "admin/alice",
+		},
+		{
+			name: "ou without eku",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:     pkix.Name{CommonName: "bob", OrganizationalUnit: []string{"ops"}},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}),
+			id: "guest/bob",
+		},
+		{
+			name: "policy",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:           pkix.Name{CommonName: "partner"},
+				DNSNames:          []string{"api.partner.com"},
+				PolicyIdentifiers: []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 1}},
+			}),
+			rule: "policy",
+			id:   "partner/api.partner.com",
+		},
+		{
+			name: "eku oid",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:            pkix.Name{CommonName: "custom"},
+				EmailAddresses:     []string{"custom@example.com"},
+				UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 2}},
+			}),
+			rule: "rules[4]",
+			id:   "custom/custom@example.com",
+		},
+		{
+			name: "name fallback",
+			cert: newTestCert(t, ca, caKey, &x509.Certificate{
+				Subject:            pkix.Name{CommonName: "noemail"},
+				UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 2}},
+			}),
+			rule: "rules[4]",
+			id:   "custom/noemail",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			chain := []*x509.Certificate{tc.cert, ca}
+			r := mapping.MatchChain(chain)
+			if tc.rule == "" {
+				assert.Nil(t, r)
+			} else {
+				require.NotNil(t, r)
+				assert.Equal(t, tc.rule, r.Name)
+			}
+
+			id, err := mapping.IdentityFromChain(chain)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.id, id.String())
+			assert.Equal(t, tc.cert, id.UserInfo())
+		})
+	}
+
+	// the issuer is matched only in the verified chain
+	assert.Nil(t, mapping.Match(tcases[2].cert))
+	id, err := mapping.IdentityFromCertificate(tcases[2].cert)
+	require.NoError(t, err)
+	assert.Equal(t, "guest/alice", id.String())
+	assert.Nil(t, mapping.MatchChain(nil))
+
+	id, err = mapping.IdentityFromCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "guest/", id.String())
+
+	mapping.DefaultRole = ""
+	_, err = mapping.IdentityFromCertificate(nil)
+	assert.EqualError(t, err, "client certificate is required")
+	assert.True(t, IsNotApplicable(err))
+	_, err = mapping.IdentityFromCertificate(tcases[3].cert)
+	assert.EqualError(t, err, `no rule matched: subject="CN=bob,OU=ops"`)
+}
+
+func Test_CertMappingValidate(t *testing.T) {
+	tcases := []struct {
+		rule *CertRule
+		err  string
+	}{
+		{&CertRule{Name: "r"}, `role is required: rule="r"`},
+		{&CertRule{}, `role is required: rule="rules[0]"`},
+		{&CertRule{Name: "r", Role: "r", NameFrom: "serial"}, `invalid name_from "serial": rule="r"`},
+		{&CertRule{Name: "r", Role: "r", URIs: []string{"spiffe://[a"}}, `invalid pattern "spiffe://[a": rule="r"`},
+		{&CertRule{Name: "r", Role: "r", PolicyOIDs: []string{"client_auth"}}, `invalid policy OID "client_auth": rule="r"`},
+		{&CertRule{Name: "r", Role: "r", ExtKeyUsages: []string{"1..2"}}, `invalid extended key usage "1..2": rule="r"`},
+	}
+	for _, tc := range tcases {
+		_, err := NewCertIdentityMapper(&CertMapping{Rules: []*CertRule{tc.rule}})
+		assert.EqualError(t, err, tc.err)
+	}
+
+	_, err := NewCertIdentityMapper(&CertMapping{Rules: []*CertRule{{Deny: true}}})
+	assert.NoError(t, err)
+}
+
+func Test_LoadCertMapping(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "rules.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"rules":[{"name":"all","role":"user","ous":["ops"]}]}`), 0644))
+	mapping, err := LoadCertMapping(file)
+	require.NoError(t, err)
+	require.Len(t, mapping.Rules, 1)
+	assert.Equal(t, "user", mapping.Rules[0].Role)
+
+	_, err = LoadCertMapping(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to read rules file")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{`), 0644))
+	_, err = LoadCertMapping(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to unmarshal rules")
+
+	file = filepath.Join(dir, "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("rules:\n  - name: r1\n"), 0644))
+	_, err = LoadCertMapping(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid rules in "`+file+`": role is required: rule="r1"`)
+}
+
+func Test_CertIdentityMapper(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(testRulesYAML), 0644))
+
+	m, err := NewCertIdentityMapperFromFile(file, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer m.Close()
+
+	ca, caKey := newTestCA(t)
+	cert := newTestCert(t, ca, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	t.Run("request", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.1.2:443"
+		id, err := m.IdentityFromRequest(r)
+		require.NoError(t, err)
+		assert.Equal(t, "guest/10.0.1.2", id.String())
+
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert, ca}},
+		}
+		id, err = m.IdentityFromRequest(r)
+		require.NoError(t, err)
+		assert.Equal(t, "admin/alice", id.String())
+	})
+
+	t.Run("unverified", func(t *testing.T) {
+		// the certificate is issued by CA with the same name,
+		// and is not verified with tls.RequestClientCert
+		fake, fakeKey := newTestCA(t)
+		forged := newTestCert(t, fake, fakeKey, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "attacker", OrganizationalUnit: []string{"ops"}},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.1.3:443"
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{forged, fake}}
+		id, err := m.IdentityFromRequest(r)
+		require.NoError(t, err)
+		assert.Equal(t, "guest/10.0.1.3", id.String())
+
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{forged}}},
+		})
+		id, err = m.IdentityFromContext(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "guest/", id.String())
+	})
+
+	t.Run("context", func(t *testing.T) {
+		id, err := m.IdentityFromContext(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "guest/", id.String())
+
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+				VerifiedChains:   [][]*x509.Certificate{{cert, ca}},
+			}},
+		})
+		id, err = m.IdentityFromContext(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "admin/alice", id.String())
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		// the invalid rules are not applied
+		require.NoError(t, ioutil.WriteFile(file, []byte("rules:\n  - name: r1\n"), 0644))
+		modified := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(file, modified, modified))
+		time.Sleep(100 * time.Millisecond)
+		assert.Len(t, m.Mapping().Rules, 5)
+
+		require.NoError(t, ioutil.WriteFile(file, []byte("rules:\n  - name: deny\n    deny: true\n"), 0644))
+		modified = modified.Add(time.Minute)
+		require.NoError(t, os.Chtimes(file, modified, modified))
+
+		assert.Eventually(t, func() bool {
+			return len(m.Mapping().Rules) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+
+		_, err := m.IdentityFromCertificate(cert)
+		assert.EqualError(t, err, `denied by rule "deny": subject="CN=alice,OU=ops"`)
+	})
+
+	mm, err := NewCertIdentityMapper(&CertMapping{})
+	require.NoError(t, err)
+	assert.NoError(t, mm.Close())
+}
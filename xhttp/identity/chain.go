@@ -0,0 +1,134 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotApplicable is returned by the identity provider,
+// when the request has no credentials of its kind,
+// for example no client certificate or no bearer token.
+// Use errors.Is to check the returned errors.
+var ErrNotApplicable = errors.New("identity provider is not applicable")
+
+type notApplicableError struct {
+	reason string
+}
+
+func (e *notApplicableError) Error() string {
+	return e.reason
+}
+
+// Is reports ErrNotApplicable as the target
+func (e *notApplicableError) Is(target error) bool {
+	return target == ErrNotApplicable
+}
+
+// NotApplicable returns the error which is ErrNotApplicable,
+// with the formatted reason as the message
+func NotApplicable(format string, args ...interface{}) error {
+	return &notApplicableError{reason: fmt.Sprintf(format, args...)}
+}
+
+// IsNotApplicable returns true if the error is ErrNotApplicable
+func IsNotApplicable(err error) bool {
+	return errors.Is(err, ErrNotApplicable)
+}
+
+// providedIdentity records the name of the provider of the identity
+type providedIdentity struct {
+	Identity
+	provider string
+}
+
+// WithProviderName returns Identity that records the name of its provider,
+// which is available from RequestContext.Provider
+func WithProviderName(id Identity, provider string) Identity {
+	if p, ok := id.(providedIdentity); ok {
+		id = p.Identity
+	}
+	return providedIdentity{Identity: id, provider: provider}
+}
+
+// ProviderName returns the name of the identity provider
+// recorded by WithProviderName, or empty string
+func ProviderName(id Identity) string {
+	if p, ok := id.(providedIdentity); ok {
+		return p.provider
+	}
+	return ""
+}
+
+// NamedProvider is the identity provider in the chain
+type NamedProvider struct {
+	// Name of the provider, such as "mtls" or "bearer"
+	Name string
+	// FromRequest returns the identity from HTTP request,
+	// the provider is skipped for HTTP requests if nil
+	FromRequest ProviderFromRequest
+	// FromContext returns the identity from gRPC context,
+	// the provider is skipped for gRPC calls if nil
+	FromContext ProviderFromContext
+}
+
+// ChainedProvider returns the identity of the first applicable provider.
+// The provider is not applicable if it returns ErrNotApplicable,
+// then the next provider is tried; any other error rejects the request.
+type ChainedProvider struct {
+	providers []NamedProvider
+}
+
+// NewChainedProvider returns ChainedProvider,
+// for example: mTLS cert, bearer token, API key, guest
+func NewChainedProvider(providers ...NamedProvider) *ChainedProvider {
+	return &ChainedProvider{
+		providers: providers,
+	}
+}
+
+// IdentityFromRequest returns Identity from HTTP request,
+// it can be used as ProviderFromRequest
+func (c *ChainedProvider) IdentityFromRequest(r *http.Request) (Identity, error) {
+	for _, p := range c.providers {
+		if p.FromRequest == nil {
+			continue
+		}
+		id, err := p.FromRequest(r)
+		if done, id, err := c.result(p.Name, id, err); done {
+			return id, err
+		}
+	}
+	return nil, NotApplicable("no identity provider is applicable")
+}
+
+// IdentityFromContext returns Identity from gRPC context,
+// it can be used as ProviderFromContext
+func (c *ChainedProvider) IdentityFromContext(ctx context.Context) (Identity, error) {
+	for _, p := range c.providers {
+		if p.FromContext == nil {
+			continue
+		}
+		id, err := p.FromContext(ctx)
+		if done, id, err := c.result(p.Name, id, err); done {
+			return id, err
+		}
+	}
+	return nil, NotApplicable("no identity provider is applicable")
+}
+
+func (c *ChainedProvider) result(provider string, id Identity, err error) (bool, Identity, error) {
+	if err != nil {
+		if IsNotApplicable(err) {
+			logger.Debugf("provider=%q, reason=not_applicable, err=[%v]", provider, err.Error())
+			return false, nil, nil
+		}
+		return true, nil, errors.WithMessagef(err, "%s", provider)
+	}
+	if id == nil {
+		return false, nil, nil
+	}
+	return true, WithProviderName(id, provider), nil
+}
@@ -0,0 +1,223 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_NotApplicable(t *testing.T) {
+	err := NotApplicable("no %s", "token")
+	assert.Equal(t, "no token", err.Error())
+	assert.True(t, IsNotApplicable(err))
+	assert.True(t, errors.Is(err, ErrNotApplicable))
+	assert.True(t, IsNotApplicable(errors.WithMessage(err, "wrapped")))
+	assert.True(t, IsNotApplicable(ErrNotApplicable))
+	assert.True(t, IsNotApplicable(ErrNoBearerToken))
+	assert.False(t, IsNotApplicable(errors.New("no token")))
+	assert.False(t, IsNotApplicable(nil))
+}
+
+func Test_ProviderName(t *testing.T) {
+	id := NewIdentity("role", "name", "")
+	assert.Empty(t, ProviderName(id))
+
+	pid := WithProviderName(id, "p1")
+	assert.Equal(t, "p1", ProviderName(pid))
+	assert.Equal(t, "role/name", pid.String())
+	assert.Equal(t, "p2", ProviderName(WithProviderName(pid, "p2")))
+
+	assert.Equal(t, "p1", NewRequestContext(pid).Provider())
+	assert.Empty(t, NewRequestContext(id).Provider())
+}
+
+func Test_ChainedProvider(t *testing.T) {
+	signers := testSigners(t)
+	bearer := NewBearerIdentityMapper(testKeySet(t, signers), WithAudience("api"))
+	token := signers[0].sign(t, map[string]interface{}{
+		"sub":  "user123",
+		"role": "user",
+		"aud":  "api",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+
+	certs, err := NewCertIdentityMapper(&CertMapping{
+		Rules: []*CertRule{
+			{Name: "services", Role: "service", OrganizationalUnits: []string{"services"}},
+			{Name: "revoked", Deny: true, OrganizationalUnits: []string{"revoked"}},
+		},
+	})
+	require.NoError(t, err)
+
+	apiKeys := StaticAPIKeys(map[string]Identity{
+		"key1": NewIdentity("partner", "acme", ""),
+	})
+
+	chain := NewChainedProvider(
+		NamedProvider{Name: "mtls", FromRequest: certs.IdentityFromRequest, FromContext: certs.IdentityFromContext},
+		NamedProvider{Name: "bearer", FromRequest: bearer.IdentityFromRequest, FromContext: bearer.IdentityFromContext},
+		NamedProvider{Name: "apikey", FromRequest: NewAPIKeyProvider("", apiKeys), FromContext: NewAPIKeyContextProvider("", apiKeys)},
+		NamedProvider{Name: "guest", FromRequest: GuestIdentityMapper},
+	)
+
+	withCert := func(r *http.Request, ou string) *http.Request {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client", OrganizationalUnit: []string{ou}}}
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		return r
+	}
+
+	tcases := []struct {
+		name     string
+		request  func() *http.Request
+		provider string
+		id       string
+		err      string
+	}{
+		{
+			name: "mtls",
+			request: func() *http.Request {
+				r := withCert(httptest.NewRequest(http.MethodGet, "/", nil), "services")
+				r.Header.Set(header.Authorization, "Bearer "+token)
+				return r
+			},
+			provider: "mtls",
+			id:       "service/client",
+		},
+		{
+			name: "bearer",
+			request: func() *http.Request {
+				r := withCert(httptest.NewRequest(http.MethodGet, "/", nil), "users")
+				r.Header.Set(header.Authorization, "Bearer "+token)
+				return r
+			},
+			provider: "bearer",
+			id:       "user/user123",
+		},
+		{
+			name: "apikey",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Set(header.XAPIKey, "key1")
+				return r
+			},
+			provider: "apikey",
+			id:       "partner/acme",
+		},
+		{
+			name: "guest",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = "10.0.1.2:443"
+				return r
+			},
+			provider: "guest",
+			id:       "guest/10.0.1.2",
+		},
+		{
+			name: "denied cert",
+			request: func() *http.Request {
+				r := withCert(httptest.NewRequest(http.MethodGet, "/", nil), "revoked")
+				r.Header.Set(header.Authorization, "Bearer "+token)
+				return r
+			},
+			err: `mtls: denied by rule "revoked": subject="CN=client,OU=revoked"`,
+		},
+		{
+			name: "invalid token",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Set(header.Authorization, "Bearer invalid")
+				r.Header.Set(header.XAPIKey, "key1")
+				return r
+			},
+			err: "bearer: invalid token format",
+		},
+		{
+			name: "invalid api key",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Set(header.XAPIKey, "key2")
+				return r
+			},
+			err: "apikey: invalid API key",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := chain.IdentityFromRequest(tc.request())
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.id, id.String())
+			assert.Equal(t, tc.provider, ProviderName(id))
+		})
+	}
+
+	t.Run("context handler", func(t *testing.T) {
+		var rctx *RequestContext
+		h := NewContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rctx = FromRequest(r)
+		}), chain.IdentityFromRequest)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(header.XAPIKey, "key1")
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NotNil(t, rctx)
+		assert.Equal(t, "apikey", rctx.Provider())
+		assert.Equal(t, "partner/acme", rctx.Identity().String())
+	})
+
+	t.Run("context", func(t *testing.T) {
+		// guest provider has no FromContext
+		_, err := chain.IdentityFromContext(context.Background())
+		require.Error(t, err)
+		assert.True(t, IsNotApplicable(err))
+		assert.Equal(t, "no identity provider is applicable", err.Error())
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		id, err := chain.IdentityFromContext(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "user/user123", id.String())
+		assert.Equal(t, "bearer", ProviderName(id))
+
+		interceptor := NewAuthUnaryInterceptor(chain.IdentityFromContext)
+		_, err = interceptor(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			assert.Equal(t, "bearer", FromContext(ctx).Provider())
+			return nil, nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewChainedProvider().IdentityFromRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Error(t, err)
+		assert.True(t, IsNotApplicable(err))
+
+		nilProvider := NewChainedProvider(NamedProvider{
+			Name: "nil",
+			FromRequest: func(*http.Request) (Identity, error) {
+				return nil, nil
+			},
+		})
+		_, err = nilProvider.IdentityFromRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.True(t, IsNotApplicable(err))
+	})
+}
@@ -40,12 +40,14 @@ type RequestContext struct {
 	identity      Identity
 	correlationID string
 	clientIP      string
+	provider      string
 }
 
 // NewRequestContext creates a request context with a specific identity.
 func NewRequestContext(id Identity) *RequestContext {
 	return &RequestContext{
 		identity: id,
+		provider: ProviderName(id),
 	}
 }
 
@@ -135,6 +137,7 @@ func NewContextHandler(delegate http.Handler, identityMapper ProviderFromRequest
 				identity:      identity,
 				correlationID: extractCorrelationID(r),
 				clientIP:      clientIP,
+				provider:      ProviderName(identity),
 			}
 			r = r.WithContext(context.WithValue(r.Context(), keyContext, rctx))
 		} else {
@@ -169,6 +172,35 @@ func NewAuthUnaryInterceptor(identityMapper ProviderFromContext) grpc.UnaryServe
 	}
 }
 
+// NewAuthStreamInterceptor returns grpc.StreamServerInterceptor that
+// identity to the context
+func NewAuthStreamInterceptor(identityMapper ProviderFromContext) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		id, err := identityMapper(ctx)
+		if err != nil {
+			return status.Errorf(codes.PermissionDenied, "unable to get identity: %v", err)
+		}
+		if id == nil {
+			id = guestIdentity
+		}
+		ctx = AddToContext(ctx, NewRequestContext(id))
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context for this stream
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
 // Identity returns request's identity
 func (c *RequestContext) Identity() Identity {
 	return c.identity
@@ -185,6 +217,12 @@ func (c *RequestContext) ClientIP() string {
 	return c.clientIP
 }
 
+// Provider returns the name of the provider of request's identity,
+// set by ChainedProvider
+func (c *RequestContext) Provider() string {
+	return c.provider
+}
+
 // extractCorrelationID will find or create a requestID for this http request.
 func extractCorrelationID(req *http.Request) string {
 	corID := req.Header.Get(header.XCorrelationID)
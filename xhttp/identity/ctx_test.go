@@ -15,6 +15,7 @@ import (
 	"github.com/go-phorce/dolly/xhttp/marshal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 )
 
 func TestMain(m *testing.M) {
@@ -176,6 +177,56 @@ func Test_grpcFromContext(t *testing.T) {
 	})
 }
 
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func Test_grpcStreamFromContext(t *testing.T) {
+	ss := &testServerStream{ctx: context.Background()}
+
+	t.Run("default_guest", func(t *testing.T) {
+		stream := NewAuthStreamInterceptor(GuestIdentityForContext)
+		err := stream(nil, ss, nil, func(srv interface{}, ss grpc.ServerStream) error {
+			rt := FromContext(ss.Context())
+			require.NotNil(t, rt.Identity())
+			assert.Equal(t, GuestRoleName, rt.Identity().Role())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("with_custom_id", func(t *testing.T) {
+		def := func(ctx context.Context) (Identity, error) {
+			return NewIdentity("test", "", ""), nil
+		}
+		stream := NewAuthStreamInterceptor(def)
+		err := stream(nil, ss, nil, func(srv interface{}, ss grpc.ServerStream) error {
+			rt := FromContext(ss.Context())
+			require.NotNil(t, rt.Identity())
+			assert.Equal(t, "test", rt.Identity().Role())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("with_error", func(t *testing.T) {
+		def := func(ctx context.Context) (Identity, error) {
+			return nil, errors.New("invalid request")
+		}
+		stream := NewAuthStreamInterceptor(def)
+		err := stream(nil, ss, nil, func(srv interface{}, ss grpc.ServerStream) error {
+			return errors.New("some error")
+		})
+		require.Error(t, err)
+		assert.Equal(t, "rpc error: code = PermissionDenied desc = unable to get identity: invalid request", err.Error())
+	})
+}
+
 func Test_RequestorIdentity(t *testing.T) {
 	type roleName struct {
 		Role string `json:"role,omitempty"`
@@ -105,6 +105,7 @@ func WithTestIdentity(r *http.Request, identity Identity) *http.Request {
 		identity:      identity,
 		correlationID: extractCorrelationID(r),
 		clientIP:      nodeInfoFactory().LocalIP(),
+		provider:      ProviderName(identity),
 	}
 	c := context.WithValue(r.Context(), keyContext, ctx)
 	return r.WithContext(c)
@@ -0,0 +1,310 @@
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultJWKSCacheTTL is the default period to refresh the keys fetched from JWKS URL
+const DefaultJWKSCacheTTL = time.Hour
+
+// DefaultJWKSFetchTimeout is the default timeout to fetch the keys from JWKS URL
+const DefaultJWKSFetchTimeout = 10 * time.Second
+
+// jwksMaxSize limits the size of JWKS response
+var jwksMaxSize = 1 << 20
+
+// jwksMinRefreshInterval limits how often JWKS URL is fetched,
+// when the token is signed with the unknown key
+var jwksMinRefreshInterval = 30 * time.Second
+
+// JSONWebKey is the public key from JSON Web Key Set
+type JSONWebKey struct {
+	// KeyID is "kid" parameter
+	KeyID string
+	// Algorithm is "alg" parameter, optional
+	Algorithm string
+	// Use is "use" parameter, optional
+	Use string
+	// Key is *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+	Key crypto.PublicKey
+}
+
+// KeySet provides the keys to verify the signature of JWT
+type KeySet interface {
+	// Key returns the key by ID,
+	// if kid is empty, then the only key in the set is returned
+	Key(kid string) (*JSONWebKey, error)
+}
+
+// ParseJWKS parses JSON Web Key Set as defined in RFC 7517.
+// The private and unsupported keys are skipped.
+func ParseJWKS(data []byte) ([]*JSONWebKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.WithMessage(err, "failed to parse JWKS")
+	}
+
+	var keys []*JSONWebKey
+	for _, raw := range set.Keys {
+		key, err := parseJWK(raw)
+		if err != nil {
+			logger.Warningf("reason=parseJWK, err=[%v]", err.Error())
+			continue
+		}
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	D   string `json:"d"`
+}
+
+func parseJWK(raw []byte) (*JSONWebKey, error) {
+	var k jwk
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if k.D != "" {
+		return nil, errors.Errorf("private key is not allowed: kid=%q", k.Kid)
+	}
+
+	key := &JSONWebKey{
+		KeyID:     k.Kid,
+		Algorithm: k.Alg,
+		Use:       k.Use,
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Errorf("invalid RSA modulus: kid=%q", k.Kid)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.Errorf("invalid RSA exponent: kid=%q", k.Kid)
+		}
+		key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q: kid=%q", k.Crv, k.Kid)
+		}
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+		if errX != nil || errY != nil || !curve.IsOnCurve(x, y) {
+			return nil, errors.Errorf("invalid EC key: kid=%q", k.Kid)
+		}
+		key.Key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %q: kid=%q", k.Crv, k.Kid)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid Ed25519 key: kid=%q", k.Kid)
+		}
+		key.Key = ed25519.PublicKey(x)
+	default:
+		return nil, errors.Errorf("unsupported key type %q: kid=%q", k.Kty, k.Kid)
+	}
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// StaticKeySet is KeySet with the fixed keys
+type StaticKeySet []*JSONWebKey
+
+// Key implements KeySet interface
+func (s StaticKeySet) Key(kid string) (*JSONWebKey, error) {
+	return findKey(s, kid)
+}
+
+func findKey(keys []*JSONWebKey, kid string) (*JSONWebKey, error) {
+	if kid == "" {
+		if len(keys) == 1 {
+			return keys[0], nil
+		}
+		return nil, errors.New("kid is required")
+	}
+	for _, k := range keys {
+		if k.KeyID == kid {
+			return k, nil
+		}
+	}
+	return nil, errors.Errorf("key not found: kid=%q", kid)
+}
+
+// NewJWKSFromFile returns KeySet loaded from JWKS file
+func NewJWKSFromFile(file string) (KeySet, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to load JWKS")
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to load JWKS from %q", file)
+	}
+	return StaticKeySet(keys), nil
+}
+
+// RemoteKeySet is KeySet fetched from JWKS URL,
+// the keys are cached and refreshed after the cache TTL,
+// or when the token is signed with the unknown key.
+// Only one refresh runs at a time, and the cached keys
+// are served while it is in progress.
+type RemoteKeySet struct {
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+
+	lock       sync.Mutex
+	keys       []*JSONWebKey
+	fetchedAt  time.Time
+	refreshing chan struct{}
+}
+
+// NewJWKSFromURL returns KeySet fetched from JWKS URL.
+// If client is nil, then the client with DefaultJWKSFetchTimeout is used;
+// if cacheTTL is 0, then DefaultJWKSCacheTTL is used.
+func NewJWKSFromURL(url string, client *http.Client, cacheTTL time.Duration) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultJWKSFetchTimeout}
+	}
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultJWKSCacheTTL
+	}
+	return &RemoteKeySet{
+		url:      url,
+		client:   client,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Key implements KeySet interface
+func (s *RemoteKeySet) Key(kid string) (*JSONWebKey, error) {
+	s.lock.Lock()
+	now := time.Now()
+	var done <-chan struct{}
+	if now.Sub(s.fetchedAt) >= s.cacheTTL {
+		done = s.refresh(now)
+	}
+	keys := s.keys
+	s.lock.Unlock()
+
+	key, err := findKey(keys, kid)
+	if err == nil {
+		// the cached key is served while the refresh is in progress
+		return key, nil
+	}
+
+	if done == nil {
+		s.lock.Lock()
+		if s.refreshing != nil || now.Sub(s.fetchedAt) >= jwksMinRefreshInterval {
+			// the keys may be rotated
+			done = s.refresh(now)
+		}
+		s.lock.Unlock()
+		if done == nil {
+			return nil, err
+		}
+	}
+
+	<-done
+	s.lock.Lock()
+	keys = s.keys
+	s.lock.Unlock()
+	return findKey(keys, kid)
+}
+
+// refresh starts to fetch the keys, unless the refresh is in progress,
+// and returns the channel which is closed when the refresh completes.
+// On error the cached keys are kept.
+// The caller must hold the lock.
+func (s *RemoteKeySet) refresh(now time.Time) <-chan struct{} {
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+	s.fetchedAt = now
+	done := make(chan struct{})
+	s.refreshing = done
+
+	go func() {
+		// the keys are fetched outside of the lock
+		keys, err := s.fetch()
+
+		s.lock.Lock()
+		if err != nil {
+			logger.Errorf("reason=fetch, url=%q, err=[%v]", s.url, err.Error())
+		} else {
+			s.keys = keys
+		}
+		s.refreshing = nil
+		s.lock.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+func (s *RemoteKeySet) fetch() ([]*JSONWebKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(jwksMaxSize)+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(data) > jwksMaxSize {
+		return nil, errors.Errorf("JWKS response exceeds %d bytes", jwksMaxSize)
+	}
+	return ParseJWKS(data)
+}
@@ -0,0 +1,277 @@
+package identity
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func pad(b []byte, size int) []byte {
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+// toJWK returns JWK of the public key
+func toJWK(t *testing.T, kid, alg string, pub interface{}) map[string]string {
+	k := map[string]string{"kid": kid, "use": "sig"}
+	if alg != "" {
+		k["alg"] = alg
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k["kty"] = "RSA"
+		k["n"] = b64(pub.N.Bytes())
+		k["e"] = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k["kty"] = "EC"
+		k["crv"] = pub.Curve.Params().Name
+		k["x"] = b64(pad(pub.X.Bytes(), size))
+		k["y"] = b64(pad(pub.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		k["kty"] = "OKP"
+		k["crv"] = "Ed25519"
+		k["x"] = b64(pub)
+	default:
+		t.Fatalf("unsupported key: %T", pub)
+	}
+	return k
+}
+
+func toJWKS(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func Test_ParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	enc := toJWK(t, "enc", "", &rsaKey.PublicKey)
+	enc["use"] = "enc"
+	private := toJWK(t, "private", "", &rsaKey.PublicKey)
+	private["d"] = "AQAB"
+	badCurve := toJWK(t, "badcurve", "", &ecKey.PublicKey)
+	badCurve["crv"] = "P-224"
+	notOnCurve := toJWK(t, "notoncurve", "", &ecKey.PublicKey)
+	notOnCurve["y"] = notOnCurve["x"]
+
+	data := toJWKS(t,
+		toJWK(t, "rsa", "RS256", &rsaKey.PublicKey),
+		toJWK(t, "ec", "", &ecKey.PublicKey),
+		toJWK(t, "ed", "EdDSA", edPub),
+		enc,
+		private,
+		badCurve,
+		notOnCurve,
+		map[string]string{"kid": "oct", "kty": "oct", "k": "AQAB"},
+		map[string]string{"kid": "badrsa", "kty": "RSA", "n": "", "e": "AQAB"},
+		map[string]string{"kid": "bated", "kty": "OKP", "crv": "Ed25519", "x": "AQAB"},
+	)
+
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, "rsa", keys[0].KeyID)
+	assert.Equal(t, "RS256", keys[0].Algorithm)
+	assert.Equal(t, &rsaKey.PublicKey, keys[0].Key)
+	assert.True(t, ecKey.PublicKey.Equal(keys[1].Key))
+	assert.Equal(t, edPub, keys[2].Key)
+
+	_, err = ParseJWKS([]byte(`{`))
+	assert.EqualError(t, err, "failed to parse JWKS: unexpected end of JSON input")
+
+	set := StaticKeySet(keys)
+	k, err := set.Key("ec")
+	require.NoError(t, err)
+	assert.Equal(t, "ec", k.KeyID)
+	_, err = set.Key("")
+	assert.EqualError(t, err, "kid is required")
+	_, err = set.Key("unknown")
+	assert.EqualError(t, err, `key not found: kid="unknown"`)
+
+	k, err = StaticKeySet(keys[:1]).Key("")
+	require.NoError(t, err)
+	assert.Equal(t, "rsa", k.KeyID)
+}
+
+func Test_NewJWKSFromFile(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, ioutil.WriteFile(file, toJWKS(t, toJWK(t, "ed", "", edPub)), 0644))
+
+	set, err := NewJWKSFromFile(file)
+	require.NoError(t, err)
+	k, err := set.Key("ed")
+	require.NoError(t, err)
+	assert.Equal(t, edPub, k.Key)
+
+	_, err = NewJWKSFromFile(file + ".missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to load JWKS")
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("invalid"), 0644))
+	_, err = NewJWKSFromFile(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to load JWKS from")
+}
+
+func Test_NewJWKSFromURL(t *testing.T) {
+	pub1, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub2, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var fetched int32
+	var jwks atomic.Value
+	jwks.Store(toJWKS(t, toJWK(t, "k1", "", pub1)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		data := jwks.Load().([]byte)
+		if len(data) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	set := NewJWKSFromURL(server.URL, nil, time.Hour)
+
+	k, err := set.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, pub1, k.Key)
+	_, err = set.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched), "the keys must be cached")
+
+	// the unknown key is not fetched within the minimal refresh interval
+	_, err = set.Key("k2")
+	assert.EqualError(t, err, `key not found: kid="k2"`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// the keys are rotated
+	defer func(d time.Duration) { jwksMinRefreshInterval = d }(jwksMinRefreshInterval)
+	jwksMinRefreshInterval = 0
+	jwks.Store(toJWKS(t, toJWK(t, "k2", "", pub2)))
+
+	k, err = set.Key("k2")
+	require.NoError(t, err)
+	assert.Equal(t, pub2, k.Key)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+
+	// on failure the cached keys are kept
+	jwks.Store([]byte{})
+	_, err = set.Key("k1")
+	assert.EqualError(t, err, `key not found: kid="k1"`)
+	k, err = set.Key("k2")
+	require.NoError(t, err)
+	assert.Equal(t, pub2, k.Key)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetched))
+
+	// the large response is rejected, the cached keys are kept
+	defer func(size int) { jwksMaxSize = size }(jwksMaxSize)
+	data := toJWKS(t, toJWK(t, "k1", "", pub1), toJWK(t, "k2", "", pub2))
+	jwksMaxSize = len(data) - 1
+	jwks.Store(data)
+	_, err = set.fetch()
+	assert.EqualError(t, err, fmt.Sprintf("JWKS response exceeds %d bytes", jwksMaxSize))
+	_, err = set.Key("k1")
+	assert.EqualError(t, err, `key not found: kid="k1"`)
+}
+
+func Test_RemoteKeySetRefresh(t *testing.T) {
+	set := NewJWKSFromURL("http://localhost", nil, 0)
+	assert.Equal(t, DefaultJWKSFetchTimeout, set.client.Timeout)
+	assert.Equal(t, DefaultJWKSCacheTTL, set.cacheTTL)
+
+	pub1, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub2, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var fetched int32
+	var jwks atomic.Value
+	jwks.Store(toJWKS(t, toJWK(t, "k1", "", pub1)))
+	block := make(chan struct{})
+	close(block)
+	var blocked atomic.Value
+	blocked.Store(block)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		<-blocked.Load().(chan struct{})
+		w.Write(jwks.Load().([]byte))
+	}))
+	defer server.Close()
+
+	// the keys are always expired
+	set = NewJWKSFromURL(server.URL, nil, time.Nanosecond)
+	k, err := set.Key("k1")
+	require.NoError(t, err)
+	assert.Equal(t, pub1, k.Key)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// the refresh hangs
+	block = make(chan struct{})
+	blocked.Store(block)
+	jwks.Store(toJWKS(t, toJWK(t, "k1", "", pub1), toJWK(t, "k2", "", pub2)))
+
+	// the cached keys are served while the refresh is in progress
+	for i := 0; i < 5; i++ {
+		k, err = set.Key("k1")
+		require.NoError(t, err)
+		assert.Equal(t, pub1, k.Key)
+	}
+
+	// the unknown key waits for the refresh in progress
+	result := make(chan *JSONWebKey)
+	go func() {
+		k, err := set.Key("k2")
+		assert.NoError(t, err)
+		result <- k
+	}()
+	select {
+	case <-result:
+		t.Fatal("the refresh is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(block)
+	select {
+	case k = <-result:
+		require.NotNil(t, k)
+		assert.Equal(t, pub2, k.Key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the refresh is not completed")
+	}
+	// only one refresh runs at a time
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+}
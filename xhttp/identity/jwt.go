@@ -0,0 +1,433 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultClockSkew is the default allowed clock skew for exp and nbf claims
+const DefaultClockSkew = time.Minute
+
+// ErrNoBearerToken is returned when the request has no bearer token,
+// it is ErrNotApplicable
+var ErrNoBearerToken = NotApplicable("bearer token not found")
+
+// DefaultJWTAlgorithms are the signature algorithms allowed by default
+var DefaultJWTAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Claims is the payload of JWT
+type Claims map[string]interface{}
+
+// Value returns the value of the claim by the dotted path,
+// for example "realm_access.roles"
+func (c Claims) Value(path string) interface{} {
+	var v interface{} = map[string]interface{}(c)
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+// String returns the string value of the claim by the dotted path,
+// if the value is an array, then the first element is returned
+func (c Claims) String(path string) string {
+	switch v := c.Value(path).(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// BearerOption is an option that can be passed to NewBearerIdentityMapper
+type BearerOption func(m *BearerIdentityMapper)
+
+// WithIssuer is an Option to require iss claim
+func WithIssuer(issuer string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.issuer = issuer
+	}
+}
+
+// WithAudience is an Option to require aud claim to contain one of the audiences,
+// the audience is required unless WithAnyAudience is specified
+func WithAudience(audience ...string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.audience = audience
+	}
+}
+
+// WithAnyAudience is an Option to accept the tokens issued for any audience,
+// it should be used only when the issuer signs the tokens only for this service
+func WithAnyAudience() BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.anyAudience = true
+	}
+}
+
+// WithClockSkew is an Option to specify the allowed clock skew,
+// by default DefaultClockSkew
+func WithClockSkew(skew time.Duration) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.clockSkew = skew
+	}
+}
+
+// WithAlgorithms is an Option to specify the allowed signature algorithms,
+// by default DefaultJWTAlgorithms
+func WithAlgorithms(algs ...string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.algorithms = algs
+	}
+}
+
+// WithRoleClaim is an Option to specify the dotted path of the role claim,
+// by default "role"
+func WithRoleClaim(path string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.roleClaim = path
+	}
+}
+
+// WithNameClaim is an Option to specify the dotted path of the name claim,
+// by default "sub"
+func WithNameClaim(path string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.nameClaim = path
+	}
+}
+
+// WithUserIDClaim is an Option to specify the dotted path of the user ID claim,
+// by default "sub"
+func WithUserIDClaim(path string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.userIDClaim = path
+	}
+}
+
+// WithDefaultRole is an Option to specify the role,
+// when the token has no role claim, by default GuestRoleName
+func WithDefaultRole(role string) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.defaultRole = role
+	}
+}
+
+// WithNowFunc is an Option to specify the current time function,
+// used in unit tests
+func WithNowFunc(now func() time.Time) BearerOption {
+	return func(m *BearerIdentityMapper) {
+		m.now = now
+	}
+}
+
+// BearerIdentityMapper validates JWT bearer token,
+// and maps its claims to Identity.
+// The Claims are returned by Identity.UserInfo().
+type BearerIdentityMapper struct {
+	keys        KeySet
+	issuer      string
+	audience    []string
+	anyAudience bool
+	clockSkew   time.Duration
+	algorithms  []string
+	roleClaim   string
+	nameClaim   string
+	userIDClaim string
+	defaultRole string
+	now         func() time.Time
+}
+
+// NewBearerIdentityMapper returns BearerIdentityMapper.
+// The tokens are rejected, unless the audience is specified
+// by WithAudience, or WithAnyAudience option.
+func NewBearerIdentityMapper(keys KeySet, opts ...BearerOption) *BearerIdentityMapper {
+	m := &BearerIdentityMapper{
+		keys:        keys,
+		clockSkew:   DefaultClockSkew,
+		algorithms:  DefaultJWTAlgorithms,
+		roleClaim:   "role",
+		nameClaim:   "sub",
+		userIDClaim: "sub",
+		defaultRole: GuestRoleName,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// IdentityFromRequest returns Identity from Authorization header,
+// it can be used as ProviderFromRequest
+func (m *BearerIdentityMapper) IdentityFromRequest(r *http.Request) (Identity, error) {
+	token, err := bearerToken(r.Header.Get(header.Authorization))
+	if err != nil {
+		return nil, err
+	}
+	return m.IdentityFromToken(token)
+}
+
+// IdentityFromContext returns Identity from authorization key of gRPC metadata,
+// it can be used as ProviderFromContext
+func (m *BearerIdentityMapper) IdentityFromContext(ctx context.Context) (Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var auth string
+	if values := md.Get(strings.ToLower(header.Authorization)); len(values) > 0 {
+		auth = values[0]
+	}
+	token, err := bearerToken(auth)
+	if err != nil {
+		return nil, err
+	}
+	return m.IdentityFromToken(token)
+}
+
+// IdentityFromToken validates JWT and returns Identity
+func (m *BearerIdentityMapper) IdentityFromToken(token string) (Identity, error) {
+	claims, err := m.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	role := claims.String(m.roleClaim)
+	if role == "" {
+		role = m.defaultRole
+	}
+	return NewIdentityWithUserInfo(role, claims.String(m.nameClaim), claims.String(m.userIDClaim), claims), nil
+}
+
+// ParseToken verifies the signature of JWT,
+// validates exp, nbf, iss and aud claims, and returns the claims
+func (m *BearerIdentityMapper) ParseToken(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid token format")
+	}
+
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, errors.WithMessage(err, "invalid token header")
+	}
+	if !m.isAllowed(hdr.Alg) {
+		return nil, errors.Errorf("algorithm not allowed: %q", hdr.Alg)
+	}
+
+	key, err := m.keys.Key(hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != hdr.Alg {
+		return nil, errors.Errorf("algorithm %q does not match the key: kid=%q", hdr.Alg, key.KeyID)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token signature")
+	}
+	if err = verifySignature(hdr.Alg, key.Key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.WithMessage(err, "invalid token claims")
+	}
+	if err = m.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *BearerIdentityMapper) isAllowed(alg string) bool {
+	for _, a := range m.algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *BearerIdentityMapper) validateClaims(claims Claims) error {
+	now := m.now()
+
+	exp, ok := claims.time("exp")
+	if !ok {
+		return errors.New("exp claim is required")
+	}
+	if now.After(exp.Add(m.clockSkew)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Before(nbf.Add(-m.clockSkew)) {
+		return errors.New("token is not valid yet")
+	}
+
+	if m.issuer != "" && claims.String("iss") != m.issuer {
+		return errors.Errorf("invalid issuer: %q", claims.String("iss"))
+	}
+	if len(m.audience) > 0 {
+		if !claims.hasAudience(m.audience) {
+			return errors.New("invalid audience")
+		}
+	} else if !m.anyAudience {
+		return errors.New("audience is not configured")
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func (c Claims) hasAudience(audience []string) bool {
+	var aud []string
+	switch v := c["aud"].(type) {
+	case string:
+		aud = []string{v}
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+	}
+	for _, a := range aud {
+		for _, expected := range audience {
+			if a == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func bearerToken(auth string) (string, error) {
+	if len(auth) <= len(header.Bearer)+1 ||
+		!strings.EqualFold(auth[:len(header.Bearer)], header.Bearer) ||
+		auth[len(header.Bearer)] != ' ' {
+		return "", ErrNoBearerToken
+	}
+	return strings.TrimSpace(auth[len(header.Bearer)+1:]), nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return errors.WithStack(d.Decode(v))
+}
+
+var ecCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+	default:
+		return errors.Errorf("unsupported algorithm: %q", alg)
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	invalid := errors.New("invalid token signature")
+	switch alg[0] {
+	case 'R', 'P':
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.Errorf("algorithm %q requires RSA key", alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		}
+		if err != nil {
+			return invalid
+		}
+	case 'E':
+		if alg == "EdDSA" {
+			pub, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return errors.Errorf("algorithm %q requires Ed25519 key", alg)
+			}
+			if !ed25519.Verify(pub, signed, sig) {
+				return invalid
+			}
+			return nil
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().Name != ecCurves[alg] {
+			return errors.Errorf("algorithm %q requires %s key", alg, ecCurves[alg])
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	hdr, err := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(hdr) + "." + b64(payload)
+
+	var hash crypto.Hash
+	switch s.alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+
+	var sig []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(signed))
+	case *rsa.PrivateKey:
+		h := hash.New()
+		h.Write([]byte(signed))
+		if s.alg[0] == 'P' {
+			sig, err = rsa.SignPSS(rand.Reader, key, hash, h.Sum(nil), nil)
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+		}
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		h := hash.New()
+		h.Write([]byte(signed))
+		r, ss, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		require.NoError(t, err)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = append(pad(r.Bytes(), size), pad(ss.Bytes(), size)...)
+	}
+	return signed + "." + b64(sig)
+}
+
+func testSigners(t *testing.T) []*testSigner {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ec384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ec521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return []*testSigner{
+		{kid: "rsa", alg: "RS256", key: rsaKey},
+		{kid: "rsa", alg: "RS384", key: rsaKey},
+		{kid: "rsa", alg: "RS512", key: rsaKey},
+		{kid: "rsa", alg: "PS256", key: rsaKey},
+		{kid: "rsa", alg: "PS512", key: rsaKey},
+		{kid: "ec256", alg: "ES256", key: ec256},
+		{kid: "ec384", alg: "ES384", key: ec384},
+		{kid: "ec521", alg: "ES512", key: ec521},
+		{kid: "ed", alg: "EdDSA", key: edKey},
+	}
+}
+
+func testKeySet(t *testing.T, signers []*testSigner) KeySet {
+	var keys []map[string]string
+	seen := map[string]bool{}
+	for _, s := range signers {
+		if !seen[s.kid] {
+			seen[s.kid] = true
+			keys = append(keys, toJWK(t, s.kid, "", s.key.Public()))
+		}
+	}
+	parsed, err := ParseJWKS(toJWKS(t, keys...))
+	require.NoError(t, err)
+	return StaticKeySet(parsed)
+}
+
+func Test_BearerIdentityMapper(t *testing.T) {
+	signers := testSigners(t)
+	now := time.Unix(1600000000, 0)
+
+	m := NewBearerIdentityMapper(testKeySet(t, signers),
+		WithIssuer("https://issuer"),
+		WithAudience("api", "other"),
+		WithRoleClaim("realm_access.roles"),
+		WithNameClaim("email"),
+		WithNowFunc(func() time.Time { return now }),
+	)
+
+	claims := map[string]interface{}{
+		"iss":   "https://issuer",
+		"aud":   []string{"api"},
+		"sub":   "user123",
+		"email": "user@example.com",
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Unix(),
+		"realm_access": map[string]interface{}{
+			"roles": []string{"admin", "user"},
+		},
+	}
+
+	for _, s := range signers {
+		t.Run(s.alg, func(t *testing.T) {
+			id, err := m.IdentityFromToken(s.sign(t, claims))
+			require.NoError(t, err)
+			assert.Equal(t, "admin", id.Role())
+			assert.Equal(t, "user@example.com", id.Name())
+			assert.Equal(t, "user123", id.UserID())
+			assert.Equal(t, "admin/user@example.com", id.String())
+
+			c, ok := id.UserInfo().(Claims)
+			require.True(t, ok)
+			assert.Equal(t, "https://issuer", c.String("iss"))
+			assert.Equal(t, "admin", c.String("realm_access.roles"))
+		})
+	}
+
+	t.Run("default role", func(t *testing.T) {
+		m := NewBearerIdentityMapper(testKeySet(t, signers[:1]),
+			WithAnyAudience(),
+			WithDefaultRole("user"),
+			WithUserIDClaim("uid"),
+			WithNowFunc(func() time.Time { return now }))
+		id, err := m.IdentityFromToken(signers[0].sign(t, map[string]interface{}{
+			"sub": "user123",
+			"uid": 42,
+			"exp": now.Unix(),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "user/user123", id.String())
+		assert.Equal(t, "42", id.UserID())
+	})
+}
+
+func Test_BearerIdentityMapperErrors(t *testing.T) {
+	signers := testSigners(t)
+	rs256, es256, ed := signers[0], signers[5], signers[8]
+	now := time.Unix(1600000000, 0)
+	keys := testKeySet(t, signers)
+
+	m := NewBearerIdentityMapper(keys,
+		WithIssuer("https://issuer"),
+		WithAudience("api"),
+		WithClockSkew(time.Minute),
+		WithAlgorithms("RS256", "ES256", "EdDSA"),
+		WithNowFunc(func() time.Time { return now }),
+	)
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://issuer",
+			"aud": "api",
+			"sub": "user123",
+			"exp": now.Unix(),
+		}
+	}
+	with := func(k string, v interface{}) map[string]interface{} {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+
+	_, err := m.ParseToken(rs256.sign(t, valid()))
+	require.NoError(t, err)
+	// within clock skew
+	_, err = m.ParseToken(rs256.sign(t, with("exp", now.Add(-59*time.Second).Unix())))
+	require.NoError(t, err)
+	_, err = m.ParseToken(rs256.sign(t, with("nbf", now.Add(59*time.Second).Unix())))
+	require.NoError(t, err)
+
+	tcases := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"format", "a.b", "invalid token format"},
+		{"header", "!.b.c", "invalid token header: illegal base64 data at input byte 0"},
+		{"alg not allowed", signers[1].sign(t, valid()), `algorithm not allowed: "RS384"`},
+		{"alg none", b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{}`)) + ".", `algorithm not allowed: "none"`},
+		{"unknown key", (&testSigner{kid: "unknown", alg: "RS256", key: rs256.key}).sign(t, valid()), `key not found: kid="unknown"`},
+		{"key type", (&testSigner{kid: "ed", alg: "RS256", key: rs256.key}).sign(t, valid()), `algorithm "RS256" requires RSA key`},
+		{"curve", (&testSigner{kid: "ec384", alg: "ES256", key: signers[6].key}).sign(t, valid()), `algorithm "ES256" requires P-256 key`},
+		{"ed key type", (&testSigner{kid: "rsa", alg: "EdDSA", key: ed.key}).sign(t, valid()), `algorithm "EdDSA" requires Ed25519 key`},
+		{"signature", (&testSigner{kid: "ec256", alg: "ES256", key: signers[6].key}).sign(t, valid()), "invalid token signature"},
+		{"expired", rs256.sign(t, with("exp", now.Add(-61*time.Second).Unix())), "token expired"},
+		{"no exp", es256.sign(t, with("exp", nil)), "exp claim is required"},
+		{"nbf", ed.sign(t, with("nbf", now.Add(61*time.Second).Unix())), "token is not valid yet"},
+		{"issuer", rs256.sign(t, with("iss", "https://other")), `invalid issuer: "https://other"`},
+		{"audience", rs256.sign(t, with("aud", []string{"other"})), "invalid audience"},
+		{"no audience", rs256.sign(t, with("aud", nil)), "invalid audience"},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := m.ParseToken(tc.token)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+
+	t.Run("audience not configured", func(t *testing.T) {
+		m := NewBearerIdentityMapper(keys, WithNowFunc(func() time.Time { return now }))
+		_, err := m.ParseToken(rs256.sign(t, valid()))
+		assert.EqualError(t, err, "audience is not configured")
+
+		m = NewBearerIdentityMapper(keys, WithAnyAudience(), WithNowFunc(func() time.Time { return now }))
+		_, err = m.ParseToken(rs256.sign(t, with("aud", "other")))
+		assert.NoError(t, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		token := rs256.sign(t, valid())
+		parts := strings.Split(token, ".")
+		parts[1] = b64([]byte(`{"sub":"admin","exp":1600000000}`))
+		_, err := m.ParseToken(strings.Join(parts, "."))
+		assert.EqualError(t, err, "invalid token signature")
+	})
+
+	t.Run("alg of key", func(t *testing.T) {
+		parsed, err := ParseJWKS(toJWKS(t, toJWK(t, "rsa", "RS512", rs256.key.Public())))
+		require.NoError(t, err)
+		m := NewBearerIdentityMapper(StaticKeySet(parsed))
+		_, err = m.ParseToken(rs256.sign(t, valid()))
+		assert.EqualError(t, err, `algorithm "RS256" does not match the key: kid="rsa"`)
+	})
+}
+
+func Test_BearerIdentityFromRequest(t *testing.T) {
+	signers := testSigners(t)
+	m := NewBearerIdentityMapper(testKeySet(t, signers), WithAnyAudience())
+	token := signers[0].sign(t, map[string]interface{}{
+		"sub":  "user123",
+		"role": "admin",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := m.IdentityFromRequest(r)
+	assert.Equal(t, ErrNoBearerToken, err)
+
+	r.Header.Set(header.Authorization, "Basic dXNlcjpwYXNz")
+	_, err = m.IdentityFromRequest(r)
+	assert.Equal(t, ErrNoBearerToken, err)
+
+	r.Header.Set(header.Authorization, "bearer "+token)
+	id, err := m.IdentityFromRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "admin/user123", id.String())
+
+	t.Run("context handler", func(t *testing.T) {
+		h := NewContextHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(FromRequest(r).Identity().String()))
+		}), m.IdentityFromRequest)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(header.Authorization, header.Bearer+" "+token)
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "admin/user123", w.Body.String())
+
+		w = httptest.NewRecorder()
+		r.Header.Set(header.Authorization, header.Bearer+" invalid")
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func Test_BearerIdentityFromContext(t *testing.T) {
+	signers := testSigners(t)
+	m := NewBearerIdentityMapper(testKeySet(t, signers), WithAnyAudience())
+	token := signers[8].sign(t, map[string]interface{}{
+		"sub": "user123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, err := m.IdentityFromContext(context.Background())
+	assert.Equal(t, ErrNoBearerToken, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	id, err := m.IdentityFromContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "guest/user123", id.String())
+
+	called := false
+	interceptor := NewAuthUnaryInterceptor(m.IdentityFromContext)
+	_, err = interceptor(ctx, nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		assert.Equal(t, "guest/user123", FromContext(ctx).Identity().String())
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+}
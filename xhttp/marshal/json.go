@@ -3,11 +3,15 @@ package marshal
 
 import (
 	"bufio"
+	"context"
+	goErrors "errors"
 	"io"
 	"net/http"
 	"reflect"
 
+	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
 	"github.com/ugorji/go/codec"
 )
 
@@ -103,17 +107,69 @@ func Decode(r io.Reader, result interface{}) error {
 	return codec.NewDecoder(bufio.NewReader(r), DecoderHandle()).Decode(result)
 }
 
-// DecodeBody will read the json from the HTTP request body,
+type maxBodySizeContextKey struct{}
+
+// WithMaxBodySize returns the request with the maximum size of the decoded body,
+// the decompressed body larger than the size is rejected by DecodeBody
+func WithMaxBodySize(r *http.Request, size int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), maxBodySizeContextKey{}, size))
+}
+
+// MaxBodySize returns the maximum size of the decoded body set by WithMaxBodySize,
+// or 0 if not limited
+func MaxBodySize(r *http.Request) int64 {
+	size, _ := r.Context().Value(maxBodySizeContextKey{}).(int64)
+	return size
+}
+
+// DecodeBody will read the body from the HTTP request,
 // and decode it into the supplied result instance.
-// If error occured, then
+// The body is decoded as CBOR, MessagePack or protobuf by Content-Type header,
+// otherwise as JSON, and decompressed by Content-Encoding header,
+// the decompressed body is limited by MaxBodySize.
+// If error occured, then the error response is written.
 func DecodeBody(w http.ResponseWriter, r *http.Request, result interface{}) error {
-	err := Decode(r.Body, result)
+	c, err := negotiate.RequestCodec(r)
 	if err != nil {
+		// the unknown content types are decoded as JSON
+		c = negotiate.JSON
+	}
+
+	body := &bodyReader{r: r.Body}
+	decoded := &bodyReader{}
+	rd, err := negotiate.NewDecompressor(body, r.Header.Get(header.ContentEncoding))
+	if err == nil {
+		defer rd.Close()
+		decoded.r = rd
+		if size := MaxBodySize(r); size > 0 {
+			decoded.r = &limitedReader{r: rd, remaining: size, limit: size}
+		}
+		err = c.Decode(decoded, result)
+	}
+	if err != nil {
+		// the body reader may fail with API error, such as RequestTooLarge
+		var apiErr *httperror.Error
+		if goErrors.As(body.err, &apiErr) || goErrors.As(decoded.err, &apiErr) {
+			WriteJSON(w, r, apiErr)
+			return err
+		}
+		if goErrors.Is(err, negotiate.ErrUnsupportedMediaType) {
+			WriteJSON(w, r, httperror.New(
+				http.StatusUnsupportedMediaType,
+				httperror.InvalidContentType,
+				"%s", err.Error(),
+			).WithCause(err))
+			return err
+		}
+		code := httperror.InvalidJSON
+		if c != negotiate.JSON {
+			code = httperror.InvalidRequest
+		}
 		WriteJSON(
 			w, r,
 			httperror.New(
 				http.StatusBadRequest,
-				httperror.InvalidJSON,
+				code,
 				"failed to decode '%T': %v",
 				result, err.Error(),
 			).WithCause(err))
@@ -121,3 +177,44 @@ func DecodeBody(w http.ResponseWriter, r *http.Request, result interface{}) erro
 	}
 	return nil
 }
+
+// bodyReader keeps the first error returned by the request body,
+// as the decoder does not preserve it
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// limitedReader fails with httperror.WithRequestTooLarge
+// when more than limit bytes are read
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one byte more than allowed to detect the overflow
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) <= l.remaining {
+		l.remaining -= int64(n)
+		return n, err
+	}
+	n = int(l.remaining)
+	l.remaining = 0
+	return n, httperror.WithRequestTooLarge("decompressed request body exceeds %d bytes", l.limit)
+}
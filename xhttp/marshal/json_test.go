@@ -9,6 +9,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -79,6 +82,114 @@ func Test_DecodeBody(t *testing.T) {
 	assert.Equal(t, `{"code":"invalid_json","message":"failed to decode '*marshal.AStruct': json decode error [pos 21]: no matching struct field found when decoding stream map with key C"}`, string(w.Body.Bytes()))
 }
 
+func Test_DecodeBodyNegotiated(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := negotiate.NewCompressor(&buf, negotiate.Gzip)
+	require.NoError(t, err)
+	require.NoError(t, negotiate.CBOR.Encode(zw, &AStruct{A: "a", B: "b"}, false))
+	require.NoError(t, zw.Close())
+
+	r, err := http.NewRequest(http.MethodPost, "/v1/test", &buf)
+	require.NoError(t, err)
+	r.Header.Set(header.ContentType, header.ApplicationCBOR)
+	r.Header.Set(header.ContentEncoding, negotiate.Gzip)
+
+	w := httptest.NewRecorder()
+	var res AStruct
+	require.NoError(t, DecodeBody(w, r, &res))
+	assert.Equal(t, AStruct{A: "a", B: "b"}, res)
+
+	t.Run("unknown_content_type", func(t *testing.T) {
+		// decoded as JSON
+		r, err := http.NewRequest(http.MethodPost, "/v1/test", bytes.NewBufferString(`{"A":"x"}`))
+		require.NoError(t, err)
+		r.Header.Set(header.ContentType, "text/plain")
+
+		w := httptest.NewRecorder()
+		var res AStruct
+		require.NoError(t, DecodeBody(w, r, &res))
+		assert.Equal(t, "x", res.A)
+
+		r, err = http.NewRequest(http.MethodPost, "/v1/test", bytes.NewBufferString("<a/>"))
+		require.NoError(t, err)
+		r.Header.Set(header.ContentType, "text/xml")
+
+		w = httptest.NewRecorder()
+		err = DecodeBody(w, r, &res)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_json"`)
+	})
+
+	t.Run("decompressed_too_large", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw, err := negotiate.NewCompressor(&buf, negotiate.Gzip)
+		require.NoError(t, err)
+		_, err = zw.Write([]byte(`{"A":"` + strings.Repeat("a", 1000) + `"}`))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.True(t, buf.Len() < 100)
+
+		r, err := http.NewRequest(http.MethodPost, "/v1/test", &buf)
+		require.NoError(t, err)
+		r.Header.Set(header.ContentEncoding, negotiate.Gzip)
+		assert.Equal(t, int64(0), MaxBodySize(r))
+		r = WithMaxBodySize(r, 100)
+		assert.Equal(t, int64(100), MaxBodySize(r))
+
+		w := httptest.NewRecorder()
+		err = DecodeBody(w, r, &res)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"code":"request_too_large","message":"decompressed request body exceeds 100 bytes"}`, w.Body.String())
+	})
+
+	t.Run("unsupported_content_encoding", func(t *testing.T) {
+		r, err := http.NewRequest(http.MethodPost, "/v1/test", bytes.NewBufferString("{}"))
+		require.NoError(t, err)
+		r.Header.Set(header.ContentEncoding, "compress")
+
+		w := httptest.NewRecorder()
+		err = DecodeBody(w, r, &res)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, `{"code":"invalid_content_type","message":"content encoding \"compress\": unsupported media type"}`, w.Body.String())
+	})
+
+	t.Run("invalid_body", func(t *testing.T) {
+		r, err := http.NewRequest(http.MethodPost, "/v1/test", bytes.NewBufferString("not msgpack"))
+		require.NoError(t, err)
+		r.Header.Set(header.ContentType, header.ApplicationMsgPack)
+
+		w := httptest.NewRecorder()
+		err = DecodeBody(w, r, &res)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_request"`)
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func Test_DecodeBodyWithAPIError(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodPost, "/v1/test", &failingReader{err: httperror.WithRequestTooLarge("request body exceeds %d bytes", 10)})
+	require.NoError(t, err)
+
+	var res AStruct
+	err = DecodeBody(w, r, &res)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"code":"request_too_large","message":"request body exceeds 10 bytes"}`, string(w.Body.Bytes()))
+}
+
 func Test_Uint64(t *testing.T) {
 	x := []uint64{0, 1000, 65535, 4000000, 4000000000, math.MaxInt32, math.MaxUint32, math.MaxInt64, math.MaxUint64 - 1, math.MaxUint64}
 	val := map[string]uint64{"x": 0}
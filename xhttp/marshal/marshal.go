@@ -1,16 +1,13 @@
 package marshal
 
 import (
-	"bufio"
-	"compress/gzip"
 	goErrors "errors"
-	"io"
 	"net/http"
 	"runtime"
-	"strings"
 
 	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
 	"github.com/go-phorce/dolly/xlog"
 	"github.com/ugorji/go/codec"
 )
@@ -27,9 +24,14 @@ type WriteHTTPResponse interface {
 // If the body value implements the WriteHTTPResponse interface,
 // then that will be called to have it do the response generation
 // if body implements error, then that's returned as a server error
-// use the Error type to fully specify your error response
+// use the Error type to fully specify your error response,
+// the errors are written as RFC 7807 application/problem+json if configured
+// by httperror.SetProblemConfig, or requested by Accept header,
 // otherwise body is assumed to be a succesful response, and its serialized
-// and written as a json response with a 200 status code.
+// and written with a 200 status code.
+// The response is encoded as JSON, CBOR, MessagePack or protobuf negotiated by Accept header,
+// and compressed by the encoding negotiated by Accept-Encoding header,
+// see negotiate.WriteResponse.
 //
 // multiple body parameters can be supplied, in which case the first
 // non-nil one will be used. This is useful as it allows you to do
@@ -68,19 +70,7 @@ func WriteJSON(w http.ResponseWriter, r *http.Request, bodies ...interface{}) {
 		return
 
 	default:
-		w.Header().Set(header.ContentType, header.ApplicationJSON)
-		var out io.Writer = w
-		if r != nil && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			w.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(out)
-			out = gz
-			defer gz.Close()
-		}
-		bw := bufio.NewWriter(out)
-		if err := NewEncoder(bw, r).Encode(body); err != nil {
-			logger.Warningf("reason=encode, type=%T, err=[%v]", body, err.Error())
-		}
-		bw.Flush()
+		negotiate.WriteResponse(w, r, http.StatusOK, body)
 	}
 }
 
@@ -89,18 +79,20 @@ func httpError(bv interface{}, r *http.Request) {
 	// the error happened, 0 = this function, we don't want that.
 	_, fn, line, _ := runtime.Caller(2)
 
+	// the trace and span IDs of the request
+	log := xlog.WithContext(r.Context(), logger)
 	if e, ok := bv.(*httperror.Error); ok {
 		if e.HTTPStatus >= 500 {
-			logger.Errorf("INTERNAL_ERROR=%s:%d:%s:%s:%s:%d",
+			log.Errorf("INTERNAL_ERROR=%s:%d:%s:%s:%s:%d",
 				r.URL.Path, e.HTTPStatus, e.Code, e.Message, fn, line)
 			if e.Cause != nil {
-				logger.Errorf("err=[%+v]", e.Cause)
+				log.Errorf("err=[%+v]", e.Cause)
 			}
 		} else {
-			logger.Warningf("API_ERROR=%s:%d:%s:%s:%s:%d",
+			log.Warningf("API_ERROR=%s:%d:%s:%s:%s:%d",
 				r.URL.Path, e.HTTPStatus, e.Code, e.Message, fn, line)
 			if e.Cause != nil {
-				logger.Warningf("err=[%+v]", e.Cause)
+				log.Warningf("err=[%+v]", e.Cause)
 			}
 		}
 	}
@@ -1,12 +1,21 @@
 package marshal
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/go-phorce/dolly/tracing"
 	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/go-phorce/dolly/xlog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_WritePlainJSON(t *testing.T) {
@@ -35,3 +44,81 @@ func Test_WritePlainJSON(t *testing.T) {
 		assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
 	})
 }
+
+func Test_WriteJSONProblem(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	WriteJSON(w, r, errors.New("generic"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+
+	r.Header.Set(header.Accept, header.ApplicationProblemJSON)
+	w = httptest.NewRecorder()
+	WriteJSON(w, r, errors.New("generic"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, header.ApplicationProblemJSON, w.Header().Get(header.ContentType))
+	assert.Contains(t, w.Body.String(), `"detail":"generic"`)
+	assert.Contains(t, w.Body.String(), `"status":500`)
+
+	// the configuration
+	defer httperror.SetProblemConfig(httperror.ProblemConfig{})
+	httperror.SetProblemConfig(httperror.ProblemConfig{Always: true})
+	r.Header.Del(header.Accept)
+	w = httptest.NewRecorder()
+	WriteJSON(w, r, httperror.WithForbidden("denied"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, header.ApplicationProblemJSON, w.Header().Get(header.ContentType))
+
+	// the successful responses are not affected
+	w = httptest.NewRecorder()
+	WriteJSON(w, r, &AStruct{A: "a"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+}
+
+func Test_WriteJSONTraceContext(t *testing.T) {
+	var b bytes.Buffer
+	writer := bufio.NewWriter(&b)
+	xlog.SetFormatter(xlog.NewStringFormatter(writer))
+	defer xlog.SetFormatter(xlog.NewPrettyFormatter(os.Stderr, false))
+
+	sc, err := tracing.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+	r = r.WithContext(tracing.ContextWithRemoteSpanContext(r.Context(), sc))
+
+	w := httptest.NewRecorder()
+	WriteJSON(w, r, httperror.WithUnexpected("failed"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	writer.Flush()
+	assert.Contains(t, b.String(), `trace_id="4bf92f3577b34da6a3ce929d0e0e4736", span_id="00f067aa0ba902b7", INTERNAL_ERROR=/v1/items:500:`)
+}
+
+func Test_WriteJSONNegotiated(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/test", nil)
+	require.NoError(t, err)
+	r.Header.Set(header.Accept, header.ApplicationMsgPack)
+
+	w := httptest.NewRecorder()
+	WriteJSON(w, r, &AStruct{A: "a", B: "b"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, header.ApplicationMsgPack, w.Header().Get(header.ContentType))
+
+	var res AStruct
+	require.NoError(t, negotiate.MsgPack.Decode(w.Body, &res))
+	assert.Equal(t, AStruct{A: "a", B: "b"}, res)
+
+	// the errors are negotiated as well
+	w = httptest.NewRecorder()
+	WriteJSON(w, r, httperror.WithNotFound("not found"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, header.ApplicationMsgPack, w.Header().Get(header.ContentType))
+
+	var e httperror.Error
+	require.NoError(t, negotiate.MsgPack.Decode(w.Body, &e))
+	assert.Equal(t, httperror.NotFound, e.Code)
+	assert.Equal(t, "not found", e.Message)
+}
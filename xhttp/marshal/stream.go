@@ -0,0 +1,480 @@
+package marshal
+
+import (
+	"bytes"
+	goErrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+// DefaultStreamWriteTimeout specifies the write deadline of each write to the stream,
+// that overrides WriteTimeout of the server, which otherwise would cut the long-lived streams
+var DefaultStreamWriteTimeout = time.Minute
+
+// streamWriter provides the common functionality of the streaming responses:
+// the headers are written on the first write, and each write is flushed to the client,
+// so the writers can be used with xhttp.ResponseCapture of the request logger and metrics.
+// The write deadline of the connection is extended before each write,
+// if the ResponseWriter of the server supports SetWriteDeadline (Go 1.20 and later),
+// otherwise the streaming routes require the server to be configured with WriteTimeout=0.
+type streamWriter struct {
+	w            http.ResponseWriter
+	r            *http.Request
+	flusher      http.Flusher
+	deadliner    writeDeadliner
+	contentType  string
+	writeTimeout time.Duration
+	stopping     <-chan struct{}
+
+	lock    sync.Mutex
+	buf     bytes.Buffer
+	started bool
+	closed  bool
+	count   int
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request, contentType string) *streamWriter {
+	s := &streamWriter{
+		w:            w,
+		r:            r,
+		contentType:  contentType,
+		writeTimeout: DefaultStreamWriteTimeout,
+		deadliner:    findWriteDeadliner(w),
+	}
+	s.flusher, _ = w.(http.Flusher)
+	return s
+}
+
+// writeDeadliner is implemented by http.ResponseWriter of the server since Go 1.20
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
+// findWriteDeadliner returns writeDeadliner of the ResponseWriter,
+// or of the wrapped ResponseWriter returned by Unwrap, or nil if not supported
+func findWriteDeadliner(w http.ResponseWriter) writeDeadliner {
+	for w != nil {
+		if d, ok := w.(writeDeadliner); ok {
+			return d
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}
+
+// SetWriteTimeout specifies the write deadline of each write to the stream,
+// DefaultStreamWriteTimeout is used by default, and 0 disables the deadline
+func (s *streamWriter) SetWriteTimeout(timeout time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writeTimeout = timeout
+}
+
+// StopOn stops the stream when the channel is closed, such as HTTPServer.Stopping(),
+// the heartbeats are stopped, and the following writes fail,
+// so the handler can return before the server is shut down
+func (s *streamWriter) StopOn(stopping <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopping = stopping
+}
+
+// isStopping returns true if the channel of StopOn is closed
+func (s *streamWriter) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Count returns the number of items written to the stream
+func (s *streamWriter) Count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+// Close writes the headers of the stream, if nothing was written yet,
+// the stream must not be used after Close
+func (s *streamWriter) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.close(nil)
+}
+
+// WriteError writes the error response, if nothing was written yet,
+// otherwise the error is logged, and the stream is closed.
+// JSON array is left unterminated, so the client fails to parse the truncated response.
+func (s *streamWriter) WriteError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writeError(err, nil)
+}
+
+func (s *streamWriter) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	h := s.w.Header()
+	h.Set(header.ContentType, s.contentType)
+	h.Set(header.CacheControl, "no-cache")
+	// the stream is written as is, without the negotiated compression
+	h.Del(header.ContentEncoding)
+	h.Del(header.ContentLength)
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// write writes and flushes the data, the lock must be held
+func (s *streamWriter) write(data []byte) error {
+	if s.closed {
+		return errors.New("stream is closed")
+	}
+	if s.r != nil {
+		if err := s.r.Context().Err(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if s.isStopping() {
+		return errors.New("stream is stopped")
+	}
+	if s.deadliner != nil {
+		var deadline time.Time
+		if s.writeTimeout > 0 {
+			deadline = time.Now().Add(s.writeTimeout)
+		}
+		// the error is reported by Write, if the deadline is not supported by the connection
+		_ = s.deadliner.SetWriteDeadline(deadline)
+	}
+	s.start()
+	if _, err := s.w.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// encode returns JSON encoded value, the returned slice is valid until the next encode
+func (s *streamWriter) encode(v interface{}) ([]byte, error) {
+	s.buf.Reset()
+	if err := negotiate.JSON.Encode(&s.buf, v, false); err != nil {
+		return nil, errors.WithMessagef(err, "failed to encode '%T'", v)
+	}
+	return s.buf.Bytes(), nil
+}
+
+// close writes the trailer, if the stream was started, the lock must be held
+func (s *streamWriter) close(trailer []byte) error {
+	if s.closed {
+		return nil
+	}
+	var err error
+	if !s.started {
+		s.start()
+		if s.flusher != nil {
+			s.flusher.Flush()
+		}
+	}
+	if len(trailer) > 0 {
+		err = s.write(trailer)
+	}
+	s.closed = true
+	return err
+}
+
+// writeError writes the error response, if the stream was not started,
+// or the trailer otherwise, the lock must be held
+func (s *streamWriter) writeError(err error, trailer func(error) []byte) {
+	if s.closed {
+		return
+	}
+	if !s.started {
+		s.started = true
+		s.closed = true
+		WriteJSON(s.w, s.r, err)
+		return
+	}
+
+	log := xlog.WithContext(s.r.Context(), logger)
+	log.Warningf("reason=stream, path=%s, count=%d, err=[%v]", requestPath(s.r), s.count, err.Error())
+	if trailer != nil {
+		if werr := s.write(trailer(err)); werr != nil {
+			log.Debugf("reason=stream_error, err=[%v]", werr.Error())
+		}
+	}
+	s.closed = true
+}
+
+func requestPath(r *http.Request) string {
+	if r == nil || r.URL == nil {
+		return ""
+	}
+	return r.URL.Path
+}
+
+// NDJSONWriter writes newline-delimited JSON stream,
+// each item is written as JSON on a separate line, and flushed to the client
+type NDJSONWriter struct {
+	*streamWriter
+}
+
+// NewNDJSONWriter returns a writer of application/x-ndjson stream
+func NewNDJSONWriter(w http.ResponseWriter, r *http.Request) *NDJSONWriter {
+	return &NDJSONWriter{
+		streamWriter: newStreamWriter(w, r, header.ApplicationNDJSON),
+	}
+}
+
+// Write writes the item to the stream
+func (s *NDJSONWriter) Write(v interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, err := s.encode(v)
+	if err != nil {
+		return err
+	}
+	if err = s.write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// JSONArrayWriter writes JSON array incrementally,
+// each item is encoded and flushed to the client as it's written
+type JSONArrayWriter struct {
+	*streamWriter
+}
+
+// NewJSONArrayWriter returns a writer of application/json array
+func NewJSONArrayWriter(w http.ResponseWriter, r *http.Request) *JSONArrayWriter {
+	return &JSONArrayWriter{
+		streamWriter: newStreamWriter(w, r, header.ApplicationJSON),
+	}
+}
+
+// Write writes the item of the array
+func (s *JSONArrayWriter) Write(v interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, err := s.encode(v)
+	if err != nil {
+		return err
+	}
+	sep := byte(',')
+	if s.count == 0 {
+		sep = '['
+	}
+	if err = s.write(append([]byte{sep}, b...)); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Close terminates the array, the stream must not be used after Close
+func (s *JSONArrayWriter) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.count == 0 {
+		return s.close([]byte("[]"))
+	}
+	return s.close([]byte("]"))
+}
+
+// SSEEvent is the event of Server-Sent Events stream
+type SSEEvent struct {
+	// ID is the event ID, the client reports the last received ID
+	// in Last-Event-ID header when reconnecting
+	ID string
+	// Event is the event type, the client handles the event as "message" if not specified
+	Event string
+	// Data is the event data, string and []byte are written as is,
+	// other values are encoded as JSON
+	Data interface{}
+	// Retry is the reconnection time hint for the client
+	Retry time.Duration
+}
+
+// SSEWriter writes Server-Sent Events stream
+type SSEWriter struct {
+	*streamWriter
+	heartbeat chan struct{}
+}
+
+// NewSSEWriter returns a writer of text/event-stream
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) *SSEWriter {
+	return &SSEWriter{
+		streamWriter: newStreamWriter(w, r, header.TextEventStream),
+	}
+}
+
+// LastEventID returns the ID of the last event received by the client,
+// the handler should resume the stream after this event
+func (s *SSEWriter) LastEventID() string {
+	if s.r == nil {
+		return ""
+	}
+	return s.r.Header.Get(header.LastEventID)
+}
+
+// Send writes the event to the stream
+func (s *SSEWriter) Send(e *SSEEvent) error {
+	if strings.ContainsAny(e.ID, "\r\n") {
+		return errors.New("invalid parameter: id")
+	}
+	if strings.ContainsAny(e.Event, "\r\n") {
+		return errors.New("invalid parameter: event")
+	}
+
+	var b bytes.Buffer
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		enc, err := s.encode(v)
+		if err != nil {
+			return err
+		}
+		data = string(enc)
+	}
+	if e.Data != nil {
+		for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteByte('\n')
+
+	if err := s.write(b.Bytes()); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Comment writes the comment line, that is ignored by the client
+func (s *SSEWriter) Comment(text string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.write([]byte(":" + strings.ReplaceAll(text, "\n", " ") + "\n\n"))
+}
+
+// Heartbeat starts writing the comments with the interval to keep the connection alive,
+// until the writer is closed, the request is done, or the channel of StopOn is closed.
+// Close must be called before the handler returns.
+func (s *SSEWriter) Heartbeat(interval time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.heartbeat != nil || s.closed {
+		return
+	}
+	stop := make(chan struct{})
+	s.heartbeat = stop
+
+	var done <-chan struct{}
+	if s.r != nil {
+		done = s.r.Context().Done()
+	}
+	stopping := s.stopping
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.lock.Lock()
+				err := s.write([]byte(":\n\n"))
+				s.lock.Unlock()
+				if err != nil {
+					return
+				}
+			case <-stop:
+				return
+			case <-done:
+				return
+			case <-stopping:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the heartbeat, the stream must not be used after Close
+func (s *SSEWriter) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopHeartbeat()
+	return s.close(nil)
+}
+
+// WriteError writes the error response, if nothing was written yet,
+// otherwise the error is sent as "error" event, and the stream is closed
+func (s *SSEWriter) WriteError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopHeartbeat()
+	s.writeError(err, func(err error) []byte {
+		data, eerr := s.encode(streamError(err))
+		if eerr != nil {
+			return nil
+		}
+		return []byte("event: error\ndata: " + string(data) + "\n\n")
+	})
+}
+
+func (s *SSEWriter) stopHeartbeat() {
+	if s.heartbeat != nil {
+		close(s.heartbeat)
+		s.heartbeat = nil
+	}
+}
+
+// streamError returns the API error to be written to the stream
+func streamError(err error) interface{} {
+	var apiErr *httperror.Error
+	if goErrors.As(err, &apiErr) {
+		return apiErr
+	}
+	var manyErr *httperror.ManyError
+	if goErrors.As(err, &manyErr) {
+		return manyErr
+	}
+	return httperror.WithUnexpected(err.Error())
+}
@@ -0,0 +1,225 @@
+package marshal_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamItem struct {
+	ID int `json:"id"`
+}
+
+// flushRecorder records the body at the time of each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushed = append(w.flushed, w.Body.String())
+	w.ResponseRecorder.Flush()
+}
+
+func newStreamRequest(t *testing.T) *http.Request {
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+	return r
+}
+
+func Test_NDJSONWriter(t *testing.T) {
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rc := xhttp.NewResponseCapture(w)
+
+	s := marshal.NewNDJSONWriter(rc, newStreamRequest(t))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.Write(&streamItem{ID: i}))
+	}
+	require.NoError(t, s.Close())
+	assert.Equal(t, 3, s.Count())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, header.ApplicationNDJSON, w.Header().Get(header.ContentType))
+	assert.Equal(t, "no-cache", w.Header().Get(header.CacheControl))
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", w.Body.String())
+	assert.Equal(t, []string{
+		"{\"id\":1}\n",
+		"{\"id\":1}\n{\"id\":2}\n",
+		"{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n",
+	}, w.flushed)
+	assert.Equal(t, uint64(w.Body.Len()), rc.BodySize())
+
+	assert.EqualError(t, s.Write(&streamItem{ID: 4}), "stream is closed")
+}
+
+func Test_JSONArrayWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := marshal.NewJSONArrayWriter(w, newStreamRequest(t))
+	require.NoError(t, s.Close())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	s = marshal.NewJSONArrayWriter(w, newStreamRequest(t))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.Write(&streamItem{ID: i}))
+	}
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+	assert.Equal(t, `[{"id":1},{"id":2},{"id":3}]`, w.Body.String())
+
+	// the error after the first item leaves the array unterminated
+	w = httptest.NewRecorder()
+	s = marshal.NewJSONArrayWriter(w, newStreamRequest(t))
+	require.NoError(t, s.Write(&streamItem{ID: 1}))
+	s.WriteError(errors.New("db failed"))
+	require.NoError(t, s.Close())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"id":1}`, w.Body.String())
+}
+
+func Test_StreamWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := marshal.NewNDJSONWriter(w, newStreamRequest(t))
+	s.WriteError(httperror.WithForbidden("not allowed"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+	assert.Equal(t, `{"code":"forbidden","message":"not allowed"}`, w.Body.String())
+	assert.EqualError(t, s.Write(&streamItem{ID: 1}), "stream is closed")
+
+	// the request is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	s = marshal.NewNDJSONWriter(w, newStreamRequest(t).WithContext(ctx))
+	assert.EqualError(t, s.Write(&streamItem{ID: 1}), "context canceled")
+}
+
+func Test_SSEWriter(t *testing.T) {
+	r := newStreamRequest(t)
+	r.Header.Set(header.LastEventID, "41")
+
+	w := httptest.NewRecorder()
+	s := marshal.NewSSEWriter(w, r)
+	assert.Equal(t, "41", s.LastEventID())
+
+	require.NoError(t, s.Send(&marshal.SSEEvent{ID: "42", Event: "item", Data: &streamItem{ID: 42}, Retry: 3 * time.Second}))
+	require.NoError(t, s.Send(&marshal.SSEEvent{Data: "line1\nline2"}))
+	require.NoError(t, s.Comment("ping"))
+	assert.EqualError(t, s.Send(&marshal.SSEEvent{ID: "4\n3"}), "invalid parameter: id")
+	assert.EqualError(t, s.Send(&marshal.SSEEvent{Event: "a\rb"}), "invalid parameter: event")
+	s.WriteError(httperror.WithNotFound("item not found"))
+	require.NoError(t, s.Close())
+	assert.Equal(t, 2, s.Count())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, header.TextEventStream, w.Header().Get(header.ContentType))
+	assert.Equal(t, "id: 42\nevent: item\nretry: 3000\ndata: {\"id\":42}\n\n"+
+		"data: line1\ndata: line2\n\n"+
+		":ping\n\n"+
+		"event: error\ndata: {\"code\":\"not_found\",\"message\":\"item not found\"}\n\n",
+		w.Body.String())
+}
+
+// syncRecorder is the recorder safe for the concurrent use by the heartbeat
+type syncRecorder struct {
+	*httptest.ResponseRecorder
+	flushes chan struct{}
+}
+
+func (w *syncRecorder) Flush() {
+	select {
+	case w.flushes <- struct{}{}:
+	default:
+	}
+}
+
+func Test_SSEWriterHeartbeat(t *testing.T) {
+	w := &syncRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		flushes:          make(chan struct{}, 10),
+	}
+	s := marshal.NewSSEWriter(w, newStreamRequest(t))
+	s.Heartbeat(10 * time.Millisecond)
+	// second call is ignored
+	s.Heartbeat(time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-w.flushes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("heartbeat was not sent")
+		}
+	}
+	require.NoError(t, s.Close())
+	// no heartbeat after Close
+	time.Sleep(30 * time.Millisecond)
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, ":\n\n:\n\n"), body)
+	assert.Equal(t, "", strings.ReplaceAll(body, ":\n\n", ""))
+}
+
+// deadlineRecorder records the write deadlines
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (w *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	w.deadlines = append(w.deadlines, deadline)
+	return nil
+}
+
+func Test_StreamWriteDeadline(t *testing.T) {
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	// the deadline is set through the wrappers of the middleware
+	s := marshal.NewNDJSONWriter(xhttp.NewResponseCapture(w), newStreamRequest(t))
+
+	start := time.Now()
+	require.NoError(t, s.Write(streamItem{ID: 1}))
+	require.Len(t, w.deadlines, 1)
+	assert.False(t, w.deadlines[0].Before(start.Add(marshal.DefaultStreamWriteTimeout)))
+
+	// the deadline is disabled
+	s.SetWriteTimeout(0)
+	require.NoError(t, s.Write(streamItem{ID: 2}))
+	require.Len(t, w.deadlines, 2)
+	assert.True(t, w.deadlines[1].IsZero())
+	require.NoError(t, s.Close())
+}
+
+func Test_StreamStopOn(t *testing.T) {
+	stopping := make(chan struct{})
+
+	w := &syncRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		flushes:          make(chan struct{}, 10),
+	}
+	s := marshal.NewSSEWriter(w, newStreamRequest(t))
+	s.StopOn(stopping)
+	s.Heartbeat(10 * time.Millisecond)
+	select {
+	case <-w.flushes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat was not sent")
+	}
+
+	close(stopping)
+	err := s.Send(&marshal.SSEEvent{Data: "after"})
+	require.Error(t, err)
+	assert.Equal(t, "stream is stopped", err.Error())
+	require.NoError(t, s.Close())
+}
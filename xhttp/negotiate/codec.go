@@ -0,0 +1,149 @@
+package negotiate
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes and decodes the bodies of the specific content type
+type Codec interface {
+	// ContentType returns the media type of the encoded body
+	ContentType() string
+	// Supports returns true if the value can be encoded or decoded by the codec
+	Supports(v interface{}) bool
+	// Encode writes the encoded value,
+	// pretty specifies to produce a human readable output, if supported by the codec
+	Encode(w io.Writer, v interface{}, pretty bool) error
+	// Decode reads the encoded value
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	// JSON is the codec for application/json
+	JSON Codec = &ugorjiCodec{
+		contentType: header.ApplicationJSON,
+		enc:         &jsonEncHandle,
+		encPP:       &jsonEncPPHandle,
+		dec:         &jsonDecHandle,
+	}
+	// CBOR is the codec for application/cbor
+	CBOR Codec = &ugorjiCodec{
+		contentType: header.ApplicationCBOR,
+		enc:         &cborHandle,
+		encPP:       &cborHandle,
+		dec:         &cborHandle,
+	}
+	// MsgPack is the codec for application/msgpack
+	MsgPack Codec = &ugorjiCodec{
+		contentType: header.ApplicationMsgPack,
+		enc:         &msgpackHandle,
+		encPP:       &msgpackHandle,
+		dec:         &msgpackHandle,
+	}
+	// Protobuf is the codec for application/x-protobuf,
+	// it supports only proto.Message values
+	Protobuf Codec = protobufCodec{}
+)
+
+var (
+	// jsonEncHandle is used to encode json, its configured for the most optimal output/encoding overhead
+	jsonEncHandle codec.JsonHandle
+	// jsonEncPPHandle is used to encode json with a human readable pretty printed out put,
+	// fields are serialized in a canonical order everytime
+	jsonEncPPHandle codec.JsonHandle
+	// jsonDecHandle is used to decode json
+	jsonDecHandle codec.JsonHandle
+
+	cborHandle    codec.CborHandle
+	msgpackHandle codec.MsgpackHandle
+)
+
+func init() {
+	mapType := reflect.TypeOf(map[string]interface{}{})
+
+	jsonDecHandle.BasicHandle.DecodeOptions.ErrorIfNoField = true
+	jsonDecHandle.MapType = mapType
+
+	jsonEncPPHandle.BasicHandle.EncodeOptions.Canonical = true
+	jsonEncPPHandle.Indent = -1
+
+	cborHandle.MapType = mapType
+
+	msgpackHandle.MapType = mapType
+	msgpackHandle.RawToString = true
+	msgpackHandle.WriteExt = true
+}
+
+// ugorjiCodec is the codec based on ugorji handles,
+// the handles are shared, they should not be mutated
+type ugorjiCodec struct {
+	contentType string
+	enc         codec.Handle
+	encPP       codec.Handle
+	dec         codec.Handle
+}
+
+func (c *ugorjiCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c *ugorjiCodec) Supports(v interface{}) bool {
+	return true
+}
+
+func (c *ugorjiCodec) Encode(w io.Writer, v interface{}, pretty bool) error {
+	h := c.enc
+	if pretty {
+		h = c.encPP
+	}
+	return codec.NewEncoder(w, h).Encode(v)
+}
+
+func (c *ugorjiCodec) Decode(r io.Reader, v interface{}) error {
+	// codec can make many little reads from the reader, so wrap it in a buffered reader
+	// to keep perf lively
+	return codec.NewDecoder(bufio.NewReader(r), c.dec).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (c protobufCodec) ContentType() string {
+	return header.ApplicationProtobuf
+}
+
+func (c protobufCodec) Supports(v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+func (c protobufCodec) Encode(w io.Writer, v interface{}, pretty bool) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("protobuf: unsupported type %T", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = w.Write(b)
+	return errors.WithStack(err)
+}
+
+func (c protobufCodec) Decode(r io.Reader, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("protobuf: unsupported type %T", v)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(proto.Unmarshal(b, m))
+}
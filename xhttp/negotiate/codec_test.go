@@ -0,0 +1,84 @@
+package negotiate_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type item struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, c := range []negotiate.Codec{negotiate.JSON, negotiate.CBOR, negotiate.MsgPack} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			assert.True(t, c.Supports(item{}))
+
+			var buf bytes.Buffer
+			require.NoError(t, c.Encode(&buf, &item{ID: 1, Name: "one"}, false))
+
+			var res item
+			require.NoError(t, c.Decode(&buf, &res))
+			assert.Equal(t, item{ID: 1, Name: "one"}, res)
+
+			var m map[string]interface{}
+			buf.Reset()
+			require.NoError(t, c.Encode(&buf, map[string]string{"name": "two"}, true))
+			require.NoError(t, c.Decode(&buf, &m))
+			assert.Equal(t, map[string]interface{}{"name": "two"}, m)
+		})
+	}
+}
+
+func TestCodec_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, negotiate.JSON.Encode(&buf, map[string]int{"b": 2, "a": 1}, true))
+	assert.Equal(t, "{\n\t\"a\": 1,\n\t\"b\": 2\n}", buf.String())
+
+	var res item
+	err := negotiate.JSON.Decode(bytes.NewBufferString(`{"id":1,"unknown":2}`), &res)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no matching struct field found")
+}
+
+func TestCodec_Protobuf(t *testing.T) {
+	c := negotiate.Protobuf
+	assert.Equal(t, header.ApplicationProtobuf, c.ContentType())
+	assert.False(t, c.Supports(item{}))
+	assert.True(t, c.Supports(wrapperspb.String("one")))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Encode(&buf, wrapperspb.String("one"), true))
+	exp, err := proto.Marshal(wrapperspb.String("one"))
+	require.NoError(t, err)
+	assert.Equal(t, exp, buf.Bytes())
+
+	res := &wrapperspb.StringValue{}
+	require.NoError(t, c.Decode(&buf, res))
+	assert.Equal(t, "one", res.Value)
+
+	assert.EqualError(t, c.Encode(&buf, &item{}, false), "protobuf: unsupported type *negotiate_test.item")
+	assert.EqualError(t, c.Decode(&buf, &item{}), "protobuf: unsupported type *negotiate_test.item")
+}
+
+func TestCodecFor(t *testing.T) {
+	assert.Equal(t, negotiate.JSON, negotiate.CodecFor("application/json"))
+	assert.Equal(t, negotiate.JSON, negotiate.CodecFor("Application/JSON"))
+	assert.Equal(t, negotiate.JSON, negotiate.CodecFor("application/problem+json"))
+	assert.Equal(t, negotiate.CBOR, negotiate.CodecFor("application/cbor"))
+	assert.Equal(t, negotiate.CBOR, negotiate.CodecFor("application/cose+cbor"))
+	assert.Equal(t, negotiate.MsgPack, negotiate.CodecFor("application/msgpack"))
+	assert.Equal(t, negotiate.MsgPack, negotiate.CodecFor("application/x-msgpack"))
+	assert.Equal(t, negotiate.Protobuf, negotiate.CodecFor("application/x-protobuf"))
+	assert.Equal(t, negotiate.Protobuf, negotiate.CodecFor("application/protobuf"))
+	assert.Nil(t, negotiate.CodecFor("text/html"))
+	assert.Nil(t, negotiate.CodecFor("application/vnd.example+xml"))
+}
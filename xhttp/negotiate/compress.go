@@ -0,0 +1,160 @@
+package negotiate
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Gzip is the gzip content encoding
+	Gzip = "gzip"
+	// Deflate is the deflate content encoding, the zlib format
+	Deflate = "deflate"
+	// Brotli is the brotli content encoding
+	Brotli = "br"
+	// Zstd is the zstd content encoding
+	Zstd = "zstd"
+	// Identity is the content encoding without compression
+	Identity = "identity"
+)
+
+// DefaultMinCompressSize specifies the default minimum size of the response body to compress
+const DefaultMinCompressSize = 1024
+
+// CompressionConfig specifies the options of compression of responses
+type CompressionConfig struct {
+	// Encodings specifies the supported content encodings
+	// in the order of preference, if the client accepts more than one
+	// with the same quality value
+	Encodings []string
+	// MinSize specifies the minimum size of the response body to compress,
+	// the smaller responses are not compressed
+	MinSize int
+}
+
+// DefaultCompressionConfig returns the default compression options
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Encodings: []string{Brotli, Zstd, Gzip, Deflate},
+		MinSize:   DefaultMinCompressSize,
+	}
+}
+
+var compressionConfig atomic.Value
+
+func init() {
+	compressionConfig.Store(DefaultCompressionConfig())
+}
+
+// SetCompressionConfig sets the options of compression of responses
+func SetCompressionConfig(cfg CompressionConfig) {
+	compressionConfig.Store(cfg)
+}
+
+// GetCompressionConfig returns the options of compression of responses
+func GetCompressionConfig() CompressionConfig {
+	return compressionConfig.Load().(CompressionConfig)
+}
+
+// AcceptedEncoding returns the supported content encoding
+// with the highest quality value in Accept-Encoding header of the request,
+// or empty string if the response should not be compressed
+func AcceptedEncoding(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	accept := r.Header.Get(header.AcceptEncoding)
+	if accept == "" {
+		return ""
+	}
+	qs := map[string]float64{}
+	for _, v := range parseQValues(accept) {
+		qs[v.value] = v.q
+	}
+
+	identityQ := qs[Identity]
+	best, bestQ := "", 0.0
+	for _, enc := range GetCompressionConfig().Encodings {
+		q, ok := qs[enc]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if !ok || q <= 0 || q < identityQ {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// NewCompressor returns the writer that compresses with the content encoding
+func NewCompressor(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch strings.ToLower(encoding) {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Deflate:
+		return zlib.NewWriter(w), nil
+	case Brotli:
+		return brotli.NewWriter(w), nil
+	case Zstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zw, nil
+	case "", Identity:
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedMediaType, "content encoding %q", encoding)
+	}
+}
+
+// NewDecompressor returns the reader that decompresses the content encoding
+func NewDecompressor(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(encoding) {
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zr, nil
+	case Deflate:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zr, nil
+	case Brotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zr.IOReadCloser(), nil
+	case "", Identity:
+		return ioutil.NopCloser(r), nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedMediaType, "content encoding %q", encoding)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
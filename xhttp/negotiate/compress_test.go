@@ -0,0 +1,94 @@
+package negotiate_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionConfig(t *testing.T) {
+	defer negotiate.SetCompressionConfig(negotiate.DefaultCompressionConfig())
+
+	assert.Equal(t, negotiate.CompressionConfig{
+		Encodings: []string{negotiate.Brotli, negotiate.Zstd, negotiate.Gzip, negotiate.Deflate},
+		MinSize:   negotiate.DefaultMinCompressSize,
+	}, negotiate.GetCompressionConfig())
+
+	negotiate.SetCompressionConfig(negotiate.CompressionConfig{Encodings: []string{negotiate.Gzip}})
+	assert.Equal(t, []string{negotiate.Gzip}, negotiate.GetCompressionConfig().Encodings)
+}
+
+func TestAcceptedEncoding(t *testing.T) {
+	defer negotiate.SetCompressionConfig(negotiate.DefaultCompressionConfig())
+
+	tcases := []struct {
+		accept string
+		exp    string
+	}{
+		{"", ""},
+		{"compress", ""},
+		{"gzip", negotiate.Gzip},
+		{"GZIP", negotiate.Gzip},
+		{"deflate, gzip", negotiate.Gzip},
+		{"gzip, deflate, br", negotiate.Brotli},
+		{"gzip, zstd", negotiate.Zstd},
+		{"br;q=0.5, gzip;q=0.8", negotiate.Gzip},
+		{"*", negotiate.Brotli},
+		{"br;q=0, *;q=0.5", negotiate.Zstd},
+		{"identity, gzip;q=0.5", ""},
+		{"gzip;q=0", ""},
+	}
+	for _, tc := range tcases {
+		r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+		require.NoError(t, err)
+		r.Header.Set(header.AcceptEncoding, tc.accept)
+		assert.Equal(t, tc.exp, negotiate.AcceptedEncoding(r), "Accept-Encoding: %s", tc.accept)
+	}
+	assert.Empty(t, negotiate.AcceptedEncoding(nil))
+
+	negotiate.SetCompressionConfig(negotiate.CompressionConfig{Encodings: []string{negotiate.Gzip}})
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+	r.Header.Set(header.AcceptEncoding, "br, zstd")
+	assert.Empty(t, negotiate.AcceptedEncoding(r))
+	r.Header.Set(header.AcceptEncoding, "br, gzip;q=0.1")
+	assert.Equal(t, negotiate.Gzip, negotiate.AcceptedEncoding(r))
+}
+
+func TestCompressor(t *testing.T) {
+	data := strings.Repeat("compressed data ", 100)
+	for _, enc := range []string{negotiate.Gzip, negotiate.Deflate, negotiate.Brotli, negotiate.Zstd, negotiate.Identity, ""} {
+		t.Run(enc, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := negotiate.NewCompressor(&buf, enc)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(data))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			if enc != negotiate.Identity && enc != "" {
+				assert.Less(t, buf.Len(), len(data))
+			}
+
+			r, err := negotiate.NewDecompressor(&buf, enc)
+			require.NoError(t, err)
+			defer r.Close()
+			res, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, data, string(res))
+		})
+	}
+
+	_, err := negotiate.NewCompressor(&bytes.Buffer{}, "compress")
+	assert.EqualError(t, err, `content encoding "compress": unsupported media type`)
+	_, err = negotiate.NewDecompressor(&bytes.Buffer{}, "compress")
+	assert.EqualError(t, err, `content encoding "compress": unsupported media type`)
+	_, err = negotiate.NewDecompressor(bytes.NewBufferString("not gzip"), negotiate.Gzip)
+	assert.Error(t, err)
+}
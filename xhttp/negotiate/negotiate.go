@@ -0,0 +1,182 @@
+// Package negotiate provides content negotiation of HTTP bodies:
+// the codec is selected by Accept or Content-Type header,
+// and the compression is selected by Accept-Encoding or Content-Encoding header.
+package negotiate
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "xhttp/negotiate")
+
+// ErrUnsupportedMediaType is returned when the content type
+// or the content encoding of the request is not supported
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// codecs is the map of the supported media types
+var codecs = map[string]Codec{
+	header.ApplicationJSON:     JSON,
+	header.ApplicationCBOR:     CBOR,
+	header.ApplicationMsgPack:  MsgPack,
+	"application/x-msgpack":    MsgPack,
+	header.ApplicationProtobuf: Protobuf,
+	"application/protobuf":     Protobuf,
+}
+
+// suffixes is the map of the structured syntax suffixes of the media types,
+// such as application/problem+json
+var suffixes = map[string]Codec{
+	"+json": JSON,
+	"+cbor": CBOR,
+}
+
+// CodecFor returns the codec of the media type, or nil if not supported
+func CodecFor(mediaType string) Codec {
+	mediaType = strings.ToLower(mediaType)
+	if c, ok := codecs[mediaType]; ok {
+		return c
+	}
+	if idx := strings.LastIndex(mediaType, "+"); idx > 0 {
+		return suffixes[mediaType[idx:]]
+	}
+	return nil
+}
+
+// ResponseCodec returns the codec with the highest quality value
+// in Accept header of the request, that supports the value.
+// JSON codec is returned, if the client does not specify a supported media type.
+func ResponseCodec(r *http.Request, v interface{}) Codec {
+	if r == nil {
+		return JSON
+	}
+	accept := r.Header.Get(header.Accept)
+	if accept == "" {
+		return JSON
+	}
+	for _, mr := range parseQValues(accept) {
+		if mr.q <= 0 {
+			break
+		}
+		if mr.value == "*/*" || mr.value == "application/*" {
+			return JSON
+		}
+		if c := CodecFor(mr.value); c != nil && c.Supports(v) {
+			return c
+		}
+	}
+	return JSON
+}
+
+// RequestCodec returns the codec of Content-Type header of the request,
+// JSON codec is returned, if Content-Type is not specified.
+// The returned error wraps ErrUnsupportedMediaType,
+// if the content type is not supported.
+func RequestCodec(r *http.Request) (Codec, error) {
+	ct := r.Header.Get(header.ContentType)
+	if ct == "" {
+		return JSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, errors.Wrapf(ErrUnsupportedMediaType, "content type %q", ct)
+	}
+	c := CodecFor(mediaType)
+	if c == nil {
+		return nil, errors.Wrapf(ErrUnsupportedMediaType, "content type %q", ct)
+	}
+	return c, nil
+}
+
+// ShouldPrettyPrint returns true if the request indicated it would like a
+// pretty printed response (by having ?pp on the URL)
+func ShouldPrettyPrint(r *http.Request) bool {
+	if r == nil || r.URL == nil {
+		return false
+	}
+	_, pp := r.URL.Query()["pp"]
+	return pp
+}
+
+// WriteResponse writes the value encoded by the codec negotiated by Accept header,
+// and compressed by the encoding negotiated by Accept-Encoding header
+func WriteResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	c := ResponseCodec(r, v)
+	WriteResponseAs(w, r, statusCode, c, c.ContentType(), v)
+}
+
+// WriteResponseAs writes the value encoded by the specified codec with the content type,
+// and compressed by the encoding negotiated by Accept-Encoding header
+func WriteResponseAs(w http.ResponseWriter, r *http.Request, statusCode int, c Codec, contentType string, v interface{}) {
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, v, ShouldPrettyPrint(r)); err != nil {
+		logger.Warningf("reason=encode, type=%T, content_type=%s, err=[%v]", v, c.ContentType(), err.Error())
+	}
+
+	w.Header().Set(header.ContentType, contentType)
+
+	var out io.WriteCloser
+	if buf.Len() >= GetCompressionConfig().MinSize {
+		if enc := AcceptedEncoding(r); enc != "" {
+			cw, err := NewCompressor(w, enc)
+			if err != nil {
+				logger.Warningf("reason=compress, encoding=%s, err=[%v]", enc, err.Error())
+			} else {
+				w.Header().Set(header.ContentEncoding, enc)
+				w.Header().Add(header.Vary, header.AcceptEncoding)
+				out = cw
+			}
+		}
+	}
+
+	w.WriteHeader(statusCode)
+	if out == nil {
+		w.Write(buf.Bytes())
+		return
+	}
+	out.Write(buf.Bytes())
+	if err := out.Close(); err != nil {
+		logger.Warningf("reason=compress, err=[%v]", err.Error())
+	}
+}
+
+type qvalue struct {
+	value string
+	q     float64
+}
+
+// parseQValues returns the values of Accept or Accept-Encoding header
+// sorted by the quality value
+func parseQValues(s string) []qvalue {
+	var list []qvalue
+	for _, part := range strings.Split(s, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		list = append(list, qvalue{value: value, q: q})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	return list
+}
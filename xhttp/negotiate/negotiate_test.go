@@ -0,0 +1,152 @@
+package negotiate_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/negotiate"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestResponseCodec(t *testing.T) {
+	tcases := []struct {
+		accept string
+		v      interface{}
+		exp    negotiate.Codec
+	}{
+		{"", item{}, negotiate.JSON},
+		{"text/html", item{}, negotiate.JSON},
+		{"*/*", item{}, negotiate.JSON},
+		{"application/cbor", item{}, negotiate.CBOR},
+		{"application/msgpack, application/json", item{}, negotiate.MsgPack},
+		{"application/json;q=0.9, application/msgpack", item{}, negotiate.MsgPack},
+		{"application/cbor;q=0, */*", item{}, negotiate.JSON},
+		{"application/x-protobuf", item{}, negotiate.JSON},
+		{"application/x-protobuf, application/cbor;q=0.5", item{}, negotiate.CBOR},
+		{"application/x-protobuf", wrapperspb.String("one"), negotiate.Protobuf},
+		{"application/problem+json", item{}, negotiate.JSON},
+	}
+	for _, tc := range tcases {
+		r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+		require.NoError(t, err)
+		r.Header.Set(header.Accept, tc.accept)
+		assert.Equal(t, tc.exp, negotiate.ResponseCodec(r, tc.v), "Accept: %s", tc.accept)
+	}
+	assert.Equal(t, negotiate.JSON, negotiate.ResponseCodec(nil, item{}))
+}
+
+func TestRequestCodec(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "/v1/items", nil)
+	require.NoError(t, err)
+
+	c, err := negotiate.RequestCodec(r)
+	require.NoError(t, err)
+	assert.Equal(t, negotiate.JSON, c)
+
+	r.Header.Set(header.ContentType, "application/cbor; charset=utf-8")
+	c, err = negotiate.RequestCodec(r)
+	require.NoError(t, err)
+	assert.Equal(t, negotiate.CBOR, c)
+
+	r.Header.Set(header.ContentType, "text/xml")
+	_, err = negotiate.RequestCodec(r)
+	assert.EqualError(t, err, `content type "text/xml": unsupported media type`)
+	assert.True(t, errors.Is(err, negotiate.ErrUnsupportedMediaType))
+
+	r.Header.Set(header.ContentType, "application/json; =")
+	_, err = negotiate.RequestCodec(r)
+	assert.True(t, errors.Is(err, negotiate.ErrUnsupportedMediaType))
+}
+
+func TestShouldPrettyPrint(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/items?pp", nil)
+	require.NoError(t, err)
+	assert.True(t, negotiate.ShouldPrettyPrint(r))
+
+	r, err = http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+	assert.False(t, negotiate.ShouldPrettyPrint(r))
+	assert.False(t, negotiate.ShouldPrettyPrint(nil))
+}
+
+func TestWriteResponse(t *testing.T) {
+	defer negotiate.SetCompressionConfig(negotiate.DefaultCompressionConfig())
+
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	negotiate.WriteResponse(w, r, http.StatusCreated, &item{ID: 1})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, header.ApplicationJSON, w.Header().Get(header.ContentType))
+	assert.Empty(t, w.Header().Get(header.ContentEncoding))
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+
+	r.Header.Set(header.Accept, header.ApplicationCBOR)
+	w = httptest.NewRecorder()
+	negotiate.WriteResponse(w, r, http.StatusOK, &item{ID: 1, Name: "one"})
+	assert.Equal(t, header.ApplicationCBOR, w.Header().Get(header.ContentType))
+	var res item
+	require.NoError(t, negotiate.CBOR.Decode(w.Body, &res))
+	assert.Equal(t, item{ID: 1, Name: "one"}, res)
+
+	// the small body is not compressed
+	r.Header.Set(header.Accept, header.ApplicationJSON)
+	r.Header.Set(header.AcceptEncoding, "gzip")
+	w = httptest.NewRecorder()
+	negotiate.WriteResponse(w, r, http.StatusOK, &item{ID: 1})
+	assert.Empty(t, w.Header().Get(header.ContentEncoding))
+	assert.Empty(t, w.Header().Get(header.Vary))
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+
+	large := &item{ID: 2, Name: strings.Repeat("x", negotiate.DefaultMinCompressSize)}
+	w = httptest.NewRecorder()
+	negotiate.WriteResponse(w, r, http.StatusOK, large)
+	assert.Equal(t, negotiate.Gzip, w.Header().Get(header.ContentEncoding))
+	assert.Equal(t, header.AcceptEncoding, w.Header().Get(header.Vary))
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	res = item{}
+	require.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, *large, res)
+
+	negotiate.SetCompressionConfig(negotiate.CompressionConfig{Encodings: []string{negotiate.Zstd}})
+	r.Header.Set(header.AcceptEncoding, "zstd")
+	w = httptest.NewRecorder()
+	negotiate.WriteResponse(w, r, http.StatusOK, &item{ID: 1})
+	assert.Equal(t, negotiate.Zstd, w.Header().Get(header.ContentEncoding))
+	zr, err := negotiate.NewDecompressor(w.Body, negotiate.Zstd)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(body))
+}
+
+func TestWriteResponseAs(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/items?pp", nil)
+	require.NoError(t, err)
+	r.Header.Set(header.Accept, header.ApplicationCBOR)
+
+	w := httptest.NewRecorder()
+	negotiate.WriteResponseAs(w, r, http.StatusNotFound, negotiate.JSON, header.ApplicationProblemJSON, map[string]int{"status": 404})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, header.ApplicationProblemJSON, w.Header().Get(header.ContentType))
+	assert.Equal(t, "{\n\t\"status\": 404\n}", w.Body.String())
+
+	// encoding error
+	w = httptest.NewRecorder()
+	negotiate.WriteResponseAs(w, r, http.StatusOK, negotiate.Protobuf, header.ApplicationProtobuf, &item{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+}
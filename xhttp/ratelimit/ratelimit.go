@@ -0,0 +1,310 @@
+// Package ratelimit provides HTTP middleware that limits the rate of the requests
+// by identity, role, client IP or route, using token buckets.
+//
+// The policy allows Limit requests per Period, with bursts up to Burst requests.
+// A policy with a long period, such as 10000 requests per 24h, can be used as a quota.
+//
+// The throttled requests are rejected with 429 Too Many Requests status code
+// and Retry-After header, the allowed requests have RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/metrics/tags"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/pkg/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly", "xhttp/ratelimit")
+
+// Keys of the requests
+const (
+	// KeyIdentity limits the requests by the caller's identity
+	KeyIdentity = "identity"
+	// KeyRole limits the requests by the caller's role
+	KeyRole = "role"
+	// KeyClientIP limits the requests by the client IP
+	KeyClientIP = "ip"
+	// KeyRoute limits the requests by the method and the route, see Limiter.WithRoute
+	KeyRoute = "route"
+)
+
+// KeyFunc returns the key of the request to limit,
+// the request with empty key is not limited by the policy
+type KeyFunc func(r *http.Request) string
+
+// ByIdentity returns the key of the caller's identity
+func ByIdentity(r *http.Request) string {
+	return identity.FromRequest(r).Identity().String()
+}
+
+// ByRole returns the key of the caller's role
+func ByRole(r *http.Request) string {
+	return identity.FromRequest(r).Identity().Role()
+}
+
+// ByClientIP returns the key of the client IP
+func ByClientIP(r *http.Request) string {
+	return identity.ClientIPFromRequest(r)
+}
+
+// ByRoute returns the key of the method and the path,
+// where the IDs in the path are replaced by xhttp.NormalizePathIDs,
+// so the requests to the same route share the bucket
+func ByRoute(r *http.Request) string {
+	return r.Method + " " + xhttp.NormalizePathIDs(r.URL.Path)
+}
+
+var keyFuncs = map[string]KeyFunc{
+	KeyIdentity: ByIdentity,
+	KeyRole:     ByRole,
+	KeyClientIP: ByClientIP,
+	KeyRoute:    ByRoute,
+}
+
+// Policy specifies the rate limit
+type Policy struct {
+	// Name specifies the name of the policy, used in the metrics and the logs
+	Name string `json:"name" yaml:"name"`
+	// By specifies the key of the requests: identity, role, ip or route
+	By string `json:"by" yaml:"by"`
+	// Limit specifies the number of the requests allowed per Period
+	Limit int `json:"limit" yaml:"limit"`
+	// Period specifies the period of the Limit
+	Period time.Duration `json:"period" yaml:"period"`
+	// Burst specifies the number of the requests allowed at once,
+	// if not specified, then Limit is used
+	Burst int `json:"burst,omitempty" yaml:"burst,omitempty"`
+	// Paths specifies the path prefixes the policy applies to,
+	// matched on the path segments, for example /v1/login matches /v1/login/otp,
+	// but not /v1/login-history;
+	// if not specified, then the policy applies to all the paths
+	Paths []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+	// Roles specifies the roles the policy applies to,
+	// if not specified, then the policy applies to all the roles
+	Roles []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// Key specifies the custom key of the requests, it overrides By
+	Key KeyFunc `json:"-" yaml:"-"`
+}
+
+type policy struct {
+	Policy
+	limit Limit
+	key   KeyFunc
+	roles map[string]bool
+}
+
+func (p *policy) applies(r *http.Request) bool {
+	if len(p.Paths) > 0 {
+		matched := false
+		for _, prefix := range p.Paths {
+			if hasPathPrefix(r.URL.Path, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(p.roles) > 0 {
+		return p.roles[identity.FromRequest(r).Identity().Role()]
+	}
+	return true
+}
+
+// hasPathPrefix returns true if the path starts with the prefix
+// on the path segments boundary
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Limiter limits the rate of the requests
+type Limiter struct {
+	store    Store
+	policies []*policy
+	route    func(r *http.Request) string
+}
+
+// New returns Limiter with the policies,
+// if store is nil, then in-memory store is used
+func New(store Store, policies ...Policy) (*Limiter, error) {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	l := &Limiter{
+		store: store,
+	}
+	for _, p := range policies {
+		if p.Name == "" {
+			return nil, errors.New("invalid parameter: name")
+		}
+		if p.Limit <= 0 || p.Period <= 0 {
+			return nil, errors.Errorf("invalid policy %q: limit and period must be positive", p.Name)
+		}
+		key := p.Key
+		if key == nil && p.By == KeyRoute {
+			key = l.byRoute
+		} else if key == nil {
+			key = keyFuncs[p.By]
+			if key == nil {
+				return nil, errors.Errorf("invalid policy %q: unsupported key %q", p.Name, p.By)
+			}
+		}
+		burst := p.Burst
+		if burst <= 0 {
+			burst = p.Limit
+		}
+		lp := &policy{
+			Policy: p,
+			key:    key,
+			limit: Limit{
+				Rate:  float64(p.Limit) / p.Period.Seconds(),
+				Burst: burst,
+			},
+		}
+		if len(p.Roles) > 0 {
+			lp.roles = map[string]bool{}
+			for _, role := range p.Roles {
+				lp.roles[role] = true
+			}
+		}
+		l.policies = append(l.policies, lp)
+	}
+	return l, nil
+}
+
+// WithRoute sets the function that returns the template of the route matched by the router,
+// such as rest.RouteTemplate, to use as the route key instead of the request path.
+// The template is available when the limiter is the middleware of the routes.
+func (l *Limiter) WithRoute(route func(r *http.Request) string) *Limiter {
+	l.route = route
+	return l
+}
+
+// byRoute returns the key of the method and the route template,
+// or ByRoute if the request is not routed yet
+func (l *Limiter) byRoute(r *http.Request) string {
+	if l.route != nil {
+		if route := l.route(r); route != "" {
+			return r.Method + " " + route
+		}
+	}
+	return ByRoute(r)
+}
+
+type takenToken struct {
+	key   string
+	limit Limit
+}
+
+// Allow takes the tokens of the policies that apply to the request,
+// and returns the result of the most restrictive policy,
+// or nil if no policy applies.
+// If the request is rejected, then the tokens taken by other policies are refunded.
+// If the store fails, then the request is allowed.
+func (l *Limiter) Allow(r *http.Request) (*Result, string) {
+	var res *Result
+	var name string
+	var taken []takenToken
+	for _, p := range l.policies {
+		if !p.applies(r) {
+			continue
+		}
+		key := p.key(r)
+		if key == "" {
+			continue
+		}
+
+		bucket := p.Name + ":" + key
+		pr, err := l.store.Take(r.Context(), bucket, p.limit)
+		if err != nil {
+			logger.Errorf("policy=%s, key=%q, err=[%+v]", p.Name, key, err)
+			continue
+		}
+		if pr.Allowed {
+			taken = append(taken, takenToken{key: bucket, limit: p.limit})
+		}
+		if res == nil || restrictive(pr, res) {
+			res = pr
+			name = p.Name
+		}
+	}
+
+	if res != nil && !res.Allowed {
+		for _, t := range taken {
+			if err := l.store.Refund(r.Context(), t.key, t.limit); err != nil {
+				logger.Errorf("reason=refund, key=%q, err=[%+v]", t.key, err)
+			}
+		}
+	}
+	return res, name
+}
+
+// restrictive returns true if a is more restrictive than b
+func restrictive(a, b *Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+var keyForHTTPReqThrottled = []string{"http", "request", "throttled"}
+
+// Handler returns http.Handler that limits the rate of the requests to the delegate
+func (l *Limiter) Handler(delegate http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, name := l.Allow(r)
+		if res == nil {
+			delegate.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set(header.RateLimitLimit, strconv.Itoa(res.Limit))
+		h.Set(header.RateLimitRemaining, strconv.Itoa(res.Remaining))
+		h.Set(header.RateLimitReset, seconds(res.Reset))
+
+		if res.Allowed {
+			delegate.ServeHTTP(w, r)
+			return
+		}
+
+		role := identity.FromRequest(r).Identity().Role()
+		metrics.IncrCounter(keyForHTTPReqThrottled, 1,
+			metrics.Tag{Name: "policy", Value: name},
+			metrics.Tag{Name: tags.Role, Value: role},
+		)
+		xlog.WithContext(r.Context(), logger).Noticef("reason=throttled, policy=%s, role=%q, path=%s, retry_after=%s",
+			name, role, r.URL.Path, res.RetryAfter)
+
+		retryAfter := seconds(res.RetryAfter)
+		h.Set(header.RetryAfter, retryAfter)
+		marshal.WriteJSON(w, r, httperror.WithRateLimitExceeded("rate limit exceeded, retry after %s seconds", retryAfter))
+	})
+}
+
+// seconds returns the duration in seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
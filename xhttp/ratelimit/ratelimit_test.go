@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New(t *testing.T) {
+	l, err := New(nil)
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryStore{}, l.store)
+
+	_, err = New(nil, Policy{By: KeyRole, Limit: 1, Period: time.Second})
+	assert.EqualError(t, err, "invalid parameter: name")
+
+	_, err = New(nil, Policy{Name: "p1", By: KeyRole, Period: time.Second})
+	assert.EqualError(t, err, `invalid policy "p1": limit and period must be positive`)
+
+	_, err = New(nil, Policy{Name: "p1", By: KeyRole, Limit: 1})
+	assert.EqualError(t, err, `invalid policy "p1": limit and period must be positive`)
+
+	_, err = New(nil, Policy{Name: "p1", By: "header", Limit: 1, Period: time.Second})
+	assert.EqualError(t, err, `invalid policy "p1": unsupported key "header"`)
+
+	l, err = New(nil,
+		Policy{Name: "custom", By: "header", Limit: 1, Period: time.Second, Key: ByRoute},
+		Policy{Name: "quota", By: KeyIdentity, Limit: 1000, Period: 24 * time.Hour, Burst: 10},
+	)
+	require.NoError(t, err)
+	require.Len(t, l.policies, 2)
+	assert.Equal(t, Limit{Rate: 1, Burst: 1}, l.policies[0].limit)
+	assert.Equal(t, 10, l.policies[1].limit.Burst)
+	assert.InDelta(t, 1000.0/86400, l.policies[1].limit.Rate, 0.0001)
+}
+
+func Test_Keys(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+	require.NoError(t, err)
+	r.RemoteAddr = "10.1.1.1:443"
+	r = identity.WithTestIdentity(r, identity.NewIdentity("user", "alice", ""))
+
+	assert.Equal(t, "user/alice", ByIdentity(r))
+	assert.Equal(t, "user", ByRole(r))
+	assert.Equal(t, "10.1.1.1", ByClientIP(r))
+	assert.Equal(t, "GET /v1/items", ByRoute(r))
+
+	r.URL.Path = "/v1/items/123"
+	assert.Equal(t, "GET /v1/items/:id", ByRoute(r))
+
+	l, err := New(nil, Policy{Name: "route", By: KeyRoute, Limit: 1, Period: time.Second})
+	require.NoError(t, err)
+	key := l.policies[0].key
+	assert.Equal(t, "GET /v1/items/:id", key(r))
+
+	l.WithRoute(func(*http.Request) string { return "/v1/items/:item" })
+	assert.Equal(t, "GET /v1/items/:item", key(r))
+	l.WithRoute(func(*http.Request) string { return "" })
+	assert.Equal(t, "GET /v1/items/:id", key(r))
+}
+
+func Test_AllowRefund(t *testing.T) {
+	store, _ := newTestStore()
+	l, err := New(store,
+		Policy{Name: "identity", By: KeyIdentity, Limit: 1, Period: time.Minute},
+		Policy{Name: "role", By: KeyRole, Limit: 3, Period: time.Minute},
+	)
+	require.NoError(t, err)
+
+	allow := func(name string) *Result {
+		r, err := http.NewRequest(http.MethodGet, "/v1/items", nil)
+		require.NoError(t, err)
+		r = identity.WithTestIdentity(r, identity.NewIdentity("user", name, ""))
+		res, _ := l.Allow(r)
+		require.NotNil(t, res)
+		return res
+	}
+
+	assert.True(t, allow("alice").Allowed)
+	// rejected by identity, the role token is refunded
+	for i := 0; i < 3; i++ {
+		res := allow("alice")
+		assert.False(t, res.Allowed)
+	}
+	assert.True(t, allow("bob").Allowed)
+	assert.True(t, allow("carol").Allowed)
+
+	res, name := l.Allow(identity.WithTestIdentity(httptest.NewRequest(http.MethodGet, "/v1/items", nil),
+		identity.NewIdentity("user", "dave", "")))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "role", name)
+}
+
+type failingStore struct{}
+
+func (s failingStore) Take(context.Context, string, Limit) (*Result, error) {
+	return nil, errors.New("store is not available")
+}
+
+func (s failingStore) Refund(context.Context, string, Limit) error {
+	return errors.New("store is not available")
+}
+
+func Test_Handler(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("test"), im)
+	require.NoError(t, err)
+
+	store, c := newTestStore()
+	l, err := New(store,
+		Policy{Name: "users", By: KeyIdentity, Limit: 2, Period: time.Second, Roles: []string{"user"}},
+		Policy{Name: "admin", By: KeyRoute, Limit: 1, Period: time.Minute, Paths: []string{"/v1/admin"}},
+		Policy{Name: "skip", Key: func(*http.Request) string { return "" }, Limit: 1, Period: time.Hour},
+	)
+	require.NoError(t, err)
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	call := func(path, role, name string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		r = identity.WithTestIdentity(r, identity.NewIdentity(role, name, ""))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// no policy applies
+	w := call("/v1/items", "guest", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(header.RateLimitLimit))
+
+	w = call("/v1/items", "user", "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(header.RateLimitLimit))
+	assert.Equal(t, "1", w.Header().Get(header.RateLimitRemaining))
+	assert.Equal(t, "1", w.Header().Get(header.RateLimitReset))
+
+	w = call("/v1/items", "user", "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get(header.RateLimitRemaining))
+
+	w = call("/v1/items", "user", "alice")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get(header.RetryAfter))
+	assert.Equal(t, "0", w.Header().Get(header.RateLimitRemaining))
+	assert.Contains(t, w.Body.String(), `"code":"rate_limit_exceeded"`)
+
+	// the other identity is not throttled
+	w = call("/v1/items", "user", "bob")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	c.Add(500 * time.Millisecond)
+	w = call("/v1/items", "user", "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the most restrictive policy is reported
+	w = call("/v1/admin/logs", "user", "carol")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(header.RateLimitLimit))
+	assert.Equal(t, "60", w.Header().Get(header.RateLimitReset))
+
+	w = call("/v1/admin/logs", "admin", "dave")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get(header.RetryAfter))
+
+	data := im.Data()
+	require.NotEmpty(t, data)
+	assert.Equal(t, 1, data[0].Counters["test.http.request.throttled;policy=users;role=user"].Count)
+	assert.Equal(t, 1, data[0].Counters["test.http.request.throttled;policy=admin;role=admin"].Count)
+
+	// the store failure allows the request
+	l, err = New(failingStore{}, Policy{Name: "users", By: KeyIdentity, Limit: 1, Period: time.Second})
+	require.NoError(t, err)
+	h = l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 3; i++ {
+		w = call("/v1/items", "user", "alice")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func Test_HasPathPrefix(t *testing.T) {
+	tcases := []struct {
+		path, prefix string
+		exp          bool
+	}{
+		{"/v1/login", "/v1/login", true},
+		{"/v1/login/otp", "/v1/login", true},
+		{"/v1/login/otp", "/v1/login/", true},
+		{"/v1/login-history", "/v1/login", false},
+		{"/v1/log", "/v1/login", false},
+		{"/v1/login", "/", true},
+		{"/v1/login", "", true},
+	}
+	for _, tc := range tcases {
+		assert.Equal(t, tc.exp, hasPathPrefix(tc.path, tc.prefix), "%s: %s", tc.path, tc.prefix)
+	}
+}
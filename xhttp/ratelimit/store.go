@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit specifies the token bucket
+type Limit struct {
+	// Rate specifies the number of tokens added to the bucket per second
+	Rate float64
+	// Burst specifies the capacity of the bucket
+	Burst int
+}
+
+// Result is the result of taking a token from the bucket
+type Result struct {
+	// Allowed is true if the token was taken
+	Allowed bool
+	// Limit specifies the capacity of the bucket
+	Limit int
+	// Remaining specifies the number of tokens left in the bucket
+	Remaining int
+	// RetryAfter specifies the duration until the next token is available,
+	// if not allowed
+	RetryAfter time.Duration
+	// Reset specifies the duration until the bucket is full
+	Reset time.Duration
+}
+
+// Store provides the token buckets.
+// The store can be shared across the cluster nodes to enforce the limits
+// for the cluster, the default in-memory store enforces the limits per node.
+type Store interface {
+	// Take takes a token from the bucket of the key
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+	// Refund returns the token taken from the bucket of the key,
+	// when the request is rejected by another policy
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// DefaultSweepInterval specifies the default interval to remove the full buckets
+// from the in-memory store
+const DefaultSweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill adds the tokens accumulated since the last update
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// MemoryStore provides in-memory token buckets
+type MemoryStore struct {
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	interval  time.Duration
+	now       func() time.Time
+}
+
+// NewMemoryStore returns in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*bucket{},
+		interval:  DefaultSweepInterval,
+		now:       time.Now,
+		lastSweep: time.Now(),
+	}
+}
+
+// Len returns the number of the buckets in the store
+func (s *MemoryStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.buckets)
+}
+
+// Take takes a token from the bucket of the key
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (*Result, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b := s.buckets[key]
+	if b == nil || b.limit != limit {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+			limit:  limit,
+		}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+
+	res := &Result{
+		Limit: limit.Burst,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = secondsToDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return res, nil
+}
+
+// Refund returns the token taken from the bucket of the key
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b := s.buckets[key]
+	if b == nil || b.limit != limit {
+		// the bucket is full or reset
+		return nil
+	}
+	b.refill(s.now())
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	return nil
+}
+
+// sweep removes the buckets that are full
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.interval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock provides the time for the tests
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = c.Now
+	s.lastSweep = c.now
+	return s, c
+}
+
+func Test_MemoryStore(t *testing.T) {
+	s, c := newTestStore()
+	ctx := context.Background()
+
+	// 2 per second, burst 3
+	limit := Limit{Rate: 2, Burst: 3}
+	for i := 2; i >= 0; i-- {
+		res, err := s.Take(ctx, "k1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, err := s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	// the other key has its own bucket
+	res, err = s.Take(ctx, "k2", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, s.Len())
+
+	c.Add(500 * time.Millisecond)
+	res, err = s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	// refilled up to burst
+	c.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		res, err = s.Take(ctx, "k1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, err = s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+
+	// changed limit resets the bucket
+	res, err = s.Take(ctx, "k1", Limit{Rate: 1, Burst: 10})
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 9, res.Remaining)
+}
+
+func Test_MemoryStoreSweep(t *testing.T) {
+	s, c := newTestStore()
+	ctx := context.Background()
+
+	limit := Limit{Rate: 1, Burst: 10}
+	_, err := s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	c.Add(DefaultSweepInterval / 2)
+	for i := 0; i < 10; i++ {
+		_, err = s.Take(ctx, "k2", Limit{Rate: 0.1, Burst: 10})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, s.Len())
+
+	// k1 is full, k2 is not
+	c.Add(DefaultSweepInterval/2 + time.Second)
+	_, err = s.Take(ctx, "k3", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Len())
+
+	s.lock.Lock()
+	assert.NotContains(t, s.buckets, "k1")
+	assert.Contains(t, s.buckets, "k2")
+	s.lock.Unlock()
+}
+
+func Test_MemoryStoreRefund(t *testing.T) {
+	s, _ := newTestStore()
+	ctx := context.Background()
+
+	limit := Limit{Rate: 1, Burst: 2}
+	// no bucket
+	require.NoError(t, s.Refund(ctx, "k1", limit))
+	assert.Equal(t, 0, s.Len())
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Take(ctx, "k1", limit)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Refund(ctx, "k1", limit))
+	res, err := s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	// not above burst
+	require.NoError(t, s.Refund(ctx, "k1", limit))
+	require.NoError(t, s.Refund(ctx, "k1", limit))
+	require.NoError(t, s.Refund(ctx, "k1", limit))
+	res, err = s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Remaining)
+
+	// changed limit is not refunded
+	require.NoError(t, s.Refund(ctx, "k1", Limit{Rate: 1, Burst: 10}))
+	res, err = s.Take(ctx, "k1", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Remaining)
+}
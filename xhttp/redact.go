@@ -0,0 +1,245 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+)
+
+// RedactedValue replaces the redacted values
+const RedactedValue = "[REDACTED]"
+
+// PEMPrivateKeyPattern matches PEM encoded private keys,
+// including the keys truncated by the capture limit
+const PEMPrivateKeyPattern = `-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(-----END [A-Z0-9 ]*PRIVATE KEY-----|\z)`
+
+// CaptureRedaction specifies the redaction rules of the captured requests
+type CaptureRedaction struct {
+	// Headers specifies the names of the headers to redact
+	Headers []string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// JSONPaths specifies the dot-separated paths of the values in JSON bodies to redact,
+	// "*" matches any key or array element, for example "items.*.token";
+	// if specified, then the text bodies that are not JSON are redacted
+	JSONPaths []string `json:"json_paths,omitempty" yaml:"json_paths,omitempty"`
+	// Patterns specifies the regular expressions of the values to redact
+	// in the bodies, the headers and the URI
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+}
+
+// DefaultCaptureRedaction returns the redaction rules of the credentials headers
+// and PEM encoded private keys
+func DefaultCaptureRedaction() CaptureRedaction {
+	return CaptureRedaction{
+		Headers: []string{
+			header.Authorization,
+			"Proxy-Authorization",
+			"Cookie",
+			"Set-Cookie",
+		},
+		Patterns: []string{
+			PEMPrivateKeyPattern,
+		},
+	}
+}
+
+// Redactor applies the redaction rules to the captured requests
+type Redactor struct {
+	headers  map[string]bool
+	paths    [][]string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor returns Redactor for the rules
+func NewRedactor(cfg CaptureRedaction) (*Redactor, error) {
+	r := &Redactor{
+		headers: make(map[string]bool, len(cfg.Headers)),
+	}
+	for _, h := range cfg.Headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range cfg.JSONPaths {
+		path := strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+		if path == "" {
+			return nil, errors.Errorf("invalid JSON path: %q", p)
+		}
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern: %q", p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact applies the redaction rules to the captured request,
+// the bodies are expected to be decompressed, as BodyCapture does
+func (r *Redactor) Redact(c *CapturedRequest) {
+	c.URI = r.redactPatterns(c.URI)
+	c.RequestBody = r.RedactBody(c.RequestHeaders.Get(header.ContentType), c.RequestBody)
+	c.ResponseBody = r.RedactBody(c.ResponseHeaders.Get(header.ContentType), c.ResponseBody)
+	r.RedactHeaders(c.RequestHeaders)
+	r.RedactHeaders(c.ResponseHeaders)
+}
+
+// RedactHeaders applies the redaction rules to the headers
+func (r *Redactor) RedactHeaders(h http.Header) {
+	for name, values := range h {
+		if r.headers[http.CanonicalHeaderKey(name)] {
+			for i := range values {
+				values[i] = RedactedValue
+			}
+			continue
+		}
+		for i, v := range values {
+			values[i] = r.redactPatterns(v)
+		}
+	}
+}
+
+// RedactBody applies the redaction rules to the decompressed body.
+// The binary body, such as CBOR, MessagePack or protobuf, is redacted,
+// as the rules can not be applied to it.
+// If JSON paths are specified, then the body of any text content type
+// is parsed as JSON, as the server decodes it, and NDJSON is parsed by lines;
+// if the body can not be parsed, for example due to truncation,
+// then the whole body, or the line, is redacted.
+func (r *Redactor) RedactBody(contentType, body string) string {
+	if body == "" {
+		return body
+	}
+	if isBinary(contentType) || !utf8.ValidString(body) {
+		return RedactedValue
+	}
+	if len(r.paths) > 0 {
+		if isNDJSON(contentType) {
+			lines := strings.Split(body, "\n")
+			for i, line := range lines {
+				if strings.TrimSpace(line) != "" {
+					lines[i] = r.redactJSON(line)
+				}
+			}
+			body = strings.Join(lines, "\n")
+		} else {
+			body = r.redactJSON(body)
+		}
+	}
+	return r.redactPatterns(body)
+}
+
+// redactJSON redacts JSON paths in the sequence of JSON values,
+// or returns RedactedValue if the body is not JSON
+func (r *Redactor) redactJSON(body string) string {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return RedactedValue
+		}
+		for _, p := range r.paths {
+			v = redactPath(v, p)
+		}
+		if err := enc.Encode(v); err != nil {
+			return RedactedValue
+		}
+	}
+	if b.Len() == 0 {
+		return RedactedValue
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (r *Redactor) redactPatterns(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, RedactedValue)
+	}
+	return s
+}
+
+func redactPath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return RedactedValue
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if path[0] == "*" || path[0] == k {
+				t[k] = redactPath(val, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				t[i] = redactPath(val, path[1:])
+			}
+		}
+	}
+	return v
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+func isNDJSON(contentType string) bool {
+	switch mediaType(contentType) {
+	case header.ApplicationNDJSON, "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
+
+var binaryMediaTypes = map[string]bool{
+	header.ApplicationCBOR:           true,
+	header.ApplicationMsgPack:        true,
+	"application/x-msgpack":          true,
+	header.ApplicationProtobuf:       true,
+	"application/protobuf":           true,
+	header.ApplicationGRPC:           true,
+	header.ApplicationTimestampQuery: true,
+	header.ApplicationTimestampReply: true,
+	"application/octet-stream":       true,
+}
+
+// isBinary returns true for the content types of binary encodings
+func isBinary(contentType string) bool {
+	mt := mediaType(contentType)
+	if binaryMediaTypes[mt] {
+		return true
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/", header.ApplicationGRPC + "+"} {
+		if strings.HasPrefix(mt, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range []string{"+cbor", "+msgpack", "+proto"} {
+		if strings.HasSuffix(mt, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package xhttp
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -31,6 +32,11 @@ type configuration struct {
 	granularity int64
 	extractor   AdditionalLogExtractor
 	logger      xlog.Logger
+	format      LogFormat
+	fields      []string
+	sampling    float64
+	pathLevels  []pathLevel
+	route       func(r *http.Request) string
 }
 
 // WithLoggerSkipPaths is an Option allows to skip logs on path/agent match
@@ -51,6 +57,8 @@ type RequestLogger struct {
 // The generated Log lines are in the format
 // <prefix>:<HTTP Method>:<ClientCertSubjectCN>:<Path>:<RemoteIP>:<RemotePort>:<StatusCode>:<HTTP Version>:<Response Body Size>:<Request Duration>:<Additional Fields>
 // skippath parameter allows to specify a list of paths to not log.
+// The structured formats, the fields, the sampling and the log levels per path
+// can be specified by the options.
 func NewRequestLogger(handler http.Handler, prefix string, additionalEntries AdditionalLogExtractor, granularity time.Duration, packageLogger string, opts ...RequestLoggerOption) http.Handler {
 	if handler == nil {
 		panic(errNoHandler)
@@ -70,6 +78,8 @@ func NewRequestLogger(handler http.Handler, prefix string, additionalEntries Add
 		extractor:   additionalEntries,
 		logger:      l,
 		prefix:      prefix,
+		format:      LogFormatText,
+		sampling:    1,
 	}
 
 	for _, opt := range opts {
@@ -86,6 +96,15 @@ func NewRequestLogger(handler http.Handler, prefix string, additionalEntries Add
 // real handler to collect info about the response, and then write out the log line
 func (l *RequestLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now().UTC()
+	r, _ = withUpstreamTimer(r)
+	var body *countingReader
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &countingReader{ReadCloser: r.Body}
+		// shallow copy, to not modify the request of the caller
+		r = r.WithContext(r.Context())
+		r.Body = body
+	}
+
 	rw := NewResponseCapture(w)
 	l.handler.ServeHTTP(rw, r)
 
@@ -102,36 +121,65 @@ func (l *RequestLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	dur := time.Since(start)
-	clientCertUser := l.client(r)
-	extra := ""
-	if l.cfg.extractor != nil {
-		fields := l.cfg.extractor(rw, r)
-		if len(fields) > 0 {
-			extra = ":" + strings.Join(fields, ":")
-		}
+	if !l.cfg.sampled(rw.StatusCode()) {
+		return
 	}
-	l.cfg.logger.Infof("%s:%s:%s:%s:%s:%d:%d.%d:%d:%v:%q%s",
-		l.cfg.prefix,
-		clientCertUser,
-		r.Method,
-		r.URL.Path,
-		r.RemoteAddr,
-		rw.statusCode,
-		r.ProtoMajor, r.ProtoMinor,
-		rw.bodySize,
-		dur.Nanoseconds()/l.cfg.granularity,
-		agent,
-		extra)
-}
 
-func (l *RequestLogger) client(r *http.Request) string {
-	if r.TLS == nil {
-		return ""
+	rec := &accessRecord{
+		cfg:      &l.cfg,
+		r:        r,
+		rw:       rw,
+		start:    start,
+		duration: time.Since(start),
 	}
-	pc := r.TLS.PeerCertificates
-	if len(pc) == 0 {
-		return ""
+	if body != nil {
+		rec.requestBytes = body.n
+	}
+	level := l.cfg.level(r.URL.Path)
+
+	var msg string
+	switch l.cfg.format {
+	case LogFormatKV:
+		l.cfg.logger.KV(level, rec.kv()...)
+		return
+	case LogFormatJSON:
+		msg = rec.json()
+	case LogFormatCombined:
+		msg = rec.combined()
+	default:
+		extra := ""
+		if l.cfg.extractor != nil {
+			fields := l.cfg.extractor(rw, r)
+			if len(fields) > 0 {
+				extra = ":" + strings.Join(fields, ":")
+			}
+		}
+		msg = fmt.Sprintf("%s:%s:%s:%s:%s:%d:%d.%d:%d:%v:%q%s",
+			l.cfg.prefix,
+			clientCN(r),
+			r.Method,
+			r.URL.Path,
+			r.RemoteAddr,
+			rw.StatusCode(),
+			r.ProtoMajor, r.ProtoMinor,
+			rw.bodySize,
+			rec.duration.Nanoseconds()/l.cfg.granularity,
+			agent,
+			extra)
+	}
+
+	switch level {
+	case xlog.CRITICAL, xlog.ERROR:
+		l.cfg.logger.Errorf("%s", msg)
+	case xlog.WARNING:
+		l.cfg.logger.Warningf("%s", msg)
+	case xlog.NOTICE:
+		l.cfg.logger.Noticef("%s", msg)
+	case xlog.DEBUG:
+		l.cfg.logger.Debugf("%s", msg)
+	case xlog.TRACE:
+		l.cfg.logger.Tracef("%s", msg)
+	default:
+		l.cfg.logger.Infof("%s", msg)
 	}
-	return pc[0].Subject.CommonName
 }
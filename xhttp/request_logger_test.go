@@ -50,6 +50,32 @@ func TestHttp_ResponseCapture(t *testing.T) {
 	assertRespEqual(t, w, http.StatusNotFound, "/foo not found/foo not found")
 }
 
+func TestHttp_ResponseCaptureGRPC(t *testing.T) {
+	tcases := []struct {
+		contentType string
+		trailer     string
+		status      string
+		exp         int
+	}{
+		{header.ApplicationGRPC, "Grpc-Status", "0", http.StatusOK},
+		{header.ApplicationGRPC + "+proto", "Grpc-Status", "7", http.StatusForbidden},
+		{header.ApplicationGRPC, http.TrailerPrefix + "Grpc-Status", "16", http.StatusUnauthorized},
+		{header.ApplicationGRPC, "Grpc-Status", "14", http.StatusServiceUnavailable},
+		{header.ApplicationGRPC, "Grpc-Status", "2", http.StatusInternalServerError},
+		{header.ApplicationGRPC, "Grpc-Status", "", http.StatusOK},
+		{header.ApplicationJSON, "Grpc-Status", "7", http.StatusOK},
+	}
+	for _, tc := range tcases {
+		rc := NewResponseCapture(httptest.NewRecorder())
+		rc.Header().Set(header.ContentType, tc.contentType)
+		rc.WriteHeader(http.StatusOK)
+		if tc.status != "" {
+			rc.Header().Set(tc.trailer, tc.status)
+		}
+		assert.Equal(t, tc.exp, rc.StatusCode(), "%s %s=%s", tc.contentType, tc.trailer, tc.status)
+	}
+}
+
 type testHandler struct {
 	t            *testing.T
 	statusCode   int
@@ -2,7 +2,10 @@ package xhttp
 
 import (
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-phorce/dolly/metrics"
@@ -10,21 +13,119 @@ import (
 	"github.com/go-phorce/dolly/xhttp/identity"
 )
 
+// RequestMetricsOption is an option that can be passed to NewRequestMetrics
+type RequestMetricsOption func(c *metricsConfig)
+
+type metricsConfig struct {
+	route         func(r *http.Request) string
+	normalize     func(path string) string
+	identityLabel bool
+	roleLabel     bool
+	providerLabel bool
+}
+
+// MetricsURIUnmatched is the URI label of the requests that did not match any route,
+// when the route template is provided by WithMetricsRoute and the path normalizer is not set
+const MetricsURIUnmatched = "unmatched"
+
+// WithMetricsRoute is an Option to provide the template of the route matched by the router,
+// which is used as URI label instead of the request path.
+// The requests without the route, such as 404s, are labeled as MetricsURIUnmatched,
+// unless WithMetricsPathNormalizer is specified, to bound the cardinality of the label.
+func WithMetricsRoute(route func(r *http.Request) string) RequestMetricsOption {
+	return func(c *metricsConfig) {
+		c.route = route
+	}
+}
+
+// WithMetricsPathNormalizer is an Option to normalize the request path
+// used as URI label, when the route template is not available,
+// for example NormalizePathIDs
+func WithMetricsPathNormalizer(normalize func(path string) string) RequestMetricsOption {
+	return func(c *metricsConfig) {
+		c.normalize = normalize
+	}
+}
+
+// WithMetricsIdentityLabel is an Option to add the identity name label,
+// disabled by default
+func WithMetricsIdentityLabel(enabled bool) RequestMetricsOption {
+	return func(c *metricsConfig) {
+		c.identityLabel = enabled
+	}
+}
+
+// WithMetricsProviderLabel is an Option to add the label
+// with the name of the identity provider, disabled by default
+func WithMetricsProviderLabel(enabled bool) RequestMetricsOption {
+	return func(c *metricsConfig) {
+		c.providerLabel = enabled
+	}
+}
+
+// WithMetricsRoleLabel is an Option to add the identity role label,
+// enabled by default
+func WithMetricsRoleLabel(enabled bool) RequestMetricsOption {
+	return func(c *metricsConfig) {
+		c.roleLabel = enabled
+	}
+}
+
+var idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{16,}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// NormalizePathIDs replaces the path segments with numbers, UUIDs or long hex values
+// by ":id", for example /v1/users/123/keys/abc becomes /v1/users/:id/keys/abc
+func NormalizePathIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if idSegment.MatchString(s) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// DefaultDurationBuckets specifies the histogram buckets of the request duration, in milliseconds
+var DefaultDurationBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// DefaultSizeBuckets specifies the histogram buckets of the request and response sizes, in bytes
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+// RequestMetricsHistograms returns the histogram buckets of the request metrics,
+// to be used in metrics.PrometheusOpts
+func RequestMetricsHistograms() map[string][]float64 {
+	return map[string][]float64{
+		"http_request_perf":  DefaultDurationBuckets,
+		"http_request_size":  DefaultSizeBuckets,
+		"http_response_size": DefaultSizeBuckets,
+	}
+}
+
 // a http.Handler that records execution metrics of the wrapper handler
 type requestMetrics struct {
 	handler       http.Handler
 	responseCodes []string
+	cfg           metricsConfig
 }
 
+// inflightRequests is the number of requests being served by all request metrics handlers
+var inflightRequests int64
+
 // NewRequestMetrics creates a wrapper handler to produce metrics for each request
-func NewRequestMetrics(h http.Handler) http.Handler {
+func NewRequestMetrics(h http.Handler, opts ...RequestMetricsOption) http.Handler {
 	rm := requestMetrics{
 		handler:       h,
 		responseCodes: make([]string, 599),
+		cfg: metricsConfig{
+			roleLabel: true,
+		},
 	}
 	for idx := range rm.responseCodes {
 		rm.responseCodes[idx] = strconv.Itoa(idx)
 	}
+	for _, opt := range opts {
+		opt(&rm.cfg)
+	}
 	return &rm
 }
 
@@ -36,31 +137,95 @@ func (rm *requestMetrics) statusCode(statusCode int) string {
 	return strconv.Itoa(statusCode)
 }
 
+func (rm *requestMetrics) uri(r *http.Request) string {
+	return rm.cfg.uri(r)
+}
+
+// RequestMetricsURI returns the URI label of the request,
+// as published by the request metrics handler with the same options
+func RequestMetricsURI(r *http.Request, opts ...RequestMetricsOption) string {
+	cfg := metricsConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg.uri(r)
+}
+
+func (c *metricsConfig) uri(r *http.Request) string {
+	if c.route != nil {
+		if route := c.route(r); route != "" {
+			return route
+		}
+		if c.normalize == nil {
+			return MetricsURIUnmatched
+		}
+	}
+	if c.normalize != nil {
+		return c.normalize(r.URL.Path)
+	}
+	return r.URL.Path
+}
+
 var (
 	keyForHTTPReqPerf       = []string{"http", "request", "perf"}
 	keyForHTTPReqSuccessful = []string{"http", "request", "status", "successful"}
 	keyForHTTPReqFailed     = []string{"http", "request", "status", "failed"}
+	keyForHTTPReqInFlight   = []string{"http", "request", "inflight"}
+	keyForHTTPReqSize       = []string{"http", "request", "size"}
+	keyForHTTPRespSize      = []string{"http", "response", "size"}
 )
 
 func (rm *requestMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now().UTC()
+
+	metrics.SetGauge(keyForHTTPReqInFlight, float32(atomic.AddInt64(&inflightRequests, 1)))
+	defer func() {
+		metrics.SetGauge(keyForHTTPReqInFlight, float32(atomic.AddInt64(&inflightRequests, -1)))
+	}()
+
+	var body *countingReader
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &countingReader{ReadCloser: r.Body}
+		// shallow copy, to not modify the request of the caller
+		r = r.WithContext(r.Context())
+		r.Body = body
+	}
+
 	rc := NewResponseCapture(w)
 	rm.handler.ServeHTTP(rc, r)
-	role := identity.FromRequest(r).Identity().Role()
+	rctx := identity.FromRequest(r)
+	idn := rctx.Identity()
 	sc := rc.StatusCode()
 
-	tags := []metrics.Tag{
+	labels := []metrics.Tag{
 		{Name: tags.Method, Value: r.Method},
-		{Name: tags.Role, Value: role},
-		{Name: tags.Status, Value: rm.statusCode(sc)},
-		{Name: tags.URI, Value: r.URL.Path},
 	}
+	if rm.cfg.roleLabel {
+		labels = append(labels, metrics.Tag{Name: tags.Role, Value: idn.Role()})
+	}
+	labels = append(labels,
+		metrics.Tag{Name: tags.Status, Value: rm.statusCode(sc)},
+		metrics.Tag{Name: tags.URI, Value: rm.uri(r)},
+	)
+	if rm.cfg.identityLabel {
+		labels = append(labels, metrics.Tag{Name: tags.Identity, Value: idn.Name()})
+	}
+	if rm.cfg.providerLabel {
+		labels = append(labels, metrics.Tag{Name: tags.IdentityProvider, Value: rctx.Provider()})
+	}
+
+	metrics.MeasureSince(keyForHTTPReqPerf, start, labels...)
 
-	metrics.MeasureSince(keyForHTTPReqPerf, start, tags...)
+	var requestSize uint64
+	if body != nil {
+		requestSize = body.n
+	}
+	metrics.AddSample(keyForHTTPReqSize, float32(requestSize), labels...)
+	metrics.AddSample(keyForHTTPRespSize, float32(rc.BodySize()), labels...)
 
 	if sc >= 400 {
-		metrics.IncrCounter(keyForHTTPReqFailed, 1, tags...)
+		metrics.IncrCounter(keyForHTTPReqFailed, 1, labels...)
 	} else {
-		metrics.IncrCounter(keyForHTTPReqSuccessful, 1, tags...)
+		metrics.IncrCounter(keyForHTTPReqSuccessful, 1, labels...)
 	}
 }
@@ -2,8 +2,10 @@ package xhttp
 
 import (
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -90,3 +92,95 @@ func Test_RequestMetrics(t *testing.T) {
 	assertCounter("test.http.request.status.failed;method=POST;role=dolly;status=400;uri=/", 1)
 	assertCounter("test.http.request.status.failed;method=POST;role=dolly;status=400;uri=/bar", 2)
 }
+
+func Test_RequestMetricsOptions(t *testing.T) {
+	im := metrics.NewInmemSink(time.Minute, time.Minute*5)
+	_, err := metrics.NewGlobal(metrics.DefaultConfig("test"), im)
+	require.NoError(t, err)
+
+	var inflight float32
+	h := func(w http.ResponseWriter, r *http.Request) {
+		data := im.Data()
+		inflight = data[0].Gauges["test.http.request.inflight"].Value
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		w.Write(body)
+	}
+	rm := NewRequestMetrics(http.HandlerFunc(h),
+		WithMetricsRoute(func(r *http.Request) string {
+			if strings.HasPrefix(r.URL.Path, "/v1/users/") {
+				return "/v1/users/:id"
+			}
+			return ""
+		}),
+		WithMetricsPathNormalizer(NormalizePathIDs),
+		WithMetricsIdentityLabel(true),
+		WithMetricsRoleLabel(false),
+		WithMetricsProviderLabel(true),
+	)
+	req := func(uri, body string) {
+		r, err := http.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+		require.NoError(t, err)
+		r = identity.WithTestIdentity(r, identity.WithProviderName(identity.NewIdentity("dolly", "bob", ""), "bearer"))
+		rm.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	req("/v1/users/1", "12345")
+	req("/v1/users/2", "12345")
+	req("/v1/keys/1234/cert", "")
+
+	data := im.Data()
+	prefix := "test.http.request.perf;method=POST;status=200;uri="
+	assert.Equal(t, 2, data[0].Samples[prefix+"/v1/users/:id;identity=bob;identity_provider=bearer"].Count)
+	assert.Equal(t, 1, data[0].Samples[prefix+"/v1/keys/:id/cert;identity=bob;identity_provider=bearer"].Count)
+
+	reqSize := data[0].Samples["test.http.request.size;method=POST;status=200;uri=/v1/users/:id;identity=bob;identity_provider=bearer"]
+	require.NotNil(t, reqSize)
+	assert.Equal(t, float64(10), reqSize.Sum)
+	respSize := data[0].Samples["test.http.response.size;method=POST;status=200;uri=/v1/users/:id;identity=bob;identity_provider=bearer"]
+	require.NotNil(t, respSize)
+	assert.Equal(t, float64(20), respSize.Sum)
+
+	assert.Equal(t, float32(1), inflight)
+	assert.Equal(t, float32(0), data[0].Gauges["test.http.request.inflight"].Value)
+}
+
+func Test_NormalizePathIDs(t *testing.T) {
+	tcases := []struct {
+		path string
+		exp  string
+	}{
+		{"/", "/"},
+		{"/v1/status", "/v1/status"},
+		{"/v1/users/123", "/v1/users/:id"},
+		{"/v1/users/123/keys/abc", "/v1/users/:id/keys/abc"},
+		{"/v1/certs/0123456789abcdef0123", "/v1/certs/:id"},
+		{"/v1/requests/123e4567-e89b-12d3-a456-426614174000/", "/v1/requests/:id/"},
+		{"/v1/abcdef", "/v1/abcdef"},
+	}
+	for _, tc := range tcases {
+		assert.Equal(t, tc.exp, NormalizePathIDs(tc.path), tc.path)
+	}
+
+	h := RequestMetricsHistograms()
+	assert.Equal(t, DefaultDurationBuckets, h["http_request_perf"])
+	assert.Equal(t, DefaultSizeBuckets, h["http_response_size"])
+}
+
+func Test_RequestMetricsURI(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/v1/users/123", nil)
+	require.NoError(t, err)
+
+	route := WithMetricsRoute(func(r *http.Request) string {
+		if r.Method == http.MethodPost {
+			return "/v1/users/:user"
+		}
+		return ""
+	})
+	assert.Equal(t, "/v1/users/123", RequestMetricsURI(r))
+	assert.Equal(t, MetricsURIUnmatched, RequestMetricsURI(r, route))
+	assert.Equal(t, "/v1/users/:id", RequestMetricsURI(r, route, WithMetricsPathNormalizer(NormalizePathIDs)))
+
+	r.Method = http.MethodPost
+	assert.Equal(t, "/v1/users/:user", RequestMetricsURI(r, route, WithMetricsPathNormalizer(NormalizePathIDs)))
+}
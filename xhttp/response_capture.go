@@ -2,6 +2,11 @@ package xhttp
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"google.golang.org/grpc/codes"
 )
 
 // ResponseCapture is a net/http.ResponseWriter that delegates everything
@@ -18,10 +23,63 @@ func NewResponseCapture(w http.ResponseWriter) *ResponseCapture {
 }
 
 // StatusCode returns the http status set by the handler.
+// For gRPC responses, the status is mapped from grpc-status trailer,
+// as gRPC calls always respond with 200 OK.
 func (r *ResponseCapture) StatusCode() int {
+	if r.statusCode == http.StatusOK {
+		if code, ok := grpcStatus(r.delegate.Header()); ok {
+			return grpcHTTPStatus(code)
+		}
+	}
 	return r.statusCode
 }
 
+// grpcStatus returns the code of grpc-status trailer of gRPC response
+func grpcStatus(h http.Header) (codes.Code, bool) {
+	if !strings.HasPrefix(h.Get(header.ContentType), header.ApplicationGRPC) {
+		return codes.OK, false
+	}
+	val := h.Get("Grpc-Status")
+	if val == "" {
+		val = h.Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	code, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		return codes.OK, false
+	}
+	return codes.Code(code), true
+}
+
+// grpcHTTPStatus returns HTTP status that corresponds to gRPC code
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // BodySize returns in bytes the total number of bytes written to the response body so far.
 func (r *ResponseCapture) BodySize() uint64 {
 	return r.bodySize
@@ -48,6 +106,11 @@ func (r *ResponseCapture) WriteHeader(sc int) {
 	r.delegate.WriteHeader(sc)
 }
 
+// Unwrap returns the underlying ResponseWriter
+func (r *ResponseCapture) Unwrap() http.ResponseWriter {
+	return r.delegate
+}
+
 // Flush sends any buffered data to the client.
 func (r *ResponseCapture) Flush() {
 	if flusher, ok := r.delegate.(http.Flusher); ok {
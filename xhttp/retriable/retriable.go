@@ -16,6 +16,9 @@ import (
 	"time"
 
 	"github.com/go-phorce/dolly/algorithms/slices"
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/go-phorce/dolly/xhttp/httperror"
 	"github.com/go-phorce/dolly/xlog"
 	"github.com/pkg/errors"
@@ -44,6 +47,8 @@ type contextValueName string
 const (
 	// ContextValueForHTTPHeader specifies context value name for HTTP headers
 	contextValueForHTTPHeader = contextValueName("HTTP-Header")
+	// contextValueForStream specifies context value name for the stream requests
+	contextValueForStream = contextValueName("Stream")
 )
 
 // GenericHTTP defines a number of generalized HTTP request handling wrappers
@@ -345,8 +350,23 @@ func NewDefaultPolicy() *Policy {
 // requestBody can be io.Reader, []byte, or an object to be JSON encoded
 // responseBody can be io.Writer, or a struct to decode JSON into.
 func (c *Client) Request(ctx context.Context, method string, hosts []string, path string, requestBody interface{}, responseBody interface{}) (http.Header, int, error) {
-	var body io.ReadSeeker
+	body, err := requestBodyReader(requestBody)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.executeRequest(ctx, method, hosts, path, body)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	return c.DecodeResponse(resp, responseBody)
+}
 
+// requestBodyReader returns the reader of the request body,
+// requestBody can be io.Reader, []byte, string, or an object to be JSON encoded
+func requestBodyReader(requestBody interface{}) (io.ReadSeeker, error) {
+	var body io.ReadSeeker
 	if requestBody != nil {
 		switch val := requestBody.(type) {
 		case io.ReadSeeker:
@@ -354,7 +374,7 @@ func (c *Client) Request(ctx context.Context, method string, hosts []string, pat
 		case io.Reader:
 			b, err := ioutil.ReadAll(val)
 			if err != nil {
-				return nil, 0, errors.WithStack(err)
+				return nil, errors.WithStack(err)
 			}
 			body = bytes.NewReader(b)
 		case []byte:
@@ -364,18 +384,12 @@ func (c *Client) Request(ctx context.Context, method string, hosts []string, pat
 		default:
 			js, err := json.Marshal(requestBody)
 			if err != nil {
-				return nil, 0, errors.WithStack(err)
+				return nil, errors.WithStack(err)
 			}
 			body = bytes.NewReader(js)
 		}
 	}
-	resp, err := c.executeRequest(ctx, method, hosts, path, body)
-	if err != nil {
-		return nil, 0, errors.WithStack(err)
-	}
-	defer resp.Body.Close()
-
-	return c.DecodeResponse(resp, responseBody)
+	return body, nil
 }
 
 // Head makes HEAD request against the specified hosts.
@@ -504,6 +518,13 @@ func (c *Client) Do(r *http.Request) (*http.Response, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	// the time of the call is reported by the request logger of the server,
+	// if the request is made while serving the incoming request
+	started := time.Now()
+	defer func() {
+		xhttp.AddUpstreamDuration(r.Context(), time.Since(started))
+	}()
+
 	for retries = 0; ; retries++ {
 		// Always rewind the request body when non-nil.
 		if req.body != nil {
@@ -518,7 +539,7 @@ func (c *Client) Do(r *http.Request) (*http.Response, error) {
 			}
 		}
 
-		resp, err = c.httpClient.Do(req.Request)
+		resp, err = c.doAttempt(req.Request, retries)
 
 		// Check if we should continue with retries.
 		shouldRetry, sleepDuration, reason := c.Policy.ShouldRetry(req.Request, resp, err, retries)
@@ -545,6 +566,29 @@ func (c *Client) Do(r *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
+// doAttempt sends the request in the client span,
+// propagating the trace context in traceparent header
+func (c *Client) doAttempt(r *http.Request, retry int) (*http.Response, error) {
+	ctx, span := tracing.StartSpan(r.Context(), "HTTP "+r.Method, tracing.SpanKindClient)
+	defer span.End()
+
+	tracing.Inject(ctx, r.Header)
+	span.SetAttribute(tracing.AttrHTTPMethod, r.Method)
+	span.SetAttribute(tracing.AttrHTTPURL, r.URL.Scheme+"://"+r.URL.Host+r.URL.Path)
+	span.SetAttribute(tracing.AttrHTTPRetry, retry)
+
+	resp, err := c.clientFor(r).Do(r)
+	if err != nil {
+		span.SetError(err)
+	} else {
+		span.SetAttribute(tracing.AttrHTTPStatusCode, resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetError(errors.New(resp.Status))
+		}
+	}
+	return resp, err
+}
+
 // shouldTryDifferentHost returns true if a connection error occurred
 // or response has a specific HTTP status:
 // - StatusInternalServerError
@@ -605,6 +649,9 @@ func (c *Client) DecodeResponse(resp *http.Response, body interface{}) (http.Hea
 	if resp.StatusCode == http.StatusNoContent {
 		return resp.Header, resp.StatusCode, nil
 	} else if resp.StatusCode >= http.StatusMultipleChoices { // 300
+		if isProblem(resp) {
+			return resp.Header, resp.StatusCode, decodeProblem(resp)
+		}
 		e := new(httperror.Error)
 		e.HTTPStatus = resp.StatusCode
 		bodyCopy := bytes.Buffer{}
@@ -632,6 +679,26 @@ func (c *Client) DecodeResponse(resp *http.Response, body interface{}) (http.Hea
 	return resp.Header, resp.StatusCode, nil
 }
 
+// isProblem returns true if the response has RFC 7807 problem details
+func isProblem(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get(header.ContentType), header.ApplicationProblemJSON)
+}
+
+// decodeProblem returns *httperror.Error, or *httperror.ManyError, from the problem details
+func decodeProblem(resp *http.Response) error {
+	p := new(httperror.Problem)
+	bodyCopy := bytes.Buffer{}
+	bodyTee := io.TeeReader(resp.Body, &bodyCopy)
+	if err := json.NewDecoder(bodyTee).Decode(p); err != nil {
+		io.Copy(ioutil.Discard, bodyTee) // ensure all of body is read
+		return errors.New(string(bodyCopy.Bytes()))
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	return p.ToError()
+}
+
 func shouldRetryFactory(limit int, wait time.Duration, reason string) ShouldRetry {
 	return func(r *http.Request, resp *http.Response, err error, retries int) (bool, time.Duration, string) {
 		return (limit >= retries), wait, reason
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/go-phorce/dolly/rest/tlsconfig"
+	"github.com/go-phorce/dolly/tracing"
+	"github.com/go-phorce/dolly/xhttp"
 	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/go-phorce/dolly/xhttp/httperror"
 	"github.com/go-phorce/dolly/xhttp/marshal"
@@ -663,6 +665,47 @@ func Test_DecodeResponse(t *testing.T) {
 	assert.Equal(t, "unable to decode body response to (*map[string]string) type: invalid character '}' looking for beginning of value", err.Error())
 }
 
+func Test_DecodeResponseProblem(t *testing.T) {
+	c := retriable.New()
+
+	res := http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     http.Header{"Content-Type": []string{"application/problem+json"}},
+		Body: ioutil.NopCloser(bytes.NewBufferString(
+			`{"type":"https://api.example.com/errors/not_found","title":"Not Found","status":404,"detail":"item not found","correlation_id":"123"}`)),
+	}
+
+	var body map[string]string
+	_, sc, err := c.DecodeResponse(&res, &body)
+	assert.Equal(t, http.StatusNotFound, sc)
+	require.Error(t, err)
+	ge, ok := err.(*httperror.Error)
+	require.True(t, ok, "expected *httperror.Error, but was %T %v", err, err)
+	assert.Equal(t, httperror.NotFound, ge.Code)
+	assert.Equal(t, "item not found", ge.Message)
+	assert.Equal(t, http.StatusNotFound, ge.HTTPStatus)
+
+	// the field errors, the status from the response
+	res.StatusCode = http.StatusBadRequest
+	res.Header.Set("Content-Type", "application/problem+json; charset=utf-8")
+	res.Body = ioutil.NopCloser(bytes.NewBufferString(
+		`{"type":"about:blank","title":"Bad Request","code":"invalid_request","detail":"validation failed","errors":{"name":{"code":"invalid_parameter","message":"name is required"}}}`))
+	_, _, err = c.DecodeResponse(&res, &body)
+	require.Error(t, err)
+	many, ok := err.(*httperror.ManyError)
+	require.True(t, ok, "expected *httperror.ManyError, but was %T %v", err, err)
+	assert.Equal(t, http.StatusBadRequest, many.HTTPStatus)
+	assert.Equal(t, httperror.InvalidRequest, many.Code)
+	require.Contains(t, many.Errors, "name")
+	assert.Equal(t, "name is required", many.Errors["name"].Message)
+
+	// invalid problem
+	res.Body = ioutil.NopCloser(bytes.NewBufferString(`{"type":`))
+	_, _, err = c.DecodeResponse(&res, &body)
+	require.Error(t, err)
+	assert.Equal(t, `{"type":`, err.Error())
+}
+
 func makeTestHandler(t *testing.T, expURI string, status int, responseBody string) http.Handler {
 	h := func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, expURI, r.URL.Path, "received wrong URI")
@@ -707,3 +750,72 @@ func makeTestHandlerSlow(t *testing.T, expURI string, status int, delay time.Dur
 	}
 	return http.HandlerFunc(h)
 }
+
+func Test_RetriableUpstreamDuration(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		marshal.WriteJSON(w, r, map[string]string{"status": "ok"})
+	}))
+	defer upstream.Close()
+
+	client := retriable.New()
+	var upstreamDuration time.Duration
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res map[string]string
+		_, _, err := client.Request(r.Context(), http.MethodGet, []string{upstream.URL}, "/v1/status", nil, &res)
+		require.NoError(t, err)
+		upstreamDuration = xhttp.UpstreamDuration(r.Context())
+		marshal.WriteJSON(w, r, res)
+	})
+
+	server := httptest.NewServer(xhttp.NewRequestLogger(h, "upstream", nil, time.Millisecond, ""))
+	defer server.Close()
+
+	var res map[string]string
+	_, status, err := client.Request(context.Background(), http.MethodGet, []string{server.URL}, "/v1/call", nil, &res)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", res["status"])
+	assert.True(t, upstreamDuration >= 20*time.Millisecond, "upstream: %v", upstreamDuration)
+}
+
+func Test_RetriableTracing(t *testing.T) {
+	exp := tracing.NewInMemoryExporter()
+	tracing.SetExporter(exp, nil)
+	defer tracing.SetExporter(nil, nil)
+
+	var traceParent string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get(header.TraceParent)
+		marshal.WriteJSON(w, r, map[string]string{"status": "ok"})
+	})
+	server := httptest.NewServer(tracing.NewHTTPHandler(h))
+	defer server.Close()
+
+	client := retriable.New()
+	ctx, root := tracing.StartSpan(context.Background(), "root", tracing.SpanKindInternal)
+
+	var res map[string]string
+	_, status, err := client.Request(ctx, http.MethodGet, []string{server.URL}, "/v1/status?q=1", nil, &res)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	root.End()
+
+	spans := exp.Spans()
+	require.Len(t, spans, 3)
+	srv, cli := spans[0], spans[1]
+	assert.Equal(t, tracing.SpanKindServer, srv.Kind)
+	assert.Equal(t, tracing.SpanKindClient, cli.Kind)
+
+	rootID := root.SpanContext()
+	assert.Equal(t, rootID.TraceID, cli.SpanContext.TraceID)
+	assert.Equal(t, rootID.TraceID, srv.SpanContext.TraceID)
+	assert.Equal(t, rootID.SpanID, cli.ParentSpanID)
+	assert.Equal(t, cli.SpanContext.SpanID, srv.ParentSpanID)
+	assert.Equal(t, cli.SpanContext.TraceParent(), traceParent)
+
+	assert.Equal(t, "HTTP GET", cli.Name)
+	assert.Equal(t, server.URL+"/v1/status", cli.Attributes[tracing.AttrHTTPURL])
+	assert.Equal(t, 0, cli.Attributes[tracing.AttrHTTPRetry])
+	assert.Equal(t, http.StatusOK, cli.Attributes[tracing.AttrHTTPStatusCode])
+}
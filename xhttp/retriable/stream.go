@@ -0,0 +1,366 @@
+package retriable
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/pkg/errors"
+)
+
+// RequestStream sends request to the specified hosts,
+// and returns the response with the body to be read as a stream,
+// such as NDJSON, Server-Sent Events or JSON array.
+// The supplied hosts are tried in order until one succeeds.
+// For responses with status codes >= 300 it will try and convert the response
+// into a Go error.
+// The caller must close the body of the returned response.
+// The stream is not limited by the timeout of the client,
+// it is cancelled by ctx, or by the idle timeouts of the transport.
+//
+// hosts should include all the protocol/host/port preamble, e.g. https://foo.bar:3444
+// path should be an absolute URI path, i.e. /foo/bar/baz
+// requestBody can be io.Reader, []byte, or an object to be JSON encoded
+func (c *Client) RequestStream(ctx context.Context, method string, hosts []string, path string, requestBody interface{}) (*http.Response, error) {
+	body, err := requestBodyReader(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		// the stream is not limited by Policy.RequestTimeout
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, contextValueForStream, true)
+
+	resp, err := c.executeRequest(ctx, method, hosts, path, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		_, _, err = c.DecodeResponse(resp, nil)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// clientFor returns HTTP client for the request,
+// the client for the streams has no timeout
+func (c *Client) clientFor(r *http.Request) *http.Client {
+	if stream, _ := r.Context().Value(contextValueForStream).(bool); stream && c.httpClient.Timeout > 0 {
+		hc := *c.httpClient
+		hc.Timeout = 0
+		return &hc
+	}
+	return c.httpClient
+}
+
+// NDJSONReader reads newline-delimited JSON stream
+type NDJSONReader struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// NewNDJSONReader returns the reader of application/x-ndjson stream
+func NewNDJSONReader(body io.ReadCloser) *NDJSONReader {
+	return &NDJSONReader{
+		body: body,
+		dec:  json.NewDecoder(body),
+	}
+}
+
+// Next decodes the next item of the stream,
+// io.EOF is returned at the end of the stream
+func (r *NDJSONReader) Next(v interface{}) error {
+	err := r.dec.Decode(v)
+	if err == io.EOF {
+		return err
+	}
+	return errors.WithStack(err)
+}
+
+// Close closes the stream
+func (r *NDJSONReader) Close() error {
+	return r.body.Close()
+}
+
+// JSONArrayReader reads the items of JSON array as they are received
+type JSONArrayReader struct {
+	body    io.ReadCloser
+	dec     *json.Decoder
+	started bool
+	done    bool
+}
+
+// NewJSONArrayReader returns the reader of application/json array
+func NewJSONArrayReader(body io.ReadCloser) *JSONArrayReader {
+	return &JSONArrayReader{
+		body: body,
+		dec:  json.NewDecoder(body),
+	}
+}
+
+// Next decodes the next item of the array,
+// io.EOF is returned at the end of the array,
+// an error is returned if the array is not terminated
+func (r *JSONArrayReader) Next(v interface{}) error {
+	if r.done {
+		return io.EOF
+	}
+	if !r.started {
+		t, err := r.dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if d, ok := t.(json.Delim); !ok || d != '[' {
+			return errors.Errorf("expected JSON array, but got %v", t)
+		}
+		r.started = true
+	}
+	if !r.dec.More() {
+		t, err := r.dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if d, ok := t.(json.Delim); !ok || d != ']' {
+			return errors.Errorf("expected end of JSON array, but got %v", t)
+		}
+		r.done = true
+		return io.EOF
+	}
+	return unexpectedEOF(r.dec.Decode(v))
+}
+
+// Close closes the stream
+func (r *JSONArrayReader) Close() error {
+	return r.body.Close()
+}
+
+func unexpectedEOF(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return errors.WithStack(err)
+}
+
+// SSEEvent is the event of Server-Sent Events stream
+type SSEEvent struct {
+	// ID is the event ID
+	ID string
+	// Event is the event type, empty for "message" events
+	Event string
+	// Data is the event data
+	Data string
+	// Retry is the reconnection time hint from the server
+	Retry time.Duration
+}
+
+// Decode decodes JSON data of the event
+func (e *SSEEvent) Decode(v interface{}) error {
+	return errors.WithStack(json.Unmarshal([]byte(e.Data), v))
+}
+
+// SSEReader reads Server-Sent Events stream
+type SSEReader struct {
+	body        io.ReadCloser
+	rd          *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewSSEReader returns the reader of text/event-stream
+func NewSSEReader(body io.ReadCloser) *SSEReader {
+	return &SSEReader{
+		body: body,
+		rd:   bufio.NewReader(body),
+	}
+}
+
+// LastEventID returns the ID of the last received event,
+// the client should specify it in Last-Event-ID header when reconnecting
+func (r *SSEReader) LastEventID() string {
+	return r.lastEventID
+}
+
+// Retry returns the last reconnection time hint from the server
+func (r *SSEReader) Retry() time.Duration {
+	return r.retry
+}
+
+// Next returns the next event of the stream, the comments are skipped,
+// io.EOF is returned at the end of the stream
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	e := &SSEEvent{}
+	var data []string
+	hasData := false
+
+	for {
+		line, err := r.rd.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				// the incomplete event is discarded
+				return nil, io.EOF
+			}
+			return nil, errors.WithStack(err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if !hasData {
+				// dispatch only events with data
+				e = &SSEEvent{}
+				continue
+			}
+			e.ID = r.lastEventID
+			e.Data = strings.Join(data, "\n")
+			return e, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			e.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms >= 0 {
+				r.retry = time.Duration(ms) * time.Millisecond
+				e.Retry = r.retry
+			}
+		}
+	}
+}
+
+// Close closes the stream
+func (r *SSEReader) Close() error {
+	return r.body.Close()
+}
+
+// DefaultSSERetry specifies the default reconnection delay of SSEStream,
+// if not specified by the server
+const DefaultSSERetry = 3 * time.Second
+
+// SSEStream reads Server-Sent Events stream,
+// and reconnects when the stream is closed or failed,
+// sending the ID of the last received event in Last-Event-ID header
+type SSEStream struct {
+	c     *Client
+	ctx   context.Context
+	hosts []string
+	path  string
+
+	rd          *SSEReader
+	lastEventID string
+	retry       time.Duration
+}
+
+// SSEStream returns the stream of Server-Sent Events from the specified hosts,
+// the connection is established by the first call to Next.
+// The stream is stopped when ctx is cancelled,
+// or when the server responds with 204 No Content.
+func (c *Client) SSEStream(ctx context.Context, hosts []string, path string) *SSEStream {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &SSEStream{
+		c:     c,
+		ctx:   ctx,
+		hosts: hosts,
+		path:  path,
+		retry: DefaultSSERetry,
+	}
+}
+
+// LastEventID returns the ID of the last received event
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Next returns the next event of the stream, reconnecting if needed,
+// io.EOF is returned if the server responds with 204 No Content,
+// the error is returned if the server responds with error status, or ctx is cancelled
+func (s *SSEStream) Next() (*SSEEvent, error) {
+	for {
+		if s.rd == nil {
+			if err := s.connect(); err != nil {
+				return nil, err
+			}
+		}
+
+		e, err := s.rd.Next()
+		s.lastEventID = s.rd.LastEventID()
+		if r := s.rd.Retry(); r > 0 {
+			s.retry = r
+		}
+		if err == nil {
+			return e, nil
+		}
+
+		s.rd.Close()
+		s.rd = nil
+		logger.Infof("reason=reconnect, path=%q, last_event_id=%q, retry=%v, err=[%v]",
+			s.path, s.lastEventID, s.retry, err.Error())
+
+		select {
+		case <-s.ctx.Done():
+			return nil, errors.WithStack(s.ctx.Err())
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+func (s *SSEStream) connect() error {
+	headers := map[string]string{}
+	if values, ok := s.ctx.Value(contextValueForHTTPHeader).(map[string]string); ok {
+		for key, val := range values {
+			headers[key] = val
+		}
+	}
+	headers[header.Accept] = header.TextEventStream
+	if s.lastEventID != "" {
+		headers[header.LastEventID] = s.lastEventID
+	}
+
+	resp, err := s.c.RequestStream(WithHeaders(s.ctx, headers), http.MethodGet, s.hosts, s.path, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		resp.Body.Close()
+		return io.EOF
+	}
+
+	s.rd = NewSSEReader(resp.Body)
+	s.rd.lastEventID = s.lastEventID
+	return nil
+}
+
+// Close closes the stream
+func (s *SSEStream) Close() error {
+	if s.rd == nil {
+		return nil
+	}
+	err := s.rd.Close()
+	s.rd = nil
+	return err
+}
@@ -0,0 +1,275 @@
+package retriable_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xhttp/retriable"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamItem struct {
+	ID int `json:"id"`
+}
+
+func streamServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/ndjson", func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewNDJSONWriter(w, r)
+		defer s.Close()
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, s.Write(&streamItem{ID: i}))
+		}
+	})
+	mux.HandleFunc("/v1/array", func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewJSONArrayWriter(w, r)
+		defer s.Close()
+		for i := 1; i <= 20; i++ {
+			require.NoError(t, s.Write(&streamItem{ID: i}))
+		}
+	})
+	mux.HandleFunc("/v1/array/truncated", func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewJSONArrayWriter(w, r)
+		defer s.Close()
+		require.NoError(t, s.Write(&streamItem{ID: 1}))
+		s.WriteError(httperror.WithUnexpected("db failed"))
+	})
+	mux.HandleFunc("/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewSSEWriter(w, r)
+		defer s.Close()
+		s.Heartbeat(time.Millisecond)
+		require.NoError(t, s.Comment("connected"))
+		require.NoError(t, s.Send(&marshal.SSEEvent{ID: r.Header.Get(header.LastEventID) + "1", Event: "item", Data: &streamItem{ID: 1}, Retry: time.Second}))
+		require.NoError(t, s.Send(&marshal.SSEEvent{Data: "line1\nline2"}))
+		s.WriteError(httperror.WithNotFound("no more items"))
+	})
+	mux.HandleFunc("/v1/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewNDJSONWriter(w, r)
+		s.WriteError(httperror.WithForbidden("not allowed"))
+	})
+
+	var h http.Handler = mux
+	h = xhttp.NewRequestMetrics(h)
+	h = xhttp.NewRequestLogger(h, "stream", nil, time.Millisecond, "")
+	return httptest.NewServer(h)
+}
+
+func Test_RequestStream(t *testing.T) {
+	server := streamServer(t)
+	defer server.Close()
+
+	client := retriable.New()
+	ctx := context.Background()
+	hosts := []string{server.URL}
+
+	t.Run("ndjson", func(t *testing.T) {
+		resp, err := client.RequestStream(ctx, http.MethodGet, hosts, "/v1/ndjson", nil)
+		require.NoError(t, err)
+		assert.Equal(t, header.ApplicationNDJSON, resp.Header.Get(header.ContentType))
+
+		rd := retriable.NewNDJSONReader(resp.Body)
+		defer rd.Close()
+
+		var items []streamItem
+		for {
+			var item streamItem
+			err = rd.Next(&item)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			items = append(items, item)
+		}
+		assert.Equal(t, []streamItem{{1}, {2}, {3}}, items)
+	})
+
+	t.Run("array", func(t *testing.T) {
+		resp, err := client.RequestStream(ctx, http.MethodGet, hosts, "/v1/array", nil)
+		require.NoError(t, err)
+
+		rd := retriable.NewJSONArrayReader(resp.Body)
+		defer rd.Close()
+
+		count := 0
+		for {
+			var item streamItem
+			err = rd.Next(&item)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			count++
+			assert.Equal(t, count, item.ID)
+		}
+		assert.Equal(t, 20, count)
+		assert.Equal(t, io.EOF, rd.Next(&streamItem{}))
+	})
+
+	t.Run("array_truncated", func(t *testing.T) {
+		resp, err := client.RequestStream(ctx, http.MethodGet, hosts, "/v1/array/truncated", nil)
+		require.NoError(t, err)
+
+		rd := retriable.NewJSONArrayReader(resp.Body)
+		defer rd.Close()
+
+		var item streamItem
+		require.NoError(t, rd.Next(&item))
+		err = rd.Next(&item)
+		require.Error(t, err)
+		assert.NotEqual(t, io.EOF, err)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		ctx := retriable.WithHeaders(ctx, map[string]string{
+			header.Accept:      header.TextEventStream,
+			header.LastEventID: "4",
+		})
+		resp, err := client.RequestStream(ctx, http.MethodGet, hosts, "/v1/events", nil)
+		require.NoError(t, err)
+		assert.Equal(t, header.TextEventStream, resp.Header.Get(header.ContentType))
+
+		rd := retriable.NewSSEReader(resp.Body)
+		defer rd.Close()
+
+		e, err := rd.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "41", e.ID)
+		assert.Equal(t, "item", e.Event)
+		assert.Equal(t, time.Second, e.Retry)
+		var item streamItem
+		require.NoError(t, e.Decode(&item))
+		assert.Equal(t, 1, item.ID)
+
+		e, err = rd.Next()
+		require.NoError(t, err)
+		assert.Equal(t, &retriable.SSEEvent{ID: "41", Data: "line1\nline2"}, e)
+
+		e, err = rd.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "error", e.Event)
+		var apiErr httperror.Error
+		require.NoError(t, e.Decode(&apiErr))
+		assert.Equal(t, httperror.NotFound, apiErr.Code)
+
+		_, err = rd.Next()
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, "41", rd.LastEventID())
+		assert.Equal(t, time.Second, rd.Retry())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := client.RequestStream(ctx, http.MethodGet, hosts, "/v1/forbidden", nil)
+		require.Error(t, err)
+		assert.Equal(t, &httperror.Error{HTTPStatus: http.StatusForbidden, Code: httperror.Forbidden, Message: "not allowed"}, err)
+	})
+}
+
+func Test_SSEReader(t *testing.T) {
+	stream := "retry: 500\n\n" +
+		": comment\r\n" +
+		"id: 1\r\n" +
+		"event: add\r\n" +
+		"data\r\n" +
+		"data:two\r\n\r\n" +
+		"id: 2\n\n" +
+		"data: incomplete"
+
+	rd := retriable.NewSSEReader(ioutil.NopCloser(strings.NewReader(stream)))
+	e, err := rd.Next()
+	require.NoError(t, err)
+	assert.Equal(t, &retriable.SSEEvent{ID: "1", Event: "add", Data: "\ntwo"}, e)
+	assert.Equal(t, 500*time.Millisecond, rd.Retry())
+
+	_, err = rd.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "2", rd.LastEventID())
+	require.NoError(t, rd.Close())
+}
+
+func Test_RequestStreamTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := marshal.NewNDJSONWriter(w, r)
+		defer s.Close()
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, s.Write(&streamItem{ID: i}))
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// the stream is longer than the client's timeout
+	client := retriable.New().WithTimeout(150 * time.Millisecond)
+	resp, err := client.RequestStream(nil, http.MethodGet, []string{server.URL}, "/v1/ndjson", nil)
+	require.NoError(t, err)
+
+	rd := retriable.NewNDJSONReader(resp.Body)
+	defer rd.Close()
+
+	count := 0
+	for {
+		var item streamItem
+		err = rd.Next(&item)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 3, count)
+}
+
+func Test_SSEStream(t *testing.T) {
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last := r.Header.Get(header.LastEventID)
+		lastEventIDs = append(lastEventIDs, last)
+		if len(last) >= 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s := marshal.NewSSEWriter(w, r)
+		defer s.Close()
+		// one event per connection
+		require.NoError(t, s.Send(&marshal.SSEEvent{ID: last + "1", Data: &streamItem{ID: len(last) + 1}, Retry: time.Millisecond}))
+	}))
+	defer server.Close()
+
+	ctx := retriable.WithHeaders(context.Background(), map[string]string{"X-Test": "stream"})
+	s := retriable.New().SSEStream(ctx, []string{server.URL}, "/v1/events")
+	defer s.Close()
+
+	for i := 1; i <= 3; i++ {
+		e, err := s.Next()
+		require.NoError(t, err)
+		var item streamItem
+		require.NoError(t, e.Decode(&item))
+		assert.Equal(t, i, item.ID)
+	}
+	_, err := s.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "111", s.LastEventID())
+	assert.Equal(t, []string{"", "1", "11", "111"}, lastEventIDs)
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := retriable.New().SSEStream(ctx, []string{server.URL}, "/v1/events")
+		defer s.Close()
+
+		_, err := s.Next()
+		require.NoError(t, err)
+		cancel()
+		_, err = s.Next()
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,49 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type upstreamContextKey struct{}
+
+// upstreamTimer accumulates the time spent in the upstream calls
+// made while serving the request
+type upstreamTimer struct {
+	nanos int64
+}
+
+// withUpstreamTimer returns the request with upstreamTimer in the context
+func withUpstreamTimer(r *http.Request) (*http.Request, *upstreamTimer) {
+	if t, ok := r.Context().Value(upstreamContextKey{}).(*upstreamTimer); ok {
+		return r, t
+	}
+	t := &upstreamTimer{}
+	return r.WithContext(context.WithValue(r.Context(), upstreamContextKey{}, t)), t
+}
+
+// AddUpstreamDuration adds the duration of the upstream call,
+// made while serving the request with the context,
+// the total duration is reported by the request logger
+func AddUpstreamDuration(ctx context.Context, d time.Duration) {
+	if ctx == nil {
+		return
+	}
+	if t, ok := ctx.Value(upstreamContextKey{}).(*upstreamTimer); ok {
+		atomic.AddInt64(&t.nanos, int64(d))
+	}
+}
+
+// UpstreamDuration returns the total duration of the upstream calls,
+// made while serving the request with the context
+func UpstreamDuration(ctx context.Context) time.Duration {
+	if ctx == nil {
+		return 0
+	}
+	if t, ok := ctx.Value(upstreamContextKey{}).(*upstreamTimer); ok {
+		return time.Duration(atomic.LoadInt64(&t.nanos))
+	}
+	return 0
+}
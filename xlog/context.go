@@ -0,0 +1,52 @@
+package xlog
+
+import (
+	"context"
+	"sync"
+)
+
+// ContextValuesProvider returns key-value pairs from the context to be logged,
+// for example the trace and span IDs
+type ContextValuesProvider func(ctx context.Context) []interface{}
+
+var (
+	contextLock      sync.RWMutex
+	contextProviders []ContextValuesProvider
+)
+
+// RegisterContextValuesProvider registers the provider of key-value pairs,
+// which are added to the logger returned by WithContext
+func RegisterContextValuesProvider(p ContextValuesProvider) {
+	contextLock.Lock()
+	defer contextLock.Unlock()
+	contextProviders = append(contextProviders, p)
+}
+
+// ContextValues returns key-value pairs from the context,
+// provided by the registered providers
+func ContextValues(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	contextLock.RLock()
+	defer contextLock.RUnlock()
+
+	var values []interface{}
+	for _, p := range contextProviders {
+		values = append(values, p(ctx)...)
+	}
+	return values
+}
+
+// WithContext returns the logger with key-value pairs from the context,
+// such as the trace and span IDs.
+// The package loggers do not read the context,
+// the request-scoped logs must use the returned logger.
+func WithContext(ctx context.Context, l Logger) Logger {
+	values := ContextValues(ctx)
+	if len(values) == 0 {
+		return l
+	}
+	return l.WithValues(values...)
+}
@@ -0,0 +1,42 @@
+package xlog_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+type testContextKey struct{}
+
+func Test_WithContext(t *testing.T) {
+	xlog.RegisterContextValuesProvider(func(ctx context.Context) []interface{} {
+		if v, ok := ctx.Value(testContextKey{}).(string); ok {
+			return []interface{}{"test_id", v}
+		}
+		return nil
+	})
+
+	assert.Empty(t, xlog.ContextValues(nil))
+	assert.Empty(t, xlog.ContextValues(context.Background()))
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "123")
+	assert.Equal(t, []interface{}{"test_id", "123"}, xlog.ContextValues(ctx))
+
+	var b bytes.Buffer
+	writer := bufio.NewWriter(&b)
+	xlog.SetFormatter(xlog.NewStringFormatter(writer).WithCaller(true))
+	xlog.SetGlobalLogLevel(xlog.INFO)
+
+	assert.Equal(t, logger, xlog.WithContext(context.Background(), logger))
+
+	xlog.WithContext(ctx, logger).Infof("Test Info")
+	assert.Contains(t, b.String(), ` xlog_test: src=Test_WithContext, test_id="123", Test Info`)
+	b.Reset()
+
+	xlog.WithContext(ctx, logger).KV(xlog.INFO, "status", "ok")
+	assert.Contains(t, b.String(), ` xlog_test: src=Test_WithContext, test_id="123", status="ok"`)
+}
@@ -15,6 +15,7 @@
 package xlog
 
 import (
+	"sort"
 	"strings"
 	"sync"
 
@@ -158,6 +159,29 @@ func MustRepoLogger(repo string) RepoLogger {
 	return r
 }
 
+// GetRepos returns the names of the repositories registered with PackageLogger.
+func GetRepos() []string {
+	logger.Lock()
+	defer logger.Unlock()
+	list := make([]string, 0, len(logger.repoMap))
+	for repo := range logger.repoMap {
+		list = append(list, repo)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// LogLevels returns a map of package names within a repository to their current loglevel.
+func (r RepoLogger) LogLevels() map[string]LogLevel {
+	logger.Lock()
+	defer logger.Unlock()
+	out := make(map[string]LogLevel, len(r))
+	for k, v := range r {
+		out[k] = v.level
+	}
+	return out
+}
+
 // SetRepoLogLevel sets the log level for all packages in the repository.
 func (r RepoLogger) SetRepoLogLevel(l LogLevel) {
 	logger.Lock()
@@ -124,4 +124,13 @@ func Test_GetRepoLogger(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, xlog.NOTICE, mm["pkg2"])
 	assert.Equal(t, xlog.DEBUG, mm["pkg3"])
+
+	assert.Equal(t, map[string]xlog.LogLevel{
+		"pkg2": xlog.DEBUG,
+		"pkg3": xlog.TRACE,
+	}, r.LogLevels())
+
+	repos := xlog.GetRepos()
+	assert.Contains(t, repos, "repo1")
+	assert.Contains(t, repos, "repo2")
 }
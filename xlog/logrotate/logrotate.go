@@ -90,3 +90,60 @@ func (c *logrotator) Close() error {
 	}
 	return nil
 }
+
+type rotatingWriter struct {
+	file    *lumberjack.Logger
+	buf     *bufio.Writer
+	channel *ChannelWriter
+	closed  bool
+}
+
+// NewWriter creates a lumberjack log rotator, without redirecting logs output to it,
+// which can be used for the additional log streams, for example the request captures.
+// If buffered, the writes are done by a background go routine
+// and flushed to the file at least every second.
+// Call Close() on the returned writer to flush buffered data and close the file.
+func NewWriter(logFolder, baseFilename string, maxAge, maxSize int, buffered bool) (io.WriteCloser, error) {
+	err := os.MkdirAll(logFolder, 0755)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	w := &rotatingWriter{
+		file: &lumberjack.Logger{
+			Filename: filepath.Join(logFolder, baseFilename+".log"),
+			MaxAge:   maxAge,
+			MaxSize:  maxSize,
+		},
+	}
+	if buffered {
+		w.buf = bufio.NewWriterSize(w.file, 8192)
+		w.channel = NewChannelWriter(w.buf, 256, time.Second)
+	}
+	return w, nil
+}
+
+// Write implements the io.Writer interface
+func (w *rotatingWriter) Write(d []byte) (int, error) {
+	if w.channel != nil {
+		if w.channel.IsStopped() {
+			return 0, errors.New("already closed")
+		}
+		return w.channel.Write(d)
+	}
+	return w.file.Write(d)
+}
+
+// Close will ensure that queued/buffered but unwritten entries are flushed to disk
+func (w *rotatingWriter) Close() error {
+	if w.closed {
+		return errors.New("already closed")
+	}
+	w.closed = true
+
+	if w.channel != nil {
+		// Stop flushes the buffer
+		w.channel.Stop()
+	}
+	return errors.WithStack(w.file.Close())
+}
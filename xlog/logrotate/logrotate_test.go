@@ -3,6 +3,8 @@ package logrotate_test
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -41,3 +43,27 @@ func Test_Rotate(t *testing.T) {
 	writer.Flush()
 	assert.NotEmpty(t, b.Bytes())
 }
+
+func Test_NewWriter(t *testing.T) {
+	tmpDir := filepath.Join(os.TempDir(), "tests", "logrotate")
+
+	for _, buffered := range []bool{false, true} {
+		name := fmt.Sprintf("writer_%t", buffered)
+		filename := filepath.Join(tmpDir, name+".log")
+		os.Remove(filename)
+
+		w, err := logrotate.NewWriter(tmpDir, name, 1, 1, buffered)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("line1\n"))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("line2\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.EqualError(t, w.Close(), "already closed")
+
+		b, err := ioutil.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "line1\nline2\n", string(b), name)
+	}
+}
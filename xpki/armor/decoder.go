@@ -0,0 +1,73 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Decoder reads armored blocks from a stream.
+// Only the current block is kept in memory,
+// the text outside of the blocks, as well as the corrupted blocks, are skipped.
+type Decoder struct {
+	r     *bufio.Reader
+	block bytes.Buffer
+	end   []byte
+	err   error
+}
+
+// NewDecoder returns a Decoder, which reads armored blocks from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+var beginPrefix = pemStart[1:]
+
+// Next returns the next valid block from the stream,
+// or io.EOF if there are no more blocks.
+func (d *Decoder) Next() (*Block, error) {
+	for d.err == nil {
+		var line []byte
+		line, d.err = d.r.ReadBytes('\n')
+		if d.err != nil && d.err != io.EOF {
+			d.err = errors.WithStack(d.err)
+			return nil, d.err
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		trimmed := bytes.TrimRight(line, " \t\r\n")
+		if bytes.HasPrefix(trimmed, beginPrefix) && bytes.HasSuffix(trimmed, pemEndOfLine) {
+			// start of the block, previous incomplete block is discarded
+			blockType := trimmed[len(beginPrefix) : len(trimmed)-len(pemEndOfLine)]
+			d.end = append(append(append(d.end[:0], pemEnd[1:]...), blockType...), pemEndOfLine...)
+			d.block.Reset()
+		} else if d.end == nil {
+			// outside of the block
+			continue
+		}
+
+		d.block.Write(trimmed)
+		d.block.WriteByte('\n')
+
+		if bytes.Equal(trimmed, d.end) {
+			d.end = nil
+			p, _ := Decode(d.block.Bytes())
+			d.block.Reset()
+			if p != nil {
+				return p, nil
+			}
+			logger.Debug("reason=skip_invalid_block")
+		}
+	}
+
+	if d.err == io.EOF {
+		return nil, io.EOF
+	}
+	return nil, d.err
+}
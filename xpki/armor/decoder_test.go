@@ -0,0 +1,118 @@
+package armor_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-phorce/dolly/xpki/armor"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Decoder(t *testing.T) {
+	cases := []struct {
+		file  string
+		count int
+	}{
+		{file: "testdata/RPM-GPG-KEY-CentOS-7", count: 1},
+		{file: "testdata/test-gpg-keys-2", count: 2},
+		{file: "testdata/test-gpg-keys-2 corrupted1", count: 1},
+		{file: "testdata/test-gpg-keys-2 corrupted2", count: 1},
+		{file: "testdata/test-gpg-keys-2 corrupted3", count: 0},
+		{file: "testdata/test-gpg-keys-2 corrupted4", count: 0},
+		{file: "testdata/test-gpg-keys-2 corrupted5", count: 0},
+		{file: "testdata/test-gpg-keys-2 corrupted6", count: 0},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.file, func(t *testing.T) {
+			f, err := os.Open(cs.file)
+			require.NoError(t, err)
+			defer f.Close()
+
+			d := armor.NewDecoder(f)
+			count := 0
+			for {
+				block, err := d.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				require.NotNil(t, block)
+				assert.Equal(t, "PGP PUBLIC KEY BLOCK", block.Type)
+				count++
+			}
+			assert.Equal(t, cs.count, count)
+
+			// EOF is sticky
+			_, err = d.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func Test_Decoder_MatchesDecode(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/test-gpg-keys-2")
+	require.NoError(t, err)
+
+	var expected []*armor.Block
+	for rest := data; len(rest) > 0; {
+		var b *armor.Block
+		b, rest = armor.Decode(rest)
+		if b == nil {
+			break
+		}
+		expected = append(expected, b)
+	}
+
+	d := armor.NewDecoder(bytes.NewReader(data))
+	for _, exp := range expected {
+		b, err := d.Next()
+		require.NoError(t, err)
+		assert.Equal(t, exp, b)
+	}
+	_, err = d.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func Test_Decoder_Encoded(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("junk before\n")
+	for _, s := range []string{"first", "second", "third"} {
+		require.NoError(t, armor.Encode(&buf, &armor.Block{
+			Type:    "PGP MESSAGE",
+			Headers: map[string]string{"Comment": s},
+			Bytes:   []byte(strings.Repeat(s, 50)),
+		}))
+		buf.WriteString("junk between\n")
+	}
+	// incomplete block at the end
+	buf.WriteString("-----BEGIN PGP MESSAGE-----\n\nabcd\n")
+
+	d := armor.NewDecoder(&buf)
+	for _, s := range []string{"first", "second", "third"} {
+		b, err := d.Next()
+		require.NoError(t, err)
+		assert.Equal(t, s, b.Headers["Comment"])
+		assert.Equal(t, strings.Repeat(s, 50), string(b.Bytes))
+	}
+	_, err := d.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func Test_Decoder_ReadError(t *testing.T) {
+	d := armor.NewDecoder(errReader{})
+	_, err := d.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, errors.Cause(err))
+}
@@ -0,0 +1,176 @@
+package armor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const lineLength = 64
+
+// lineBreaker breaks the base64 output into lines of lineLength
+type lineBreaker struct {
+	out  io.Writer
+	line [lineLength]byte
+	used int
+}
+
+func (l *lineBreaker) Write(b []byte) (n int, err error) {
+	n = len(b)
+	for len(b) > 0 {
+		c := copy(l.line[l.used:], b)
+		l.used += c
+		b = b[c:]
+		if l.used == lineLength {
+			if _, err = l.out.Write(l.line[:]); err != nil {
+				return 0, err
+			}
+			if _, err = l.out.Write(newline); err != nil {
+				return 0, err
+			}
+			l.used = 0
+		}
+	}
+	return n, nil
+}
+
+func (l *lineBreaker) Close() (err error) {
+	if l.used > 0 {
+		if _, err = l.out.Write(l.line[:l.used]); err != nil {
+			return err
+		}
+		if _, err = l.out.Write(newline); err != nil {
+			return err
+		}
+		l.used = 0
+	}
+	return nil
+}
+
+var newline = []byte{'\n'}
+
+// encoder implements io.WriteCloser for the armored block
+type encoder struct {
+	out       io.Writer
+	breaker   *lineBreaker
+	b64       io.WriteCloser
+	blockType string
+	crc       uint32
+	closed    bool
+}
+
+// NewEncoder returns a WriteCloser, which encodes the written data
+// as an OpenPGP armored block of the specified type.
+// The header lines are written immediately, and the data is encoded
+// as it is written, without buffering the whole content in memory.
+// Close must be called to write the checksum and the END line,
+// it does not close the underlying writer.
+func NewEncoder(out io.Writer, blockType string, headers map[string]string) (io.WriteCloser, error) {
+	if blockType == "" {
+		return nil, errors.New("invalid parameter: blockType")
+	}
+
+	err := writeHeader(out, blockType, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encoder{
+		out:       out,
+		breaker:   &lineBreaker{out: out},
+		blockType: blockType,
+		crc:       crc24Init,
+	}
+	e.b64 = base64.NewEncoder(base64.StdEncoding, e.breaker)
+	return e, nil
+}
+
+func (e *encoder) Write(data []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("armor: encoder is closed")
+	}
+	e.crc = crc24(e.crc, data)
+	return e.b64.Write(data)
+}
+
+func (e *encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	err := e.b64.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = e.breaker.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	crc := e.crc & crc24Mask
+	checksum := []byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}
+
+	var buf bytes.Buffer
+	buf.WriteByte('=')
+	buf.WriteString(base64.StdEncoding.EncodeToString(checksum))
+	buf.WriteString("\n-----END ")
+	buf.WriteString(e.blockType)
+	buf.WriteString("-----\n")
+
+	_, err = e.out.Write(buf.Bytes())
+	return errors.WithStack(err)
+}
+
+func writeHeader(out io.Writer, blockType string, headers map[string]string) error {
+	var buf bytes.Buffer
+	buf.WriteString("-----BEGIN ")
+	buf.WriteString(blockType)
+	buf.WriteString("-----\n")
+
+	// sort headers for deterministic output
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteString(": ")
+		buf.WriteString(headers[k])
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	_, err := out.Write(buf.Bytes())
+	return errors.WithStack(err)
+}
+
+// Encode writes the armored encoding of b to out
+func Encode(out io.Writer, b *Block) error {
+	if b == nil {
+		return errors.New("invalid parameter: block")
+	}
+	w, err := NewEncoder(out, b.Type, b.Headers)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(b.Bytes); err != nil {
+		return errors.WithStack(err)
+	}
+	return w.Close()
+}
+
+// EncodeToMemory returns the armored encoding of b.
+// If b has invalid type, nil is returned.
+func EncodeToMemory(b *Block) []byte {
+	var buf bytes.Buffer
+	if err := Encode(&buf, b); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,84 @@
+package armor_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-phorce/dolly/xpki/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xarmor "golang.org/x/crypto/openpgp/armor"
+)
+
+func Test_ArmorEncode(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 47, 48, 49, 1024, 4099} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+
+		b := &armor.Block{
+			Type: "PGP MESSAGE",
+			Headers: map[string]string{
+				"Version": "dolly",
+				"Comment": "test",
+			},
+			Bytes: data,
+		}
+
+		encoded := armor.EncodeToMemory(b)
+		require.NotEmpty(t, encoded)
+
+		decoded, rest := armor.Decode(encoded)
+		require.NotNil(t, decoded, "size=%d:\n%s", size, string(encoded))
+		assert.Empty(t, rest)
+		assert.Equal(t, b.Type, decoded.Type)
+		assert.Equal(t, b.Headers, decoded.Headers)
+		assert.Equal(t, data, decoded.Bytes)
+
+		// compatibility with x/crypto
+		xb, err := xarmor.Decode(bytes.NewReader(encoded))
+		require.NoError(t, err, "size=%d", size)
+		assert.Equal(t, b.Type, xb.Type)
+		xdata, err := ioutil.ReadAll(xb.Body)
+		require.NoError(t, err, "size=%d", size)
+		assert.Equal(t, data, append([]byte{}, xdata...))
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := armor.NewEncoder(&buf, "PGP SIGNATURE", nil)
+		require.NoError(t, err)
+
+		var expected []byte
+		for i := 0; i < 100; i++ {
+			chunk := []byte("chunk of data to encode\n")
+			expected = append(expected, chunk...)
+			_, err = w.Write(chunk)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, w.Close())
+
+		_, err = w.Write([]byte("after close"))
+		assert.Error(t, err)
+
+		for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+			assert.LessOrEqual(t, len(line), 64)
+		}
+
+		decoded, _ := armor.Decode(buf.Bytes())
+		require.NotNil(t, decoded)
+		assert.Equal(t, "PGP SIGNATURE", decoded.Type)
+		assert.Empty(t, decoded.Headers)
+		assert.Equal(t, expected, decoded.Bytes)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := armor.NewEncoder(&bytes.Buffer{}, "", nil)
+		assert.EqualError(t, err, "invalid parameter: blockType")
+		assert.Error(t, armor.Encode(&bytes.Buffer{}, nil))
+		assert.Nil(t, armor.EncodeToMemory(&armor.Block{}))
+	})
+}
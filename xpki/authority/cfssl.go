@@ -0,0 +1,110 @@
+package authority
+
+import (
+	"encoding/json"
+
+	"github.com/go-phorce/dolly/xpki/csr"
+	"github.com/pkg/errors"
+)
+
+// cfsslConfig provides CFSSL format of the ca-config
+type cfsslConfig struct {
+	Signing *struct {
+		Default  *cfsslProfile            `json:"default"`
+		Profiles map[string]*cfsslProfile `json:"profiles"`
+	} `json:"signing"`
+}
+
+// cfsslProfile provides CFSSL format of the signing profile
+type cfsslProfile struct {
+	Usage        []string      `json:"usages"`
+	Expiry       csr.Duration  `json:"expiry"`
+	Backdate     csr.Duration  `json:"backdate"`
+	OCSPNoCheck  bool          `json:"ocsp_no_check"`
+	NameAllowed  string        `json:"name_whitelist"`
+	CSRAllowed   *cfsslAllowed `json:"csr_whitelist"`
+	CAConstraint struct {
+		IsCA           bool `json:"is_ca"`
+		MaxPathLen     int  `json:"max_path_len"`
+		MaxPathLenZero bool `json:"max_path_len_zero"`
+	} `json:"ca_constraint"`
+	AllowedExtensions []csr.OID `json:"allowed_extensions"`
+	Policies          []struct {
+		ID         csr.OID                          `json:"ID"`
+		Qualifiers []csr.CertificatePolicyQualifier `json:"qualifiers"`
+	} `json:"policies"`
+}
+
+// cfsslAllowed provides CFSSL format of the allowed CSR fields
+type cfsslAllowed struct {
+	Subject        bool
+	DNSNames       bool
+	IPAddresses    bool
+	EmailAddresses bool
+	URIs           bool
+}
+
+// parseCFSSLProfiles returns profiles from ca-config in CFSSL format,
+// or nil if the "signing" section is not present
+func parseCFSSLProfiles(body []byte) (map[string]*CertProfile, error) {
+	var cfg cfsslConfig
+	err := json.Unmarshal(body, &cfg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if cfg.Signing == nil {
+		return nil, nil
+	}
+
+	profiles := make(map[string]*CertProfile)
+	for name, p := range cfg.Signing.Profiles {
+		if p != nil {
+			profiles[name] = p.toCertProfile()
+		}
+	}
+	if cfg.Signing.Default != nil {
+		profiles["default"] = cfg.Signing.Default.toCertProfile()
+	}
+	return profiles, nil
+}
+
+func (p *cfsslProfile) toCertProfile() *CertProfile {
+	profile := &CertProfile{
+		Usage:             p.Usage,
+		Expiry:            p.Expiry,
+		Backdate:          p.Backdate,
+		OCSPNoCheck:       p.OCSPNoCheck,
+		AllowedNames:      p.NameAllowed,
+		AllowedExtensions: p.AllowedExtensions,
+		CAConstraint: CAConstraint{
+			IsCA:       p.CAConstraint.IsCA,
+			MaxPathLen: p.CAConstraint.MaxPathLen,
+		},
+	}
+
+	if profile.CAConstraint.IsCA &&
+		profile.CAConstraint.MaxPathLen == 0 &&
+		!p.CAConstraint.MaxPathLenZero {
+		// CFSSL does not limit the path length in this case
+		profile.CAConstraint.MaxPathLen = -1
+	}
+
+	if p.CSRAllowed != nil {
+		profile.AllowedCSRFields = &csr.AllowedFields{
+			Subject:        p.CSRAllowed.Subject,
+			DNSNames:       p.CSRAllowed.DNSNames,
+			IPAddresses:    p.CSRAllowed.IPAddresses,
+			EmailAddresses: p.CSRAllowed.EmailAddresses,
+			URIs:           p.CSRAllowed.URIs,
+		}
+	}
+
+	for _, policy := range p.Policies {
+		profile.Policies = append(profile.Policies, csr.CertificatePolicy{
+			ID:         policy.ID,
+			Qualifiers: policy.Qualifiers,
+		})
+	}
+
+	return profile
+}
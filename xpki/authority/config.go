@@ -261,6 +261,7 @@ func DefaultCertProfile() *CertProfile {
 
 // LoadConfig loads the configuration file stored at the path
 // and returns the configuration.
+// JSON files in CFSSL ca-config format are supported as well.
 func LoadConfig(path string) (*Config, error) {
 	if path == "" {
 		return nil, errors.New("invalid path")
@@ -274,6 +275,10 @@ func LoadConfig(path string) (*Config, error) {
 	var cfg = new(Config)
 	if strings.HasSuffix(path, ".json") {
 		err = json.Unmarshal(body, cfg)
+		if err == nil && len(cfg.Profiles) == 0 {
+			// try to load ca-config in CFSSL format
+			cfg.Profiles, err = parseCFSSLProfiles(body)
+		}
 	} else {
 		err = yaml.Unmarshal(body, cfg)
 	}
@@ -93,6 +93,45 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadCFSSLConfig(t *testing.T) {
+	cfg, err := authority.LoadConfig("testdata/ca-config.cfssl.json")
+	require.NoError(t, err)
+	require.Len(t, cfg.Profiles, 5)
+
+	def := cfg.DefaultCertProfile()
+	require.NotNil(t, def)
+	assert.Equal(t, 8760*time.Hour, def.Expiry.TimeDuration())
+	assert.Equal(t, []string{"signing", "key encipherment"}, def.Usage)
+
+	server := cfg.Profiles["server"]
+	require.NotNil(t, server)
+	assert.Equal(t, 30*time.Minute, server.Backdate.TimeDuration())
+	assert.Equal(t, "^localhost$", server.AllowedNames)
+	assert.NotNil(t, server.AllowedNamesRegex)
+	require.NotNil(t, server.AllowedCSRFields)
+	assert.True(t, server.AllowedCSRFields.Subject)
+	assert.True(t, server.AllowedCSRFields.DNSNames)
+	assert.False(t, server.AllowedCSRFields.IPAddresses)
+
+	ca := cfg.Profiles["CA"]
+	require.NotNil(t, ca)
+	assert.True(t, ca.CAConstraint.IsCA)
+	assert.Equal(t, 1, ca.CAConstraint.MaxPathLen)
+
+	root := cfg.Profiles["ROOT"]
+	require.NotNil(t, root)
+	assert.True(t, root.CAConstraint.IsCA)
+	assert.Equal(t, -1, root.CAConstraint.MaxPathLen)
+
+	ts := cfg.Profiles["timestamp"]
+	require.NotNil(t, ts)
+	assert.True(t, ts.IsAllowedExtention(csr.OID{2, 5, 29, 37}))
+	require.Len(t, ts.Policies, 1)
+	assert.Equal(t, "1.2.1000.1", ts.Policies[0].ID.String())
+	require.Len(t, ts.Policies[0].Qualifiers, 1)
+	assert.Equal(t, csr.CpsQualifierType, ts.Policies[0].Qualifiers[0].Type)
+}
+
 func TestCertProfile(t *testing.T) {
 	p := authority.CertProfile{
 		Expiry:       csr.OneYear,
@@ -1,11 +1,11 @@
 package authority
 
 import (
-	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 
+	"github.com/go-phorce/dolly/xpki/certutil"
 	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/pkg/errors"
 )
@@ -98,23 +98,5 @@ func addPolicies(template *x509.Certificate, policies []csr.CertificatePolicy) e
 // standard manner. This is done by computing the SHA-1 digest of the
 // SubjectPublicKeyInfo component of the certificate.
 func computeSKI(template *x509.Certificate) ([]byte, error) {
-	pub := template.PublicKey
-	encodedPub, err := x509.MarshalPKIXPublicKey(pub)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	var subPKI subjectPublicKeyInfo
-	_, err = asn1.Unmarshal(encodedPub, &subPKI)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	pubHash := sha1.Sum(subPKI.SubjectPublicKey.Bytes)
-	return pubHash[:], nil
-}
-
-type subjectPublicKeyInfo struct {
-	Algorithm        pkix.AlgorithmIdentifier
-	SubjectPublicKey asn1.BitString
+	return certutil.SubjectKeyID(template.PublicKey)
 }
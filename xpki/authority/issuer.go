@@ -402,7 +402,7 @@ func (ca *Issuer) sign(template *x509.Certificate) ([]byte, error) {
 		caCert = ca.bundle.Cert
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, template.PublicKey, ca.signer)
+	derBytes, err := certutil.CreateCertificate(template, caCert, template.PublicKey, ca.signer)
 	if err != nil {
 		return nil, errors.WithMessagef(err, "create certificate")
 	}
@@ -2,6 +2,7 @@ package authority
 
 import (
 	"crypto"
+	"time"
 
 	"github.com/go-phorce/dolly/xpki/cryptoprov"
 	"github.com/go-phorce/dolly/xpki/csr"
@@ -75,3 +76,60 @@ func NewRoot(profile string, cfg *Config, provider cryptoprov.Provider, req *csr
 	}
 	return
 }
+
+// DefaultCAProfile returns a default configuration
+// for a self-signed CA certificate profile,
+// specifying cert and CRL signing usages and a 5 years expiration time.
+func DefaultCAProfile() *CertProfile {
+	return &CertProfile{
+		Description: "default CA profile",
+		Usage:       []string{"cert sign", "crl sign"},
+		Expiry:      5 * csr.OneYear,
+		CAConstraint: CAConstraint{
+			IsCA:       true,
+			MaxPathLen: -1,
+		},
+	}
+}
+
+// MakeCAPolicy returns CA policy configuration from the given certificate request,
+// the policy contains the "default" profile to sign self-signed CA certificate
+func MakeCAPolicy(req *csr.CertificateRequest) (*Config, error) {
+	profile := DefaultCAProfile()
+	cfg := &Config{
+		Profiles: map[string]*CertProfile{
+			"default": profile,
+		},
+	}
+	if req.CA == nil {
+		return cfg, nil
+	}
+
+	if req.CA.Expiry != "" {
+		expiry, err := time.ParseDuration(req.CA.Expiry)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		profile.Expiry = csr.Duration(expiry)
+	}
+
+	if req.CA.Backdate != "" {
+		backdate, err := time.ParseDuration(req.CA.Backdate)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		profile.Backdate = csr.Duration(backdate)
+	}
+
+	switch {
+	case req.CA.PathLength != 0:
+		if req.CA.PathLenZero {
+			logger.Infof("reason=ignore_pathlenzero, pathlen=%d", req.CA.PathLength)
+		}
+		profile.CAConstraint.MaxPathLen = req.CA.PathLength
+	case req.CA.PathLenZero:
+		profile.CAConstraint.MaxPathLen = 0
+	}
+
+	return cfg, nil
+}
@@ -1,15 +1,24 @@
 package authority_test
 
 import (
+	"crypto"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-phorce/dolly/algorithms/guid"
 	"github.com/go-phorce/dolly/xpki/authority"
 	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/certutil/testscheme"
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
 	"github.com/go-phorce/dolly/xpki/cryptoprov/inmemcrypto"
+	"github.com/go-phorce/dolly/xpki/cryptoprov/testprov"
 	"github.com/go-phorce/dolly/xpki/csr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -113,3 +122,200 @@ func TestNewRootEx(t *testing.T) {
 	_, err = cert.Verify(opts)
 	require.NoError(t, err, "failed to verify certificate")
 }
+
+func TestMakeCAPolicy(t *testing.T) {
+	req := &csr.CertificateRequest{}
+	cfg, err := authority.MakeCAPolicy(req)
+	require.NoError(t, err)
+	def := cfg.DefaultCertProfile()
+	require.NotNil(t, def)
+	assert.NoError(t, def.Validate())
+	assert.True(t, def.CAConstraint.IsCA)
+	assert.Equal(t, -1, def.CAConstraint.MaxPathLen)
+	assert.Equal(t, 5*csr.OneYear, def.Expiry)
+
+	req.CA = &csr.CAConfig{
+		PathLength: 2,
+		Expiry:     "8760h",
+		Backdate:   "1h",
+	}
+	cfg, err = authority.MakeCAPolicy(req)
+	require.NoError(t, err)
+	def = cfg.DefaultCertProfile()
+	assert.Equal(t, 2, def.CAConstraint.MaxPathLen)
+	assert.Equal(t, csr.OneYear, def.Expiry)
+	assert.Equal(t, time.Hour, def.Backdate.TimeDuration())
+
+	req.CA = &csr.CAConfig{
+		PathLenZero: true,
+	}
+	cfg, err = authority.MakeCAPolicy(req)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.DefaultCertProfile().CAConstraint.MaxPathLen)
+
+	req.CA = &csr.CAConfig{
+		Expiry: "invalid",
+	}
+	_, err = authority.MakeCAPolicy(req)
+	assert.EqualError(t, err, `time: invalid duration "invalid"`)
+
+	req.CA = &csr.CAConfig{
+		Backdate: "invalid",
+	}
+	_, err = authority.MakeCAPolicy(req)
+	assert.EqualError(t, err, `time: invalid duration "invalid"`)
+}
+
+func TestNewRootAndIssuerFromCFSSL(t *testing.T) {
+	rootCSR := `{
+		"CN": "[TEST] Dolly Root CA",
+		"key": {
+			"algo": "ecdsa",
+			"size": 256
+		},
+		"names": [
+			{
+				"C": "US",
+				"O": "go-phorce",
+				"OU": "dolly-dev"
+			}
+		],
+		"ca": {
+			"pathlen": 1,
+			"expiry": "8760h"
+		}
+	}`
+
+	defprov := inmemcrypto.NewProvider()
+	crypto, err := cryptoprov.New(defprov, nil)
+	require.NoError(t, err)
+
+	prov := csr.NewProvider(defprov)
+	req := csr.CertificateRequest{
+		KeyRequest: prov.NewKeyRequest("TestNewRootAndIssuerFromCFSSL", "RSA", 2048, csr.SigningKey),
+	}
+	err = json.Unmarshal([]byte(rootCSR), &req)
+	require.NoError(t, err)
+
+	policy, err := authority.MakeCAPolicy(&req)
+	require.NoError(t, err)
+
+	certPEM, _, key, err := authority.NewRoot("default", policy, defprov, &req)
+	require.NoError(t, err)
+
+	root, err := certutil.ParseFromPEM(certPEM)
+	require.NoError(t, err)
+	assert.Equal(t, "[TEST] Dolly Root CA", root.Subject.CommonName)
+	assert.Equal(t, 1, root.MaxPathLen)
+	assert.Equal(t, x509.ECDSA, root.PublicKeyAlgorithm)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(caKeyFile, key, 0600))
+
+	cfg, err := authority.LoadConfig("testdata/ca-config.cfssl.json")
+	require.NoError(t, err)
+
+	issuer, err := authority.NewIssuer(&authority.IssuerConfig{
+		CertFile: caFile,
+		KeyFile:  caKeyFile,
+		Profiles: cfg.Profiles,
+	}, crypto)
+	require.NoError(t, err)
+
+	serverReq := prov.NewSigningCertificateRequest("TestNewRootAndIssuerFromCFSSL-server", "ECDSA", 256, "localhost", nil, []string{"localhost"})
+	csrPEM, _, _, _, err := prov.CreateRequestAndExportKey(serverReq)
+	require.NoError(t, err)
+
+	crt, _, err := issuer.Sign(csr.SignRequest{
+		Request: string(csrPEM),
+		Profile: "server",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", crt.Subject.CommonName)
+	assert.Equal(t, []string{"localhost"}, crt.DNSNames)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, crt.ExtKeyUsage)
+	assert.NoError(t, crt.CheckSignatureFrom(root))
+}
+
+func TestNewRootAndIssuerWithSignatureScheme(t *testing.T) {
+	testscheme.Register()
+
+	tp, err := testprov.Init()
+	require.NoError(t, err)
+	prov := csr.NewProvider(tp)
+
+	req := csr.CertificateRequest{
+		CommonName: "[TEST] PQ Root CA",
+		KeyRequest: prov.NewKeyRequest("TestNewRootWithSignatureScheme", testscheme.AlgorithmName, 0, csr.SigningKey),
+	}
+
+	certPEM, csrPEM, _, err := authority.NewRoot("ROOT", rootCfg, tp, &req)
+	require.NoError(t, err)
+
+	_, err = csr.ParsePEM(csrPEM)
+	require.NoError(t, err)
+
+	root, err := certutil.ParseFromPEM(certPEM)
+	require.NoError(t, err)
+	assert.Equal(t, req.CommonName, root.Subject.CommonName)
+	assert.Equal(t, x509.UnknownSignatureAlgorithm, root.SignatureAlgorithm)
+	assert.True(t, root.IsCA)
+	require.NoError(t, certutil.CheckSignatureFrom(root, root))
+
+	pub, err := certutil.PublicKey(root)
+	require.NoError(t, err)
+	assert.IsType(t, &testscheme.PublicKey{}, pub)
+
+	// issuer with PQ key
+	caReq := csr.CertificateRequest{
+		CommonName: "[TEST] PQ Issuing CA",
+		KeyRequest: prov.NewKeyRequest("TestIssuerWithSignatureScheme", testscheme.AlgorithmName, 0, csr.SigningKey),
+	}
+	_, caKey, _, err := prov.GenerateKeyAndRequest(&caReq)
+	require.NoError(t, err)
+	signer := caKey.(crypto.Signer)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: caReq.CommonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := certutil.CreateCertificate(template, template, signer.Public(), signer)
+	require.NoError(t, err)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	cfg, err := authority.LoadConfig("testdata/ca-config.cfssl.json")
+	require.NoError(t, err)
+
+	issuer, err := authority.CreateIssuer(&authority.IssuerConfig{Profiles: cfg.Profiles}, caPEM, nil, nil, signer)
+	require.NoError(t, err)
+
+	for _, algo := range []string{"ECDSA", testscheme.AlgorithmName} {
+		t.Run(algo, func(t *testing.T) {
+			serverReq := csr.CertificateRequest{
+				CommonName: "localhost",
+				SAN:        []string{"localhost"},
+				KeyRequest: prov.NewKeyRequest("TestIssuerWithSignatureScheme-"+algo, algo, 256, csr.SigningKey),
+			}
+			csrPEM, _, _, err := prov.GenerateKeyAndRequest(&serverReq)
+			require.NoError(t, err)
+
+			crt, _, err := issuer.Sign(csr.SignRequest{
+				Request: string(csrPEM),
+				Profile: "server",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "localhost", crt.Subject.CommonName)
+			assert.Equal(t, []string{"localhost"}, crt.DNSNames)
+			assert.Equal(t, issuer.Bundle().Cert.SubjectKeyId, crt.AuthorityKeyId)
+			assert.NoError(t, certutil.CheckSignatureFrom(crt, issuer.Bundle().Cert))
+		})
+	}
+}
@@ -58,8 +58,14 @@ func (b *Bundle) ExpiresInHours() time.Duration {
 	return b.Expires.Sub(time.Now().UTC()) / time.Hour * time.Hour
 }
 
-// VerifyBundleFromPEM constructs and verifies the cert chain
+// VerifyBundleFromPEM constructs and verifies the cert chain.
+// The chains with the algorithms of registered signature schemes are verified
+// by CheckSignatureFrom, as they are not supported by crypto/x509.
 func VerifyBundleFromPEM(certPEM, intCAPEM, rootPEM []byte) (bundle *Bundle, status *BundleStatus, err error) {
+	if usesSignatureScheme(certPEM, intCAPEM, rootPEM) {
+		return verifySchemeBundle(certPEM, intCAPEM, rootPEM)
+	}
+
 	b, err := bundler.NewBundlerFromPEM(rootPEM, intCAPEM)
 	if err != nil {
 		err = errors.WithMessage(err, "failed to create bundler")
@@ -0,0 +1,165 @@
+package certutil
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxChainLength limits the length of the chain to build
+const maxChainLength = 10
+
+// expiringPeriod specifies the period to report expiring certificates
+const expiringPeriod = 30 * 24 * time.Hour
+
+// usesSignatureScheme returns true if any of the certificates
+// uses a key or signature of the registered signature schemes
+func usesSignatureScheme(pems ...[]byte) bool {
+	schemes.lock.RLock()
+	registered := len(schemes.list) > 0
+	schemes.lock.RUnlock()
+	if !registered {
+		return false
+	}
+
+	for _, p := range pems {
+		certs, err := ParseChainFromPEM(p)
+		if err != nil {
+			return false
+		}
+		for _, crt := range certs {
+			if isSchemeCertificate(crt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSchemeCertificate returns true if the certificate
+// uses a key or signature of the registered signature schemes
+func isSchemeCertificate(crt *x509.Certificate) bool {
+	if crt.PublicKey == nil {
+		var spki publicKeyInfo
+		if _, err := asn1.Unmarshal(crt.RawSubjectPublicKeyInfo, &spki); err == nil &&
+			SignatureSchemeByOID(spki.Algorithm.Algorithm) != nil {
+			return true
+		}
+	}
+	if crt.SignatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		var c certificate
+		if _, err := asn1.Unmarshal(crt.Raw, &c); err == nil &&
+			SignatureSchemeByOID(c.SignatureAlgorithm.Algorithm) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySchemeBundle constructs and verifies the cert chain,
+// which uses algorithms of the registered signature schemes
+func verifySchemeBundle(certPEM, intCAPEM, rootPEM []byte) (*Bundle, *BundleStatus, error) {
+	certs, err := ParseChainFromPEM(certPEM)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to bundle")
+	}
+	if len(certs) == 0 {
+		return nil, nil, errors.New("failed to bundle: certificate not found")
+	}
+	intermediates, err := ParseChainFromPEM(intCAPEM)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to create bundler")
+	}
+	roots, err := ParseChainFromPEM(rootPEM)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to create bundler")
+	}
+
+	crt := certs[0]
+	pool := append(certs[1:], intermediates...)
+	chain := []*x509.Certificate{crt}
+	status := &BundleStatus{}
+	now := time.Now()
+	expires := crt.NotAfter
+
+	var root *x509.Certificate
+	for c := crt; ; {
+		if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+			return nil, nil, errors.Errorf("failed to bundle: certificate is expired or not yet valid: CN=%q", c.Subject.CommonName)
+		}
+		if c.NotAfter.Before(expires) {
+			expires = c.NotAfter
+		}
+		if c.NotAfter.Sub(now) < expiringPeriod {
+			status.ExpiringSKIs = append(status.ExpiringSKIs, GetSubjectKeyID(c))
+		}
+
+		if r := findParent(c, roots); r != nil {
+			if err = CheckSignatureFrom(c, r); err != nil {
+				return nil, nil, errors.WithMessagef(err, "failed to bundle: CN=%q", c.Subject.CommonName)
+			}
+			if !bytes.Equal(c.Raw, r.Raw) {
+				root = r
+			}
+			break
+		}
+		if bytes.Equal(c.RawIssuer, c.RawSubject) {
+			// self-signed certificate is not trusted
+			break
+		}
+
+		parent := findParent(c, pool)
+		if parent == nil || len(chain) >= maxChainLength {
+			break
+		}
+		if err = CheckSignatureFrom(c, parent); err != nil {
+			return nil, nil, errors.WithMessagef(err, "failed to bundle: CN=%q", c.Subject.CommonName)
+		}
+		chain = append(chain, parent)
+		c = parent
+	}
+
+	if len(roots) > 0 && root == nil {
+		return nil, nil, errors.Errorf("failed to bundle: unable to verify the chain to the root: CN=%q", crt.Subject.CommonName)
+	}
+
+	var pemCert, pemRoot, pemCA string
+	pemCert, _ = EncodeToPEMString(true, crt)
+	if root != nil {
+		pemRoot, _ = EncodeToPEMString(true, root)
+	}
+	if len(chain) > 1 {
+		pemCA, _ = EncodeToPEMString(true, chain[1:]...)
+	}
+
+	bundle := &Bundle{
+		Chain:       chain,
+		Cert:        crt,
+		RootCert:    root,
+		IssuerCert:  FindIssuer(crt, chain, root),
+		Issuer:      &crt.Issuer,
+		IssuerID:    GetIssuerID(crt),
+		Subject:     &crt.Subject,
+		SubjectID:   GetSubjectID(crt),
+		Expires:     expires,
+		Hostnames:   crt.DNSNames,
+		CertPEM:     pemCert,
+		CACertsPEM:  pemCA,
+		RootCertPEM: pemRoot,
+	}
+
+	return bundle, status, nil
+}
+
+// findParent returns the issuer of the certificate from the list
+func findParent(crt *x509.Certificate, list []*x509.Certificate) *x509.Certificate {
+	for _, c := range list {
+		if c != nil && bytes.Equal(crt.RawIssuer, c.RawSubject) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,473 @@
+package certutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"sync"
+
+	"github.com/go-phorce/dolly/xpki/oid"
+	"github.com/pkg/errors"
+)
+
+// SignatureScheme provides a signature algorithm, which is not supported by crypto/x509,
+// such as post-quantum or composite signatures.
+// The scheme is used to create and verify certificates and certificate requests,
+// when the public key belongs to the scheme.
+type SignatureScheme interface {
+	// Algorithm returns the signature algorithm info
+	Algorithm() *oid.SignatureAlgorithmInfo
+	// IsPublicKey returns true, if the public key belongs to the scheme
+	IsPublicKey(pub crypto.PublicKey) bool
+	// MarshalPublicKey returns DER encoded SubjectPublicKeyInfo of the key
+	MarshalPublicKey(pub crypto.PublicKey) ([]byte, error)
+	// ParsePublicKey returns the public key from DER encoded SubjectPublicKeyInfo
+	ParsePublicKey(der []byte) (crypto.PublicKey, error)
+	// SignerOpts returns options for crypto.Signer
+	SignerOpts() crypto.SignerOpts
+	// Verify verifies the signature of the signed data
+	Verify(pub crypto.PublicKey, signed, signature []byte) error
+}
+
+var schemes = struct {
+	lock sync.RWMutex
+	list []SignatureScheme
+}{}
+
+// RegisterSignatureScheme registers the signature scheme,
+// the name of the algorithm is registered in oid package as well
+func RegisterSignatureScheme(scheme SignatureScheme) error {
+	info := scheme.Algorithm()
+	if info == nil || len(info.OID()) == 0 {
+		return errors.New("invalid signature algorithm")
+	}
+
+	if oid.LookupByOID(info.String()) == nil {
+		_, err := oid.Register(oid.Registration{
+			Name:         info.Name(),
+			Type:         oid.AlgSig,
+			OID:          info.OID(),
+			Registration: info.Registration(),
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	schemes.lock.Lock()
+	defer schemes.lock.Unlock()
+
+	for i, s := range schemes.list {
+		if s.Algorithm().OID().Equal(info.OID()) {
+			schemes.list[i] = scheme
+			return nil
+		}
+	}
+	schemes.list = append(schemes.list, scheme)
+	return nil
+}
+
+// SignatureSchemeForPublicKey returns the registered signature scheme for the key,
+// or nil if the key is supported by crypto/x509
+func SignatureSchemeForPublicKey(pub crypto.PublicKey) SignatureScheme {
+	if pub == nil {
+		return nil
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return nil
+	}
+
+	schemes.lock.RLock()
+	defer schemes.lock.RUnlock()
+	for _, s := range schemes.list {
+		if s.IsPublicKey(pub) {
+			return s
+		}
+	}
+	return nil
+}
+
+// SignatureSchemeByOID returns the registered signature scheme
+// by OID of the signature or public key algorithm
+func SignatureSchemeByOID(algo asn1.ObjectIdentifier) SignatureScheme {
+	schemes.lock.RLock()
+	defer schemes.lock.RUnlock()
+	for _, s := range schemes.list {
+		info := s.Algorithm()
+		if info.OID().Equal(algo) ||
+			(info.PublicKeyAlgorithm != nil && info.PublicKeyAlgorithm.OID().Equal(algo)) {
+			return s
+		}
+	}
+	return nil
+}
+
+// MarshalPublicKey returns DER encoded SubjectPublicKeyInfo of the key
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	if scheme := SignatureSchemeForPublicKey(pub); scheme != nil {
+		return scheme.MarshalPublicKey(pub)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return der, nil
+}
+
+// ParsePublicKey returns the public key from DER encoded SubjectPublicKeyInfo
+func ParsePublicKey(der []byte) (crypto.PublicKey, error) {
+	var spki publicKeyInfo
+	_, err := asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if scheme := SignatureSchemeByOID(spki.Algorithm.Algorithm); scheme != nil {
+		return scheme.ParsePublicKey(der)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pub, nil
+}
+
+// PublicKey returns the public key of the certificate,
+// including the keys of the registered signature schemes
+func PublicKey(crt *x509.Certificate) (crypto.PublicKey, error) {
+	if crt.PublicKey != nil {
+		return crt.PublicKey, nil
+	}
+	return ParsePublicKey(crt.RawSubjectPublicKeyInfo)
+}
+
+// SubjectKeyID returns SHA1 hash of the public key,
+// as specified in RFC 5280, 4.2.1.2 method (1)
+func SubjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var spki publicKeyInfo
+	_, err = asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h := sha1.Sum(spki.PublicKey.RightAlign())
+	return h[:], nil
+}
+
+// CreateCertificate creates a new certificate based on a template,
+// see x509.CreateCertificate.
+// In addition to the keys supported by crypto/x509,
+// the public key and the signer can belong to a registered signature scheme.
+func CreateCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	subjectScheme := SignatureSchemeForPublicKey(pub)
+	signerScheme := SignatureSchemeForPublicKey(signer.Public())
+	if subjectScheme == nil && signerScheme == nil {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return der, nil
+	}
+
+	// crypto/x509 does not support the keys,
+	// so the certificate is created with a placeholder key,
+	// then the public key and the signature are replaced
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	selfSigned := template == parent
+	tmpl := *template
+	par := *parent
+	tbsPub := pub
+	var tbsSigner crypto.Signer = signer
+
+	if subjectScheme != nil {
+		tbsPub = &placeholder.PublicKey
+		if len(tmpl.SubjectKeyId) == 0 {
+			tmpl.SubjectKeyId, err = SubjectKeyID(pub)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if selfSigned {
+			par.SubjectKeyId = tmpl.SubjectKeyId
+		}
+	}
+	if signerScheme != nil {
+		tbsSigner = placeholder
+		tmpl.SignatureAlgorithm = x509.ECDSAWithSHA256
+		par.PublicKey = &placeholder.PublicKey
+	} else if selfSigned {
+		par.PublicKey = signer.Public()
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &par, tbsPub, tbsSigner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var crt certificate
+	_, err = asn1.Unmarshal(der, &crt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var tbs tbsCertificate
+	_, err = asn1.Unmarshal(crt.TBSCertificate.FullBytes, &tbs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if subjectScheme != nil {
+		spki, err := subjectScheme.MarshalPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		tbs.PublicKey = asn1.RawValue{FullBytes: spki}
+	}
+
+	sigAlgo := crt.SignatureAlgorithm
+	var opts crypto.SignerOpts
+	if signerScheme != nil {
+		sigAlgo = pkix.AlgorithmIdentifier{Algorithm: signerScheme.Algorithm().OID()}
+		opts = signerScheme.SignerOpts()
+	} else {
+		// the algorithm is selected by crypto/x509 for the signer
+		parsed, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		opts, err = signerOptsForX509(parsed.SignatureAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tbs.SignatureAlgorithm = sigAlgo
+
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	signature, err := signWithOpts(signer, tbsDER, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err = asn1.Marshal(certificate{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: sigAlgo,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return der, nil
+}
+
+// CreateCertificateRequest creates a new certificate request based on a template,
+// see x509.CreateCertificateRequest.
+// In addition to the keys supported by crypto/x509,
+// the signer can belong to a registered signature scheme.
+func CreateCertificateRequest(template *x509.CertificateRequest, signer crypto.Signer) ([]byte, error) {
+	scheme := SignatureSchemeForPublicKey(signer.Public())
+	if scheme == nil {
+		der, err := x509.CreateCertificateRequest(rand.Reader, template, signer)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return der, nil
+	}
+
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tmpl := *template
+	tmpl.SignatureAlgorithm = x509.ECDSAWithSHA256
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &tmpl, placeholder)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var req certificateRequest
+	_, err = asn1.Unmarshal(der, &req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var tbs tbsCertificateRequest
+	_, err = asn1.Unmarshal(req.TBSCSR.FullBytes, &tbs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	spki, err := scheme.MarshalPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	tbs.PublicKey = asn1.RawValue{FullBytes: spki}
+
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	signature, err := signWithOpts(signer, tbsDER, scheme.SignerOpts())
+	if err != nil {
+		return nil, err
+	}
+
+	der, err = asn1.Marshal(certificateRequest{
+		TBSCSR:             asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: scheme.Algorithm().OID()},
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return der, nil
+}
+
+// CheckSignatureFrom verifies that the signature on crt is a valid signature from parent,
+// see x509.Certificate.CheckSignatureFrom.
+// In addition to the algorithms supported by crypto/x509,
+// the signature can be created by a registered signature scheme.
+func CheckSignatureFrom(crt, parent *x509.Certificate) error {
+	if crt.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		return errors.WithStack(crt.CheckSignatureFrom(parent))
+	}
+
+	if parent.Version == 3 && !parent.BasicConstraintsValid ||
+		parent.BasicConstraintsValid && !parent.IsCA {
+		return errors.WithStack(x509.ConstraintViolationError{})
+	}
+	if parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.WithStack(x509.ConstraintViolationError{})
+	}
+
+	var c certificate
+	_, err := asn1.Unmarshal(crt.Raw, &c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return checkSchemeSignature(c.SignatureAlgorithm.Algorithm, parent.RawSubjectPublicKeyInfo, crt.RawTBSCertificate, crt.Signature)
+}
+
+// CheckCertificateRequestSignature verifies the signature on the certificate request,
+// see x509.CertificateRequest.CheckSignature.
+// In addition to the algorithms supported by crypto/x509,
+// the signature can be created by a registered signature scheme.
+func CheckCertificateRequestSignature(req *x509.CertificateRequest) error {
+	if req.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		return errors.WithStack(req.CheckSignature())
+	}
+
+	var c certificateRequest
+	_, err := asn1.Unmarshal(req.Raw, &c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return checkSchemeSignature(c.SignatureAlgorithm.Algorithm, req.RawSubjectPublicKeyInfo, req.RawTBSCertificateRequest, req.Signature)
+}
+
+func checkSchemeSignature(algo asn1.ObjectIdentifier, spki, signed, signature []byte) error {
+	scheme := SignatureSchemeByOID(algo)
+	if scheme == nil {
+		return errors.WithStack(x509.ErrUnsupportedAlgorithm)
+	}
+
+	pub, err := ParsePublicKey(spki)
+	if err != nil {
+		return err
+	}
+	if !scheme.IsPublicKey(pub) {
+		return errors.Errorf("signature algorithm %s does not match the public key %T",
+			scheme.Algorithm().Name(), pub)
+	}
+	return scheme.Verify(pub, signed, signature)
+}
+
+func signWithOpts(signer crypto.Signer, data []byte, opts crypto.SignerOpts) ([]byte, error) {
+	digest := data
+	if h := opts.HashFunc(); h != 0 {
+		digest = Digest(h, data)
+	}
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign")
+	}
+	return signature, nil
+}
+
+// signerOptsForX509 returns signer options for x509 signature algorithm
+func signerOptsForX509(algo x509.SignatureAlgorithm) (crypto.SignerOpts, error) {
+	switch algo {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1:
+		return crypto.SHA1, nil
+	case x509.SHA256WithRSA, x509.ECDSAWithSHA256:
+		return crypto.SHA256, nil
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384:
+		return crypto.SHA384, nil
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512:
+		return crypto.SHA512, nil
+	case x509.SHA256WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+	case x509.SHA384WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, nil
+	case x509.SHA512WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, nil
+	case x509.PureEd25519:
+		return crypto.Hash(0), nil
+	}
+	return nil, errors.Errorf("unsupported signature algorithm: %s", algo)
+}
+
+type certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	UniqueID           asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+type certificateRequest struct {
+	TBSCSR             asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificateRequest struct {
+	Version       int
+	Subject       asn1.RawValue
+	PublicKey     asn1.RawValue
+	RawAttributes []asn1.RawValue `asn1:"tag:0"`
+}
+
+type publicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
@@ -0,0 +1,217 @@
+package certutil_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/certutil/testscheme"
+	"github.com/go-phorce/dolly/xpki/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testscheme.Register()
+}
+
+func makeTemplate(cn string, isCA bool, serial int64) *x509.Certificate {
+	t := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour * 365),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		t.KeyUsage = x509.KeyUsageDigitalSignature
+		t.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		t.DNSNames = []string{cn}
+	}
+	return t
+}
+
+func toPEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func Test_SignatureScheme(t *testing.T) {
+	scheme := certutil.SignatureSchemeByOID(testscheme.OID)
+	require.NotNil(t, scheme)
+	assert.Equal(t, testscheme.AlgorithmName, scheme.Algorithm().Name())
+
+	info := oid.LookupByName(testscheme.AlgorithmName)
+	require.NotNil(t, info)
+	assert.Equal(t, testscheme.OID.String(), info.String())
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.Nil(t, certutil.SignatureSchemeForPublicKey(ecKey.Public()))
+	assert.Nil(t, certutil.SignatureSchemeForPublicKey(nil))
+
+	pqKey, err := testscheme.GenerateKey()
+	require.NoError(t, err)
+	assert.Equal(t, scheme, certutil.SignatureSchemeForPublicKey(pqKey.Public()))
+
+	der, err := certutil.MarshalPublicKey(pqKey.Public())
+	require.NoError(t, err)
+	pub, err := certutil.ParsePublicKey(der)
+	require.NoError(t, err)
+	assert.Equal(t, pqKey.Public(), pub)
+
+	der, err = certutil.MarshalPublicKey(ecKey.Public())
+	require.NoError(t, err)
+	pub, err = certutil.ParsePublicKey(der)
+	require.NoError(t, err)
+	assert.Equal(t, ecKey.Public(), pub)
+
+	_, err = certutil.ParsePublicKey([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func Test_CreateCertificateWithScheme(t *testing.T) {
+	rootKey, err := testscheme.GenerateKey()
+	require.NoError(t, err)
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafKey, err := testscheme.GenerateKey()
+	require.NoError(t, err)
+
+	// PQ root
+	rootTmpl := makeTemplate("[TEST] PQ Root", true, 1)
+	der, err := certutil.CreateCertificate(rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	assert.Equal(t, x509.UnknownSignatureAlgorithm, root.SignatureAlgorithm)
+	assert.NotEmpty(t, root.SubjectKeyId)
+	require.NoError(t, certutil.CheckSignatureFrom(root, root))
+
+	pub, err := certutil.PublicKey(root)
+	require.NoError(t, err)
+	assert.Equal(t, rootKey.Public(), pub)
+
+	ski, err := certutil.SubjectKeyID(rootKey.Public())
+	require.NoError(t, err)
+	assert.Equal(t, ski, root.SubjectKeyId)
+
+	// ECDSA intermediate, signed by PQ root
+	caTmpl := makeTemplate("[TEST] ECDSA CA", true, 2)
+	der, err = certutil.CreateCertificate(caTmpl, root, caKey.Public(), rootKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	assert.Equal(t, caKey.Public(), ca.PublicKey)
+	assert.Equal(t, root.SubjectKeyId, ca.AuthorityKeyId)
+	require.NoError(t, certutil.CheckSignatureFrom(ca, root))
+
+	// PQ leaf, signed by ECDSA intermediate
+	leafTmpl := makeTemplate("leaf.example.com", false, 3)
+	der, err = certutil.CreateCertificate(leafTmpl, ca, leafKey.Public(), caKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	assert.Equal(t, x509.ECDSAWithSHA256, leaf.SignatureAlgorithm)
+	assert.Nil(t, leaf.PublicKey)
+	require.NoError(t, certutil.CheckSignatureFrom(leaf, ca))
+	// crypto/x509 verifies the classic signature
+	require.NoError(t, leaf.CheckSignatureFrom(ca))
+
+	pub, err = certutil.PublicKey(leaf)
+	require.NoError(t, err)
+	assert.Equal(t, leafKey.Public(), pub)
+
+	// wrong issuer
+	assert.Error(t, certutil.CheckSignatureFrom(ca, ca))
+	otherKey, err := testscheme.GenerateKey()
+	require.NoError(t, err)
+	der, err = certutil.CreateCertificate(rootTmpl, rootTmpl, otherKey.Public(), otherKey)
+	require.NoError(t, err)
+	other, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	assert.EqualError(t, certutil.CheckSignatureFrom(ca, other), "invalid signature")
+	assert.Error(t, certutil.CheckSignatureFrom(root, leaf))
+
+	t.Run("bundle", func(t *testing.T) {
+		bundle, status, err := certutil.VerifyBundleFromPEM(toPEM(leaf.Raw), toPEM(ca.Raw), toPEM(root.Raw))
+		require.NoError(t, err)
+		assert.False(t, status.IsUntrusted())
+		require.Len(t, bundle.Chain, 2)
+		assert.Equal(t, leaf, bundle.Cert)
+		assert.Equal(t, ca, bundle.IssuerCert)
+		assert.Equal(t, root, bundle.RootCert)
+		assert.Equal(t, []string{"leaf.example.com"}, bundle.Hostnames)
+		assert.NotEmpty(t, bundle.CACertsPEM)
+		assert.NotEmpty(t, bundle.RootCertPEM)
+
+		bundle, _, err = certutil.VerifyBundleFromPEM(toPEM(ca.Raw), nil, nil)
+		require.NoError(t, err)
+		require.Len(t, bundle.Chain, 1)
+		assert.Nil(t, bundle.RootCert)
+
+		_, _, err = certutil.VerifyBundleFromPEM(toPEM(leaf.Raw), toPEM(ca.Raw), toPEM(other.Raw))
+		assert.Error(t, err)
+	})
+}
+
+func Test_CreateCertificateRequestWithScheme(t *testing.T) {
+	key, err := testscheme.GenerateKey()
+	require.NoError(t, err)
+
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "pq.example.com"},
+		DNSNames: []string{"pq.example.com"},
+	}
+	der, err := certutil.CreateCertificateRequest(template, key)
+	require.NoError(t, err)
+
+	req, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	assert.Equal(t, "pq.example.com", req.Subject.CommonName)
+	assert.Equal(t, []string{"pq.example.com"}, req.DNSNames)
+	require.NoError(t, certutil.CheckCertificateRequestSignature(req))
+
+	pub, err := certutil.ParsePublicKey(req.RawSubjectPublicKeyInfo)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), pub)
+
+	// tampered signature
+	req.Signature[0] ^= 0xff
+	assert.EqualError(t, certutil.CheckCertificateRequestSignature(req), "invalid signature")
+
+	// classic
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err = certutil.CreateCertificateRequest(template, ecKey)
+	require.NoError(t, err)
+	req, err = x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	require.NoError(t, certutil.CheckCertificateRequestSignature(req))
+}
+
+func Test_RegisterSignatureScheme(t *testing.T) {
+	// registering the same scheme again replaces it
+	require.NoError(t, certutil.RegisterSignatureScheme(testscheme.New()))
+	assert.Error(t, certutil.RegisterSignatureScheme(&invalidScheme{}))
+}
+
+type invalidScheme struct {
+	testscheme.Scheme
+}
+
+func (s *invalidScheme) Algorithm() *oid.SignatureAlgorithmInfo {
+	return nil
+}
+
+var _ crypto.Signer = (*testscheme.PrivateKey)(nil)
@@ -0,0 +1,145 @@
+// Package testscheme provides a signature scheme, which is not supported by crypto/x509,
+// to test the plumbing of post-quantum and composite signature algorithms.
+// The scheme uses Ed25519 keys under a private OID.
+package testscheme
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/oid"
+	"github.com/pkg/errors"
+)
+
+// AlgorithmName specifies the name of the test algorithm
+const AlgorithmName = "TEST-ED25519"
+
+// OID specifies the private OID of the test algorithm,
+// which is used for both public key and signature
+var OID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 100, 1}
+
+// PublicKey of the test scheme
+type PublicKey struct {
+	Key ed25519.PublicKey
+}
+
+// PrivateKey of the test scheme
+type PrivateKey struct {
+	key ed25519.PrivateKey
+	pub *PublicKey
+}
+
+// GenerateKey returns a new private key
+func GenerateKey() (*PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &PrivateKey{key: priv, pub: &PublicKey{Key: pub}}, nil
+}
+
+// Public returns the public key
+func (k *PrivateKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs the message, the message must not be pre-hashed
+func (k *PrivateKey) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != 0 {
+		return nil, errors.New("message must not be hashed")
+	}
+	return ed25519.Sign(k.key, message), nil
+}
+
+// Scheme implements certutil.SignatureScheme
+type Scheme struct {
+	info *oid.SignatureAlgorithmInfo
+}
+
+// New returns the test scheme
+func New() *Scheme {
+	return &Scheme{
+		info: oid.NewSignatureAlgorithmInfo(AlgorithmName, OID, "",
+			oid.NewPublicKeyAlgorithmInfo(AlgorithmName, OID, ""),
+			nil),
+	}
+}
+
+// Register registers the test scheme in certutil
+func Register() {
+	err := certutil.RegisterSignatureScheme(New())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Algorithm returns the signature algorithm info
+func (s *Scheme) Algorithm() *oid.SignatureAlgorithmInfo {
+	return s.info
+}
+
+// IsPublicKey returns true, if the public key belongs to the scheme
+func (s *Scheme) IsPublicKey(pub crypto.PublicKey) bool {
+	_, ok := pub.(*PublicKey)
+	return ok
+}
+
+type publicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// MarshalPublicKey returns DER encoded SubjectPublicKeyInfo of the key
+func (s *Scheme) MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	key, ok := pub.(*PublicKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported key: %T", pub)
+	}
+	der, err := asn1.Marshal(publicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: OID},
+		PublicKey: asn1.BitString{Bytes: key.Key, BitLength: len(key.Key) * 8},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return der, nil
+}
+
+// ParsePublicKey returns the public key from DER encoded SubjectPublicKeyInfo
+func (s *Scheme) ParsePublicKey(der []byte) (crypto.PublicKey, error) {
+	var spki publicKeyInfo
+	_, err := asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !spki.Algorithm.Algorithm.Equal(OID) {
+		return nil, errors.Errorf("unsupported algorithm: %s", spki.Algorithm.Algorithm)
+	}
+	key := spki.PublicKey.RightAlign()
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	return &PublicKey{Key: ed25519.PublicKey(key)}, nil
+}
+
+// SignerOpts returns options for crypto.Signer
+func (s *Scheme) SignerOpts() crypto.SignerOpts {
+	return crypto.Hash(0)
+}
+
+// Verify verifies the signature of the signed data
+func (s *Scheme) Verify(pub crypto.PublicKey, signed, signature []byte) error {
+	key, ok := pub.(*PublicKey)
+	if !ok {
+		return errors.Errorf("unsupported key: %T", pub)
+	}
+	if !ed25519.Verify(key.Key, signed, signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -37,6 +37,15 @@ type KeyGenerator interface {
 	GetKey(keyID string) (crypto.PrivateKey, error)
 }
 
+// AlgorithmKeyGenerator is an optional interface of the Provider
+// to generate keys for algorithms other than RSA and ECDSA,
+// such as post-quantum or composite keys.
+// The generated key must be supported by a signature scheme registered in certutil package.
+type AlgorithmKeyGenerator interface {
+	// GenerateKey returns key for algorithm name, size and purpose: 1-signing, 2-encryption
+	GenerateKey(label string, algo string, size int, purpose int) (crypto.PrivateKey, error)
+}
+
 // Provider defines an interface to work with crypto providers: HSM, SoftHSM, KMS, crytpto
 type Provider interface {
 	KeyGenerator
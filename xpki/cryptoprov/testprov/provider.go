@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/go-phorce/dolly/xpki/certutil/testscheme"
 	"github.com/pkg/errors"
 )
 
@@ -206,6 +207,32 @@ func (p *Provider) GenerateECDSAKey(label string, curve elliptic.Curve) (crypto.
 	return si, nil
 }
 
+// GenerateKey creates signer using randomly generated key of the test signature scheme,
+// see certutil/testscheme package
+func (p *Provider) GenerateKey(label string, algo string, size int, purpose int) (crypto.PrivateKey, error) {
+	if algo != testscheme.AlgorithmName {
+		return nil, errors.Errorf("unsupported algorithm: %s", algo)
+	}
+	key, err := testscheme.GenerateKey()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(label) == 0 {
+		label = fmt.Sprintf("%x", guid.MustCreate())
+	}
+
+	id := p.idGenerator.Generate()
+
+	si := &provImpl{
+		id:    id,
+		label: label,
+		pvk:   key,
+	}
+	p.inMemProv.registerKey(id, si)
+	return si, nil
+}
+
 // IdentifyKey returns key id and label for the given private key
 func (p *Provider) IdentifyKey(priv crypto.PrivateKey) (keyID, label string, err error) {
 	if ki, ok := priv.(*provImpl); ok {
@@ -0,0 +1,35 @@
+package csr
+
+import (
+	"encoding/json"
+)
+
+// certificateRequest is used to unmarshal CertificateRequest
+// without recursion into the custom UnmarshalJSON
+type certificateRequest CertificateRequest
+
+// UnmarshalJSON unmarshals CertificateRequest from JSON.
+// In addition to the native format, the CFSSL format of CSR is supported,
+// where "CN" specifies the Common Name, and "hosts" specifies SAN.
+func (r *CertificateRequest) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*certificateRequest
+		CN    string   `json:"CN"`
+		Hosts []string `json:"hosts"`
+	}{
+		certificateRequest: (*certificateRequest)(r),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if r.CommonName == "" {
+		r.CommonName = aux.CN
+	}
+	if len(r.SAN) == 0 {
+		r.SAN = aux.Hosts
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package csr_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-phorce/dolly/xpki/cryptoprov/inmemcrypto"
+	"github.com/go-phorce/dolly/xpki/csr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateRequestFromCFSSL(t *testing.T) {
+	cfsslCSR := `{
+		"CN": "[TEST] Root CA",
+		"hosts": ["localhost", "127.0.0.1"],
+		"key": {
+			"algo": "rsa",
+			"size": 4096
+		},
+		"names": [
+			{
+				"C": "US",
+				"L": "CA",
+				"O": "go-phorce",
+				"OU": "dolly-dev"
+			}
+		],
+		"ca": {
+			"pathlen": 2,
+			"expiry": "8760h"
+		}
+	}`
+
+	prov := csr.NewProvider(inmemcrypto.NewProvider())
+	req := csr.CertificateRequest{
+		KeyRequest: prov.NewKeyRequest("TestCertificateRequestFromCFSSL", "ECDSA", 256, csr.SigningKey),
+	}
+
+	err := json.Unmarshal([]byte(cfsslCSR), &req)
+	require.NoError(t, err)
+	assert.Equal(t, "[TEST] Root CA", req.CommonName)
+	assert.Equal(t, []string{"localhost", "127.0.0.1"}, req.SAN)
+	assert.Equal(t, "rsa", req.KeyRequest.Algo())
+	assert.Equal(t, 4096, req.KeyRequest.Size())
+	require.Len(t, req.Names, 1)
+	assert.Equal(t, "US", req.Names[0].C)
+	assert.Equal(t, "dolly-dev", req.Names[0].OU)
+	require.NotNil(t, req.CA)
+	assert.Equal(t, 2, req.CA.PathLength)
+	assert.Equal(t, "8760h", req.CA.Expiry)
+
+	nativeCSR := `{
+		"common_name": "localhost",
+		"CN": "ignored",
+		"san": ["localhost"],
+		"hosts": ["ignored"]
+	}`
+	req = csr.CertificateRequest{}
+	err = json.Unmarshal([]byte(nativeCSR), &req)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", req.CommonName)
+	assert.Equal(t, []string{"localhost"}, req.SAN)
+	assert.Nil(t, req.CA)
+
+	err = json.Unmarshal([]byte(`{"CN": 1}`), &req)
+	assert.Error(t, err)
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-phorce/dolly/xpki/certutil"
 	"github.com/pkg/errors"
 )
 
@@ -122,6 +123,16 @@ type CertificateRequest struct {
 	SAN []string `json:"san" yaml:"san"`
 	// KeyRequest for generated key
 	KeyRequest KeyRequest `json:"key,omitempty" yaml:"key,omitempty"`
+	// CA specifies optional configuration for a self-signed CA
+	CA *CAConfig `json:"ca,omitempty" yaml:"ca,omitempty"`
+}
+
+// CAConfig is a section used in the requests initialising a new CA.
+type CAConfig struct {
+	PathLength  int    `json:"pathlen" yaml:"pathlen"`
+	PathLenZero bool   `json:"pathlenzero" yaml:"pathlenzero"`
+	Expiry      string `json:"expiry" yaml:"expiry"`
+	Backdate    string `json:"backdate" yaml:"backdate"`
 }
 
 // Validate provides the default validation logic for certificate
@@ -188,11 +199,19 @@ func Parse(csrBytes []byte) (*x509.Certificate, error) {
 		return nil, errors.WithMessagef(err, "failed to parse")
 	}
 
-	err = csrv.CheckSignature()
+	err = certutil.CheckCertificateRequestSignature(csrv)
 	if err != nil {
 		return nil, errors.WithMessagef(err, "key mismatch")
 	}
 
+	if csrv.PublicKey == nil {
+		// the key of the registered signature scheme
+		csrv.PublicKey, err = certutil.ParsePublicKey(csrv.RawSubjectPublicKeyInfo)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to parse public key")
+		}
+	}
+
 	template := &x509.Certificate{
 		Subject:            csrv.Subject,
 		PublicKeyAlgorithm: csrv.PublicKeyAlgorithm,
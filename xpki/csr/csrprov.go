@@ -4,7 +4,6 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -14,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/go-phorce/dolly/xlog"
+	"github.com/go-phorce/dolly/xpki/certutil"
 	"github.com/go-phorce/dolly/xpki/cryptoprov"
 	"github.com/pkg/errors"
 )
@@ -135,7 +135,13 @@ func (c *Provider) GenerateKeyAndRequest(req *CertificateRequest) (csrPEM []byte
 		}
 	}
 
-	csrPEM, err = x509.CreateCertificateRequest(rand.Reader, &template, priv)
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		err = errors.Errorf("unsupported key: %T", priv)
+		return
+	}
+
+	csrPEM, err = certutil.CreateCertificateRequest(&template, signer)
 	if err != nil {
 		err = errors.WithMessage(err, "create CSR")
 		return
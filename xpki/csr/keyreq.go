@@ -78,8 +78,9 @@ func (kr *keyRequest) SigAlgo() x509.SignatureAlgorithm {
 	return SigAlgo(kr.Algo(), kr.Size())
 }
 
-// Generate generates a key as specified in the request. Currently,
-// only ECDSA and RSA are supported.
+// Generate generates a key as specified in the request.
+// ECDSA and RSA are supported by all providers,
+// other algorithms require the provider to implement cryptoprov.AlgorithmKeyGenerator.
 func (kr *keyRequest) Generate() (crypto.PrivateKey, error) {
 	switch algo := kr.Algo(); strings.ToUpper(algo) {
 	case "RSA":
@@ -115,6 +116,13 @@ func (kr *keyRequest) Generate() (crypto.PrivateKey, error) {
 		}
 		return pk, nil
 	default:
+		if gen, ok := kr.prov.(cryptoprov.AlgorithmKeyGenerator); ok {
+			pk, err := gen.GenerateKey(kr.Label(), algo, kr.Size(), kr.Purpose())
+			if err != nil {
+				return nil, errors.WithMessagef(err, "generate %s key", algo)
+			}
+			return pk, nil
+		}
 		return nil, errors.Errorf("invalid algorithm: %s", algo)
 	}
 }
@@ -162,7 +170,9 @@ func validateECDSAKeyPairCurveInfoHandler(size int) error {
 	return errors.Errorf("invalid curve size: %d", size)
 }
 
-// SigAlgo returns signature algorithm for the given algorithm name and key size
+// SigAlgo returns signature algorithm for the given algorithm name and key size.
+// For algorithms not supported by crypto/x509, x509.UnknownSignatureAlgorithm is returned,
+// and the signature algorithm is selected by the scheme registered in certutil package.
 // TODO: use oid pkg
 func SigAlgo(algo string, size int) x509.SignatureAlgorithm {
 	switch strings.ToUpper(algo) {
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	xoid "github.com/go-phorce/dolly/xpki/oid"
 	"github.com/pkg/errors"
 )
 
@@ -78,6 +79,7 @@ func (oid OID) String() string {
 }
 
 // UnmarshalJSON unmarshals a JSON string into an OID.
+// The value can be in dot notation, or a name registered in xpki/oid package.
 func (oid *OID) UnmarshalJSON(data []byte) (err error) {
 	last := len(data) - 1
 	if data[0] != '"' || data[last] != '"' {
@@ -92,6 +94,7 @@ func (oid *OID) UnmarshalJSON(data []byte) (err error) {
 }
 
 // UnmarshalYAML unmarshals a YAML string into an OID.
+// The value can be in dot notation, or a name registered in xpki/oid package.
 func (oid *OID) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var buf string
 	err := unmarshal(&buf)
@@ -113,6 +116,10 @@ func (oid OID) MarshalJSON() ([]byte, error) {
 }
 
 func parseObjectIdentifier(oidString string) (oid asn1.ObjectIdentifier, err error) {
+	if info := xoid.LookupByName(oidString); info != nil {
+		return info.OID(), nil
+	}
+
 	validOID, err := regexp.MatchString("\\d(\\.\\d+)*", oidString)
 	if err != nil {
 		return
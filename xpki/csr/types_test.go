@@ -1,12 +1,15 @@
 package csr
 
 import (
+	"encoding/asn1"
 	"encoding/json"
 	"testing"
 	"time"
 
+	xoid "github.com/go-phorce/dolly/xpki/oid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 )
 
 func TestDurationString(t *testing.T) {
@@ -86,3 +89,31 @@ func TestOIDJSONDecode(t *testing.T) {
 		assert.Equal(t, tc.err, err.Error())
 	}
 }
+
+func TestOIDRegisteredName(t *testing.T) {
+	custom := xoid.MustRegister(xoid.Registration{
+		Name: "id-kp-csrTestSigning",
+		Type: xoid.AlgExtKeyUsage,
+		OID:  asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 3, 1},
+	})
+	defer xoid.Unregister(custom.OID())
+
+	var v struct {
+		Usages []OID `json:"usages" yaml:"usages"`
+	}
+
+	err := json.Unmarshal([]byte(`{"usages":["id-kp-csrTestSigning","id-kp-serverAuth","1.2.3"]}`), &v)
+	require.NoError(t, err)
+	require.Len(t, v.Usages, 3)
+	assert.Equal(t, "1.3.6.1.4.1.99999.3.1", v.Usages[0].String())
+	assert.Equal(t, "1.3.6.1.5.5.7.3.1", v.Usages[1].String())
+	assert.Equal(t, "1.2.3", v.Usages[2].String())
+
+	err = yaml.Unmarshal([]byte("usages:\n- id-kp-csrTestSigning\n- 1.2.3\n"), &v)
+	require.NoError(t, err)
+	require.Len(t, v.Usages, 2)
+	assert.Equal(t, "1.3.6.1.4.1.99999.3.1", v.Usages[0].String())
+
+	err = json.Unmarshal([]byte(`{"usages":["id-kp-notRegistered"]}`), &v)
+	assert.EqualError(t, err, `invalid OID: "id-kp-notRegistered"`)
+}
@@ -1,3 +1,6 @@
+// Package csrprov provides CFSSL based CSR processing.
+//
+// Deprecated: use xpki/csr and xpki/authority packages instead.
 package csrprov
 
 import (
@@ -0,0 +1,91 @@
+package gpg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// CreateOpenPGPEntityFromSigner creates self-signed PGP entity from crypto.Signer,
+// the private key is not exported from the signer,
+// which allows to use keys stored in HSM or KMS.
+// Note that KeyID of the entity depends on creationTime,
+// so the same time must be used to create the entity for signing.
+func CreateOpenPGPEntityFromSigner(creationTime time.Time, signer crypto.Signer, uid *packet.UserId) (*openpgp.Entity, error) {
+	if signer == nil {
+		return nil, errors.New("invalid parameter: signer")
+	}
+
+	switch signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, errors.Errorf("unsupported key type: %T", signer.Public())
+	}
+
+	creationTime = creationTime.UTC().Truncate(time.Second)
+	privKey := ConvertLocalSignerToPgpPrivateKey(creationTime, signer)
+	pubKey := ConvertPublicKeyToPGP(creationTime, signer.Public())
+
+	entity, err := CreateOpenPGPEntity(pubKey, privKey, uid, OpenPGPEntitySignSelf)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return entity, nil
+}
+
+// OpenpgpClearSign creates clear-signed message
+func OpenpgpClearSign(message io.Reader, w io.Writer, signer *openpgp.Entity, config *packet.Config) error {
+	if signer == nil || signer.PrivateKey == nil {
+		return errors.New("signing key not found")
+	}
+
+	plaintext, err := clearsign.Encode(w, signer.PrivateKey, config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = io.Copy(plaintext, message)
+	if err != nil {
+		plaintext.Close()
+		return errors.WithStack(err)
+	}
+
+	err = plaintext.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// VerifyDetachedSignature verifies armored detached signature of the message,
+// and returns the signer from the keyring
+func VerifyDetachedSignature(keyring openpgp.KeyRing, message, signature io.Reader) (*openpgp.Entity, error) {
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, message, signature)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid signature")
+	}
+	return signer, nil
+}
+
+// VerifyClearSignedMessage verifies clear-signed message,
+// and returns the signer from the keyring and the message
+func VerifyClearSignedMessage(keyring openpgp.KeyRing, data []byte) (*openpgp.Entity, []byte, error) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("clear-signed message not found")
+	}
+
+	signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "invalid signature")
+	}
+	return signer, block.Plaintext, nil
+}
@@ -0,0 +1,101 @@
+package gpg_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/xpki/gpg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// opaqueSigner hides the private key type, as HSM keys do
+type opaqueSigner struct {
+	s crypto.Signer
+}
+
+func (o *opaqueSigner) Public() crypto.PublicKey {
+	return o.s.Public()
+}
+
+func (o *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return o.s.Sign(rand, digest, opts)
+}
+
+func Test_SignAndVerifyWithSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	created := time.Now()
+	message := []byte("Test data to sign\nwith two lines\n")
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		signer := &opaqueSigner{s: key}
+		entity, err := gpg.CreateOpenPGPEntityFromSigner(created, signer, packet.NewUserId("dolly", "test", "dolly@go-phorce.com"))
+		require.NoError(t, err)
+
+		// the same creation time produces the same key ID
+		entity2, err := gpg.CreateOpenPGPEntityFromSigner(created, signer, nil)
+		require.NoError(t, err)
+		assert.Equal(t, entity.PrimaryKey.KeyId, entity2.PrimaryKey.KeyId)
+
+		pub, err := gpg.EncodePGPEntityToPEM(entity)
+		require.NoError(t, err)
+		assert.Contains(t, string(pub), "dolly (test) <dolly@go-phorce.com>")
+
+		keyring, err := gpg.KeyRing(pub)
+		require.NoError(t, err)
+		require.Len(t, keyring, 1)
+
+		// detached
+		var sig bytes.Buffer
+		err = gpg.OpenpgpDetachSign(bytes.NewReader(message), &sig, entity2, gpg.OpenpgpSigTypeBinary, nil)
+		require.NoError(t, err)
+
+		signedBy, err := gpg.VerifyDetachedSignature(keyring, bytes.NewReader(message), bytes.NewReader(sig.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, entity.PrimaryKey.KeyId, signedBy.PrimaryKey.KeyId)
+
+		_, err = gpg.VerifyDetachedSignature(keyring, bytes.NewReader([]byte("modified")), bytes.NewReader(sig.Bytes()))
+		assert.Error(t, err)
+
+		// clear-signed
+		var clear bytes.Buffer
+		err = gpg.OpenpgpClearSign(bytes.NewReader(message), &clear, entity2, nil)
+		require.NoError(t, err)
+		assert.Contains(t, clear.String(), "-----BEGIN PGP SIGNED MESSAGE-----")
+
+		signedBy, plaintext, err := gpg.VerifyClearSignedMessage(keyring, clear.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, entity.PrimaryKey.KeyId, signedBy.PrimaryKey.KeyId)
+		assert.Equal(t, message, plaintext)
+
+		_, _, err = gpg.VerifyClearSignedMessage(keyring, message)
+		assert.EqualError(t, err, "clear-signed message not found")
+
+		// different creation time produces different key ID
+		other, err := gpg.CreateOpenPGPEntityFromSigner(created.Add(-time.Hour), signer, nil)
+		require.NoError(t, err)
+		sig.Reset()
+		err = gpg.OpenpgpDetachSign(bytes.NewReader(message), &sig, other, gpg.OpenpgpSigTypeBinary, nil)
+		require.NoError(t, err)
+		_, err = gpg.VerifyDetachedSignature(keyring, bytes.NewReader(message), bytes.NewReader(sig.Bytes()))
+		assert.EqualError(t, err, "invalid signature: openpgp: signature made by unknown entity")
+	}
+
+	_, err = gpg.CreateOpenPGPEntityFromSigner(created, nil, nil)
+	assert.EqualError(t, err, "invalid parameter: signer")
+
+	err = gpg.OpenpgpClearSign(bytes.NewReader(message), &bytes.Buffer{}, nil, nil)
+	assert.EqualError(t, err, "signing key not found")
+}
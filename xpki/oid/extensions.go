@@ -0,0 +1,33 @@
+package oid
+
+import "encoding/asn1"
+
+// X509 extensions, RFC 5280
+var (
+	ExtensionSubjectKeyIdentifier   = MustRegister(Registration{Name: "id-ce-subjectKeyIdentifier", Type: AlgExtension, OID: SubjectKeyIdentifier})
+	ExtensionKeyUsage               = MustRegister(Registration{Name: "id-ce-keyUsage", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 15}})
+	ExtensionSubjectAltName         = MustRegister(Registration{Name: "id-ce-subjectAltName", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 17}})
+	ExtensionBasicConstraints       = MustRegister(Registration{Name: "id-ce-basicConstraints", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 19}})
+	ExtensionNameConstraints        = MustRegister(Registration{Name: "id-ce-nameConstraints", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 30}})
+	ExtensionCRLDistributionPoints  = MustRegister(Registration{Name: "id-ce-cRLDistributionPoints", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 31}})
+	ExtensionCertificatePolicies    = MustRegister(Registration{Name: "id-ce-certificatePolicies", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 32}})
+	ExtensionAuthorityKeyIdentifier = MustRegister(Registration{Name: "id-ce-authorityKeyIdentifier", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 35}})
+	ExtensionExtKeyUsage            = MustRegister(Registration{Name: "id-ce-extKeyUsage", Type: AlgExtension, OID: asn1.ObjectIdentifier{2, 5, 29, 37}, Decoder: OIDListDecoder})
+	ExtensionAuthorityInfoAccess    = MustRegister(Registration{Name: "id-pe-authorityInfoAccess", Type: AlgExtension, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}})
+)
+
+// Extended key usages, RFC 5280
+var (
+	ExtKeyUsageAny             = MustRegister(Registration{Name: "anyExtendedKeyUsage", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{2, 5, 29, 37, 0}})
+	ExtKeyUsageServerAuth      = MustRegister(Registration{Name: "id-kp-serverAuth", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}})
+	ExtKeyUsageClientAuth      = MustRegister(Registration{Name: "id-kp-clientAuth", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}})
+	ExtKeyUsageCodeSigning     = MustRegister(Registration{Name: "id-kp-codeSigning", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 3}})
+	ExtKeyUsageEmailProtection = MustRegister(Registration{Name: "id-kp-emailProtection", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}})
+	ExtKeyUsageTimeStamping    = MustRegister(Registration{Name: "id-kp-timeStamping", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	ExtKeyUsageOCSPSigning     = MustRegister(Registration{Name: "id-kp-OCSPSigning", Type: AlgExtKeyUsage, OID: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}})
+)
+
+// Certificate policies, RFC 5280
+var (
+	PolicyAny = MustRegister(Registration{Name: "anyPolicy", Type: AlgPolicy, OID: asn1.ObjectIdentifier{2, 5, 29, 32, 0}})
+)
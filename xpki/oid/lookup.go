@@ -22,6 +22,9 @@ var OIDStrToInfo = map[string]Info{
 	"1.2.840.10045.4.3.2":     ECDSAWithSHA256,
 	"1.2.840.10045.4.3.3":     ECDSAWithSHA384,
 	"1.2.840.10045.4.3.4":     ECDSAWithSHA512,
+	"2.16.840.1.101.3.4.3.17": MLDSA44,
+	"2.16.840.1.101.3.4.3.18": MLDSA65,
+	"2.16.840.1.101.3.4.3.19": MLDSA87,
 }
 
 // AlgNameToInfo provides mapping from algorith name to Info
@@ -68,20 +71,29 @@ var AlgNameToInfo = map[string]Info{
 	"ECDSAWithSHA512": ECDSAWithSHA512,
 	"ECDSA-SHA512":    ECDSAWithSHA512,
 	"ECDSA_SHA512":    ECDSAWithSHA512,
+	"ML-DSA-44":       MLDSA44,
+	"ML-DSA-65":       MLDSA65,
+	"ML-DSA-87":       MLDSA87,
 }
 
-// LookupByOID returns an algorithm by OID
+// LookupByOID returns an algorithm, or registered OID, by OID
 func LookupByOID(oid string) Info {
 	if len(oid) == 0 {
 		return nil
 	}
-	return OIDStrToInfo[oid]
+	if info, ok := OIDStrToInfo[oid]; ok {
+		return info
+	}
+	return registered.byOID(oid)
 }
 
-// LookupByName returns an algorithm by name
+// LookupByName returns an algorithm, or registered OID, by name
 func LookupByName(name string) Info {
 	if len(name) == 0 {
 		return nil
 	}
-	return AlgNameToInfo[name]
+	if info, ok := AlgNameToInfo[name]; ok {
+		return info
+	}
+	return registered.byName(name)
 }
@@ -35,6 +35,9 @@ var oidTests = []oidInfo{
 	{oid: "1.2.840.10045.4.3.2", name: "ECDSA-SHA256", typ: AlgSig, info: RSAWithSHA256},
 	{oid: "1.2.840.10045.4.3.3", name: "ECDSA-SHA384", typ: AlgSig, info: RSAWithSHA384},
 	{oid: "1.2.840.10045.4.3.4", name: "ECDSA-SHA512", typ: AlgSig, info: RSAWithSHA512},
+	{oid: "2.16.840.1.101.3.4.3.17", name: "ML-DSA-44", typ: AlgSig, info: MLDSA44},
+	{oid: "2.16.840.1.101.3.4.3.18", name: "ML-DSA-65", typ: AlgSig, info: MLDSA65},
+	{oid: "2.16.840.1.101.3.4.3.19", name: "ML-DSA-87", typ: AlgSig, info: MLDSA87},
 }
 
 func Test_LookupByOID(t *testing.T) {
@@ -0,0 +1,58 @@
+package oid
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
+
+//
+// Post-quantum Signature Algorithms
+//
+// The algorithms are not supported by crypto/x509 in older Go versions,
+// the signing and verification is provided by the schemes registered in certutil package.
+//
+
+// MLDSA44 specifies ML-DSA-44 signature algorithm, FIPS 204
+var MLDSA44 = newPureSignatureAlgorithmInfo("ML-DSA-44", SignatureAlgorithmMLDSA44,
+	"{joint-iso-itu-t(2) country(16) us(840) organization(1) gov(101) csor(3) nistAlgorithm(4) sigAlgs(3) id-ml-dsa-44(17)}")
+
+// MLDSA65 specifies ML-DSA-65 signature algorithm, FIPS 204
+var MLDSA65 = newPureSignatureAlgorithmInfo("ML-DSA-65", SignatureAlgorithmMLDSA65,
+	"{joint-iso-itu-t(2) country(16) us(840) organization(1) gov(101) csor(3) nistAlgorithm(4) sigAlgs(3) id-ml-dsa-65(18)}")
+
+// MLDSA87 specifies ML-DSA-87 signature algorithm, FIPS 204
+var MLDSA87 = newPureSignatureAlgorithmInfo("ML-DSA-87", SignatureAlgorithmMLDSA87,
+	"{joint-iso-itu-t(2) country(16) us(840) organization(1) gov(101) csor(3) nistAlgorithm(4) sigAlgs(3) id-ml-dsa-87(19)}")
+
+func newPureSignatureAlgorithmInfo(name string, oid asn1.ObjectIdentifier, registration string) SignatureAlgorithmInfo {
+	return *NewSignatureAlgorithmInfo(name, oid, registration,
+		NewPublicKeyAlgorithmInfo(name, oid, registration),
+		nil)
+}
+
+// NewPublicKeyAlgorithmInfo returns PublicKeyAlgorithmInfo for the algorithm,
+// which is not supported by crypto/x509, such as post-quantum or composite keys
+func NewPublicKeyAlgorithmInfo(name string, oid asn1.ObjectIdentifier, registration string) *PublicKeyAlgorithmInfo {
+	return &PublicKeyAlgorithmInfo{
+		name:         name,
+		oid:          oid,
+		oidstr:       oid.String(),
+		registration: registration,
+		publey:       x509.UnknownPublicKeyAlgorithm,
+	}
+}
+
+// NewSignatureAlgorithmInfo returns SignatureAlgorithmInfo for the algorithm,
+// which is not supported by crypto/x509, such as post-quantum or composite signatures.
+// The hash can be nil, if the algorithm signs the message without pre-hashing.
+func NewSignatureAlgorithmInfo(name string, oid asn1.ObjectIdentifier, registration string, pub *PublicKeyAlgorithmInfo, hash *HashAlgorithmInfo) *SignatureAlgorithmInfo {
+	return &SignatureAlgorithmInfo{
+		name:               name,
+		oid:                oid,
+		oidstr:             oid.String(),
+		registration:       registration,
+		X509:               x509.UnknownSignatureAlgorithm,
+		PublicKeyAlgorithm: pub,
+		HashAlgorithm:      hash,
+	}
+}
@@ -25,7 +25,12 @@ type SignatureAlgorithmInfo struct {
 // crypto.SignerOpts interface for signing digests.
 // You can use a cryptoid.HashAlgorithm directly when
 // using a crypto.Signer interface to sign digests.
+// For algorithms that sign the message without pre-hashing,
+// such as ML-DSA, zero value is returned.
 func (h SignatureAlgorithmInfo) HashFunc() crypto.Hash {
+	if h.HashAlgorithm == nil {
+		return crypto.Hash(0)
+	}
 	return h.HashAlgorithm.HashFunc()
 }
 
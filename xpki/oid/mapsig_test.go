@@ -113,3 +113,22 @@ func Test_SignatureAlgorithmByX509(t *testing.T) {
 		SignatureAlgorithmByX509(x509.DSAWithSHA1)
 	})
 }
+
+func Test_SignatureAlgorithmPQ(t *testing.T) {
+	for _, info := range []SignatureAlgorithmInfo{MLDSA44, MLDSA65, MLDSA87} {
+		algo, err := SignatureAlgorithmByOID(info.String())
+		require.NoError(t, err)
+		assert.Equal(t, info.Name(), algo.Name())
+		assert.Equal(t, x509.UnknownSignatureAlgorithm, algo.X509)
+		assert.Equal(t, crypto.Hash(0), algo.HashFunc())
+		require.NotNil(t, algo.PublicKeyAlgorithm)
+		assert.Equal(t, info.String(), algo.PublicKeyAlgorithm.String())
+		assert.Equal(t, x509.UnknownPublicKeyAlgorithm, algo.PublicKeyAlgorithm.Algorithm())
+	}
+
+	custom := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2, 1}
+	info := NewSignatureAlgorithmInfo("Composite-Test", custom, "", NewPublicKeyAlgorithmInfo("Composite-Test", custom, ""), &SHA256)
+	assert.Equal(t, "Composite-Test", info.Name())
+	assert.Equal(t, "1.3.6.1.4.1.99999.2.1", info.String())
+	assert.Equal(t, crypto.SHA256, info.HashFunc())
+}
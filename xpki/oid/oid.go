@@ -20,6 +20,14 @@ const (
 	AlgPubKey
 	// AlgSig specifies signature
 	AlgSig
+	// AlgExtension specifies X509 extension
+	AlgExtension
+	// AlgExtKeyUsage specifies X509 extended key usage
+	AlgExtKeyUsage
+	// AlgPolicy specifies certificate policy
+	AlgPolicy
+	// AlgCustom specifies other registered OID
+	AlgCustom
 )
 
 // Info provides basic OID info: friendly name, OID and registration string
@@ -56,6 +64,11 @@ var (
 var (
 	SignatureAlgorithmRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
 	SignatureAlgorithmECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+	// ML-DSA uses the same OID for public key and signature algorithms, FIPS 204
+	SignatureAlgorithmMLDSA44 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}
+	SignatureAlgorithmMLDSA65 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}
+	SignatureAlgorithmMLDSA87 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}
 )
 
 // Digest Algorithm OIDs
@@ -0,0 +1,253 @@
+package oid
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ValueDecoder returns printable representation of DER encoded value
+type ValueDecoder func(der []byte) (string, error)
+
+// Registration provides the definition of the OID to register
+type Registration struct {
+	// Name is friendly name of the OID, must be unique
+	Name string
+	// Type of the OID
+	Type AlgType
+	// OID is ASN1 ObjectIdentifier
+	OID asn1.ObjectIdentifier
+	// Registration is an optional official registration info
+	Registration string
+	// Decoder is an optional decoder of the values, such as extension value
+	Decoder ValueDecoder
+}
+
+// RegisteredInfo provides OID info registered at runtime
+type RegisteredInfo struct {
+	name         string
+	typ          AlgType
+	oid          asn1.ObjectIdentifier
+	oidstr       string
+	registration string
+	decoder      ValueDecoder
+}
+
+// Name is friendly name of the OID
+func (h *RegisteredInfo) Name() string {
+	return h.name
+}
+
+// OID is ASN1 ObjectIdentifier
+func (h *RegisteredInfo) OID() asn1.ObjectIdentifier {
+	return h.oid
+}
+
+// Registration returns official registration info in
+// "{iso(1) identified-organization(3) oiw(14) secsig(3) algorithm(2) 26}" format
+func (h *RegisteredInfo) Registration() string {
+	return h.registration
+}
+
+// String returns string representation of OID: "1.2.840.113549.1"
+func (h *RegisteredInfo) String() string {
+	return h.oidstr
+}
+
+// Type returns OID type
+func (h *RegisteredInfo) Type() AlgType {
+	return h.typ
+}
+
+// Decode returns printable representation of DER encoded value.
+// If the decoder is not registered, the value is hex encoded.
+func (h *RegisteredInfo) Decode(der []byte) (string, error) {
+	if h.decoder == nil {
+		return hex.EncodeToString(der), nil
+	}
+	return h.decoder(der)
+}
+
+type registry struct {
+	lock  sync.RWMutex
+	oids  map[string]*RegisteredInfo
+	names map[string]*RegisteredInfo
+}
+
+var registered = &registry{
+	oids:  make(map[string]*RegisteredInfo),
+	names: make(map[string]*RegisteredInfo),
+}
+
+func (r *registry) byOID(oid string) Info {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if info, ok := r.oids[oid]; ok {
+		return info
+	}
+	return nil
+}
+
+func (r *registry) byName(name string) Info {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if info, ok := r.names[name]; ok {
+		return info
+	}
+	return nil
+}
+
+// Register registers custom OID, such as private enterprise extension,
+// certificate policy, or extended key usage.
+// Registering the same name and OID again replaces the decoder.
+func Register(reg Registration) (*RegisteredInfo, error) {
+	if reg.Name == "" {
+		return nil, errors.New("invalid parameter: name")
+	}
+	if len(reg.OID) < 2 {
+		return nil, errors.Errorf("invalid parameter: oid")
+	}
+
+	info := &RegisteredInfo{
+		name:         reg.Name,
+		typ:          reg.Type,
+		oid:          reg.OID,
+		oidstr:       reg.OID.String(),
+		registration: reg.Registration,
+		decoder:      reg.Decoder,
+	}
+
+	if _, ok := AlgNameToInfo[info.name]; ok {
+		return nil, errors.Errorf("name already registered: %s", info.name)
+	}
+	if _, ok := OIDStrToInfo[info.oidstr]; ok {
+		return nil, errors.Errorf("OID already registered: %s", info.oidstr)
+	}
+
+	registered.lock.Lock()
+	defer registered.lock.Unlock()
+
+	if existing, ok := registered.names[info.name]; ok && existing.oidstr != info.oidstr {
+		return nil, errors.Errorf("name already registered: %s", info.name)
+	}
+	if existing, ok := registered.oids[info.oidstr]; ok && existing.name != info.name {
+		return nil, errors.Errorf("OID already registered: %s", info.oidstr)
+	}
+
+	registered.oids[info.oidstr] = info
+	registered.names[info.name] = info
+
+	return info, nil
+}
+
+// MustRegister registers custom OID, and panics in case of error
+func MustRegister(reg Registration) *RegisteredInfo {
+	info, err := Register(reg)
+	if err != nil {
+		panic(err)
+	}
+	return info
+}
+
+// Unregister removes registered OID
+func Unregister(oid asn1.ObjectIdentifier) {
+	oidstr := oid.String()
+
+	registered.lock.Lock()
+	defer registered.lock.Unlock()
+
+	if info, ok := registered.oids[oidstr]; ok {
+		delete(registered.oids, oidstr)
+		delete(registered.names, info.name)
+	}
+}
+
+// ExtensionName returns friendly name of the extension,
+// or OID string if the extension is not registered
+func ExtensionName(oid asn1.ObjectIdentifier) string {
+	oidstr := oid.String()
+	if info := LookupByOID(oidstr); info != nil {
+		return info.Name()
+	}
+	return oidstr
+}
+
+// DecodeExtensionValue returns printable representation of the extension value,
+// using the decoder of the registered OID.
+// For unknown extensions, the value is hex encoded.
+func DecodeExtensionValue(ext pkix.Extension) string {
+	if info, ok := LookupByOID(ext.Id.String()).(*RegisteredInfo); ok {
+		if s, err := info.Decode(ext.Value); err == nil {
+			return s
+		}
+	}
+	return hex.EncodeToString(ext.Value)
+}
+
+// ExtensionString returns printable representation of the extension in
+// "name (oid) [critical]: value" format
+func ExtensionString(ext pkix.Extension) string {
+	var b strings.Builder
+	name := ExtensionName(ext.Id)
+	oidstr := ext.Id.String()
+	b.WriteString(name)
+	if name != oidstr {
+		fmt.Fprintf(&b, " (%s)", oidstr)
+	}
+	if ext.Critical {
+		b.WriteString(" [critical]")
+	}
+	b.WriteString(": ")
+	b.WriteString(DecodeExtensionValue(ext))
+	return b.String()
+}
+
+// StringDecoder decodes ASN.1 string value,
+// such as UTF8String, PrintableString or IA5String
+func StringDecoder(der []byte) (string, error) {
+	var s string
+	rest, err := asn1.Unmarshal(der, &s)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(rest) > 0 {
+		return "", errors.New("trailing data")
+	}
+	return s, nil
+}
+
+// IntegerDecoder decodes ASN.1 INTEGER value
+func IntegerDecoder(der []byte) (string, error) {
+	var i int64
+	rest, err := asn1.Unmarshal(der, &i)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(rest) > 0 {
+		return "", errors.New("trailing data")
+	}
+	return fmt.Sprintf("%d", i), nil
+}
+
+// OIDListDecoder decodes ASN.1 SEQUENCE OF OBJECT IDENTIFIER,
+// such as Extended Key Usage, with friendly names of registered OIDs
+func OIDListDecoder(der []byte) (string, error) {
+	var oids []asn1.ObjectIdentifier
+	rest, err := asn1.Unmarshal(der, &oids)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(rest) > 0 {
+		return "", errors.New("trailing data")
+	}
+	names := make([]string, len(oids))
+	for i, oid := range oids {
+		names[i] = ExtensionName(oid)
+	}
+	return strings.Join(names, ", "), nil
+}
@@ -0,0 +1,115 @@
+package oid
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Register(t *testing.T) {
+	custom := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1, 1}
+	info, err := Register(Registration{
+		Name:         "id-ourTeamExtension",
+		Type:         AlgExtension,
+		OID:          custom,
+		Registration: "{iso(1) identified-organization(3) dod(6) internet(1) private(4) enterprise(1) 99999 1 1}",
+		Decoder:      StringDecoder,
+	})
+	require.NoError(t, err)
+	defer Unregister(custom)
+
+	assert.Equal(t, "id-ourTeamExtension", info.Name())
+	assert.EqualValues(t, AlgExtension, info.Type())
+	assert.Equal(t, "1.3.6.1.4.1.99999.1.1", info.String())
+	assert.True(t, custom.Equal(info.OID()))
+	assert.NotEmpty(t, info.Registration())
+
+	assert.Equal(t, info, LookupByOID("1.3.6.1.4.1.99999.1.1"))
+	assert.Equal(t, info, LookupByName("id-ourTeamExtension"))
+
+	// same registration is allowed
+	_, err = Register(Registration{Name: "id-ourTeamExtension", OID: custom})
+	require.NoError(t, err)
+
+	_, err = Register(Registration{Name: "id-ourTeamExtension", OID: asn1.ObjectIdentifier{1, 2, 3}})
+	assert.EqualError(t, err, "name already registered: id-ourTeamExtension")
+	_, err = Register(Registration{Name: "id-other", OID: custom})
+	assert.EqualError(t, err, "OID already registered: 1.3.6.1.4.1.99999.1.1")
+	_, err = Register(Registration{Name: "SHA256", OID: asn1.ObjectIdentifier{1, 2, 3}})
+	assert.EqualError(t, err, "name already registered: SHA256")
+	_, err = Register(Registration{Name: "sha", OID: DigestAlgorithmSHA256})
+	assert.EqualError(t, err, "OID already registered: 2.16.840.1.101.3.4.2.1")
+	_, err = Register(Registration{OID: custom})
+	assert.EqualError(t, err, "invalid parameter: name")
+	_, err = Register(Registration{Name: "id-no-oid"})
+	assert.EqualError(t, err, "invalid parameter: oid")
+
+	assert.Panics(t, func() {
+		MustRegister(Registration{Name: "id-other", OID: custom})
+	})
+
+	Unregister(custom)
+	assert.Nil(t, LookupByOID("1.3.6.1.4.1.99999.1.1"))
+	assert.Nil(t, LookupByName("id-ourTeamExtension"))
+}
+
+func Test_ExtensionString(t *testing.T) {
+	custom := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1, 2}
+	MustRegister(Registration{
+		Name:    "id-ourTeamLevel",
+		Type:    AlgExtension,
+		OID:     custom,
+		Decoder: IntegerDecoder,
+	})
+	defer Unregister(custom)
+
+	level, err := asn1.Marshal(42)
+	require.NoError(t, err)
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{ExtKeyUsageServerAuth.OID(), {1, 2, 3}})
+	require.NoError(t, err)
+	str, err := asn1.MarshalWithParams("team", "utf8")
+	require.NoError(t, err)
+
+	tcases := []struct {
+		ext pkix.Extension
+		exp string
+	}{
+		{
+			ext: pkix.Extension{Id: custom, Critical: true, Value: level},
+			exp: "id-ourTeamLevel (1.3.6.1.4.1.99999.1.2) [critical]: 42",
+		},
+		{
+			ext: pkix.Extension{Id: custom, Value: []byte{1, 2}},
+			exp: "id-ourTeamLevel (1.3.6.1.4.1.99999.1.2): 0102",
+		},
+		{
+			ext: pkix.Extension{Id: ExtensionExtKeyUsage.OID(), Value: eku},
+			exp: "id-ce-extKeyUsage (2.5.29.37): id-kp-serverAuth, 1.2.3",
+		},
+		{
+			ext: pkix.Extension{Id: SubjectKeyIdentifier, Value: []byte{0xab, 0xcd}},
+			exp: "id-ce-subjectKeyIdentifier (2.5.29.14): abcd",
+		},
+		{
+			ext: pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: []byte{0xff}},
+			exp: "1.2.3.4: ff",
+		},
+	}
+	for _, tc := range tcases {
+		assert.Equal(t, tc.exp, ExtensionString(tc.ext))
+	}
+
+	s, err := StringDecoder(str)
+	require.NoError(t, err)
+	assert.Equal(t, "team", s)
+
+	_, err = StringDecoder(append(str, 0))
+	assert.EqualError(t, err, "trailing data")
+	_, err = IntegerDecoder([]byte{0xff})
+	assert.Error(t, err)
+	_, err = OIDListDecoder([]byte{0xff})
+	assert.Error(t, err)
+}